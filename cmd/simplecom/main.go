@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"os/user"
 
 	internalcli "github.com/adyen/ecommerce/internal/cli"
 	"github.com/adyen/ecommerce/internal/config"
 	"github.com/adyen/ecommerce/internal/database"
+	"github.com/adyen/ecommerce/internal/fakeadyen"
 	"github.com/adyen/ecommerce/internal/handlers"
+	"github.com/adyen/ecommerce/internal/models"
 	"github.com/adyen/ecommerce/internal/repository"
 	"github.com/adyen/ecommerce/internal/services"
 	"github.com/joho/godotenv"
@@ -18,15 +23,47 @@ import (
 
 var version = "0.1.0"
 
+// Storage backends for the serve command
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
+// buildRepositories creates the repositories for the given storage backend
+func buildRepositories(deps *internalcli.ServerDependencies, storage string) error {
+	switch storage {
+	case storagePostgres:
+		deps.OrderRepo = repository.NewOrderRepository()
+		deps.StatusRetryRepo = repository.NewStatusRetryRepository()
+		deps.ProductRepo = repository.NewProductRepository()
+		deps.CartRepo = repository.NewCartRepository()
+	case storageMemory:
+		productRepo := repository.NewMemoryProductRepository(repository.DemoProducts()...)
+		deps.OrderRepo = repository.NewMemoryOrderRepository()
+		deps.StatusRetryRepo = repository.NewMemoryStatusRetryRepository()
+		deps.ProductRepo = productRepo
+		deps.CartRepo = repository.NewMemoryCartRepository(productRepo)
+	default:
+		return fmt.Errorf("unknown storage %q, must be %q or %q", storage, storagePostgres, storageMemory)
+	}
+	return nil
+}
+
 // buildServerDependencies creates all dependencies needed for the server
-func buildServerDependencies() (internalcli.ServerDependencies, error) {
+func buildServerDependencies(storage string) (internalcli.ServerDependencies, error) {
 	var deps internalcli.ServerDependencies
 
-	// Create order repository
-	deps.OrderRepo = repository.NewOrderRepository()
+	// Create repositories
+	if err := buildRepositories(&deps, storage); err != nil {
+		return deps, err
+	}
 
 	// Load server configuration
-	deps.ServerConfig = config.LoadServerConfig()
+	serverConfig, err := config.LoadServerConfig()
+	if err != nil {
+		return deps, fmt.Errorf("invalid server configuration: %w", err)
+	}
+	deps.ServerConfig = serverConfig
 
 	// Load Adyen configuration
 	adyenConfig, err := config.LoadAdyenConfig()
@@ -35,43 +72,93 @@ func buildServerDependencies() (internalcli.ServerDependencies, error) {
 	}
 	deps.AdyenConfig = adyenConfig
 
+	// Configure how order references are generated
+	orderConfig, err := config.LoadOrderConfig()
+	if err != nil {
+		return deps, fmt.Errorf("invalid order configuration: %w", err)
+	}
+	if storage == storageMemory && orderConfig.ReferenceGenerator == config.ReferenceGeneratorSequence {
+		return deps, fmt.Errorf("ORDER_REFERENCE_GENERATOR=%s requires %s storage", config.ReferenceGeneratorSequence, storagePostgres)
+	}
+	models.SetReferenceGenerator(newReferenceGenerator(orderConfig))
+
 	// Create service layer
 	adyenClient := services.NewAdyenClient(adyenConfig)
-	orderService := services.NewOrderService(deps.OrderRepo)
-	paymentService := services.NewPaymentService(adyenClient, orderService, adyenConfig)
+	deps.OrderEvents = services.NewOrderEventBus()
+	orderService := services.NewOrderService(deps.OrderRepo, deps.OrderEvents)
+	deps.StatusRetries = services.NewStatusRetryService(deps.StatusRetryRepo, orderService)
+	deps.StatusRetryInterval = orderConfig.StatusRetryInterval
+	deps.OrderExpiry = services.NewOrderExpiryService(deps.OrderRepo, orderService)
+	deps.OrderExpiryInterval = orderConfig.ExpiryInterval
+	paymentService := services.NewPaymentService(adyenClient, orderService, deps.StatusRetries, adyenConfig)
+	productService := services.NewProductService(deps.ProductRepo)
+	cartService := services.NewCartService(deps.CartRepo, deps.ProductRepo)
 
-	// Create product
-	deps.Product = handlers.Product{
-		Name:        "Premium Widget",
-		Description: "A high-quality widget perfect for all your widget needs. Durable, reliable, and designed to last.",
-		Price:       "$1.00",
-		ImageURL:    "/static/images/widget-placeholder.svg",
+	// Create catalog handler listing all products
+	catalogHandler, err := handlers.NewCatalogHandler("templates/catalog.html", productService)
+	if err != nil {
+		return deps, fmt.Errorf("failed to create catalog handler: %w", err)
 	}
+	deps.CatalogHandler = catalogHandler
 
-	// Create product handler with injected product
-	productHandler, err := handlers.NewProductHandler("templates/product.html", deps.Product)
+	// Create product handler for SKU-addressed product pages
+	productHandler, err := handlers.NewProductHandler("templates/product.html", productService)
 	if err != nil {
 		return deps, fmt.Errorf("failed to create product handler: %w", err)
 	}
 	deps.ProductHandler = productHandler
 
-	// Create checkout handler
-	checkoutHandler, err := handlers.NewCheckoutHandler("templates/checkout.html", deps.Product, deps.AdyenConfig)
+	// Create cart page and cart item handlers
+	cartHandler, err := handlers.NewCartHandler("templates/cart.html", cartService)
+	if err != nil {
+		return deps, fmt.Errorf("failed to create cart handler: %w", err)
+	}
+	deps.CartHandler = cartHandler
+	deps.CartAddHandler = handlers.NewCartAddHandler(cartService)
+	deps.CartUpdateHandler = handlers.NewCartUpdateHandler(cartService)
+	deps.CartRemoveHandler = handlers.NewCartRemoveHandler(cartService)
+
+	// Payment sessions use the shopper's country and locale, derived from each request
+	locales := handlers.NewLocaleResolver(deps.ServerConfig.DefaultShopper)
+
+	// Create checkout handler for the shopper's cart
+	checkoutHandler, err := handlers.NewCheckoutHandler("templates/checkout.html", cartService, deps.AdyenConfig, locales)
 	if err != nil {
 		return deps, fmt.Errorf("failed to create checkout handler: %w", err)
 	}
 	deps.CheckoutHandler = checkoutHandler
 
-	// Create session API handler with payment service
-	deps.SessionHandler = handlers.NewSessionHandler(paymentService, deps.Product)
+	// Orders are only shown to the shopper who placed them
+	cookieSecret, err := cookieSigningKey(deps.ServerConfig)
+	if err != nil {
+		return deps, err
+	}
+	orderAccess := handlers.NewOrderAccess(cookieSecret)
+
+	// Create session API handler with payment service, returning shoppers to this server
+	publicURL := handlers.NewPublicURL(deps.ServerConfig.PublicBaseURL, deps.ServerConfig.TrustedProxies)
+	deps.SessionHandler = handlers.NewSessionHandler(paymentService, cartService, orderAccess, publicURL, locales)
 
 	// Create confirmation handler with payment service
-	confirmationHandler, err := handlers.NewConfirmationHandler("templates/confirmation.html", paymentService)
+	confirmationHandler, err := handlers.NewConfirmationHandler("templates/confirmation.html", paymentService, cartService)
 	if err != nil {
 		return deps, fmt.Errorf("failed to create confirmation handler: %w", err)
 	}
 	deps.ConfirmationHandler = confirmationHandler
 
+	// Create processing handler for payments whose outcome arrives later
+	processingHandler, err := handlers.NewProcessingHandler("templates/processing.html", deps.OrderRepo, orderAccess)
+	if err != nil {
+		return deps, fmt.Errorf("failed to create processing handler: %w", err)
+	}
+	deps.ProcessingHandler = processingHandler
+
+	// Create order status API handler polled while payments are processing
+	deps.OrderStatusHandler = handlers.NewOrderStatusHandler(deps.OrderRepo, orderAccess)
+
+	// Create order events handler streaming status transitions as they happen
+	deps.OrderEventsHandler = handlers.NewOrderEventsHandler(deps.OrderRepo, deps.OrderEvents, orderAccess)
+
 	// Create failure handler
 	failureHandler, err := handlers.NewFailureHandler("templates/failure.html", deps.OrderRepo)
 	if err != nil {
@@ -79,29 +166,120 @@ func buildServerDependencies() (internalcli.ServerDependencies, error) {
 	}
 	deps.FailureHandler = failureHandler
 
+	// Create webhook handler with webhook service
+	if adyenConfig.HMACKey == "" {
+		log.Printf("Warning: ADYEN_HMAC_KEY is not set, webhook notifications will be rejected")
+	}
+	webhookService := services.NewWebhookService(orderService, adyenConfig)
+	deps.WebhookHandler = handlers.NewWebhookHandler(webhookService)
+
 	return deps, nil
 }
 
+// cookieSigningKey returns the key for signing shopper cookies, generating a random one
+// if COOKIE_SECRET is not set
+func cookieSigningKey(cfg config.ServerConfig) ([]byte, error) {
+	if cfg.CookieSecret != "" {
+		return []byte(cfg.CookieSecret), nil
+	}
+
+	log.Printf("Warning: COOKIE_SECRET is not set, shoppers lose access to their orders when the server restarts")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate cookie secret: %w", err)
+	}
+	return key, nil
+}
+
+// newReferenceGenerator creates the order reference generator selected by the configuration
+func newReferenceGenerator(cfg *config.OrderConfig) models.ReferenceGenerator {
+	if cfg.ReferenceGenerator == config.ReferenceGeneratorSequence {
+		return repository.NewSequenceReferenceGenerator(cfg.ReferencePrefix)
+	}
+	return models.NewRandomReferenceGenerator(cfg.ReferencePrefix)
+}
+
+// buildPaymentService creates the payment service used by the order management commands
+func buildPaymentService() (services.PaymentService, error) {
+	adyenConfig, err := config.LoadAdyenConfig()
+	if err != nil {
+		return nil, fmt.Errorf("missing required Adyen configuration: %w", err)
+	}
+
+	adyenClient := services.NewAdyenClient(adyenConfig)
+	// Nobody subscribes to events in a one-off command, the server picks up changes from the webhooks
+	orderService := services.NewOrderService(repository.NewOrderRepository(), services.NewOrderEventBus())
+	statusRetries := services.NewStatusRetryService(repository.NewStatusRetryRepository(), orderService)
+	return services.NewPaymentService(adyenClient, orderService, statusRetries, adyenConfig), nil
+}
+
+// withOrderService connects to the database and runs fn with an order service
+func withOrderService(fn func(services.OrderService) error) error {
+	if err := database.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close()
+
+	return fn(services.NewOrderService(repository.NewOrderRepository(), services.NewOrderEventBus()))
+}
+
+// adminContext records the operating system user running the command as the actor
+// of the order changes it makes
+func adminContext(ctx context.Context) context.Context {
+	actor := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		actor = current.Username
+	}
+	return models.WithActor(ctx, actor)
+}
+
+// withPaymentService connects to the database and runs fn with a payment service
+func withPaymentService(fn func(services.PaymentService) error) error {
+	if err := database.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close()
+
+	paymentService, err := buildPaymentService()
+	if err != nil {
+		return err
+	}
+
+	return fn(paymentService)
+}
+
 // ServeCommand returns the serve command
 func ServeCommand(db *sql.DB) *cli.Command {
 	return &cli.Command{
 		Name:  "serve",
 		Usage: "Start the e-commerce web server",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "storage",
+				Value: storagePostgres,
+				Usage: "where to store products, carts and orders: postgres, or memory for demos without a database",
+			},
+		},
 		Action: func(c *cli.Context) error {
-			// Connect to database
-			if err := database.Connect(); err != nil {
-				return fmt.Errorf("failed to connect to database: %w", err)
-			}
-			defer database.Close()
-			log.Println("Connected to database successfully")
+			storage := c.String("storage")
+			if storage == storagePostgres {
+				// Connect to database
+				if err := database.Connect(); err != nil {
+					return fmt.Errorf("failed to connect to database: %w", err)
+				}
+				defer database.Close()
+				log.Println("Connected to database successfully")
 
-			// Run database migrations
-			if err := database.RunMigrations(); err != nil {
-				return fmt.Errorf("failed to run database migrations: %w", err)
+				// Run database migrations
+				if err := database.RunMigrations(); err != nil {
+					return fmt.Errorf("failed to run database migrations: %w", err)
+				}
+			} else {
+				log.Printf("Using %s storage, data will be lost when the server stops", storage)
 			}
 
 			// Build all server dependencies
-			deps, err := buildServerDependencies()
+			deps, err := buildServerDependencies(storage)
 			if err != nil {
 				return err
 			}
@@ -111,6 +289,192 @@ func ServeCommand(db *sql.DB) *cli.Command {
 	}
 }
 
+// withMigrator connects to the database and runs fn with a schema migrator
+func withMigrator(fn func(*database.Migrator) error) error {
+	if err := database.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.Close()
+
+	return fn(database.NewMigrator(database.DB))
+}
+
+// MigrateCommand returns the database migration command
+func MigrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "Manage the database schema",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "Apply all pending migrations",
+				Action: func(c *cli.Context) error {
+					return withMigrator(func(migrator *database.Migrator) error {
+						return internalcli.RunMigrateUp(migrator, os.Stdout)
+					})
+				},
+			},
+			{
+				Name:  "down",
+				Usage: "Revert the most recently applied migration",
+				Action: func(c *cli.Context) error {
+					return withMigrator(func(migrator *database.Migrator) error {
+						return internalcli.RunMigrateDown(migrator, os.Stdout)
+					})
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "List migrations and whether they have been applied",
+				Action: func(c *cli.Context) error {
+					return withMigrator(func(migrator *database.Migrator) error {
+						return internalcli.RunMigrateStatus(migrator, os.Stdout)
+					})
+				},
+			},
+			{
+				Name:      "to",
+				Usage:     "Migrate up or down to a specific version",
+				ArgsUsage: "<version>",
+				Action: func(c *cli.Context) error {
+					return withMigrator(func(migrator *database.Migrator) error {
+						return internalcli.RunMigrateTo(migrator, c.Args().First(), os.Stdout)
+					})
+				},
+			},
+		},
+	}
+}
+
+// OrdersCommand returns the order management command
+func OrdersCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "orders",
+		Usage: "Manage existing orders",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "refund",
+				Usage:     "Refund an authorized order through Adyen",
+				ArgsUsage: "<reference>",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:  "amount",
+						Usage: "amount to refund in minor units (defaults to the amount not refunded yet)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return withPaymentService(func(paymentService services.PaymentService) error {
+						return internalcli.RunRefund(adminContext(c.Context), paymentService, c.Args().First(), c.Int64("amount"), os.Stdout)
+					})
+				},
+			},
+			{
+				Name:      "capture",
+				Usage:     "Capture an order authorized with manual capture",
+				ArgsUsage: "<reference>",
+				Action: func(c *cli.Context) error {
+					return withPaymentService(func(paymentService services.PaymentService) error {
+						return internalcli.RunCapture(adminContext(c.Context), paymentService, c.Args().First(), os.Stdout)
+					})
+				},
+			},
+			{
+				Name:      "cancel",
+				Usage:     "Cancel an order and release its uncaptured payment, confirmed by Adyen's CANCELLATION webhook",
+				ArgsUsage: "<reference>",
+				Action: func(c *cli.Context) error {
+					return withPaymentService(func(paymentService services.PaymentService) error {
+						return internalcli.RunCancel(adminContext(c.Context), paymentService, c.Args().First(), os.Stdout)
+					})
+				},
+			},
+			{
+				Name:      "history",
+				Usage:     "Show every status change of an order, e.g. for disputes",
+				ArgsUsage: "<reference>",
+				Action: func(c *cli.Context) error {
+					return withOrderService(func(orderService services.OrderService) error {
+						return internalcli.RunHistory(c.Context, orderService, c.Args().First(), os.Stdout)
+					})
+				},
+			},
+			{
+				Name:  "stuck",
+				Usage: "List orders whose status update failed to be stored and is waiting to be retried",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "retry",
+						Usage: "retry the updates that are due before listing",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return withOrderService(func(orderService services.OrderService) error {
+						statusRetries := services.NewStatusRetryService(repository.NewStatusRetryRepository(), orderService)
+						return internalcli.RunStuck(c.Context, statusRetries, c.Bool("retry"), os.Stdout)
+					})
+				},
+			},
+			{
+				Name:  "expire",
+				Usage: "Expire pending orders whose payment session ended without a payment",
+				Action: func(c *cli.Context) error {
+					return withOrderService(func(orderService services.OrderService) error {
+						expiry := services.NewOrderExpiryService(repository.NewOrderRepository(), orderService)
+						return internalcli.RunExpire(adminContext(c.Context), expiry, os.Stdout)
+					})
+				},
+			},
+		},
+	}
+}
+
+// FakeAdyenCommand returns the command that runs a local fake Adyen Checkout API
+func FakeAdyenCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "fake-adyen",
+		Usage: "Run a local fake of the Adyen Checkout API for offline development and tests",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "port",
+				Value: "8081",
+				Usage: "port to listen on",
+			},
+			&cli.StringFlag{
+				Name:  "outcome",
+				Value: fakeadyen.OutcomeAuthorised,
+				Usage: "default payment outcome: Authorised, Refused, Pending or Error",
+			},
+			&cli.StringFlag{
+				Name:  "webhook-url",
+				Value: "http://localhost:8080/api/webhooks/adyen",
+				Usage: "where to send webhook notifications (empty to disable)",
+			},
+			&cli.StringFlag{
+				Name:    "hmac-key",
+				EnvVars: []string{"ADYEN_HMAC_KEY"},
+				Usage:   "hex HMAC key used to sign webhook notifications",
+			},
+			&cli.StringFlag{
+				Name:  "api-key",
+				Usage: "API key clients must send (any key is accepted if empty)",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			fake, err := fakeadyen.NewServer(fakeadyen.Config{
+				APIKey:         c.String("api-key"),
+				DefaultOutcome: c.String("outcome"),
+				WebhookURL:     c.String("webhook-url"),
+				HMACKey:        c.String("hmac-key"),
+			})
+			if err != nil {
+				return err
+			}
+
+			return internalcli.RunFakeAdyen(fake, c.String("port"))
+		},
+	}
+}
+
 func main() {
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
@@ -123,6 +487,9 @@ func main() {
 		Version: version,
 		Commands: []*cli.Command{
 			ServeCommand(nil),
+			MigrateCommand(),
+			OrdersCommand(),
+			FakeAdyenCommand(),
 		},
 	}
 
@@ -24,7 +24,7 @@ func TestCheckoutNavigationFromProductPage(t *testing.T) {
 	defer page.Close()
 
 	// Given I am viewing the Premium Widget product page
-	if _, err = page.Goto("http://localhost:8080/"); err != nil {
+	if _, err = page.Goto(baseURL + "/products/widget-001"); err != nil {
 		t.Fatalf("Failed to navigate to product page: %v", err)
 	}
 
@@ -35,7 +35,7 @@ func TestCheckoutNavigationFromProductPage(t *testing.T) {
 	}
 
 	// Then I should be redirected to the checkout page
-	if err = page.WaitForURL("**/checkout", playwright.PageWaitForURLOptions{
+	if err = page.WaitForURL("**/checkout**", playwright.PageWaitForURLOptions{
 		Timeout: playwright.Float(5000),
 	}); err != nil {
 		t.Fatalf("Did not redirect to checkout page: %v", err)
@@ -71,21 +71,16 @@ func TestCheckoutNavigationFromProductPage(t *testing.T) {
 //	  And I should see payment method options
 //	  And I should see a card payment form
 func TestCheckoutAdyenDropinComponent(t *testing.T) {
-	page, err := browser.NewPage()
-	if err != nil {
-		t.Fatal(err)
-	}
+	page := newPage(t)
 	defer page.Close()
 
 	// Given I am on the checkout page
-	if _, err = page.Goto("http://localhost:8080/checkout"); err != nil {
-		t.Fatalf("Failed to navigate to checkout page: %v", err)
-	}
+	openCheckout(t, page)
 
 	// When the page loads
 	// Wait for the loading message to disappear (indicates session was created)
 	loadingMessage := page.Locator("#loading-container")
-	if err = loadingMessage.WaitFor(playwright.LocatorWaitForOptions{
+	if err := loadingMessage.WaitFor(playwright.LocatorWaitForOptions{
 		State:   playwright.WaitForSelectorStateHidden,
 		Timeout: playwright.Float(10000),
 	}); err != nil {
@@ -121,3 +116,21 @@ func TestCheckoutAdyenDropinComponent(t *testing.T) {
 		t.Error("Card payment option is not visible")
 	}
 }
+
+// openCheckout adds the Premium Widget to a fresh cart with "Buy Now" and
+// waits for the checkout page, since checkout is only reachable with a cart
+func openCheckout(t *testing.T, page playwright.Page) {
+	t.Helper()
+
+	if _, err := page.Goto(baseURL + "/products/widget-001"); err != nil {
+		t.Fatalf("Failed to navigate to product page: %v", err)
+	}
+	if err := page.Locator("button:has-text('Buy Now')").Click(); err != nil {
+		t.Fatalf("Failed to click Buy Now button: %v", err)
+	}
+	if err := page.WaitForURL("**/checkout**", playwright.PageWaitForURLOptions{
+		Timeout: playwright.Float(5000),
+	}); err != nil {
+		t.Fatalf("Did not redirect to checkout page: %v", err)
+	}
+}
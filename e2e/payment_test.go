@@ -1,12 +1,10 @@
 package e2e
 
 import (
-	"fmt"
-	"regexp"
 	"strings"
 	"testing"
-	"time"
 
+	"github.com/adyen/ecommerce/internal/fakeadyen"
 	"github.com/playwright-community/playwright-go"
 )
 
@@ -17,8 +15,7 @@ import (
 //	  Given I am viewing the "Premium Widget" for "$1.00"
 //	  When I click "Buy Now"
 //	  And I navigate to the checkout page
-//	  And I enter valid payment details
-//	  And I submit the payment
+//	  And I pay with a payment method that is authorised
 //	  Then I should be redirected to the confirmation page
 //	  And I should see "Order Confirmed" or "Thank You" message
 //	  And I should see my order reference number
@@ -26,105 +23,26 @@ import (
 //	  And I should see the amount "$1.00"
 //	  And I should see payment status "Authorized" or "Paid"
 func TestPaymentSuccessfulFlow(t *testing.T) {
-	page, err := browser.NewPage()
-	if err != nil {
-		t.Fatal(err)
-	}
+	page := newPage(t)
 	defer page.Close()
 
 	// Given I am viewing the "Premium Widget" for "$1.00"
-	if _, err = page.Goto("http://localhost:8080/"); err != nil {
-		t.Fatalf("Failed to navigate to product page: %v", err)
-	}
-
 	// When I click "Buy Now"
-	buyButton := page.Locator("button:has-text('Buy Now')")
-	if err = buyButton.Click(); err != nil {
-		t.Fatalf("Failed to click Buy Now button: %v", err)
-	}
-
 	// And I navigate to the checkout page
-	if err = page.WaitForURL("**/checkout", playwright.PageWaitForURLOptions{
-		Timeout: playwright.Float(5000),
-	}); err != nil {
-		t.Fatalf("Did not redirect to checkout page: %v", err)
-	}
+	openCheckout(t, page)
 
-	// Wait for Adyen Drop-in to load
-	loadingMessage := page.Locator("#loading-container")
-	if err = loadingMessage.WaitFor(playwright.LocatorWaitForOptions{
-		State:   playwright.WaitForSelectorStateHidden,
-		Timeout: playwright.Float(15000),
-	}); err != nil {
-		t.Fatalf("Checkout did not load: %v", err)
-	}
-
-	// Wait for Adyen Drop-in component to render
-	time.Sleep(3 * time.Second)
-
-	// Click on the card payment method to expand it (if collapsed)
-	cardPaymentMethod := page.Locator(".adyen-checkout__payment-method--card, [class*='card']").First()
-	if visible, _ := cardPaymentMethod.IsVisible(); visible {
-		// Check if it needs to be clicked to expand
-		if err = cardPaymentMethod.Click(); err != nil {
-			t.Logf("Card payment method already expanded or click not needed: %v", err)
-		}
-		time.Sleep(1 * time.Second)
-	}
-
-	// And I enter valid payment details (using Adyen test card)
-	// Adyen renders the card form in an iframe
-	// Wait for the iframe to be available
-	page.WaitForSelector("iframe[title*='card number'], iframe[title*='Card number']", playwright.PageWaitForSelectorOptions{
-		State:   playwright.WaitForSelectorStateVisible,
-		Timeout: playwright.Float(10000),
-	})
-
-	cardFrame := page.FrameLocator("iframe[title*='card number'], iframe[title*='Card number']")
-	cardNumberInput := cardFrame.Locator("input[aria-label='Card number'], input[data-fieldtype='encryptedCardNumber']")
-
-	if err = cardNumberInput.Fill("4111111111111111"); err != nil {
-		t.Fatalf("Failed to enter card number: %v", err)
-	}
-
-	// Expiry date
-	expiryFrame := page.FrameLocator("iframe[title*='expiry'], iframe[title*='Expiry']")
-	expiryInput := expiryFrame.Locator("input[aria-label='Expiry date'], input[data-fieldtype='encryptedExpiryDate']")
-	if err = expiryInput.Fill("03/30"); err != nil {
-		t.Fatalf("Failed to enter expiry date: %v", err)
-	}
-
-	// CVC
-	cvcFrame := page.FrameLocator("iframe[title*='security'], iframe[title*='Security'], iframe[title*='CVC']")
-	cvcInput := cvcFrame.Locator("input[aria-label='Security code'], input[data-fieldtype='encryptedSecurityCode']")
-	if err = cvcInput.Fill("737"); err != nil {
-		t.Fatalf("Failed to enter CVC: %v", err)
-	}
-
-	// Cardholder name (if visible, not in iframe)
-	holderNameInput := page.Locator("input[name='holderName']")
-	if visible, _ := holderNameInput.IsVisible(); visible {
-		if err = holderNameInput.Fill("Test User"); err != nil {
-			t.Logf("Warning: Could not fill cardholder name: %v", err)
-		}
-	}
-
-	// And I submit the payment
-	payButton := page.Locator("button[type='submit']:has-text('Pay'), button:has-text('Pay')")
-	if err = payButton.Click(); err != nil {
-		t.Fatalf("Failed to click Pay button: %v", err)
-	}
+	// And I pay with a payment method that is authorised
+	pay(t, page, fakeadyen.OutcomeAuthorised)
 
 	// Then I should be redirected to the confirmation page
-	if err = page.WaitForURL("**/confirmation**", playwright.PageWaitForURLOptions{
-		Timeout: playwright.Float(30000),
+	if err := page.WaitForURL("**/order/confirmation**", playwright.PageWaitForURLOptions{
+		Timeout: playwright.Float(10000),
 	}); err != nil {
 		t.Fatalf("Did not redirect to confirmation page: %v", err)
 	}
 
 	// And I should see "Order Confirmed" or "Thank You" message
-	title := page.Locator(".confirmation-title")
-	titleText, err := title.TextContent()
+	titleText, err := page.Locator(".confirmation-title").TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find confirmation title: %v", err)
 	}
@@ -134,8 +52,7 @@ func TestPaymentSuccessfulFlow(t *testing.T) {
 	}
 
 	// And I should see my order reference number
-	orderRef := page.Locator(".order-reference").First()
-	orderRefText, err := orderRef.TextContent()
+	orderRefText, err := page.Locator(".order-reference").First().TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find order reference: %v", err)
 	}
@@ -145,35 +62,19 @@ func TestPaymentSuccessfulFlow(t *testing.T) {
 	t.Logf("Order reference: %s", orderRefText)
 
 	// And I should see "Premium Widget"
-	productName := page.Locator(".product-name")
-	productNameText, err := productName.TextContent()
-	if err != nil {
-		t.Fatalf("Failed to find product name: %v", err)
-	}
-	if productNameText != "Premium Widget" {
-		t.Errorf("Expected 'Premium Widget', got: %s", productNameText)
-	}
-
 	// And I should see the amount "$1.00"
-	amount := page.Locator(".product-amount")
-	amountText, err := amount.TextContent()
-	if err != nil {
-		t.Fatalf("Failed to find amount: %v", err)
-	}
-	if amountText != "$1.00" {
-		t.Errorf("Expected '$1.00', got: %s", amountText)
-	}
+	expectOrderedWidget(t, page)
 
 	// And I should see payment status "Authorized" or "Paid"
-	status := page.Locator(".status-badge")
-	statusText, err := status.TextContent()
+	statusText, err := page.Locator(".status-badge").TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find payment status: %v", err)
 	}
 	statusLower := strings.ToLower(statusText)
 	if !strings.Contains(statusLower, "authorized") &&
 		!strings.Contains(statusLower, "paid") &&
-		!strings.Contains(statusLower, "authorised") {
+		!strings.Contains(statusLower, "authorised") &&
+		!strings.Contains(statusLower, "captured") {
 		t.Errorf("Expected status 'Authorized' or 'Paid', got: %s", statusText)
 	}
 }
@@ -191,117 +92,62 @@ func TestPaymentSuccessfulFlow(t *testing.T) {
 //	  And I should see the payment status "Authorized"
 //	  And I should see the product and amount
 func TestReturnToConfirmationPage(t *testing.T) {
-	// First, complete a payment to get a confirmation URL
-	page, err := browser.NewPage()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Navigate and complete payment (abbreviated version)
-	if _, err = page.Goto("http://localhost:8080/"); err != nil {
-		t.Fatalf("Failed to navigate to product page: %v", err)
-	}
+	// Given I completed a payment
+	page := newPage(t)
+	openCheckout(t, page)
+	pay(t, page, fakeadyen.OutcomeAuthorised)
 
-	buyButton := page.Locator("button:has-text('Buy Now')")
-	if err = buyButton.Click(); err != nil {
-		t.Fatalf("Failed to click Buy Now button: %v", err)
-	}
-
-	// Wait for checkout page
-	if err = page.WaitForURL("**/checkout", playwright.PageWaitForURLOptions{
-		Timeout: playwright.Float(5000),
-	}); err != nil {
-		t.Fatalf("Did not redirect to checkout page: %v", err)
-	}
-
-	// Wait for Adyen Drop-in to load
-	loadingMessage := page.Locator("#loading-container")
-	if err = loadingMessage.WaitFor(playwright.LocatorWaitForOptions{
-		State:   playwright.WaitForSelectorStateHidden,
-		Timeout: playwright.Float(15000),
-	}); err != nil {
-		t.Fatalf("Checkout did not load: %v", err)
-	}
-
-	time.Sleep(3 * time.Second)
-
-	// Click card payment method if needed
-	cardPaymentMethod := page.Locator(".adyen-checkout__payment-method--card, [class*='card']").First()
-	if visible, _ := cardPaymentMethod.IsVisible(); visible {
-		cardPaymentMethod.Click()
-		time.Sleep(1 * time.Second)
-	}
-
-	// Fill payment details quickly
-	page.WaitForSelector("iframe[title*='card number'], iframe[title*='Card number']", playwright.PageWaitForSelectorOptions{
-		State:   playwright.WaitForSelectorStateVisible,
+	if err := page.WaitForURL("**/order/confirmation**", playwright.PageWaitForURLOptions{
 		Timeout: playwright.Float(10000),
-	})
-
-	cardFrame := page.FrameLocator("iframe[title*='card number'], iframe[title*='Card number']")
-	cardNumberInput := cardFrame.Locator("input[aria-label='Card number'], input[data-fieldtype='encryptedCardNumber']")
-	if err = cardNumberInput.Fill("4111111111111111"); err != nil {
-		t.Fatalf("Failed to enter card number: %v", err)
-	}
-
-	expiryFrame := page.FrameLocator("iframe[title*='expiry'], iframe[title*='Expiry']")
-	expiryInput := expiryFrame.Locator("input[aria-label='Expiry date'], input[data-fieldtype='encryptedExpiryDate']")
-	if err = expiryInput.Fill("03/30"); err != nil {
-		t.Fatalf("Failed to enter expiry date: %v", err)
-	}
-
-	cvcFrame := page.FrameLocator("iframe[title*='security'], iframe[title*='Security'], iframe[title*='CVC']")
-	cvcInput := cvcFrame.Locator("input[aria-label='Security code'], input[data-fieldtype='encryptedSecurityCode']")
-	if err = cvcInput.Fill("737"); err != nil {
-		t.Fatalf("Failed to enter CVC: %v", err)
-	}
-
-	holderNameInput := page.Locator("input[name='holderName']")
-	if visible, _ := holderNameInput.IsVisible(); visible {
-		holderNameInput.Fill("Test User")
-	}
-
-	payButton := page.Locator("button[type='submit']:has-text('Pay'), button:has-text('Pay')")
-	if err = payButton.Click(); err != nil {
-		t.Fatalf("Failed to click Pay button: %v", err)
-	}
-
-	// Wait for confirmation page
-	if err = page.WaitForURL("**/confirmation**", playwright.PageWaitForURLOptions{
-		Timeout: playwright.Float(30000),
 	}); err != nil {
 		t.Fatalf("Did not redirect to confirmation page: %v", err)
 	}
 
-	// Get the confirmation URL and order reference
+	// And I received a confirmation page URL
 	confirmationURL := page.URL()
 	t.Logf("Confirmation URL: %s", confirmationURL)
 
-	orderRef := page.Locator(".order-reference").First()
-	orderRefText, err := orderRef.TextContent()
+	orderRefText, err := page.Locator(".order-reference").First().TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find order reference: %v", err)
 	}
 	t.Logf("Order reference: %s", orderRefText)
 
-	// When I close my browser (simulate by closing page and opening new one)
+	// When I close my browser (simulate by closing the page and opening a new one with the
+	// same cookies, which tie the order to the shopper who placed it)
+	cookies, err := page.Context().Cookies()
+	if err != nil {
+		t.Fatalf("Failed to read cookies: %v", err)
+	}
 	page.Close()
 
-	// And I reopen the confirmation page URL later
-	newPage, err := browser.NewPage()
+	context, err := browser.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer context.Close()
+
+	optionalCookies := make([]playwright.OptionalCookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		optionalCookies = append(optionalCookies, cookie.ToOptionalCookie())
+	}
+	if err = context.AddCookies(optionalCookies); err != nil {
+		t.Fatalf("Failed to restore cookies: %v", err)
+	}
+
+	newPage, err := context.NewPage()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer newPage.Close()
 
+	// And I reopen the confirmation page URL later
 	if _, err = newPage.Goto(confirmationURL); err != nil {
 		t.Fatalf("Failed to reopen confirmation page: %v", err)
 	}
 
 	// Then I should still see my order details
 	// And I should see order reference
-	reopenedOrderRef := newPage.Locator(".order-reference").First()
-	reopenedOrderRefText, err := reopenedOrderRef.TextContent()
+	reopenedOrderRefText, err := newPage.Locator(".order-reference").First().TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find order reference on reopened page: %v", err)
 	}
@@ -310,8 +156,7 @@ func TestReturnToConfirmationPage(t *testing.T) {
 	}
 
 	// And I should see the payment status "Authorized"
-	status := newPage.Locator(".status-badge")
-	statusText, err := status.TextContent()
+	statusText, err := newPage.Locator(".status-badge").TextContent()
 	if err != nil {
 		t.Fatalf("Failed to find payment status: %v", err)
 	}
@@ -320,23 +165,7 @@ func TestReturnToConfirmationPage(t *testing.T) {
 	}
 
 	// And I should see the product and amount
-	productName := newPage.Locator(".product-name")
-	productNameText, err := productName.TextContent()
-	if err != nil {
-		t.Fatalf("Failed to find product name: %v", err)
-	}
-	if productNameText != "Premium Widget" {
-		t.Errorf("Expected 'Premium Widget', got: %s", productNameText)
-	}
-
-	amount := newPage.Locator(".product-amount")
-	amountText, err := amount.TextContent()
-	if err != nil {
-		t.Fatalf("Failed to find amount: %v", err)
-	}
-	if amountText != "$1.00" {
-		t.Errorf("Expected '$1.00', got: %s", amountText)
-	}
+	expectOrderedWidget(t, newPage)
 }
 
 // TestPaymentDeclined tests the payment declined flow
@@ -344,158 +173,99 @@ func TestReturnToConfirmationPage(t *testing.T) {
 //
 //	Scenario: Payment declined
 //	  Given I am on the checkout page
-//	  When I enter payment details that will be declined
-//	  And I submit the payment
+//	  When I pay with a payment method that is refused
 //	  Then I should be redirected to a failure page
 //	  And I should see "Payment Declined" or similar message
 //	  And I should see my order reference
 //	  And I should see a "Try Again" button
 func TestPaymentDeclined(t *testing.T) {
-	page, err := browser.NewPage()
-	if err != nil {
-		t.Fatal(err)
-	}
+	page := newPage(t)
 	defer page.Close()
 
 	// Given I am on the checkout page
-	if _, err = page.Goto("http://localhost:8080/checkout"); err != nil {
-		t.Fatalf("Failed to navigate to checkout page: %v", err)
+	openCheckout(t, page)
+
+	// When I pay with a payment method that is refused
+	pay(t, page, fakeadyen.OutcomeRefused)
+
+	// Then I should be redirected to a failure page
+	if err := page.WaitForURL("**/order/failed**", playwright.PageWaitForURLOptions{
+		Timeout: playwright.Float(10000),
+	}); err != nil {
+		t.Fatalf("Did not redirect to failure page: %v", err)
 	}
 
-	// Wait for Adyen Drop-in to load
-	loadingMessage := page.Locator("#loading-container")
-	if err = loadingMessage.WaitFor(playwright.LocatorWaitForOptions{
+	// And I should see "Payment Declined" or similar message
+	titleText, err := page.Locator(".failure-title").TextContent()
+	if err != nil {
+		t.Fatalf("Failed to find failure title: %v", err)
+	}
+	titleLower := strings.ToLower(titleText)
+	if !strings.Contains(titleLower, "fail") &&
+		!strings.Contains(titleLower, "decline") &&
+		!strings.Contains(titleLower, "refused") {
+		t.Errorf("Expected failure message, got: %s", titleText)
+	}
+
+	// And I should see my order reference
+	orderRefText, err := page.Locator(".order-reference").First().TextContent()
+	if err != nil {
+		t.Fatalf("Failed to find order reference: %v", err)
+	}
+	if orderRefText == "" {
+		t.Error("Order reference is empty")
+	}
+
+	// And I should see a "Try Again" button
+	visible, err := page.Locator("a:has-text('Try Again'), button:has-text('Try Again')").IsVisible()
+	if err != nil || !visible {
+		t.Error("'Try Again' button is not visible")
+	}
+}
+
+// pay waits for the Drop-in on the checkout page, pays with a card and completes the
+// payment on the fake Adyen payment page with the given outcome
+func pay(t *testing.T, page playwright.Page, outcome string) {
+	t.Helper()
+
+	if err := page.Locator("#loading-container").WaitFor(playwright.LocatorWaitForOptions{
 		State:   playwright.WaitForSelectorStateHidden,
-		Timeout: playwright.Float(15000),
+		Timeout: playwright.Float(10000),
 	}); err != nil {
 		t.Fatalf("Checkout did not load: %v", err)
 	}
 
-	time.Sleep(3 * time.Second)
-
-	// Click card payment method if needed
-	cardPaymentMethod := page.Locator(".adyen-checkout__payment-method--card, [class*='card']").First()
-	if visible, _ := cardPaymentMethod.IsVisible(); visible {
-		cardPaymentMethod.Click()
-		time.Sleep(1 * time.Second)
+	if err := page.Locator(".adyen-checkout__payment-method--card a:has-text('Pay')").Click(); err != nil {
+		t.Fatalf("Failed to click Pay: %v", err)
+	}
+	if err := page.WaitForURL(fakeAdyenURL+"/_fake/sessions/**", playwright.PageWaitForURLOptions{
+		Timeout: playwright.Float(5000),
+	}); err != nil {
+		t.Fatalf("Did not open the Adyen payment page: %v", err)
 	}
 
-	// When I enter payment details that will be declined
-	// Using an invalid/declined test card - card that triggers a refusal
-	// According to Adyen docs, we can use card with specific CVC to trigger decline
-	page.WaitForSelector("iframe[title*='card number'], iframe[title*='Card number']", playwright.PageWaitForSelectorOptions{
-		State:   playwright.WaitForSelectorStateVisible,
-		Timeout: playwright.Float(10000),
-	})
-
-	cardFrame := page.FrameLocator("iframe[title*='card number'], iframe[title*='Card number']")
-	cardNumberInput := cardFrame.Locator("input[aria-label='Card number'], input[data-fieldtype='encryptedCardNumber']")
-	// Use a card that will be refused (CVC 111 with standard test card triggers refusal in some cases)
-	// Or we can use the card ending in 0002 which is documented as refused
-	if err = cardNumberInput.Fill("4111111111110002"); err != nil {
-		t.Fatalf("Failed to enter card number: %v", err)
-	}
-
-	expiryFrame := page.FrameLocator("iframe[title*='expiry'], iframe[title*='Expiry']")
-	expiryInput := expiryFrame.Locator("input[aria-label='Expiry date'], input[data-fieldtype='encryptedExpiryDate']")
-	if err = expiryInput.Fill("03/30"); err != nil {
-		t.Fatalf("Failed to enter expiry date: %v", err)
-	}
-
-	cvcFrame := page.FrameLocator("iframe[title*='security'], iframe[title*='Security'], iframe[title*='CVC']")
-	cvcInput := cvcFrame.Locator("input[aria-label='Security code'], input[data-fieldtype='encryptedSecurityCode']")
-	if err = cvcInput.Fill("737"); err != nil {
-		t.Fatalf("Failed to enter CVC: %v", err)
-	}
-
-	holderNameInput := page.Locator("input[name='holderName']")
-	if visible, _ := holderNameInput.IsVisible(); visible {
-		if err = holderNameInput.Fill("Test User"); err != nil {
-			t.Logf("Warning: Could not fill cardholder name: %v", err)
-		}
-	}
-
-	// And I submit the payment
-	payButton := page.Locator("button[type='submit']:has-text('Pay'), button:has-text('Pay')")
-	if err = payButton.Click(); err != nil {
-		t.Fatalf("Failed to click Pay button: %v", err)
-	}
-
-	// Then I should be redirected to a failure page (or see error on checkout page)
-	// Wait for either failure page or error message on checkout page
-	time.Sleep(5 * time.Second)
-
-	currentURL := page.URL()
-	t.Logf("Current URL after declined payment: %s", currentURL)
-
-	// Check if we're on the failure page
-	if strings.Contains(currentURL, "/failure") {
-		// And I should see "Payment Declined" or similar message
-		title := page.Locator(".failure-title, h1")
-		titleText, err := title.TextContent()
-		if err != nil {
-			t.Fatalf("Failed to find failure title: %v", err)
-		}
-		titleLower := strings.ToLower(titleText)
-		if !strings.Contains(titleLower, "fail") &&
-			!strings.Contains(titleLower, "decline") &&
-			!strings.Contains(titleLower, "refused") {
-			t.Logf("Warning: Expected failure message, got: %s", titleText)
-		}
-
-		// And I should see my order reference
-		orderRef := page.Locator(".order-reference")
-		if count, _ := orderRef.Count(); count > 0 {
-			orderRefText, _ := orderRef.TextContent()
-			t.Logf("Order reference: %s", orderRefText)
-		}
-
-		// And I should see a "Try Again" button
-		tryAgainButton := page.Locator("a:has-text('Try Again'), button:has-text('Try Again')")
-		visible, err := tryAgainButton.IsVisible()
-		if err != nil || !visible {
-			t.Error("'Try Again' button is not visible")
-		}
-	} else {
-		// Check for error message on checkout page
-		errorContainer := page.Locator("#error-container, .error-message")
-		visible, _ := errorContainer.IsVisible()
-		if visible {
-			errorText, _ := errorContainer.TextContent()
-			t.Logf("Error message displayed on checkout page: %s", errorText)
-
-			// Verify error message contains decline/refusal information
-			errorLower := strings.ToLower(errorText)
-			if !strings.Contains(errorLower, "decline") &&
-				!strings.Contains(errorLower, "refuse") &&
-				!strings.Contains(errorLower, "failed") &&
-				!strings.Contains(errorLower, "error") {
-				t.Logf("Warning: Expected decline message, got: %s", errorText)
-			}
-		} else {
-			// Check for Adyen Drop-in error messages
-			adyenError := page.Locator(".adyen-checkout__error-text, [class*='error']")
-			if count, _ := adyenError.Count(); count > 0 {
-				errorText, _ := adyenError.First().TextContent()
-				t.Logf("Adyen error message: %s", errorText)
-			} else {
-				t.Logf("Warning: No clear error message found after declined payment")
-			}
-		}
+	if err := page.Locator("a:text-is('" + outcome + "')").Click(); err != nil {
+		t.Fatalf("Failed to complete the payment as %s: %v", outcome, err)
 	}
 }
 
-// Helper function to extract order reference from URL
-func extractOrderRefFromURL(url string) string {
-	re := regexp.MustCompile(`sessionId=([^&]+)`)
-	matches := re.FindStringSubmatch(url)
-	if len(matches) > 1 {
-		return matches[1]
+// expectOrderedWidget checks the order on the page holds the "Premium Widget" for "$1.00"
+func expectOrderedWidget(t *testing.T, page playwright.Page) {
+	t.Helper()
+
+	productNameText, err := page.Locator(".product-name").TextContent()
+	if err != nil {
+		t.Fatalf("Failed to find product name: %v", err)
+	}
+	if productNameText != "Premium Widget" {
+		t.Errorf("Expected 'Premium Widget', got: %s", productNameText)
 	}
-	return ""
-}
 
-// Helper function to format error message
-func formatError(context string, err error) string {
-	return fmt.Sprintf("%s: %v", context, err)
+	amountText, err := page.Locator(".product-amount").TextContent()
+	if err != nil {
+		t.Fatalf("Failed to find amount: %v", err)
+	}
+	if amountText != "$1.00" {
+		t.Errorf("Expected '$1.00', got: %s", amountText)
+	}
 }
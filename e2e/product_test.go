@@ -23,9 +23,9 @@ func TestProductDisplay(t *testing.T) {
 	}
 	defer page.Close()
 
-	// Given I am on the homepage
-	if _, err = page.Goto("http://localhost:8080/"); err != nil {
-		t.Fatalf("Failed to navigate to homepage: %v", err)
+	// Given I am on the Premium Widget product page
+	if _, err = page.Goto(baseURL + "/products/widget-001"); err != nil {
+		t.Fatalf("Failed to navigate to product page: %v", err)
 	}
 
 	// Then I should see the widget product
@@ -1,24 +1,74 @@
 package e2e
 
 import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/adyen/ecommerce/internal/fakeadyen"
 	"github.com/playwright-community/playwright-go"
 )
 
+// Credentials shared by the shop and the fake Adyen server
+const (
+	e2eAPIKey  = "e2e-api-key"
+	e2eHMACKey = "44782DEF547AAA06C910C43932B1EB0C71FC68D9D0C057550C48EC2ACF6BA056"
+)
+
 var (
 	pw      *playwright.Playwright
 	browser playwright.Browser
+
+	// baseURL is where the shop under test is served, e.g. http://localhost:54321
+	baseURL string
+	// fakeAdyen stands in for the Adyen Checkout API and payment pages
+	fakeAdyen    *fakeadyen.Server
+	fakeAdyenURL string
 )
 
-// TestMain sets up and tears down the Playwright browser for all tests
+// TestMain starts a fake Adyen server and the shop pointed at it, so the suite needs no
+// Adyen credentials or network access, then sets up the Playwright browser for all tests
 func TestMain(m *testing.M) {
-	var err error
+	os.Exit(run(m))
+}
+
+// run sets up the environment, runs the tests and tears everything down again
+func run(m *testing.M) int {
+	port, err := freePort()
+	if err != nil {
+		log.Fatalf("Failed to find a free port: %v", err)
+	}
+	baseURL = fmt.Sprintf("http://localhost:%d", port)
+
+	fakeAdyen, err = fakeadyen.NewServer(fakeadyen.Config{
+		APIKey:     e2eAPIKey,
+		WebhookURL: baseURL + "/api/webhooks/adyen",
+		HMACKey:    e2eHMACKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create fake Adyen server: %v", err)
+	}
+	fake := httptest.NewServer(fakeAdyen)
+	defer fake.Close()
+	fakeAdyenURL = fake.URL
+
+	stop, err := startShop(port)
+	if err != nil {
+		log.Fatalf("Failed to start the shop: %v", err)
+	}
+	defer stop()
 
 	// Start Playwright (browsers already installed via: go run github.com/playwright-community/playwright-go/cmd/playwright@latest install chromium)
 	pw, err = playwright.Run()
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to start Playwright: %v", err)
 	}
 	defer pw.Stop()
 
@@ -27,10 +77,128 @@ func TestMain(m *testing.M) {
 		Headless: playwright.Bool(true),
 	})
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to launch browser: %v", err)
 	}
 	defer browser.Close()
 
 	// Run tests
-	m.Run()
+	return m.Run()
+}
+
+// startShop builds the shop and serves it on port with in-memory storage, using the fake
+// Adyen server. The returned function stops it.
+func startShop(port int) (func(), error) {
+	dir, err := os.MkdirTemp("", "simplecom-e2e")
+	if err != nil {
+		return nil, err
+	}
+	binary := filepath.Join(dir, "simplecom")
+
+	build := exec.Command("go", "build", "-o", binary, "./cmd/simplecom")
+	build.Dir = ".."
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to build: %w", err)
+	}
+
+	// Templates and static files are served relative to the repository root
+	serve := exec.Command(binary, "serve", "--storage", "memory")
+	serve.Dir = ".."
+	serve.Stdout, serve.Stderr = os.Stdout, os.Stderr
+	serve.Env = append(os.Environ(),
+		fmt.Sprintf("PORT=%d", port),
+		"PUBLIC_BASE_URL="+baseURL,
+		"ADMIN_ADDR=127.0.0.1:0",
+		"COOKIE_SECRET=e2e-cookie-secret",
+		"ADYEN_ENVIRONMENT=TEST",
+		"ADYEN_BASE_URL="+fakeAdyenURL,
+		"ADYEN_API_KEY="+e2eAPIKey,
+		"ADYEN_CLIENT_KEY=test_E2E_CLIENT_KEY",
+		"ADYEN_MERCHANT_ACCOUNT=E2EMerchant",
+		"ADYEN_HMAC_KEY="+e2eHMACKey,
+	)
+	if err := serve.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to start: %w", err)
+	}
+
+	stop := func() {
+		serve.Process.Signal(os.Interrupt)
+		serve.Wait()
+		os.RemoveAll(dir)
+	}
+	if err := waitUntilServing(baseURL, 30*time.Second); err != nil {
+		stop()
+		return nil, err
+	}
+	return stop, nil
+}
+
+// waitUntilServing polls url until it answers or the timeout passes
+func waitUntilServing(url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("%s did not answer within %v", url, timeout)
+}
+
+// freePort returns a TCP port that is free to listen on
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// stubDropin replaces the Adyen Web SDK, which is loaded from Adyen's CDN, with a Drop-in
+// that shows a card payment method sending the shopper to the fake Adyen payment page
+const stubDropin = `
+window.AdyenCheckout = async (configuration) => ({
+    create: () => ({
+        mount: (selector) => {
+            const method = document.createElement('div');
+            method.className = 'adyen-checkout__payment-method adyen-checkout__payment-method--card';
+            method.textContent = 'Card ';
+            const pay = document.createElement('a');
+            pay.href = %q + '/_fake/sessions/' + configuration.session.id;
+            pay.textContent = 'Pay';
+            method.appendChild(pay);
+            document.querySelector(selector).appendChild(method);
+        }
+    })
+});
+`
+
+// newPage opens a browser page that loads the stub Drop-in instead of Adyen's SDK
+func newPage(t *testing.T) playwright.Page {
+	t.Helper()
+
+	page, err := browser.NewPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = page.Route("**/checkoutshopper/**", func(route playwright.Route) {
+		body, contentType := "", "text/css"
+		if filepath.Ext(route.Request().URL()) == ".js" {
+			body, contentType = fmt.Sprintf(stubDropin, fakeAdyenURL), "application/javascript"
+		}
+		route.Fulfill(playwright.RouteFulfillOptions{
+			Status:      playwright.Int(http.StatusOK),
+			ContentType: playwright.String(contentType),
+			Body:        body,
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to stub the Adyen SDK: %v", err)
+	}
+	return page
 }
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/adyen/ecommerce/internal/fakeadyen"
+)
+
+// RunFakeAdyen serves a fake Adyen Checkout API until a shutdown signal is received
+func RunFakeAdyen(fake *fakeadyen.Server, port string) error {
+	listener, server, err := StartFakeAdyen(fake, port)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	return WaitForShutdown(server, nil)
+}
+
+// StartFakeAdyen starts serving a fake Adyen Checkout API, returning the listener and server
+func StartFakeAdyen(fake *fakeadyen.Server, port string) (net.Listener, *http.Server, error) {
+	listener, server, err := startHTTPServer(fake, ":"+port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Fake Adyen API running, point the shop at it with ADYEN_BASE_URL=http://localhost:%d",
+		listener.Addr().(*net.TCPAddr).Port)
+	return listener, server, nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/fakeadyen"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+func TestStartFakeAdyen(t *testing.T) {
+	// GIVEN
+	fake, err := fakeadyen.NewServer(fakeadyen.Config{DefaultOutcome: fakeadyen.OutcomeRefused})
+	if err != nil {
+		t.Fatalf("Failed to create fake Adyen server: %v", err)
+	}
+
+	// WHEN
+	listener, server, err := StartFakeAdyen(fake, "0")
+	if err != nil {
+		t.Fatalf("Failed to start fake Adyen server: %v", err)
+	}
+	defer listener.Close()
+	defer server.Close()
+
+	// THEN
+	client := services.NewAdyenClient(&config.AdyenConfig{
+		APIKey:          "test-api-key",
+		MerchantAccount: "TestMerchant",
+		BaseURL:         fmt.Sprintf("http://%s", listener.Addr()),
+	})
+
+	session, err := client.CreateSession(context.Background(), &services.SessionRequest{
+		Amount:    services.Amount{Currency: "USD", Value: 100},
+		Reference: "ORDER-FAKE-001",
+		ReturnUrl: "http://localhost:8080/order/confirmation",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	status, err := client.GetSessionStatus(context.Background(), session.ID, "result")
+	if err != nil {
+		t.Fatalf("Failed to get session status: %v", err)
+	}
+	if len(status.Payments) != 1 || status.Payments[0].ResultCode != fakeadyen.OutcomeRefused {
+		t.Errorf("Expected a refused payment, got %+v", status.Payments)
+	}
+}
+
+func TestStartFakeAdyen_PortInUse(t *testing.T) {
+	// GIVEN
+	fake, _ := fakeadyen.NewServer(fakeadyen.Config{})
+	listener, server, err := StartFakeAdyen(fake, "0")
+	if err != nil {
+		t.Fatalf("Failed to start fake Adyen server: %v", err)
+	}
+	defer listener.Close()
+	defer server.Close()
+
+	// WHEN
+	port := fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port)
+	_, _, err = StartFakeAdyen(fake, port)
+
+	// THEN
+	if err == nil {
+		t.Error("Expected error for port already in use, got nil")
+	}
+}
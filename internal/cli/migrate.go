@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/adyen/ecommerce/internal/database"
+)
+
+// Migrator applies and reverts database schema migrations
+type Migrator interface {
+	Up() error
+	Down() error
+	To(version int) error
+	Status() ([]database.MigrationStatus, error)
+	Version() (int, error)
+}
+
+// RunMigrateUp applies all pending migrations
+func RunMigrateUp(migrator Migrator, out io.Writer) error {
+	if err := migrator.Up(); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return printSchemaVersion(migrator, out)
+}
+
+// RunMigrateDown reverts the most recently applied migration
+func RunMigrateDown(migrator Migrator, out io.Writer) error {
+	if err := migrator.Down(); err != nil {
+		return fmt.Errorf("failed to revert migration: %w", err)
+	}
+	return printSchemaVersion(migrator, out)
+}
+
+// RunMigrateTo migrates the schema up or down to the given version
+func RunMigrateTo(migrator Migrator, version string, out io.Writer) error {
+	if version == "" {
+		return fmt.Errorf("target version is required")
+	}
+	target, err := strconv.Atoi(version)
+	if err != nil || target < 0 {
+		return fmt.Errorf("invalid target version %q", version)
+	}
+
+	if err := migrator.To(target); err != nil {
+		return fmt.Errorf("failed to migrate to version %d: %w", target, err)
+	}
+	return printSchemaVersion(migrator, out)
+}
+
+// RunMigrateStatus prints every migration with its applied state
+func RunMigrateStatus(migrator Migrator, out io.Writer) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return fmt.Errorf("failed to read migration status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state = "applied"
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
+
+// printSchemaVersion reports the current schema version
+func printSchemaVersion(migrator Migrator, out io.Writer) error {
+	version, err := migrator.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	fmt.Fprintf(out, "Database schema is at version %d\n", version)
+	return nil
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/database"
+)
+
+// mockMigrator is a mock implementation of Migrator for testing
+type mockMigrator struct {
+	version  int
+	latest   int
+	err      error
+	statuses []database.MigrationStatus
+}
+
+func (m *mockMigrator) Up() error {
+	if m.err != nil {
+		return m.err
+	}
+	m.version = m.latest
+	return nil
+}
+
+func (m *mockMigrator) Down() error {
+	if m.err != nil {
+		return m.err
+	}
+	m.version--
+	return nil
+}
+
+func (m *mockMigrator) To(version int) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.version = version
+	return nil
+}
+
+func (m *mockMigrator) Status() ([]database.MigrationStatus, error) {
+	return m.statuses, m.err
+}
+
+func (m *mockMigrator) Version() (int, error) {
+	return m.version, nil
+}
+
+func TestRunMigrateUpDownTo(t *testing.T) {
+	tests := []struct {
+		name            string
+		run             func(Migrator, *bytes.Buffer) error
+		migratorErr     error
+		wantErr         bool
+		expectedVersion string
+	}{
+		{
+			name:            "up applies all migrations",
+			run:             func(m Migrator, out *bytes.Buffer) error { return RunMigrateUp(m, out) },
+			expectedVersion: "version 3",
+		},
+		{
+			name:            "down reverts one migration",
+			run:             func(m Migrator, out *bytes.Buffer) error { return RunMigrateDown(m, out) },
+			expectedVersion: "version 1",
+		},
+		{
+			name:            "to migrates to target version",
+			run:             func(m Migrator, out *bytes.Buffer) error { return RunMigrateTo(m, "3", out) },
+			expectedVersion: "version 3",
+		},
+		{
+			name:    "to rejects invalid version",
+			run:     func(m Migrator, out *bytes.Buffer) error { return RunMigrateTo(m, "latest", out) },
+			wantErr: true,
+		},
+		{
+			name:    "to requires a version",
+			run:     func(m Migrator, out *bytes.Buffer) error { return RunMigrateTo(m, "", out) },
+			wantErr: true,
+		},
+		{
+			name:        "migrator error",
+			run:         func(m Migrator, out *bytes.Buffer) error { return RunMigrateUp(m, out) },
+			migratorErr: errors.New("database error"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			migrator := &mockMigrator{version: 2, latest: 3, err: tt.migratorErr}
+			var out bytes.Buffer
+
+			// WHEN
+			err := tt.run(migrator, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.expectedVersion != "" && !strings.Contains(out.String(), tt.expectedVersion) {
+				t.Errorf("Expected output to contain '%s', got '%s'", tt.expectedVersion, out.String())
+			}
+		})
+	}
+}
+
+func TestRunMigrateStatus(t *testing.T) {
+	// GIVEN
+	migrator := &mockMigrator{
+		statuses: []database.MigrationStatus{
+			{
+				Migration: database.Migration{Version: 1, Name: "create_orders"},
+				Applied:   true,
+				AppliedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			{
+				Migration: database.Migration{Version: 2, Name: "create_products"},
+			},
+		},
+	}
+	var out bytes.Buffer
+
+	// WHEN
+	err := RunMigrateStatus(migrator, &out)
+
+	// THEN
+	if err != nil {
+		t.Fatalf("RunMigrateStatus() unexpected error = %v", err)
+	}
+	for _, content := range []string{"create_orders", "applied", "2026-01-02 03:04:05", "create_products", "pending"} {
+		if !strings.Contains(out.String(), content) {
+			t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+		}
+	}
+}
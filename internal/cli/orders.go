@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// RunRefund requests a refund for an order and reports the outcome
+// An amount of zero refunds the amount not refunded yet
+func RunRefund(ctx context.Context, paymentService services.PaymentService, reference string, amount int64, out io.Writer) error {
+	if reference == "" {
+		return fmt.Errorf("order reference is required")
+	}
+	if amount < 0 {
+		return fmt.Errorf("refund amount cannot be negative")
+	}
+
+	result, err := paymentService.RefundOrder(ctx, reference, amount)
+	if err != nil {
+		return fmt.Errorf("failed to refund order %s: %w", reference, err)
+	}
+
+	fmt.Fprintf(out, "Refund of %d %s requested for order %s\n", result.Amount, result.Order.Currency, result.Order.Reference)
+	fmt.Fprintf(out, "Refund PSP reference: %s (%s)\n", result.PSPReference, result.Status)
+	fmt.Fprintf(out, "Order status: %s\n", result.Order.Status)
+	return nil
+}
+
+// RunCapture captures the payment of an authorized order, typically once it ships
+func RunCapture(ctx context.Context, paymentService services.PaymentService, reference string, out io.Writer) error {
+	if reference == "" {
+		return fmt.Errorf("order reference is required")
+	}
+
+	result, err := paymentService.CaptureOrder(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to capture order %s: %w", reference, err)
+	}
+
+	fmt.Fprintf(out, "Capture of %d %s requested for order %s\n", result.Amount, result.Order.Currency, result.Order.Reference)
+	fmt.Fprintf(out, "Capture PSP reference: %s (%s)\n", result.PSPReference, result.Status)
+	fmt.Fprintf(out, "Order status: %s\n", result.Order.Status)
+	return nil
+}
+
+// RunCancel cancels an order, asking Adyen to void its payment if one was submitted
+func RunCancel(ctx context.Context, paymentService services.PaymentService, reference string, out io.Writer) error {
+	if reference == "" {
+		return fmt.Errorf("order reference is required")
+	}
+
+	result, err := paymentService.CancelOrder(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order %s: %w", reference, err)
+	}
+
+	if result.PSPReference != "" {
+		fmt.Fprintf(out, "Cancellation of the %d %s payment requested for order %s\n", result.Amount, result.Order.Currency, result.Order.Reference)
+		fmt.Fprintf(out, "Cancellation PSP reference: %s (%s)\n", result.PSPReference, result.Status)
+	}
+	fmt.Fprintf(out, "Order status: %s\n", result.Order.Status)
+	return nil
+}
+
+// RunHistory prints the status history of an order, oldest change first
+func RunHistory(ctx context.Context, orderService services.OrderService, reference string, out io.Writer) error {
+	if reference == "" {
+		return fmt.Errorf("order reference is required")
+	}
+
+	events, err := orderService.GetOrderHistory(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to get history of order %s: %w", reference, err)
+	}
+
+	if len(events) == 0 {
+		fmt.Fprintf(out, "Order %s has no status changes\n", reference)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tFROM\tTO\tSOURCE\tACTOR\tPSP REFERENCE")
+	for _, event := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.CreatedAt.Format("2006-01-02 15:04:05"),
+			event.PreviousStatus, event.Status, event.Source, event.Actor, event.PSPReference)
+	}
+	return w.Flush()
+}
+
+// RunStuck lists orders whose status update failed to be stored and is queued for retry.
+// With retry set, the updates that are due are retried first.
+func RunStuck(ctx context.Context, statusRetries services.StatusRetryService, retry bool, out io.Writer) error {
+	if retry {
+		resolved, err := statusRetries.RetryDue(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to retry queued status updates: %w", err)
+		}
+		fmt.Fprintf(out, "Stored %d queued status updates\n", resolved)
+	}
+
+	retries, err := statusRetries.ListQueued(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(retries) == 0 {
+		fmt.Fprintln(out, "No orders are waiting for a status update")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER\tSTATUS\tSOURCE\tQUEUED\tRETRIES\tNEXT RETRY\tLAST ERROR")
+	for _, retry := range retries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			retry.OrderReference, retry.Status, retry.Change.Source,
+			retry.CreatedAt.Format("2006-01-02 15:04:05"), retry.Attempts,
+			retry.NextAttemptAt.Format("2006-01-02 15:04:05"), retry.LastError)
+	}
+	return w.Flush()
+}
+
+// RunExpire expires the pending orders whose payment session ended and lists them
+func RunExpire(ctx context.Context, expiry services.OrderExpiryService, out io.Writer) error {
+	expired, err := expiry.ExpireDue(ctx)
+	for _, reference := range expired {
+		fmt.Fprintf(out, "Order %s expired\n", reference)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to expire orders: %w", err)
+	}
+
+	fmt.Fprintf(out, "Expired %d orders\n", len(expired))
+	return nil
+}
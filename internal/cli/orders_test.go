@@ -0,0 +1,489 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// mockPaymentService is a mock implementation of PaymentService for testing
+type mockPaymentService struct {
+	services.PaymentService
+	refundOrderFunc  func(string, int64) (*services.ModificationResult, error)
+	captureOrderFunc func(string) (*services.ModificationResult, error)
+	cancelOrderFunc  func(string) (*services.ModificationResult, error)
+}
+
+func (m *mockPaymentService) RefundOrder(ctx context.Context, reference string, amount int64) (*services.ModificationResult, error) {
+	return m.refundOrderFunc(reference, amount)
+}
+
+func (m *mockPaymentService) CaptureOrder(ctx context.Context, reference string) (*services.ModificationResult, error) {
+	return m.captureOrderFunc(reference)
+}
+
+func (m *mockPaymentService) CancelOrder(ctx context.Context, reference string) (*services.ModificationResult, error) {
+	return m.cancelOrderFunc(reference)
+}
+
+func TestRunRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		reference    string
+		amount       int64
+		refundError  error
+		wantErr      bool
+		checkContent []string
+	}{
+		{
+			name:         "successful refund",
+			reference:    "ORDER-123",
+			amount:       0,
+			checkContent: []string{"Refund of 1000 USD requested for order ORDER-123", "REFUND-PSP-123", "refund_requested"},
+		},
+		{
+			name:      "missing reference",
+			reference: "",
+			wantErr:   true,
+		},
+		{
+			name:      "negative amount",
+			reference: "ORDER-123",
+			amount:    -1,
+			wantErr:   true,
+		},
+		{
+			name:        "service error",
+			reference:   "ORDER-123",
+			refundError: errors.New("order cannot be refunded"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			paymentService := &mockPaymentService{
+				refundOrderFunc: func(reference string, amount int64) (*services.ModificationResult, error) {
+					if tt.refundError != nil {
+						return nil, tt.refundError
+					}
+					return &services.ModificationResult{
+						Order: &models.Order{
+							Reference: reference,
+							Amount:    1000,
+							Currency:  "USD",
+							Status:    models.OrderStatusRefundRequested,
+						},
+						Amount:       1000,
+						PSPReference: "REFUND-PSP-123",
+						Status:       "received",
+					}, nil
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunRefund(context.Background(), paymentService, tt.reference, tt.amount, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunRefund() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunCapture(t *testing.T) {
+	tests := []struct {
+		name         string
+		reference    string
+		captureError error
+		wantErr      bool
+		checkContent []string
+	}{
+		{
+			name:         "successful capture",
+			reference:    "ORDER-123",
+			checkContent: []string{"Capture of 1000 USD requested for order ORDER-123", "CAPTURE-PSP-123", "capture_requested"},
+		},
+		{
+			name:      "missing reference",
+			reference: "",
+			wantErr:   true,
+		},
+		{
+			name:         "service error",
+			reference:    "ORDER-123",
+			captureError: errors.New("order cannot be captured"),
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			paymentService := &mockPaymentService{
+				captureOrderFunc: func(reference string) (*services.ModificationResult, error) {
+					if tt.captureError != nil {
+						return nil, tt.captureError
+					}
+					return &services.ModificationResult{
+						Order: &models.Order{
+							Reference: reference,
+							Amount:    1000,
+							Currency:  "USD",
+							Status:    models.OrderStatusCaptureRequested,
+						},
+						Amount:       1000,
+						PSPReference: "CAPTURE-PSP-123",
+						Status:       "received",
+					}, nil
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunCapture(context.Background(), paymentService, tt.reference, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunCapture() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	tests := []struct {
+		name         string
+		reference    string
+		pspReference string
+		status       models.OrderStatus
+		cancelError  error
+		wantErr      bool
+		checkContent []string
+	}{
+		{
+			name:         "cancel authorized order",
+			reference:    "ORDER-123",
+			pspReference: "CANCEL-PSP-123",
+			status:       models.OrderStatusCancelRequested,
+			checkContent: []string{"Cancellation of the 1000 USD payment requested for order ORDER-123", "CANCEL-PSP-123", "cancel_requested"},
+		},
+		{
+			name:         "cancel pending order",
+			reference:    "ORDER-123",
+			status:       models.OrderStatusCancelled,
+			checkContent: []string{"Order status: cancelled"},
+		},
+		{
+			name:      "missing reference",
+			reference: "",
+			wantErr:   true,
+		},
+		{
+			name:        "service error",
+			reference:   "ORDER-123",
+			cancelError: errors.New("order cannot be cancelled"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			paymentService := &mockPaymentService{
+				cancelOrderFunc: func(reference string) (*services.ModificationResult, error) {
+					if tt.cancelError != nil {
+						return nil, tt.cancelError
+					}
+					return &services.ModificationResult{
+						Order: &models.Order{
+							Reference: reference,
+							Amount:    1000,
+							Currency:  "USD",
+							Status:    tt.status,
+						},
+						Amount:       1000,
+						PSPReference: tt.pspReference,
+						Status:       "received",
+					}, nil
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunCancel(context.Background(), paymentService, tt.reference, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunCancel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
+
+// mockOrderService is a mock implementation of OrderService for testing
+type mockOrderService struct {
+	services.OrderService
+	getOrderHistoryFunc func(string) ([]models.OrderHistoryEvent, error)
+}
+
+func (m *mockOrderService) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	return m.getOrderHistoryFunc(reference)
+}
+
+func TestRunHistory(t *testing.T) {
+	createdAt := time.Date(2024, 1, 31, 12, 0, 5, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		reference    string
+		events       []models.OrderHistoryEvent
+		historyError error
+		wantErr      bool
+		checkContent []string
+	}{
+		{
+			name:      "order with status changes",
+			reference: "ORDER-123",
+			events: []models.OrderHistoryEvent{
+				{PreviousStatus: models.OrderStatusPending, Status: models.OrderStatusAuthorized, PSPReference: "PSP-123", Source: models.OrderEventSourceWebhook, Actor: "adyen", CreatedAt: createdAt},
+				{PreviousStatus: models.OrderStatusAuthorized, Status: models.OrderStatusRefundRequested, PSPReference: "PSP-123", Source: models.OrderEventSourceAdmin, Actor: "alice", CreatedAt: createdAt.Add(time.Hour)},
+			},
+			checkContent: []string{"SOURCE", "2024-01-31 12:00:05", "pending", "webhook", "adyen", "refund_requested", "admin", "alice", "PSP-123"},
+		},
+		{
+			name:         "order without status changes",
+			reference:    "ORDER-123",
+			events:       []models.OrderHistoryEvent{},
+			checkContent: []string{"Order ORDER-123 has no status changes"},
+		},
+		{
+			name:      "missing reference",
+			reference: "",
+			wantErr:   true,
+		},
+		{
+			name:         "unknown order",
+			reference:    "ORDER-404",
+			historyError: models.ErrOrderNotFound,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			orderService := &mockOrderService{
+				getOrderHistoryFunc: func(reference string) ([]models.OrderHistoryEvent, error) {
+					return tt.events, tt.historyError
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunHistory(context.Background(), orderService, tt.reference, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.historyError != nil && !errors.Is(err, tt.historyError) {
+				t.Errorf("Expected error wrapping %v, got %v", tt.historyError, err)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
+
+// mockStatusRetryService is a mock implementation of StatusRetryService for testing
+type mockStatusRetryService struct {
+	services.StatusRetryService
+	retryDueFunc   func() (int, error)
+	listQueuedFunc func() ([]models.StatusUpdateRetry, error)
+}
+
+func (m *mockStatusRetryService) RetryDue(ctx context.Context) (int, error) {
+	return m.retryDueFunc()
+}
+
+func (m *mockStatusRetryService) ListQueued(ctx context.Context) ([]models.StatusUpdateRetry, error) {
+	return m.listQueuedFunc()
+}
+
+func TestRunStuck(t *testing.T) {
+	queuedAt := time.Date(2024, 1, 31, 12, 0, 5, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		retry        bool
+		retries      []models.StatusUpdateRetry
+		retryError   error
+		listError    error
+		wantErr      bool
+		expectRetry  bool
+		checkContent []string
+	}{
+		{
+			name: "orders waiting for an update",
+			retries: []models.StatusUpdateRetry{
+				{
+					OrderReference: "ORDER-123",
+					Status:         models.OrderStatusAuthorized,
+					Change:         models.OrderChange{Source: models.OrderEventSourceRedirect},
+					Attempts:       3,
+					LastError:      "database unavailable",
+					NextAttemptAt:  queuedAt.Add(time.Hour),
+					CreatedAt:      queuedAt,
+				},
+			},
+			checkContent: []string{"RETRIES", "ORDER-123", "authorized", "redirect", "2024-01-31 12:00:05", "2024-01-31 13:00:05", "database unavailable"},
+		},
+		{
+			name:         "no orders waiting",
+			retries:      []models.StatusUpdateRetry{},
+			checkContent: []string{"No orders are waiting for a status update"},
+		},
+		{
+			name:         "retry due updates first",
+			retry:        true,
+			retries:      []models.StatusUpdateRetry{},
+			expectRetry:  true,
+			checkContent: []string{"Stored 2 queued status updates", "No orders are waiting"},
+		},
+		{
+			name:        "retry fails",
+			retry:       true,
+			retryError:  errors.New("database unavailable"),
+			expectRetry: true,
+			wantErr:     true,
+		},
+		{
+			name:      "listing fails",
+			listError: errors.New("database unavailable"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			retried := false
+			statusRetries := &mockStatusRetryService{
+				retryDueFunc: func() (int, error) {
+					retried = true
+					return 2, tt.retryError
+				},
+				listQueuedFunc: func() ([]models.StatusUpdateRetry, error) {
+					return tt.retries, tt.listError
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunStuck(context.Background(), statusRetries, tt.retry, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunStuck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if retried != tt.expectRetry {
+				t.Errorf("Expected retry %v, got %v", tt.expectRetry, retried)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
+
+// mockOrderExpiryService is a mock implementation of OrderExpiryService for testing
+type mockOrderExpiryService struct {
+	services.OrderExpiryService
+	expireDueFunc func() ([]string, error)
+}
+
+func (m *mockOrderExpiryService) ExpireDue(ctx context.Context) ([]string, error) {
+	return m.expireDueFunc()
+}
+
+func TestRunExpire(t *testing.T) {
+	tests := []struct {
+		name         string
+		expired      []string
+		expireError  error
+		wantErr      bool
+		checkContent []string
+	}{
+		{
+			name:         "orders expired",
+			expired:      []string{"ORDER-123", "ORDER-456"},
+			checkContent: []string{"Order ORDER-123 expired", "Order ORDER-456 expired", "Expired 2 orders"},
+		},
+		{
+			name:         "nothing to expire",
+			checkContent: []string{"Expired 0 orders"},
+		},
+		{
+			name:         "expiry stops on an error",
+			expired:      []string{"ORDER-123"},
+			expireError:  errors.New("database unavailable"),
+			wantErr:      true,
+			checkContent: []string{"Order ORDER-123 expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			expiry := &mockOrderExpiryService{
+				expireDueFunc: func() ([]string, error) {
+					return tt.expired, tt.expireError
+				},
+			}
+			var out bytes.Buffer
+
+			// WHEN
+			err := RunExpire(context.Background(), expiry, &out)
+
+			// THEN
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunExpire() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, content := range tt.checkContent {
+				if !strings.Contains(out.String(), content) {
+					t.Errorf("Expected output to contain '%s', got '%s'", content, out.String())
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
@@ -12,21 +13,36 @@ import (
 	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
-	"github.com/adyen/ecommerce/internal/handlers"
-	"github.com/adyen/ecommerce/internal/repository"
+	"github.com/adyen/ecommerce/internal/services"
 )
 
 // ServerDependencies holds all dependencies needed for the server
 type ServerDependencies struct {
-	OrderRepo           *repository.OrderRepository
+	OrderRepo           services.OrderRepository
+	ProductRepo         services.ProductRepository
+	CartRepo            services.CartRepository
+	StatusRetryRepo     services.StatusRetryRepository
+	OrderEvents         services.OrderEventBus
+	StatusRetries       services.StatusRetryService
+	StatusRetryInterval time.Duration
+	OrderExpiry         services.OrderExpiryService
+	OrderExpiryInterval time.Duration
 	ServerConfig        config.ServerConfig
 	AdyenConfig         *config.AdyenConfig
-	Product             handlers.Product
+	CatalogHandler      http.Handler
 	ProductHandler      http.Handler
+	CartHandler         http.Handler
+	CartAddHandler      http.Handler
+	CartUpdateHandler   http.Handler
+	CartRemoveHandler   http.Handler
 	CheckoutHandler     http.Handler
 	SessionHandler      http.Handler
 	ConfirmationHandler http.Handler
+	ProcessingHandler   http.Handler
 	FailureHandler      http.Handler
+	OrderStatusHandler  http.Handler
+	OrderEventsHandler  http.Handler
+	WebhookHandler      http.Handler
 }
 
 // RunServe starts the e-commerce web server
@@ -44,15 +60,70 @@ func RunServe(deps ServerDependencies) error {
 func StartServer(deps ServerDependencies) (net.Listener, *http.Server, error) {
 	// Set up routes
 	mux := http.NewServeMux()
-	mux.Handle("/", deps.ProductHandler)
+	mux.Handle("/", deps.CatalogHandler)
+	mux.Handle("/products/{sku}", deps.ProductHandler)
+	mux.Handle("/cart", deps.CartHandler)
+	mux.Handle("/cart/items", deps.CartAddHandler)
+	mux.Handle("/cart/items/{sku}", deps.CartUpdateHandler)
+	mux.Handle("/cart/items/{sku}/remove", deps.CartRemoveHandler)
 	mux.Handle("/checkout", deps.CheckoutHandler)
 	mux.Handle("/api/sessions", deps.SessionHandler)
 	mux.Handle("/order/confirmation", deps.ConfirmationHandler)
+	mux.Handle("/order/processing", deps.ProcessingHandler)
 	mux.Handle("/order/failed", deps.FailureHandler)
+	mux.Handle("/api/orders/{reference}/status", deps.OrderStatusHandler)
+	mux.Handle("/api/orders/{reference}/events", deps.OrderEventsHandler)
+	mux.Handle("/api/webhooks/adyen", deps.WebhookHandler)
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
+	listener, server, err := startHTTPServer(mux, ":"+deps.ServerConfig.Port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Metrics expose process internals, so they are only served on the admin address
+	if deps.ServerConfig.AdminAddr != "" {
+		_, adminServer, err := StartAdminServer(deps.ServerConfig.AdminAddr)
+		if err != nil {
+			server.Close()
+			return nil, nil, err
+		}
+		server.RegisterOnShutdown(func() { adminServer.Close() })
+	}
+
+	// Open event streams would otherwise hold up a graceful shutdown until it times out
+	if deps.OrderEvents != nil {
+		server.RegisterOnShutdown(deps.OrderEvents.Close)
+	}
+
+	// Retry order status updates that failed to be stored until the server shuts down
+	if deps.StatusRetries != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go deps.StatusRetries.Run(ctx, deps.StatusRetryInterval)
+		server.RegisterOnShutdown(cancel)
+	}
+
+	// Expire pending orders whose payment session ended until the server shuts down
+	if deps.OrderExpiry != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go deps.OrderExpiry.Run(ctx, deps.OrderExpiryInterval)
+		server.RegisterOnShutdown(cancel)
+	}
+
+	return listener, server, nil
+}
+
+// StartAdminServer serves the expvar metrics on /debug/vars at addr, e.g. 127.0.0.1:9091
+func StartAdminServer(addr string) (net.Listener, *http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return startHTTPServer(mux, addr)
+}
+
+// startHTTPServer listens on addr and serves handler in the background
+func startHTTPServer(handler http.Handler, addr string) (net.Listener, *http.Server, error) {
 	// Create listener
-	addr := fmt.Sprintf(":%s", deps.ServerConfig.Port)
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create listener: %w", err)
@@ -60,7 +131,7 @@ func StartServer(deps ServerDependencies) (net.Listener, *http.Server, error) {
 
 	// Create HTTP server
 	server := &http.Server{
-		Handler: mux,
+		Handler: handler,
 	}
 
 	// Start server in a goroutine
@@ -9,12 +9,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
-	"github.com/adyen/ecommerce/internal/handlers"
+	"github.com/adyen/ecommerce/internal/services"
 )
 
 // errorListener wraps a net.Listener and returns an error when Close() is called
@@ -49,12 +50,20 @@ func createTestDeps(port string) ServerDependencies {
 		ServerConfig:        config.ServerConfig{Port: port},
 		OrderRepo:           nil,
 		AdyenConfig:         &config.AdyenConfig{},
-		Product:             handlers.Product{},
+		CatalogHandler:      mockHandler("catalog"),
 		ProductHandler:      mockHandler("product"),
+		CartHandler:         mockHandler("cart"),
+		CartAddHandler:      mockHandler("cart-add"),
+		CartUpdateHandler:   mockHandler("cart-update"),
+		CartRemoveHandler:   mockHandler("cart-remove"),
 		CheckoutHandler:     mockHandler("checkout"),
 		SessionHandler:      mockHandler("session"),
 		ConfirmationHandler: mockHandler("confirmation"),
+		ProcessingHandler:   mockHandler("processing"),
 		FailureHandler:      mockHandler("failure"),
+		OrderStatusHandler:  mockHandler("order-status"),
+		OrderEventsHandler:  mockHandler("order-events"),
+		WebhookHandler:      mockHandler("webhook"),
 	}
 }
 
@@ -103,8 +112,8 @@ func TestStartServer_SuccessfulStartup(t *testing.T) {
 	if status != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", status)
 	}
-	if body != "product" {
-		t.Errorf("Expected 'product', got '%s'", body)
+	if body != "catalog" {
+		t.Errorf("Expected 'catalog', got '%s'", body)
 	}
 }
 
@@ -151,11 +160,20 @@ func TestStartServer_PortAlreadyInUse(t *testing.T) {
 func TestStartServer_AllRoutesWork(t *testing.T) {
 	// GIVEN
 	deps := createTestDeps("0")
+	deps.CatalogHandler = mockHandler("catalog-response")
 	deps.ProductHandler = mockHandler("product-response")
+	deps.CartHandler = mockHandler("cart-response")
+	deps.CartAddHandler = mockHandler("cart-add-response")
+	deps.CartUpdateHandler = mockHandler("cart-update-response")
+	deps.CartRemoveHandler = mockHandler("cart-remove-response")
 	deps.CheckoutHandler = mockHandler("checkout-response")
 	deps.SessionHandler = mockHandler("session-response")
 	deps.ConfirmationHandler = mockHandler("confirmation-response")
+	deps.ProcessingHandler = mockHandler("processing-response")
 	deps.FailureHandler = mockHandler("failure-response")
+	deps.OrderStatusHandler = mockHandler("order-status-response")
+	deps.OrderEventsHandler = mockHandler("order-events-response")
+	deps.WebhookHandler = mockHandler("webhook-response")
 
 	// WHEN
 	listener, server, port := startTestServer(t, deps)
@@ -172,11 +190,20 @@ func TestStartServer_AllRoutesWork(t *testing.T) {
 		path     string
 		expected string
 	}{
-		{"/", "product-response"},
+		{"/", "catalog-response"},
+		{"/products/widget-001", "product-response"},
+		{"/cart", "cart-response"},
+		{"/cart/items", "cart-add-response"},
+		{"/cart/items/widget-001", "cart-update-response"},
+		{"/cart/items/widget-001/remove", "cart-remove-response"},
 		{"/checkout", "checkout-response"},
 		{"/api/sessions", "session-response"},
 		{"/order/confirmation", "confirmation-response"},
+		{"/order/processing", "processing-response"},
 		{"/order/failed", "failure-response"},
+		{"/api/orders/ORDER-123/status", "order-status-response"},
+		{"/api/orders/ORDER-123/events", "order-events-response"},
+		{"/api/webhooks/adyen", "webhook-response"},
 	}
 
 	for _, tc := range testCases {
@@ -224,14 +251,174 @@ func TestStartServer_GracefulShutdown(t *testing.T) {
 	}
 }
 
+func TestStartServer_ShutdownClosesOrderEvents(t *testing.T) {
+	// GIVEN
+	deps := createTestDeps("0")
+	deps.OrderEvents = services.NewOrderEventBus()
+	events, unsubscribe := deps.OrderEvents.Subscribe("ORDER-123")
+	defer unsubscribe()
+
+	listener, server, _ := startTestServer(t, deps)
+	defer listener.Close()
+
+	// WHEN
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shutdown server gracefully: %v", err)
+	}
+
+	// THEN
+	// Shutdown hooks run in their own goroutine
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected the event subscription to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the event subscription to be closed on shutdown")
+	}
+}
+
+func TestStartServer_DoesNotPublishMetrics(t *testing.T) {
+	// GIVEN
+	deps := createTestDeps("0")
+	listener, server, port := startTestServer(t, deps)
+	defer listener.Close()
+	defer server.Close()
+
+	// WHEN
+	body, _ := httpGet(t, fmt.Sprintf("http://localhost:%d/debug/vars", port))
+
+	// THEN
+	if strings.Contains(body, `"order_status_retries"`) || strings.Contains(body, `"cmdline"`) {
+		t.Errorf("Expected no metrics on the storefront, got %s", body)
+	}
+}
+
+func TestStartAdminServer_PublishesMetrics(t *testing.T) {
+	// GIVEN
+	listener, server, err := StartAdminServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start admin server: %v", err)
+	}
+	defer listener.Close()
+	defer server.Close()
+
+	// WHEN
+	body, status := httpGet(t, fmt.Sprintf("http://%s/debug/vars", listener.Addr()))
+
+	// THEN
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if !strings.Contains(body, `"order_status_retries"`) {
+		t.Errorf("Expected the order status retry metrics, got %s", body)
+	}
+}
+
+func TestStartServer_AdminAddressInUse(t *testing.T) {
+	// GIVEN
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to occupy a port: %v", err)
+	}
+	defer occupied.Close()
+
+	deps := createTestDeps("0")
+	deps.ServerConfig.AdminAddr = occupied.Addr().String()
+
+	// WHEN
+	listener, server, err := StartServer(deps)
+
+	// THEN
+	if err == nil {
+		listener.Close()
+		server.Close()
+		t.Fatal("Expected an error when the admin address is in use")
+	}
+}
+
+func TestStartServer_ShutdownStopsStatusRetries(t *testing.T) {
+	// GIVEN
+	deps := createTestDeps("0")
+	stopped := make(chan struct{})
+	deps.StatusRetries = &stubStatusRetryService{stopped: stopped}
+	deps.StatusRetryInterval = time.Millisecond
+
+	listener, server, _ := startTestServer(t, deps)
+	defer listener.Close()
+
+	// WHEN
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shutdown server gracefully: %v", err)
+	}
+
+	// THEN
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Expected the status retries to stop on shutdown")
+	}
+}
+
+// stubStatusRetryService runs until its context is cancelled, then closes stopped
+type stubStatusRetryService struct {
+	services.StatusRetryService
+	stopped chan struct{}
+}
+
+func (s *stubStatusRetryService) Run(ctx context.Context, interval time.Duration) {
+	<-ctx.Done()
+	close(s.stopped)
+}
+
+func TestStartServer_ShutdownStopsOrderExpiry(t *testing.T) {
+	// GIVEN
+	deps := createTestDeps("0")
+	stopped := make(chan struct{})
+	deps.OrderExpiry = &stubOrderExpiryService{stopped: stopped}
+	deps.OrderExpiryInterval = time.Millisecond
+
+	listener, server, _ := startTestServer(t, deps)
+	defer listener.Close()
+
+	// WHEN
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shutdown server gracefully: %v", err)
+	}
+
+	// THEN
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Expected the order expiry to stop on shutdown")
+	}
+}
+
+// stubOrderExpiryService runs until its context is cancelled, then closes stopped
+type stubOrderExpiryService struct {
+	services.OrderExpiryService
+	stopped chan struct{}
+}
+
+func (s *stubOrderExpiryService) Run(ctx context.Context, interval time.Duration) {
+	<-ctx.Done()
+	close(s.stopped)
+}
+
 func TestStartServer_ConcurrentServers(t *testing.T) {
 	// GIVEN
 	// Test that multiple servers can start on different ports without conflicts
 	deps1 := createTestDeps("0")
-	deps1.ProductHandler = mockHandler("server1")
+	deps1.CatalogHandler = mockHandler("server1")
 
 	deps2 := createTestDeps("0")
-	deps2.ProductHandler = mockHandler("server2")
+	deps2.CatalogHandler = mockHandler("server2")
 
 	// WHEN
 	listener1, server1, port1 := startTestServer(t, deps1)
@@ -279,7 +466,7 @@ func TestStartServer_ShutdownWithActiveConnections(t *testing.T) {
 	})
 
 	deps := createTestDeps("0")
-	deps.ProductHandler = slowHandler
+	deps.CatalogHandler = slowHandler
 
 	listener, server, port := startTestServer(t, deps)
 	defer listener.Close()
@@ -324,11 +511,20 @@ func TestStartServer_ShutdownWithActiveConnections(t *testing.T) {
 func BenchmarkStartServer(b *testing.B) {
 	deps := ServerDependencies{
 		ServerConfig:        config.ServerConfig{Port: "0"},
+		CatalogHandler:      mockHandler("catalog"),
 		ProductHandler:      mockHandler("product"),
+		CartHandler:         mockHandler("cart"),
+		CartAddHandler:      mockHandler("cart-add"),
+		CartUpdateHandler:   mockHandler("cart-update"),
+		CartRemoveHandler:   mockHandler("cart-remove"),
 		CheckoutHandler:     mockHandler("checkout"),
 		SessionHandler:      mockHandler("session"),
 		ConfirmationHandler: mockHandler("confirmation"),
+		ProcessingHandler:   mockHandler("processing"),
 		FailureHandler:      mockHandler("failure"),
+		OrderStatusHandler:  mockHandler("order-status"),
+		OrderEventsHandler:  mockHandler("order-events"),
+		WebhookHandler:      mockHandler("webhook"),
 	}
 
 	b.ResetTimer()
@@ -408,7 +604,7 @@ func TestWaitForShutdown_WithActiveRequests(t *testing.T) {
 	})
 
 	deps := createTestDeps("0")
-	deps.ProductHandler = slowHandler
+	deps.CatalogHandler = slowHandler
 
 	listener, server, port := startTestServer(t, deps)
 	defer listener.Close()
@@ -455,6 +651,58 @@ func TestWaitForShutdown_WithActiveRequests(t *testing.T) {
 	}
 }
 
+func TestWaitForShutdown_CancelsInFlightRequestContext(t *testing.T) {
+	// GIVEN - a handler that waits on its request context like a hung Adyen call would
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	hungHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+		case <-time.After(10 * time.Second):
+			cancelled <- nil
+		}
+	})
+
+	deps := createTestDeps("0")
+	deps.CatalogHandler = hungHandler
+
+	listener, server, port := startTestServer(t, deps)
+	defer listener.Close()
+
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/", port))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	shutdown := make(chan os.Signal, 1)
+
+	// WHEN - graceful shutdown times out and the server is closed
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- WaitForShutdownWithTimeout(server, shutdown, 50*time.Millisecond)
+	}()
+	shutdown <- syscall.SIGTERM
+
+	// THEN - the in-flight request context is cancelled
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected request context to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request context was not cancelled on shutdown")
+	}
+
+	if err := <-errCh; err != nil {
+		t.Errorf("Expected nil error, got: %v", err)
+	}
+}
+
 func TestRunServe_FullIntegration(t *testing.T) {
 	// GIVEN
 	deps := createTestDeps("0")
@@ -562,7 +810,7 @@ func TestWaitForShutdown_ShutdownAndCloseFailure(t *testing.T) {
 	})
 
 	deps := createTestDeps("0")
-	deps.ProductHandler = blockingHandler
+	deps.CatalogHandler = blockingHandler
 
 	// Create a real listener first
 	realListener, err := net.Listen("tcp", ":0")
@@ -575,7 +823,7 @@ func TestWaitForShutdown_ShutdownAndCloseFailure(t *testing.T) {
 
 	// Create the server manually
 	mux := http.NewServeMux()
-	mux.Handle("/", deps.ProductHandler)
+	mux.Handle("/", deps.CatalogHandler)
 	mux.Handle("/checkout", deps.CheckoutHandler)
 	mux.Handle("/api/sessions", deps.SessionHandler)
 	mux.Handle("/order/confirmation", deps.ConfirmationHandler)
@@ -2,7 +2,45 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capture modes supported for Adyen payments
+const (
+	CaptureModeImmediate = "immediate"
+	CaptureModeManual    = "manual"
+)
+
+// Adyen environments
+const (
+	EnvironmentTest = "TEST"
+	EnvironmentLive = "LIVE"
+)
+
+// AdyenWebVersion is the version of the Adyen Web SDK that renders Drop-in
+const AdyenWebVersion = "5.66.0"
+
+// DefaultAPIVersion is the Checkout API version used unless ADYEN_API_VERSION is set
+const DefaultAPIVersion = "v71"
+
+// DefaultAdyenTimeout bounds each Adyen API call unless ADYEN_TIMEOUT is set
+const DefaultAdyenTimeout = 10 * time.Second
+
+// Retry defaults for transient Adyen failures, overridden by ADYEN_MAX_RETRIES and ADYEN_RETRY_BACKOFF
+const (
+	DefaultAdyenMaxRetries   = 2
+	DefaultAdyenRetryBackoff = 200 * time.Millisecond
+	maxAdyenRetries          = 10
+)
+
+var (
+	liveURLPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+	apiVersionPattern    = regexp.MustCompile(`^v[0-9]+$`)
 )
 
 // AdyenConfig holds configuration for Adyen integration
@@ -11,6 +49,21 @@ type AdyenConfig struct {
 	ClientKey       string
 	MerchantAccount string
 	Environment     string
+	HMACKey         string
+	CaptureMode     string
+	BaseURL         string
+	LiveURLPrefix   string
+	APIVersion      string
+	Timeout         time.Duration
+	// MaxRetries is the number of extra attempts for a transient failure
+	MaxRetries int
+	// RetryBackoff is the base delay before the first retry, doubled on each further one
+	RetryBackoff time.Duration
+	// PaymentMethods limits the payment methods offered, globally and per country or product
+	PaymentMethods PaymentMethodsConfig
+	// Currencies are the currencies shoppers pay in per country, which must be the currency
+	// the catalog prices are in
+	Currencies CountryCurrencies
 }
 
 // LoadAdyenConfig loads Adyen configuration from environment variables
@@ -20,6 +73,11 @@ func LoadAdyenConfig() (*AdyenConfig, error) {
 		ClientKey:       os.Getenv("ADYEN_CLIENT_KEY"),
 		MerchantAccount: os.Getenv("ADYEN_MERCHANT_ACCOUNT"),
 		Environment:     os.Getenv("ADYEN_ENVIRONMENT"),
+		HMACKey:         os.Getenv("ADYEN_HMAC_KEY"),
+		CaptureMode:     os.Getenv("ADYEN_CAPTURE_MODE"),
+		BaseURL:         strings.TrimRight(os.Getenv("ADYEN_BASE_URL"), "/"),
+		LiveURLPrefix:   os.Getenv("ADYEN_LIVE_URL_PREFIX"),
+		APIVersion:      os.Getenv("ADYEN_API_VERSION"),
 	}
 
 	// Validate required fields
@@ -32,9 +90,117 @@ func LoadAdyenConfig() (*AdyenConfig, error) {
 	if config.MerchantAccount == "" {
 		return nil, fmt.Errorf("ADYEN_MERCHANT_ACCOUNT is required")
 	}
-	if config.Environment == "" {
-		config.Environment = "TEST" // Default to TEST environment
+	switch config.Environment {
+	case "":
+		config.Environment = EnvironmentTest // Default to TEST environment
+	case EnvironmentTest, EnvironmentLive:
+	default:
+		return nil, fmt.Errorf("ADYEN_ENVIRONMENT must be %q or %q", EnvironmentTest, EnvironmentLive)
+	}
+	switch config.CaptureMode {
+	case "":
+		config.CaptureMode = CaptureModeImmediate // Default to capturing on authorisation
+	case CaptureModeImmediate, CaptureModeManual:
+	default:
+		return nil, fmt.Errorf("ADYEN_CAPTURE_MODE must be %q or %q", CaptureModeImmediate, CaptureModeManual)
+	}
+
+	if config.BaseURL != "" {
+		if u, err := url.Parse(config.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("ADYEN_BASE_URL must be an absolute http(s) URL")
+		}
+	}
+	if config.LiveURLPrefix != "" && !liveURLPrefixPattern.MatchString(config.LiveURLPrefix) {
+		return nil, fmt.Errorf("ADYEN_LIVE_URL_PREFIX must only contain letters, digits and single hyphens")
+	}
+	// LIVE requests must go to the merchant's own endpoint, unless a base URL overrides it
+	if config.Environment == EnvironmentLive && config.BaseURL == "" && config.LiveURLPrefix == "" {
+		return nil, fmt.Errorf("ADYEN_LIVE_URL_PREFIX is required when ADYEN_ENVIRONMENT is %s", EnvironmentLive)
+	}
+
+	timeout, err := parseTimeout(os.Getenv, "ADYEN_TIMEOUT", DefaultAdyenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = timeout
+
+	config.MaxRetries = DefaultAdyenMaxRetries
+	if value := os.Getenv("ADYEN_MAX_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 || retries > maxAdyenRetries {
+			return nil, fmt.Errorf("ADYEN_MAX_RETRIES must be a number between 0 and %d", maxAdyenRetries)
+		}
+		config.MaxRetries = retries
+	}
+	backoff, err := parseTimeout(os.Getenv, "ADYEN_RETRY_BACKOFF", DefaultAdyenRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	config.RetryBackoff = backoff
+
+	paymentMethods, err := loadPaymentMethods(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	config.PaymentMethods = paymentMethods
+
+	currencies, err := loadCountryCurrencies(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	config.Currencies = currencies
+
+	if config.APIVersion == "" {
+		config.APIVersion = DefaultAPIVersion
+	}
+	if !apiVersionPattern.MatchString(config.APIVersion) {
+		return nil, fmt.Errorf("ADYEN_API_VERSION must look like %q", DefaultAPIVersion)
 	}
 
 	return &config, nil
 }
+
+// CheckoutURL returns the versioned Checkout API base URL, e.g. https://checkout-test.adyen.com/v71.
+// A configured base URL takes precedence; LIVE uses the merchant-specific endpoint.
+func (c *AdyenConfig) CheckoutURL() string {
+	version := c.APIVersion
+	if version == "" {
+		version = DefaultAPIVersion
+	}
+
+	switch {
+	case c.BaseURL != "":
+		return c.BaseURL + "/" + version
+	case c.Environment == EnvironmentLive:
+		return "https://" + c.LiveURLPrefix + "-checkout-live.adyenpayments.com/checkout/" + version
+	default:
+		return "https://checkout-test.adyen.com/" + version
+	}
+}
+
+// ClientEnvironment returns the environment Drop-in runs in, "test" or "live"
+func (c *AdyenConfig) ClientEnvironment() string {
+	if c.Environment == EnvironmentLive {
+		return "live"
+	}
+	return "test"
+}
+
+// SDKURL returns where the Adyen Web SDK files for the environment are served, e.g.
+// https://checkoutshopper-test.adyen.com/checkoutshopper/sdk/5.66.0
+func (c *AdyenConfig) SDKURL() string {
+	return "https://checkoutshopper-" + c.ClientEnvironment() + ".adyen.com/checkoutshopper/sdk/" + AdyenWebVersion
+}
+
+// RequestTimeout returns the deadline for a single Adyen API call
+func (c *AdyenConfig) RequestTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultAdyenTimeout
+	}
+	return c.Timeout
+}
+
+// IsManualCapture returns true if payments must be captured explicitly after authorisation
+func (c *AdyenConfig) IsManualCapture() bool {
+	return c.CaptureMode == CaptureModeManual
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// setAdyenEnv sets the required Adyen variables plus the given overrides
+func setAdyenEnv(t *testing.T, overrides map[string]string) {
+	t.Helper()
+
+	env := map[string]string{
+		"ADYEN_API_KEY":          "test-api-key",
+		"ADYEN_CLIENT_KEY":       "test-client-key",
+		"ADYEN_MERCHANT_ACCOUNT": "TestMerchant",
+		"ADYEN_ENVIRONMENT":      "",
+		"ADYEN_CAPTURE_MODE":     "",
+		"ADYEN_BASE_URL":         "",
+		"ADYEN_LIVE_URL_PREFIX":  "",
+		"ADYEN_API_VERSION":      "",
+		"ADYEN_TIMEOUT":          "",
+		"ADYEN_MAX_RETRIES":      "",
+		"ADYEN_RETRY_BACKOFF":    "",
+
+		"ADYEN_COUNTRY_CURRENCIES": "",
+
+		"ADYEN_ALLOWED_PAYMENT_METHODS":         "",
+		"ADYEN_BLOCKED_PAYMENT_METHODS":         "",
+		"ADYEN_COUNTRY_ALLOWED_PAYMENT_METHODS": "",
+		"ADYEN_COUNTRY_BLOCKED_PAYMENT_METHODS": "",
+		"ADYEN_PRODUCT_ALLOWED_PAYMENT_METHODS": "",
+		"ADYEN_PRODUCT_BLOCKED_PAYMENT_METHODS": "",
+	}
+	for key, value := range overrides {
+		env[key] = value
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadAdyenConfig_CheckoutURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expectedURL string
+	}{
+		{
+			name:        "test environment by default",
+			expectedURL: "https://checkout-test.adyen.com/v71",
+		},
+		{
+			name:        "live environment uses the merchant prefix",
+			env:         map[string]string{"ADYEN_ENVIRONMENT": "LIVE", "ADYEN_LIVE_URL_PREFIX": "1797a841fbb37ca7-AdyenDemo"},
+			expectedURL: "https://1797a841fbb37ca7-AdyenDemo-checkout-live.adyenpayments.com/checkout/v71",
+		},
+		{
+			name:        "custom API version",
+			env:         map[string]string{"ADYEN_API_VERSION": "v70"},
+			expectedURL: "https://checkout-test.adyen.com/v70",
+		},
+		{
+			name:        "base URL overrides the environment",
+			env:         map[string]string{"ADYEN_ENVIRONMENT": "LIVE", "ADYEN_BASE_URL": "http://localhost:8081/"},
+			expectedURL: "http://localhost:8081/v71",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAdyenEnv(t, tt.env)
+
+			cfg, err := LoadAdyenConfig()
+			if err != nil {
+				t.Fatalf("LoadAdyenConfig() unexpected error = %v", err)
+			}
+			if got := cfg.CheckoutURL(); got != tt.expectedURL {
+				t.Errorf("CheckoutURL() = %s, want %s", got, tt.expectedURL)
+			}
+		})
+	}
+}
+
+func TestAdyenConfig_ClientEnvironment(t *testing.T) {
+	tests := []struct {
+		environment         string
+		expectedEnvironment string
+		expectedSDKURL      string
+	}{
+		{EnvironmentTest, "test", "https://checkoutshopper-test.adyen.com/checkoutshopper/sdk/" + AdyenWebVersion},
+		{EnvironmentLive, "live", "https://checkoutshopper-live.adyen.com/checkoutshopper/sdk/" + AdyenWebVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.environment, func(t *testing.T) {
+			cfg := &AdyenConfig{Environment: tt.environment}
+			if got := cfg.ClientEnvironment(); got != tt.expectedEnvironment {
+				t.Errorf("ClientEnvironment() = %s, want %s", got, tt.expectedEnvironment)
+			}
+			if got := cfg.SDKURL(); got != tt.expectedSDKURL {
+				t.Errorf("SDKURL() = %s, want %s", got, tt.expectedSDKURL)
+			}
+		})
+	}
+}
+
+func TestLoadAdyenConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expectedError string
+	}{
+		{
+			name:          "missing API key",
+			env:           map[string]string{"ADYEN_API_KEY": ""},
+			expectedError: "ADYEN_API_KEY",
+		},
+		{
+			name:          "unknown environment",
+			env:           map[string]string{"ADYEN_ENVIRONMENT": "PROD"},
+			expectedError: "ADYEN_ENVIRONMENT",
+		},
+		{
+			name:          "live without prefix",
+			env:           map[string]string{"ADYEN_ENVIRONMENT": "LIVE"},
+			expectedError: "ADYEN_LIVE_URL_PREFIX is required",
+		},
+		{
+			name:          "invalid live prefix",
+			env:           map[string]string{"ADYEN_ENVIRONMENT": "LIVE", "ADYEN_LIVE_URL_PREFIX": "evil.example.com/"},
+			expectedError: "ADYEN_LIVE_URL_PREFIX",
+		},
+		{
+			name:          "relative base URL",
+			env:           map[string]string{"ADYEN_BASE_URL": "localhost:8081"},
+			expectedError: "ADYEN_BASE_URL",
+		},
+		{
+			name:          "invalid API version",
+			env:           map[string]string{"ADYEN_API_VERSION": "71"},
+			expectedError: "ADYEN_API_VERSION",
+		},
+		{
+			name:          "unparseable timeout",
+			env:           map[string]string{"ADYEN_TIMEOUT": "soon"},
+			expectedError: "ADYEN_TIMEOUT",
+		},
+		{
+			name:          "non-positive timeout",
+			env:           map[string]string{"ADYEN_TIMEOUT": "0s"},
+			expectedError: "ADYEN_TIMEOUT",
+		},
+		{
+			name:          "negative max retries",
+			env:           map[string]string{"ADYEN_MAX_RETRIES": "-1"},
+			expectedError: "ADYEN_MAX_RETRIES",
+		},
+		{
+			name:          "too many retries",
+			env:           map[string]string{"ADYEN_MAX_RETRIES": "100"},
+			expectedError: "ADYEN_MAX_RETRIES",
+		},
+		{
+			name:          "unparseable retry backoff",
+			env:           map[string]string{"ADYEN_RETRY_BACKOFF": "1"},
+			expectedError: "ADYEN_RETRY_BACKOFF",
+		},
+		{
+			name:          "unknown capture mode",
+			env:           map[string]string{"ADYEN_CAPTURE_MODE": "later"},
+			expectedError: "ADYEN_CAPTURE_MODE",
+		},
+		{
+			name:          "invalid payment method",
+			env:           map[string]string{"ADYEN_ALLOWED_PAYMENT_METHODS": "scheme,Credit Card"},
+			expectedError: "ADYEN_ALLOWED_PAYMENT_METHODS",
+		},
+		{
+			name:          "country entry without methods separator",
+			env:           map[string]string{"ADYEN_COUNTRY_ALLOWED_PAYMENT_METHODS": "NL=ideal"},
+			expectedError: "ADYEN_COUNTRY_ALLOWED_PAYMENT_METHODS",
+		},
+		{
+			name:          "invalid country",
+			env:           map[string]string{"ADYEN_COUNTRY_BLOCKED_PAYMENT_METHODS": "Netherlands:paypal"},
+			expectedError: "ADYEN_COUNTRY_BLOCKED_PAYMENT_METHODS",
+		},
+		{
+			name:          "invalid product payment method",
+			env:           map[string]string{"ADYEN_PRODUCT_BLOCKED_PAYMENT_METHODS": "gift-card-001:pay pal"},
+			expectedError: "ADYEN_PRODUCT_BLOCKED_PAYMENT_METHODS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAdyenEnv(t, tt.env)
+
+			_, err := LoadAdyenConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadAdyenConfig_Timeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "default timeout",
+			expectedTimeout: DefaultAdyenTimeout,
+		},
+		{
+			name:            "custom timeout",
+			env:             map[string]string{"ADYEN_TIMEOUT": "2500ms"},
+			expectedTimeout: 2500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAdyenEnv(t, tt.env)
+
+			cfg, err := LoadAdyenConfig()
+			if err != nil {
+				t.Fatalf("LoadAdyenConfig() unexpected error = %v", err)
+			}
+			if got := cfg.RequestTimeout(); got != tt.expectedTimeout {
+				t.Errorf("RequestTimeout() = %v, want %v", got, tt.expectedTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadAdyenConfig_Retries(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		expectedRetries int
+		expectedBackoff time.Duration
+	}{
+		{
+			name:            "default retries",
+			expectedRetries: DefaultAdyenMaxRetries,
+			expectedBackoff: DefaultAdyenRetryBackoff,
+		},
+		{
+			name:            "custom retries",
+			env:             map[string]string{"ADYEN_MAX_RETRIES": "4", "ADYEN_RETRY_BACKOFF": "1s"},
+			expectedRetries: 4,
+			expectedBackoff: time.Second,
+		},
+		{
+			name:            "retries disabled",
+			env:             map[string]string{"ADYEN_MAX_RETRIES": "0"},
+			expectedRetries: 0,
+			expectedBackoff: DefaultAdyenRetryBackoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAdyenEnv(t, tt.env)
+
+			cfg, err := LoadAdyenConfig()
+			if err != nil {
+				t.Fatalf("LoadAdyenConfig() unexpected error = %v", err)
+			}
+			if cfg.MaxRetries != tt.expectedRetries {
+				t.Errorf("MaxRetries = %d, want %d", cfg.MaxRetries, tt.expectedRetries)
+			}
+			if cfg.RetryBackoff != tt.expectedBackoff {
+				t.Errorf("RetryBackoff = %v, want %v", cfg.RetryBackoff, tt.expectedBackoff)
+			}
+		})
+	}
+}
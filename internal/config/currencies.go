@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// currencyPattern matches ISO 4217 currency codes, e.g. EUR
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CountryCurrencies are the currencies shoppers pay in, by country code, e.g. NL: EUR
+type CountryCurrencies map[string]string
+
+// For returns the currency a shopper in countryCode pays for products priced in
+// priceCurrency. Shoppers in a country without a configured currency pay in the price
+// currency. Prices are not converted, so models.ErrCurrencyNotOffered is returned if the
+// country pays in another currency than the products are priced in.
+func (c CountryCurrencies) For(countryCode, priceCurrency string) (string, error) {
+	currency, ok := c[countryCode]
+	if !ok {
+		return priceCurrency, nil
+	}
+	if currency != priceCurrency {
+		return "", fmt.Errorf("%w: %s pays in %s, prices are in %s", models.ErrCurrencyNotOffered, countryCode, currency, priceCurrency)
+	}
+	return currency, nil
+}
+
+// loadCountryCurrencies loads ADYEN_COUNTRY_CURRENCIES, a semicolon-separated list of
+// countries and their currencies, e.g. NL:EUR;BE:EUR;GB:GBP
+func loadCountryCurrencies(getenv func(string) string) (CountryCurrencies, error) {
+	const name = "ADYEN_COUNTRY_CURRENCIES"
+
+	var currencies CountryCurrencies
+	for _, entry := range strings.Split(getenv(name), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		country, currency, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(country) == "" {
+			return nil, fmt.Errorf("%s: entry %q must look like COUNTRY:CURRENCY", name, entry)
+		}
+		country, err := models.NormalizeCountryCode(strings.TrimSpace(country))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if !currencyPattern.MatchString(currency) {
+			return nil, fmt.Errorf("%s: invalid currency %q for %s, use a code such as EUR", name, currency, country)
+		}
+		if previous, ok := currencies[country]; ok && previous != currency {
+			return nil, fmt.Errorf("%s: %s is given both %s and %s", name, country, previous, currency)
+		}
+
+		if currencies == nil {
+			currencies = make(CountryCurrencies)
+		}
+		currencies[country] = currency
+	}
+	return currencies, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestLoadAdyenConfig_Currencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected CountryCurrencies
+		wantErr  bool
+	}{
+		{name: "none configured", value: ""},
+		{
+			name:     "currencies by country",
+			value:    "nl:eur; BE:EUR;GB:GBP;",
+			expected: CountryCurrencies{"NL": "EUR", "BE": "EUR", "GB": "GBP"},
+		},
+		{name: "missing currency", value: "NL", wantErr: true},
+		{name: "invalid country", value: "NLD:EUR", wantErr: true},
+		{name: "invalid currency", value: "NL:EURO", wantErr: true},
+		{name: "conflicting currencies", value: "NL:EUR;NL:USD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAdyenEnv(t, map[string]string{"ADYEN_COUNTRY_CURRENCIES": tt.value})
+
+			cfg, err := LoadAdyenConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadAdyenConfig() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAdyenConfig() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Currencies, tt.expected) {
+				t.Errorf("Currencies = %v, want %v", cfg.Currencies, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCountryCurrencies_For(t *testing.T) {
+	currencies := CountryCurrencies{"NL": "EUR", "GB": "GBP"}
+
+	tests := []struct {
+		name          string
+		country       string
+		priceCurrency string
+		expected      string
+		wantErr       error
+	}{
+		{name: "country's currency", country: "NL", priceCurrency: "EUR", expected: "EUR"},
+		{name: "price currency without a country currency", country: "US", priceCurrency: "USD", expected: "USD"},
+		{name: "prices in another currency", country: "GB", priceCurrency: "EUR", wantErr: models.ErrCurrencyNotOffered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currency, err := currencies.For(tt.country, tt.priceCurrency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("For() error = %v, want %v", err, tt.wantErr)
+			}
+			if currency != tt.expected {
+				t.Errorf("For() = %q, want %q", currency, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// Order reference generators
+const (
+	ReferenceGeneratorRandom   = "random"
+	ReferenceGeneratorSequence = "sequence"
+)
+
+// DefaultStatusRetryInterval is how often queued order status updates are retried
+const DefaultStatusRetryInterval = 30 * time.Second
+
+// DefaultExpiryInterval is how often abandoned pending orders are expired
+const DefaultExpiryInterval = 5 * time.Minute
+
+// OrderConfig holds configuration for order creation and updates
+type OrderConfig struct {
+	ReferencePrefix    string
+	ReferenceGenerator string
+	// StatusRetryInterval is how often the server retries order status updates that failed to be stored
+	StatusRetryInterval time.Duration
+	// ExpiryInterval is how often the server expires pending orders whose payment session ended
+	ExpiryInterval time.Duration
+}
+
+// LoadOrderConfig loads order configuration from environment variables
+func LoadOrderConfig() (*OrderConfig, error) {
+	config := OrderConfig{
+		ReferencePrefix:    os.Getenv("ORDER_REFERENCE_PREFIX"),
+		ReferenceGenerator: os.Getenv("ORDER_REFERENCE_GENERATOR"),
+	}
+
+	if config.ReferencePrefix == "" {
+		config.ReferencePrefix = models.DefaultReferencePrefix
+	}
+	if err := models.ValidateReferencePrefix(config.ReferencePrefix); err != nil {
+		return nil, fmt.Errorf("ORDER_REFERENCE_PREFIX: %w", err)
+	}
+
+	switch config.ReferenceGenerator {
+	case "":
+		config.ReferenceGenerator = ReferenceGeneratorRandom // Default to random references that need no database round trip
+	case ReferenceGeneratorRandom, ReferenceGeneratorSequence:
+	default:
+		return nil, fmt.Errorf("ORDER_REFERENCE_GENERATOR must be %q or %q", ReferenceGeneratorRandom, ReferenceGeneratorSequence)
+	}
+
+	interval, err := parseTimeout(os.Getenv, "ORDER_STATUS_RETRY_INTERVAL", DefaultStatusRetryInterval)
+	if err != nil {
+		return nil, err
+	}
+	config.StatusRetryInterval = interval
+
+	interval, err = parseTimeout(os.Getenv, "ORDER_EXPIRY_INTERVAL", DefaultExpiryInterval)
+	if err != nil {
+		return nil, err
+	}
+	config.ExpiryInterval = interval
+
+	return &config, nil
+}
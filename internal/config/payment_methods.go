@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// paymentMethodPattern matches Adyen payment method types, e.g. scheme, ideal or klarna_paynow
+var paymentMethodPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// PaymentMethodRules limits the payment methods offered to shoppers
+type PaymentMethodRules struct {
+	// Allowed are the only methods offered; empty offers every method enabled on the
+	// merchant account
+	Allowed []string
+	// Blocked are never offered
+	Blocked []string
+}
+
+// PaymentMethodsConfig holds the payment method rules for all shoppers, and the extra
+// rules for shoppers in a country and for carts holding a product
+type PaymentMethodsConfig struct {
+	PaymentMethodRules
+	Countries map[string]PaymentMethodRules // by country code, e.g. NL
+	Products  map[string]PaymentMethodRules // by product SKU
+}
+
+// For returns the rules for a shopper in countryCode paying for the products with skus.
+// A country's allowed methods replace the global ones, each product's narrow them further
+// and all blocked methods add up. models.ErrNoPaymentMethod is returned if no allowed
+// method is left.
+func (c PaymentMethodsConfig) For(countryCode string, skus []string) (PaymentMethodRules, error) {
+	rules := PaymentMethodRules{
+		Allowed: slices.Clone(c.Allowed),
+		Blocked: slices.Clone(c.Blocked),
+	}
+	restricted := len(rules.Allowed) > 0
+
+	if country, ok := c.Countries[countryCode]; ok {
+		if len(country.Allowed) > 0 {
+			rules.Allowed = slices.Clone(country.Allowed)
+			restricted = true
+		}
+		rules.Blocked = append(rules.Blocked, country.Blocked...)
+	}
+
+	for _, sku := range skus {
+		product, ok := c.Products[sku]
+		if !ok {
+			continue
+		}
+		if len(product.Allowed) > 0 {
+			if restricted {
+				rules.Allowed = slices.DeleteFunc(rules.Allowed, func(method string) bool {
+					return !slices.Contains(product.Allowed, method)
+				})
+			} else {
+				rules.Allowed = slices.Clone(product.Allowed)
+				restricted = true
+			}
+		}
+		rules.Blocked = append(rules.Blocked, product.Blocked...)
+	}
+
+	rules.Blocked = uniqueMethods(rules.Blocked)
+	rules.Allowed = slices.DeleteFunc(uniqueMethods(rules.Allowed), func(method string) bool {
+		return slices.Contains(rules.Blocked, method)
+	})
+	if restricted && len(rules.Allowed) == 0 {
+		return PaymentMethodRules{}, models.ErrNoPaymentMethod
+	}
+	return rules, nil
+}
+
+// loadPaymentMethods loads the payment method rules. The global lists are comma-separated
+// methods, the country and product lists are semicolon-separated keys with their methods,
+// e.g. NL:scheme,ideal;BE:scheme,bcmc.
+func loadPaymentMethods(getenv func(string) string) (PaymentMethodsConfig, error) {
+	var config PaymentMethodsConfig
+	var err error
+
+	if config.Allowed, err = parsePaymentMethods("ADYEN_ALLOWED_PAYMENT_METHODS", getenv("ADYEN_ALLOWED_PAYMENT_METHODS")); err != nil {
+		return PaymentMethodsConfig{}, err
+	}
+	if config.Blocked, err = parsePaymentMethods("ADYEN_BLOCKED_PAYMENT_METHODS", getenv("ADYEN_BLOCKED_PAYMENT_METHODS")); err != nil {
+		return PaymentMethodsConfig{}, err
+	}
+
+	if config.Countries, err = parseKeyedPaymentMethods(getenv, "ADYEN_COUNTRY", models.NormalizeCountryCode); err != nil {
+		return PaymentMethodsConfig{}, err
+	}
+	keepSKU := func(key string) (string, error) { return key, nil }
+	if config.Products, err = parseKeyedPaymentMethods(getenv, "ADYEN_PRODUCT", keepSKU); err != nil {
+		return PaymentMethodsConfig{}, err
+	}
+
+	return config, nil
+}
+
+// parseKeyedPaymentMethods parses the <prefix>_ALLOWED_PAYMENT_METHODS and
+// <prefix>_BLOCKED_PAYMENT_METHODS lists, normalizing each key
+func parseKeyedPaymentMethods(getenv func(string) string, prefix string, normalizeKey func(string) (string, error)) (map[string]PaymentMethodRules, error) {
+	var rules map[string]PaymentMethodRules
+	for _, blocked := range []bool{false, true} {
+		name := prefix + "_ALLOWED_PAYMENT_METHODS"
+		if blocked {
+			name = prefix + "_BLOCKED_PAYMENT_METHODS"
+		}
+
+		for _, entry := range strings.Split(getenv(name), ";") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+
+			key, list, ok := strings.Cut(entry, ":")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("%s: entry %q must look like KEY:method,method", name, entry)
+			}
+			key, err := normalizeKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			methods, err := parsePaymentMethods(name, list)
+			if err != nil {
+				return nil, err
+			}
+
+			if rules == nil {
+				rules = make(map[string]PaymentMethodRules)
+			}
+			rule := rules[key]
+			if blocked {
+				rule.Blocked = append(rule.Blocked, methods...)
+			} else {
+				rule.Allowed = append(rule.Allowed, methods...)
+			}
+			rules[key] = rule
+		}
+	}
+	return rules, nil
+}
+
+// parsePaymentMethods parses a comma-separated list of payment method types
+func parsePaymentMethods(name, value string) ([]string, error) {
+	var methods []string
+	for _, method := range strings.Split(value, ",") {
+		method = strings.TrimSpace(method)
+		if method == "" {
+			continue
+		}
+		if !paymentMethodPattern.MatchString(method) {
+			return nil, fmt.Errorf("%s: invalid payment method %q, use Adyen's type such as scheme or ideal", name, method)
+		}
+		methods = append(methods, method)
+	}
+	return uniqueMethods(methods), nil
+}
+
+// uniqueMethods returns methods without repeats, keeping the first occurrence
+func uniqueMethods(methods []string) []string {
+	var unique []string
+	for _, method := range methods {
+		if !slices.Contains(unique, method) {
+			unique = append(unique, method)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestLoadAdyenConfig_PaymentMethods(t *testing.T) {
+	setAdyenEnv(t, map[string]string{
+		"ADYEN_ALLOWED_PAYMENT_METHODS":         "scheme, ideal,scheme",
+		"ADYEN_BLOCKED_PAYMENT_METHODS":         "paypal",
+		"ADYEN_COUNTRY_ALLOWED_PAYMENT_METHODS": "nl:scheme,ideal; BE:scheme,bcmc",
+		"ADYEN_COUNTRY_BLOCKED_PAYMENT_METHODS": "NL:klarna",
+		"ADYEN_PRODUCT_BLOCKED_PAYMENT_METHODS": "gift-card-001:klarna,klarna_paynow",
+	})
+
+	cfg, err := LoadAdyenConfig()
+	if err != nil {
+		t.Fatalf("LoadAdyenConfig() unexpected error = %v", err)
+	}
+
+	expected := PaymentMethodsConfig{
+		PaymentMethodRules: PaymentMethodRules{Allowed: []string{"scheme", "ideal"}, Blocked: []string{"paypal"}},
+		Countries: map[string]PaymentMethodRules{
+			"NL": {Allowed: []string{"scheme", "ideal"}, Blocked: []string{"klarna"}},
+			"BE": {Allowed: []string{"scheme", "bcmc"}},
+		},
+		Products: map[string]PaymentMethodRules{
+			"gift-card-001": {Blocked: []string{"klarna", "klarna_paynow"}},
+		},
+	}
+	if !reflect.DeepEqual(cfg.PaymentMethods, expected) {
+		t.Errorf("PaymentMethods = %+v, want %+v", cfg.PaymentMethods, expected)
+	}
+}
+
+func TestPaymentMethodsConfig_For(t *testing.T) {
+	config := PaymentMethodsConfig{
+		PaymentMethodRules: PaymentMethodRules{Blocked: []string{"paypal"}},
+		Countries: map[string]PaymentMethodRules{
+			"NL": {Allowed: []string{"scheme", "ideal", "klarna"}},
+			"US": {Blocked: []string{"klarna"}},
+		},
+		Products: map[string]PaymentMethodRules{
+			"gift-card-001": {Allowed: []string{"scheme", "ideal"}, Blocked: []string{"klarna"}},
+			"voucher-001":   {Allowed: []string{"ideal", "paypal"}},
+			"cards-001":     {Allowed: []string{"scheme"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		country  string
+		skus     []string
+		expected PaymentMethodRules
+		wantErr  error
+	}{
+		{
+			name:     "global rules",
+			country:  "DE",
+			skus:     []string{"widget-001"},
+			expected: PaymentMethodRules{Blocked: []string{"paypal"}},
+		},
+		{
+			name:     "country replaces the allowed methods",
+			country:  "NL",
+			skus:     []string{"widget-001"},
+			expected: PaymentMethodRules{Allowed: []string{"scheme", "ideal", "klarna"}, Blocked: []string{"paypal"}},
+		},
+		{
+			name:     "country adds blocked methods",
+			country:  "US",
+			expected: PaymentMethodRules{Blocked: []string{"paypal", "klarna"}},
+		},
+		{
+			name:     "product narrows the country's methods",
+			country:  "NL",
+			skus:     []string{"widget-001", "gift-card-001"},
+			expected: PaymentMethodRules{Allowed: []string{"scheme", "ideal"}, Blocked: []string{"paypal", "klarna"}},
+		},
+		{
+			name:     "product restricts an unrestricted country",
+			country:  "DE",
+			skus:     []string{"gift-card-001"},
+			expected: PaymentMethodRules{Allowed: []string{"scheme", "ideal"}, Blocked: []string{"paypal", "klarna"}},
+		},
+		{
+			name:     "blocked methods are not allowed",
+			country:  "DE",
+			skus:     []string{"voucher-001"},
+			expected: PaymentMethodRules{Allowed: []string{"ideal"}, Blocked: []string{"paypal"}},
+		},
+		{
+			name:    "products without a common method",
+			country: "DE",
+			skus:    []string{"voucher-001", "cards-001"},
+			wantErr: models.ErrNoPaymentMethod,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.For(tt.country, tt.skus)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("For() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("For() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+
+	// The configured lists must not change
+	if len(config.Countries["NL"].Allowed) != 3 {
+		t.Errorf("For() modified the configured rules: %+v", config.Countries["NL"])
+	}
+}
@@ -2,14 +2,20 @@ package config
 
 import (
 	"fmt"
+	"time"
 )
 
+// DefaultQueryTimeout bounds each database call unless POSTGRES_QUERY_TIMEOUT is set
+const DefaultQueryTimeout = 5 * time.Second
+
 // PostgresConfig holds configuration for PostgreSQL database connection
 type PostgresConfig struct {
 	User     string
 	Password string
 	Database string
 	Host     string
+	// QueryTimeout bounds each database call
+	QueryTimeout time.Duration
 }
 
 // LoadPostgresConfig loads PostgreSQL configuration from environment variables
@@ -35,9 +41,29 @@ func LoadPostgresConfig(getenv func(string) string) (*PostgresConfig, error) {
 		return nil, fmt.Errorf("POSTGRES_HOSTNAME is required")
 	}
 
+	queryTimeout, err := parseTimeout(getenv, "POSTGRES_QUERY_TIMEOUT", DefaultQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	config.QueryTimeout = queryTimeout
+
 	return config, nil
 }
 
+// parseTimeout reads a positive duration such as "5s" from an environment variable
+func parseTimeout(getenv func(string) string, name string, defaultTimeout time.Duration) (time.Duration, error) {
+	value := getenv(name)
+	if value == "" {
+		return defaultTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 5s", name)
+	}
+	return timeout, nil
+}
+
 // ConnectionString returns a PostgreSQL connection string
 func (c *PostgresConfig) ConnectionString() string {
 	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadPostgresConfig_QueryTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		queryTimeout    string
+		expectedTimeout time.Duration
+		wantErr         bool
+	}{
+		{
+			name:            "default timeout",
+			expectedTimeout: DefaultQueryTimeout,
+		},
+		{
+			name:            "custom timeout",
+			queryTimeout:    "750ms",
+			expectedTimeout: 750 * time.Millisecond,
+		},
+		{
+			name:         "unparseable timeout",
+			queryTimeout: "fast",
+			wantErr:      true,
+		},
+		{
+			name:         "negative timeout",
+			queryTimeout: "-1s",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"POSTGRES_USER":          "user",
+				"POSTGRES_PASSWORD":      "password",
+				"POSTGRES_DB":            "ecommerce",
+				"POSTGRES_HOSTNAME":      "localhost",
+				"POSTGRES_QUERY_TIMEOUT": tt.queryTimeout,
+			}
+
+			cfg, err := LoadPostgresConfig(func(key string) string { return env[key] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPostgresConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.QueryTimeout != tt.expectedTimeout {
+				t.Errorf("QueryTimeout = %v, want %v", cfg.QueryTimeout, tt.expectedTimeout)
+			}
+		})
+	}
+}
@@ -1,20 +1,134 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// DefaultAdminAddr serves the metrics to the local machine only unless ADMIN_ADDR is set
+const DefaultAdminAddr = "127.0.0.1:9091"
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
 	Port string
+	// CookieSecret signs the cookies that tie orders to the shopper who placed them.
+	// A random secret is generated at startup if empty.
+	CookieSecret string
+	// PublicBaseURL is where shoppers reach the shop, e.g. https://shop.example.com, used for
+	// the absolute URLs the app generates. Derived from each request if empty.
+	PublicBaseURL string
+	// TrustedProxies are the addresses whose X-Forwarded-Proto and X-Forwarded-Host headers
+	// are believed when deriving the base URL from a request
+	TrustedProxies []netip.Prefix
+	// AdminAddr is where the metrics are served on /debug/vars, e.g. 127.0.0.1:9091. Keep it
+	// off the public network: the metrics include the command line and memory statistics.
+	AdminAddr string
+	// DefaultShopper is the country and locale used for shoppers whose browser tells neither
+	DefaultShopper models.ShopperLocale
 }
 
 // LoadServerConfig loads server configuration from environment variables
-func LoadServerConfig() ServerConfig {
+func LoadServerConfig() (ServerConfig, error) {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // Default to port 8080
 	}
 
-	return ServerConfig{
-		Port: port,
+	adminAddr := os.Getenv("ADMIN_ADDR")
+	if adminAddr == "" {
+		adminAddr = DefaultAdminAddr
+	}
+	if _, _, err := net.SplitHostPort(adminAddr); err != nil {
+		return ServerConfig{}, fmt.Errorf("ADMIN_ADDR must be a host and port, e.g. %s", DefaultAdminAddr)
+	}
+
+	config := ServerConfig{
+		Port:          port,
+		AdminAddr:     adminAddr,
+		CookieSecret:  os.Getenv("COOKIE_SECRET"),
+		PublicBaseURL: strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+	}
+
+	if config.PublicBaseURL != "" {
+		u, err := url.Parse(config.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return ServerConfig{}, fmt.Errorf("PUBLIC_BASE_URL must be an absolute http(s) URL without query or fragment")
+		}
+	}
+
+	proxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return ServerConfig{}, err
+	}
+	config.TrustedProxies = proxies
+
+	shopper, err := loadDefaultShopper()
+	if err != nil {
+		return ServerConfig{}, err
+	}
+	config.DefaultShopper = shopper
+
+	return config, nil
+}
+
+// loadDefaultShopper loads the default shopper country and locale. A configured country
+// without a locale keeps the default language in that country, e.g. en-NL.
+func loadDefaultShopper() (models.ShopperLocale, error) {
+	shopper := models.ShopperLocale{CountryCode: models.DefaultCountryCode, Locale: models.DefaultLocale}
+
+	if value := os.Getenv("SHOPPER_DEFAULT_COUNTRY"); value != "" {
+		country, err := models.NormalizeCountryCode(value)
+		if err != nil {
+			return models.ShopperLocale{}, fmt.Errorf("SHOPPER_DEFAULT_COUNTRY: %w", err)
+		}
+		shopper.CountryCode = country
+		shopper.Locale, _, _ = strings.Cut(models.DefaultLocale, "-")
+	}
+
+	if value := os.Getenv("SHOPPER_DEFAULT_LOCALE"); value != "" {
+		locale, err := models.NormalizeLocale(value)
+		if err != nil {
+			return models.ShopperLocale{}, fmt.Errorf("SHOPPER_DEFAULT_LOCALE: %w", err)
+		}
+		shopper.Locale = locale
+	}
+
+	if models.LocaleRegion(shopper.Locale) == "" {
+		shopper.Locale += "-" + shopper.CountryCode
+	}
+	return shopper, nil
+}
+
+// parseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: invalid CIDR range %q", entry)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: invalid IP address %q", entry)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
 	}
+	return proxies, nil
 }
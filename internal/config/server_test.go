@@ -0,0 +1,178 @@
+package config
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// setServerEnv sets the server variables to the given overrides, clearing the others
+func setServerEnv(t *testing.T, overrides map[string]string) {
+	t.Helper()
+
+	env := map[string]string{
+		"PORT":            "",
+		"COOKIE_SECRET":   "",
+		"PUBLIC_BASE_URL": "",
+		"TRUSTED_PROXIES": "",
+		"ADMIN_ADDR":      "",
+
+		"SHOPPER_DEFAULT_COUNTRY": "",
+		"SHOPPER_DEFAULT_LOCALE":  "",
+	}
+	for key, value := range overrides {
+		env[key] = value
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		expectedPort    string
+		expectedBaseURL string
+		expectedAdmin   string
+		expectedProxies []netip.Prefix
+		expectedShopper models.ShopperLocale
+	}{
+		{
+			name:            "defaults",
+			expectedPort:    "8080",
+			expectedShopper: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+		},
+		{
+			name:            "public base URL without trailing slash",
+			env:             map[string]string{"PORT": "9090", "PUBLIC_BASE_URL": "https://shop.example.com/store/"},
+			expectedPort:    "9090",
+			expectedBaseURL: "https://shop.example.com/store",
+			expectedShopper: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+		},
+		{
+			name:         "trusted proxy addresses and ranges",
+			env:          map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, 127.0.0.1,::1,192.168.1.7/24"},
+			expectedPort: "8080",
+			expectedProxies: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("127.0.0.1/32"),
+				netip.MustParsePrefix("::1/128"),
+				netip.MustParsePrefix("192.168.1.0/24"),
+			},
+			expectedShopper: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+		},
+		{
+			name:            "admin address",
+			env:             map[string]string{"ADMIN_ADDR": "10.0.0.5:9100"},
+			expectedPort:    "8080",
+			expectedAdmin:   "10.0.0.5:9100",
+			expectedShopper: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+		},
+		{
+			name:            "default shopper country and locale",
+			env:             map[string]string{"SHOPPER_DEFAULT_COUNTRY": "be", "SHOPPER_DEFAULT_LOCALE": "fr_BE"},
+			expectedPort:    "8080",
+			expectedShopper: models.ShopperLocale{CountryCode: "BE", Locale: "fr-BE"},
+		},
+		{
+			name:            "default country keeps the default language",
+			env:             map[string]string{"SHOPPER_DEFAULT_COUNTRY": "NL"},
+			expectedPort:    "8080",
+			expectedShopper: models.ShopperLocale{CountryCode: "NL", Locale: "en-NL"},
+		},
+		{
+			name:            "default language is read in the default country",
+			env:             map[string]string{"SHOPPER_DEFAULT_COUNTRY": "NL", "SHOPPER_DEFAULT_LOCALE": "nl"},
+			expectedPort:    "8080",
+			expectedShopper: models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t, tt.env)
+			if tt.expectedAdmin == "" {
+				tt.expectedAdmin = DefaultAdminAddr
+			}
+
+			cfg, err := LoadServerConfig()
+			if err != nil {
+				t.Fatalf("LoadServerConfig() unexpected error = %v", err)
+			}
+			if cfg.Port != tt.expectedPort {
+				t.Errorf("Port = %s, want %s", cfg.Port, tt.expectedPort)
+			}
+			if cfg.AdminAddr != tt.expectedAdmin {
+				t.Errorf("AdminAddr = %s, want %s", cfg.AdminAddr, tt.expectedAdmin)
+			}
+			if cfg.PublicBaseURL != tt.expectedBaseURL {
+				t.Errorf("PublicBaseURL = %s, want %s", cfg.PublicBaseURL, tt.expectedBaseURL)
+			}
+			if !slices.Equal(cfg.TrustedProxies, tt.expectedProxies) {
+				t.Errorf("TrustedProxies = %v, want %v", cfg.TrustedProxies, tt.expectedProxies)
+			}
+			if cfg.DefaultShopper != tt.expectedShopper {
+				t.Errorf("DefaultShopper = %+v, want %+v", cfg.DefaultShopper, tt.expectedShopper)
+			}
+		})
+	}
+}
+
+func TestLoadServerConfig_Validation(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expectedError string
+	}{
+		{
+			name:          "relative public base URL",
+			env:           map[string]string{"PUBLIC_BASE_URL": "shop.example.com"},
+			expectedError: "PUBLIC_BASE_URL",
+		},
+		{
+			name:          "public base URL with query",
+			env:           map[string]string{"PUBLIC_BASE_URL": "https://shop.example.com/?ref=1"},
+			expectedError: "PUBLIC_BASE_URL",
+		},
+		{
+			name:          "invalid proxy address",
+			env:           map[string]string{"TRUSTED_PROXIES": "10.0.0.1,proxy.local"},
+			expectedError: "TRUSTED_PROXIES",
+		},
+		{
+			name:          "invalid proxy range",
+			env:           map[string]string{"TRUSTED_PROXIES": "10.0.0.0/33"},
+			expectedError: "TRUSTED_PROXIES",
+		},
+		{
+			name:          "admin address without port",
+			env:           map[string]string{"ADMIN_ADDR": "localhost"},
+			expectedError: "ADMIN_ADDR",
+		},
+		{
+			name:          "invalid default country",
+			env:           map[string]string{"SHOPPER_DEFAULT_COUNTRY": "NLD"},
+			expectedError: "SHOPPER_DEFAULT_COUNTRY",
+		},
+		{
+			name:          "invalid default locale",
+			env:           map[string]string{"SHOPPER_DEFAULT_LOCALE": "dutch"},
+			expectedError: "SHOPPER_DEFAULT_LOCALE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t, tt.env)
+
+			_, err := LoadServerConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("LoadServerConfig() error = %v, want error mentioning %s", err, tt.expectedError)
+			}
+		})
+	}
+}
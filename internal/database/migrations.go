@@ -1,39 +1,473 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"time"
 )
 
-// RunMigrations creates the necessary database tables
+// Migration represents a versioned, reversible schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Applied migrations must never be edited; add a new migration instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_orders",
+		Up: `
+		CREATE TABLE IF NOT EXISTS orders (
+			id UUID PRIMARY KEY,
+			reference VARCHAR(255) UNIQUE NOT NULL,
+			amount INTEGER NOT NULL,
+			currency VARCHAR(3) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			product_name VARCHAR(255) NOT NULL,
+			psp_reference VARCHAR(255),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_orders_reference ON orders(reference);
+		CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS orders;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "create_products",
+		Up: `
+		CREATE TABLE IF NOT EXISTS products (
+			id UUID PRIMARY KEY,
+			sku VARCHAR(64) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			image_url VARCHAR(1024) NOT NULL DEFAULT '',
+			price BIGINT NOT NULL CHECK (price > 0),
+			currency VARCHAR(3) NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		INSERT INTO products (id, sku, name, description, image_url, price, currency)
+		VALUES (
+			'6f1c3e0a-3b7d-4c52-9a59-2d1b8f4e7a01',
+			'widget-001',
+			'Premium Widget',
+			'A high-quality widget perfect for all your widget needs. Durable, reliable, and designed to last.',
+			'/static/images/widget-placeholder.svg',
+			100,
+			'USD'
+		)
+		ON CONFLICT (sku) DO NOTHING;
+		`,
+		Down: `
+		DROP TABLE IF EXISTS products;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "create_carts_and_order_items",
+		Up: `
+		CREATE TABLE IF NOT EXISTS carts (
+			id UUID PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS cart_items (
+			cart_id UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (cart_id, product_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS order_items (
+			id UUID PRIMARY KEY,
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			sku VARCHAR(64) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			unit_price BIGINT NOT NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS order_items;
+		DROP TABLE IF EXISTS cart_items;
+		DROP TABLE IF EXISTS carts;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "create_order_reference_seq",
+		Up: `
+		CREATE SEQUENCE IF NOT EXISTS order_reference_seq;
+		`,
+		Down: `
+		DROP SEQUENCE IF EXISTS order_reference_seq;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "create_order_events",
+		Up: `
+		CREATE TABLE IF NOT EXISTS order_events (
+			id UUID PRIMARY KEY,
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			previous_status VARCHAR(50) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			psp_reference VARCHAR(255),
+			source VARCHAR(50) NOT NULL,
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			payload JSONB,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events(order_id, created_at);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS order_events;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "add_orders_version",
+		Up: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+		`,
+		Down: `
+		ALTER TABLE orders DROP COLUMN IF EXISTS version;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "create_order_status_retries",
+		Up: `
+		CREATE TABLE IF NOT EXISTS order_status_retries (
+			id UUID PRIMARY KEY,
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			status VARCHAR(50) NOT NULL,
+			psp_reference VARCHAR(255),
+			source VARCHAR(50) NOT NULL,
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			payload JSONB,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_order_status_retries_next_attempt_at ON order_status_retries(next_attempt_at);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS order_status_retries;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "add_orders_session_expires_at",
+		Up: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS session_expires_at TIMESTAMP;
+
+		CREATE INDEX IF NOT EXISTS idx_orders_pending_created_at ON orders(created_at) WHERE status = 'pending';
+		`,
+		Down: `
+		DROP INDEX IF EXISTS idx_orders_pending_created_at;
+		ALTER TABLE orders DROP COLUMN IF EXISTS session_expires_at;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "add_orders_session",
+		Up: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS session_id VARCHAR(255);
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS session_data TEXT;
+		`,
+		Down: `
+		ALTER TABLE orders DROP COLUMN IF EXISTS session_data;
+		ALTER TABLE orders DROP COLUMN IF EXISTS session_id;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "add_orders_session_shopper",
+		Up: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS session_country_code VARCHAR(2);
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS session_locale VARCHAR(35);
+		`,
+		Down: `
+		ALTER TABLE orders DROP COLUMN IF EXISTS session_locale;
+		ALTER TABLE orders DROP COLUMN IF EXISTS session_country_code;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "add_orders_refunded_amount",
+		Up: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+		`,
+		Down: `
+		ALTER TABLE orders DROP COLUMN IF EXISTS refunded_amount;
+		`,
+	},
+}
+
+// createMigrationsTable tracks which migrations have been applied
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Migrator applies and reverts schema migrations on a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the registered migrations
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// RunMigrations applies all pending migrations to the shared database connection
 func RunMigrations() error {
 	if DB == nil {
 		return fmt.Errorf("database connection not initialized")
 	}
 
-	// Create orders table
-	createOrdersTable := `
-	CREATE TABLE IF NOT EXISTS orders (
-		id UUID PRIMARY KEY,
-		reference VARCHAR(255) UNIQUE NOT NULL,
-		amount INTEGER NOT NULL,
-		currency VARCHAR(3) NOT NULL,
-		status VARCHAR(50) NOT NULL,
-		product_name VARCHAR(255) NOT NULL,
-		psp_reference VARCHAR(255),
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_orders_reference ON orders(reference);
-	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
-	`
-
-	_, err := DB.Exec(createOrdersTable)
-	if err != nil {
-		return fmt.Errorf("failed to create orders table: %w", err)
+	if err := NewMigrator(DB).Up(); err != nil {
+		return err
 	}
 
 	log.Println("Database migrations completed successfully")
 	return nil
 }
+
+// LatestVersion returns the version of the newest registered migration
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the newest applied migration, or 0 if none
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := m.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.LatestVersion())
+}
+
+// Down reverts the most recently applied migration
+func (m *Migrator) Down() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return fmt.Errorf("no migrations to revert")
+	}
+
+	target := 0
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+	return m.To(target)
+}
+
+// To migrates the schema up or down until the given version is the newest applied one
+func (m *Migrator) To(version int) error {
+	if err := validateMigrations(m.migrations); err != nil {
+		return err
+	}
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	up, down := planMigrations(m.migrations, current, version)
+	for _, migration := range up {
+		if err := m.apply(migration, migration.Up, true); err != nil {
+			return err
+		}
+	}
+	for _, migration := range down {
+		if err := m.apply(migration, migration.Down, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status lists every registered migration with its applied state
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// apply runs one migration step and records it in the same transaction
+func (m *Migrator) apply(migration Migration, statement string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", migration.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statement); err != nil {
+		return fmt.Errorf("failed to run migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+
+	log.Printf("Migrated %s: %d_%s", direction, migration.Version, migration.Name)
+	return nil
+}
+
+// ensureMigrationsTable creates the schema_migrations table if needed
+func (m *Migrator) ensureMigrationsTable() error {
+	if m.db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+	if _, err := m.db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// find returns the migration with the given version, or nil
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// planMigrations returns the migrations to apply (in ascending order) or
+// revert (in descending order) to move the schema from current to target
+func planMigrations(all []Migration, current, target int) (up []Migration, down []Migration) {
+	if target >= current {
+		for _, migration := range all {
+			if migration.Version > current && migration.Version <= target {
+				up = append(up, migration)
+			}
+		}
+		return up, nil
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Version <= current && all[i].Version > target {
+			down = append(down, all[i])
+		}
+	}
+	return nil, down
+}
+
+// validateMigrations checks that migrations have unique, ascending versions and both directions
+func validateMigrations(all []Migration) error {
+	previous := 0
+	for _, migration := range all {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d_%s is out of order", migration.Version, migration.Name)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return fmt.Errorf("migration %d_%s must define both up and down", migration.Version, migration.Name)
+		}
+		previous = migration.Version
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestRegisteredMigrationsAreValid(t *testing.T) {
+	if err := validateMigrations(migrations); err != nil {
+		t.Fatalf("registered migrations are invalid: %v", err)
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []Migration
+		wantErr    bool
+	}{
+		{
+			name: "ascending versions",
+			migrations: []Migration{
+				{Version: 1, Name: "one", Up: "up", Down: "down"},
+				{Version: 2, Name: "two", Up: "up", Down: "down"},
+			},
+		},
+		{
+			name: "duplicate versions",
+			migrations: []Migration{
+				{Version: 1, Name: "one", Up: "up", Down: "down"},
+				{Version: 1, Name: "again", Up: "up", Down: "down"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "descending versions",
+			migrations: []Migration{
+				{Version: 2, Name: "two", Up: "up", Down: "down"},
+				{Version: 1, Name: "one", Up: "up", Down: "down"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing down migration",
+			migrations: []Migration{
+				{Version: 1, Name: "one", Up: "up"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMigrations(tt.migrations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	all := []Migration{
+		{Version: 1, Name: "one"},
+		{Version: 2, Name: "two"},
+		{Version: 3, Name: "three"},
+	}
+
+	tests := []struct {
+		name         string
+		current      int
+		target       int
+		expectedUp   []int
+		expectedDown []int
+	}{
+		{"fresh database to latest", 0, 3, []int{1, 2, 3}, nil},
+		{"partially migrated to latest", 1, 3, []int{2, 3}, nil},
+		{"already at target", 2, 2, nil, nil},
+		{"down one version", 3, 2, nil, []int{3}},
+		{"down to empty schema", 3, 0, nil, []int{3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down := planMigrations(all, tt.current, tt.target)
+
+			if got := versions(up); !equalVersions(got, tt.expectedUp) {
+				t.Errorf("expected up %v, got %v", tt.expectedUp, got)
+			}
+			if got := versions(down); !equalVersions(got, tt.expectedDown) {
+				t.Errorf("expected down %v, got %v", tt.expectedDown, got)
+			}
+		})
+	}
+}
+
+// versions extracts the version numbers of migrations
+func versions(migrations []Migration) []int {
+	var result []int
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+// equalVersions compares two version lists
+func equalVersions(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -12,6 +13,9 @@ import (
 
 var DB *sql.DB
 
+// QueryTimeout bounds each database call made by the repositories
+var QueryTimeout = config.DefaultQueryTimeout
+
 // Connect establishes a connection to the PostgreSQL database
 func Connect() error {
 	pgConfig, err := config.LoadPostgresConfig(os.Getenv)
@@ -19,6 +23,8 @@ func Connect() error {
 		return fmt.Errorf("failed to load postgres config: %w", err)
 	}
 
+	QueryTimeout = pgConfig.QueryTimeout
+
 	// Connection string
 	connStr := pgConfig.ConnectionString()
 
@@ -33,7 +39,9 @@ func Connect() error {
 	DB.SetConnMaxLifetime(5 * time.Minute)
 
 	// Verify connection
-	if err = DB.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout)
+	defer cancel()
+	if err = DB.PingContext(ctx); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
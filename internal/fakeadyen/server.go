@@ -0,0 +1,619 @@
+// Package fakeadyen implements a local stand-in for the subset of the Adyen
+// Checkout API used by services.HTTPAdyenClient, for offline development and tests.
+package fakeadyen
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// Payment outcomes the fake can be scripted to return
+const (
+	OutcomeAuthorised = "Authorised"
+	OutcomeRefused    = "Refused"
+	OutcomePending    = "Pending"
+	OutcomeError      = "Error"
+)
+
+// Config holds the settings of a fake Adyen server
+type Config struct {
+	// APIKey, if set, must be sent in the X-API-Key header; otherwise any non-empty key is accepted
+	APIKey string
+	// DefaultOutcome is the result of payments without a scripted outcome (Authorised if empty)
+	DefaultOutcome string
+	// WebhookURL receives standard webhook notifications; no webhooks are sent if empty
+	WebhookURL string
+	// HMACKey is the hex key used to sign webhook notifications
+	HMACKey string
+}
+
+// Session is a payment session created through the fake
+type Session struct {
+	ID              string
+	MerchantAccount string
+	Reference       string
+	Amount          services.Amount
+	ReturnURL       string
+	Outcome         string
+	PSPReference    string
+}
+
+// IsCompleted returns true once a payment has been made for the session
+func (s *Session) IsCompleted() bool {
+	return s.Outcome != ""
+}
+
+// Server is a fake Adyen Checkout API server
+type Server struct {
+	config     Config
+	mux        *http.ServeMux
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	payments map[string]*Session
+	outcomes map[string]string
+	// replies holds responses by Idempotency-Key so repeated requests are not applied twice
+	replies  map[string]interface{}
+	webhooks sync.WaitGroup
+}
+
+// NewServer creates a fake Adyen server
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.DefaultOutcome == "" {
+		cfg.DefaultOutcome = OutcomeAuthorised
+	}
+	if err := validateOutcome(cfg.DefaultOutcome); err != nil {
+		return nil, err
+	}
+	if cfg.HMACKey != "" {
+		if _, err := hex.DecodeString(cfg.HMACKey); err != nil {
+			return nil, fmt.Errorf("invalid HMAC key: %w", err)
+		}
+	}
+
+	s := &Server{
+		config:     cfg,
+		mux:        http.NewServeMux(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		sessions:   make(map[string]*Session),
+		payments:   make(map[string]*Session),
+		outcomes:   make(map[string]string),
+		replies:    make(map[string]interface{}),
+	}
+
+	// Any API version is accepted, e.g. /v71/sessions
+	s.mux.HandleFunc("POST /{version}/sessions", s.authenticated(s.handleCreateSession))
+	s.mux.HandleFunc("GET /{version}/sessions/{id}", s.authenticated(s.handleSessionResult))
+	s.mux.HandleFunc("POST /{version}/payments/{pspReference}/{modification}", s.authenticated(s.handleModification))
+
+	// Control endpoints for scripting the fake and simulating the shopper
+	s.mux.HandleFunc("POST /_fake/outcomes", s.handleSetOutcome)
+	s.mux.HandleFunc("GET /_fake/sessions/{id}", s.handlePaymentPage)
+	s.mux.HandleFunc("GET /_fake/sessions/{id}/complete", s.handleComplete)
+	s.mux.HandleFunc("POST /_fake/payments/{pspReference}/settle", s.handleSettle)
+
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetOutcome scripts the outcome of the payment for a merchant reference
+func (s *Server) SetOutcome(reference, outcome string) error {
+	if err := validateOutcome(outcome); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcomes[reference] = outcome
+	return nil
+}
+
+// SetDefaultOutcome changes the outcome of payments without a scripted outcome
+func (s *Server) SetDefaultOutcome(outcome string) error {
+	if err := validateOutcome(outcome); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.DefaultOutcome = outcome
+	return nil
+}
+
+// SettlePayment gives a pending payment its final outcome and sends the AUTHORISATION webhook,
+// as Adyen does once a bank transfer or wallet payment completes
+func (s *Server) SettlePayment(pspReference, outcome string) error {
+	if err := validateOutcome(outcome); err != nil {
+		return err
+	}
+	if outcome == OutcomePending {
+		return fmt.Errorf("a pending payment must settle with a final outcome")
+	}
+
+	s.mu.Lock()
+	payment, ok := s.payments[pspReference]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("unknown payment %s", pspReference)
+	}
+	if payment.Outcome != OutcomePending {
+		s.mu.Unlock()
+		return fmt.Errorf("payment %s is not pending", pspReference)
+	}
+	payment.Outcome = outcome
+	settled := *payment
+	s.mu.Unlock()
+
+	log.Printf("Fake Adyen: pending payment %s for %s settled with %s", pspReference, settled.Reference, outcome)
+	s.sendAuthorisation(settled)
+	return nil
+}
+
+// Session returns a copy of the session with the given ID
+func (s *Server) Session(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	return *session, true
+}
+
+// WaitForWebhooks blocks until all webhooks sent so far have been delivered or failed
+func (s *Server) WaitForWebhooks() {
+	s.webhooks.Wait()
+}
+
+// authenticated rejects requests without a valid X-API-Key header
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" || (s.config.APIKey != "" && key != s.config.APIKey) {
+			writeError(w, http.StatusUnauthorized, "000", "HTTP Status Response - Unauthorized", "security")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleCreateSession implements POST /{version}/sessions
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	if s.replay(w, r) {
+		return
+	}
+
+	var req services.SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "702", "Structure of the request is invalid", "validation")
+		return
+	}
+	if req.MerchantAccount == "" || req.Reference == "" || req.ReturnUrl == "" || req.Amount.Value <= 0 || req.Amount.Currency == "" {
+		writeError(w, http.StatusUnprocessableEntity, "100", "Required field missing: merchantAccount, reference, returnUrl or amount", "validation")
+		return
+	}
+
+	session := &Session{
+		ID:              "CS" + randomID(14),
+		MerchantAccount: req.MerchantAccount,
+		Reference:       req.Reference,
+		Amount:          req.Amount,
+		ReturnURL:       req.ReturnUrl,
+	}
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+
+	log.Printf("Fake Adyen: created session %s for %s (%d %s)", session.ID, session.Reference, session.Amount.Value, session.Amount.Currency)
+
+	resp := services.SessionResponse{
+		ID:          session.ID,
+		SessionData: base64.StdEncoding.EncodeToString([]byte("fake-session:" + session.ID)),
+		ExpiresAt:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	s.remember(r, resp)
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handleSessionResult implements GET /{version}/sessions/{id}. A session that has not been
+// paid yet is completed with its scripted outcome, as if the shopper had just paid.
+func (s *Server) handleSessionResult(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.completeSession(r.PathValue("id"), "")
+	if !ok {
+		writeError(w, http.StatusNotFound, "000", "Session not found", "validation")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessionStatus(session))
+}
+
+// handleModification implements POST /{version}/payments/{pspReference}/{refunds|captures|cancels}
+func (s *Server) handleModification(w http.ResponseWriter, r *http.Request) {
+	eventCode, ok := modificationEvents[r.PathValue("modification")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "000", "Unknown modification", "validation")
+		return
+	}
+	if s.replay(w, r) {
+		return
+	}
+
+	var req services.ModificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "702", "Structure of the request is invalid", "validation")
+		return
+	}
+
+	pspReference := r.PathValue("pspReference")
+	s.mu.Lock()
+	payment, ok := s.payments[pspReference]
+	var paymentCopy Session
+	if ok {
+		paymentCopy = *payment
+	}
+	s.mu.Unlock()
+
+	if !ok || paymentCopy.Outcome != OutcomeAuthorised {
+		writeError(w, http.StatusUnprocessableEntity, "167", "Original pspReference required for this operation", "validation")
+		return
+	}
+
+	amount := req.Amount
+	if amount == nil {
+		amount = &paymentCopy.Amount
+	}
+	reference := req.Reference
+	if reference == "" {
+		reference = paymentCopy.Reference
+	}
+
+	resp := services.ModificationResponse{
+		MerchantAccount:     req.MerchantAccount,
+		PaymentPSPReference: pspReference,
+		PSPReference:        randomPSPReference(),
+		Reference:           reference,
+		Status:              "received",
+		Amount:              req.Amount,
+	}
+
+	log.Printf("Fake Adyen: received %s %s for payment %s", r.PathValue("modification"), resp.PSPReference, pspReference)
+
+	s.sendWebhook(services.NotificationRequestItem{
+		Amount:              *amount,
+		EventCode:           eventCode,
+		MerchantAccountCode: req.MerchantAccount,
+		MerchantReference:   reference,
+		OriginalReference:   pspReference,
+		PSPReference:        resp.PSPReference,
+		Success:             "true",
+	})
+	s.remember(r, resp)
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// replay answers a request whose Idempotency-Key was seen before with the original response
+func (s *Server) replay(w http.ResponseWriter, r *http.Request) bool {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	resp, ok := s.replies[key]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	log.Printf("Fake Adyen: replaying response for Idempotency-Key %s", key)
+	writeJSON(w, http.StatusCreated, resp)
+	return true
+}
+
+// remember stores the response to a request carrying an Idempotency-Key
+func (s *Server) remember(r *http.Request, resp interface{}) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		return
+	}
+
+	s.mu.Lock()
+	s.replies[key] = resp
+	s.mu.Unlock()
+}
+
+// modificationEvents maps modification endpoints to the webhook event they produce
+var modificationEvents = map[string]string{
+	"refunds":  services.EventCodeRefund,
+	"captures": services.EventCodeCapture,
+	"cancels":  services.EventCodeCancellation,
+}
+
+// handleSettle settles a pending payment over HTTP, e.g. {"outcome": "Authorised"}
+func (s *Server) handleSettle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Outcome string `json:"outcome"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.SettlePayment(r.PathValue("pspReference"), req.Outcome); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetOutcome scripts outcomes over HTTP, e.g. {"reference": "ORDER-1", "outcome": "Refused"}.
+// Without a reference the default outcome is changed.
+func (s *Server) handleSetOutcome(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reference string `json:"reference"`
+		Outcome   string `json:"outcome"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if req.Reference == "" {
+		err = s.SetDefaultOutcome(req.Outcome)
+	} else {
+		err = s.SetOutcome(req.Reference, req.Outcome)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// paymentPage lets a developer pick the outcome of a session in the browser
+var paymentPage = template.Must(template.New("payment").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Fake Adyen payment {{.Session.Reference}}</title></head>
+<body>
+    <h1>Fake Adyen payment</h1>
+    <p>{{.Session.Reference}}: {{.Session.Amount.Value}} {{.Session.Amount.Currency}} (minor units)</p>
+    {{range .Outcomes}}<a href="/_fake/sessions/{{$.Session.ID}}/complete?outcome={{.}}">{{.}}</a> {{end}}
+</body>
+</html>
+`))
+
+// handlePaymentPage renders a page to complete a session with a chosen outcome
+func (s *Server) handlePaymentPage(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.Session(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := struct {
+		Session  Session
+		Outcomes []string
+	}{session, []string{OutcomeAuthorised, OutcomeRefused, OutcomePending, OutcomeError}}
+
+	if err := paymentPage.Execute(w, data); err != nil {
+		log.Printf("Fake Adyen: error rendering payment page: %v", err)
+	}
+}
+
+// handleComplete simulates the shopper paying: the session is completed and the
+// shopper is redirected to the return URL with sessionId and sessionResult, as Adyen does
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	outcome := r.URL.Query().Get("outcome")
+	if outcome != "" {
+		if err := validateOutcome(outcome); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	session, ok := s.completeSession(r.PathValue("id"), outcome)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	returnURL, err := url.Parse(session.ReturnURL)
+	if err != nil {
+		http.Error(w, "Invalid return URL", http.StatusBadRequest)
+		return
+	}
+	query := returnURL.Query()
+	query.Set("sessionId", session.ID)
+	query.Set("sessionResult", "fake-result-"+session.ID)
+	returnURL.RawQuery = query.Encode()
+
+	http.Redirect(w, r, returnURL.String(), http.StatusFound)
+}
+
+// completeSession pays for a session with the given outcome, or its scripted outcome if empty.
+// A session that is already completed keeps its original outcome.
+func (s *Server) completeSession(id, outcome string) (Session, bool) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return Session{}, false
+	}
+	if session.IsCompleted() {
+		completed := *session
+		s.mu.Unlock()
+		return completed, true
+	}
+
+	if outcome == "" {
+		outcome = s.config.DefaultOutcome
+		if scripted, ok := s.outcomes[session.Reference]; ok {
+			outcome = scripted
+		}
+	}
+	session.Outcome = outcome
+	session.PSPReference = randomPSPReference()
+	s.payments[session.PSPReference] = session
+	completed := *session
+	s.mu.Unlock()
+
+	log.Printf("Fake Adyen: session %s for %s completed with %s, PSP reference %s",
+		completed.ID, completed.Reference, completed.Outcome, completed.PSPReference)
+
+	// Pending payments are only reported once they are settled
+	if completed.Outcome != OutcomePending {
+		s.sendAuthorisation(completed)
+	}
+
+	return completed, true
+}
+
+// sendAuthorisation reports the final outcome of a payment
+func (s *Server) sendAuthorisation(payment Session) {
+	item := services.NotificationRequestItem{
+		Amount:              payment.Amount,
+		EventCode:           services.EventCodeAuthorisation,
+		MerchantAccountCode: payment.MerchantAccount,
+		MerchantReference:   payment.Reference,
+		PSPReference:        payment.PSPReference,
+		Success:             strconv.FormatBool(payment.Outcome == OutcomeAuthorised),
+	}
+	if payment.Outcome != OutcomeAuthorised {
+		item.Reason = payment.Outcome
+	}
+	s.sendWebhook(item)
+}
+
+// sessionStatus builds the session result response for a completed session
+func sessionStatus(session Session) services.SessionStatusResponse {
+	resp := services.SessionStatusResponse{
+		ID:        session.ID,
+		Reference: session.Reference,
+		Status:    sessionStatuses[session.Outcome],
+	}
+	resp.Payments = append(resp.Payments, struct {
+		ResultCode   string `json:"resultCode"`
+		PSPReference string `json:"pspReference"`
+	}{session.Outcome, session.PSPReference})
+	return resp
+}
+
+// sessionStatuses maps payment outcomes to the session status Adyen reports
+var sessionStatuses = map[string]string{
+	OutcomeAuthorised: "completed",
+	OutcomeRefused:    "refused",
+	OutcomePending:    "paymentPending",
+	OutcomeError:      "refused",
+}
+
+// sendWebhook signs a notification and posts it to the webhook URL in the background
+func (s *Server) sendWebhook(item services.NotificationRequestItem) {
+	if s.config.WebhookURL == "" {
+		return
+	}
+
+	item.EventDate = time.Now().UTC().Format(time.RFC3339)
+	if s.config.HMACKey != "" {
+		signature, err := services.CalculateNotificationHMAC(&item, s.config.HMACKey)
+		if err != nil {
+			log.Printf("Fake Adyen: failed to sign webhook: %v", err)
+			return
+		}
+		item.AdditionalData = map[string]string{"hmacSignature": signature}
+	}
+
+	body, err := json.Marshal(services.NotificationRequest{
+		Live:              "false",
+		NotificationItems: []services.NotificationItem{{NotificationRequestItem: item}},
+	})
+	if err != nil {
+		log.Printf("Fake Adyen: failed to encode webhook: %v", err)
+		return
+	}
+
+	s.webhooks.Add(1)
+	go func() {
+		defer s.webhooks.Done()
+
+		resp, err := s.httpClient.Post(s.config.WebhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Fake Adyen: failed to send %s webhook for %s: %v", item.EventCode, item.MerchantReference, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		log.Printf("Fake Adyen: sent %s webhook for %s (status %d)", item.EventCode, item.MerchantReference, resp.StatusCode)
+	}()
+}
+
+// validateOutcome checks that an outcome is one the fake supports
+func validateOutcome(outcome string) error {
+	switch outcome {
+	case OutcomeAuthorised, OutcomeRefused, OutcomePending, OutcomeError:
+		return nil
+	}
+	return fmt.Errorf("unknown outcome %q, must be one of %s", outcome,
+		strings.Join([]string{OutcomeAuthorised, OutcomeRefused, OutcomePending, OutcomeError}, ", "))
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Fake Adyen: error encoding response: %v", err)
+	}
+}
+
+// writeError writes an error response in the format used by the Adyen API
+func writeError(w http.ResponseWriter, status int, errorCode, message, errorType string) {
+	writeJSON(w, status, services.AdyenAPIError{
+		Status:    status,
+		ErrorCode: errorCode,
+		Message:   message,
+		ErrorType: errorType,
+	})
+}
+
+// randomPSPReference returns a 16 character PSP reference
+func randomPSPReference() string {
+	return randomID(16)
+}
+
+// randomID returns n random upper case alphanumeric characters
+func randomID(n int) string {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	random := make([]byte, n)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	for i, b := range random {
+		random[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(random)
+}
@@ -0,0 +1,424 @@
+package fakeadyen
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+const testHMACKey = "44782DEF547AAA06C910C43932B1EB0C71FC68D9D0C057550C48EC2ACF6BA056"
+
+// webhookRecorder collects the notifications posted to it
+type webhookRecorder struct {
+	mu    sync.Mutex
+	items []services.NotificationRequestItem
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req services.NotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, item := range req.NotificationItems {
+		rec.items = append(rec.items, item.NotificationRequestItem)
+	}
+	w.Write([]byte("[accepted]"))
+}
+
+func (rec *webhookRecorder) received() []services.NotificationRequestItem {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]services.NotificationRequestItem(nil), rec.items...)
+}
+
+// setupFake starts a fake Adyen server and returns it with a client pointed at it
+func setupFake(t *testing.T, cfg Config) (*Server, *httptest.Server, services.AdyenClient) {
+	t.Helper()
+
+	fake, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer() unexpected error = %v", err)
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := services.NewAdyenClient(&config.AdyenConfig{
+		APIKey:          "test-api-key",
+		MerchantAccount: "TestMerchant",
+		BaseURL:         server.URL,
+	})
+	return fake, server, client
+}
+
+func createSession(t *testing.T, client services.AdyenClient, reference string) *services.SessionResponse {
+	t.Helper()
+
+	resp, err := client.CreateSession(context.Background(), &services.SessionRequest{
+		Amount:    services.Amount{Currency: "USD", Value: 1000},
+		Reference: reference,
+		ReturnUrl: "http://localhost:8080/order/confirmation",
+	})
+	if err != nil {
+		t.Fatalf("CreateSession() unexpected error = %v", err)
+	}
+	return resp
+}
+
+func TestServer_SessionOutcomes(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultOutcome string
+		scripted       string
+		expectedResult string
+		expectedStatus string
+		expectWebhook  bool
+		webhookSuccess string
+	}{
+		{
+			name:           "default outcome is authorised",
+			expectedResult: OutcomeAuthorised,
+			expectedStatus: "completed",
+			expectWebhook:  true,
+			webhookSuccess: "true",
+		},
+		{
+			name:           "scripted refusal",
+			scripted:       OutcomeRefused,
+			expectedResult: OutcomeRefused,
+			expectedStatus: "refused",
+			expectWebhook:  true,
+			webhookSuccess: "false",
+		},
+		{
+			name:           "default outcome pending sends no webhook",
+			defaultOutcome: OutcomePending,
+			expectedResult: OutcomePending,
+			expectedStatus: "paymentPending",
+		},
+		{
+			name:           "scripted error",
+			scripted:       OutcomeError,
+			expectedResult: OutcomeError,
+			expectedStatus: "refused",
+			expectWebhook:  true,
+			webhookSuccess: "false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &webhookRecorder{}
+			webhooks := httptest.NewServer(recorder)
+			defer webhooks.Close()
+
+			fake, _, client := setupFake(t, Config{
+				DefaultOutcome: tt.defaultOutcome,
+				WebhookURL:     webhooks.URL,
+				HMACKey:        testHMACKey,
+			})
+			if tt.scripted != "" {
+				if err := fake.SetOutcome("ORDER-1", tt.scripted); err != nil {
+					t.Fatalf("SetOutcome() unexpected error = %v", err)
+				}
+			}
+
+			session := createSession(t, client, "ORDER-1")
+
+			status, err := client.GetSessionStatus(context.Background(), session.ID, "result")
+			if err != nil {
+				t.Fatalf("GetSessionStatus() unexpected error = %v", err)
+			}
+			if status.Reference != "ORDER-1" || status.Status != tt.expectedStatus {
+				t.Errorf("Expected ORDER-1 with status %s, got %s with %s", tt.expectedStatus, status.Reference, status.Status)
+			}
+			if len(status.Payments) != 1 || status.Payments[0].ResultCode != tt.expectedResult || status.Payments[0].PSPReference == "" {
+				t.Fatalf("Expected one %s payment, got %+v", tt.expectedResult, status.Payments)
+			}
+
+			// Looking the session up again returns the same payment
+			again, _ := client.GetSessionStatus(context.Background(), session.ID, "result")
+			if again.Payments[0].PSPReference != status.Payments[0].PSPReference {
+				t.Error("Expected the same payment on repeated lookups")
+			}
+
+			fake.WaitForWebhooks()
+			received := recorder.received()
+			if !tt.expectWebhook {
+				if len(received) != 0 {
+					t.Errorf("Expected no webhooks, got %+v", received)
+				}
+				return
+			}
+			if len(received) != 1 {
+				t.Fatalf("Expected one webhook, got %d", len(received))
+			}
+			item := received[0]
+			if item.EventCode != services.EventCodeAuthorisation || item.Success != tt.webhookSuccess ||
+				item.MerchantReference != "ORDER-1" || item.PSPReference != status.Payments[0].PSPReference {
+				t.Errorf("Unexpected webhook: %+v", item)
+			}
+			if err := services.VerifyNotificationHMAC(&item, testHMACKey); err != nil {
+				t.Errorf("Webhook signature did not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestServer_SettlePendingPayment(t *testing.T) {
+	recorder := &webhookRecorder{}
+	webhooks := httptest.NewServer(recorder)
+	defer webhooks.Close()
+
+	fake, server, client := setupFake(t, Config{DefaultOutcome: OutcomePending, WebhookURL: webhooks.URL, HMACKey: testHMACKey})
+
+	session := createSession(t, client, "ORDER-5")
+	status, err := client.GetSessionStatus(context.Background(), session.ID, "result")
+	if err != nil {
+		t.Fatalf("GetSessionStatus() unexpected error = %v", err)
+	}
+	pspReference := status.Payments[0].PSPReference
+
+	fake.WaitForWebhooks()
+	if got := len(recorder.received()); got != 0 {
+		t.Fatalf("Expected no webhook while pending, got %d", got)
+	}
+
+	resp, err := http.Post(server.URL+"/_fake/payments/"+pspReference+"/settle", "application/json",
+		strings.NewReader(`{"outcome":"Authorised"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resp.StatusCode)
+	}
+
+	fake.WaitForWebhooks()
+	received := recorder.received()
+	if len(received) != 1 || received[0].EventCode != services.EventCodeAuthorisation ||
+		received[0].PSPReference != pspReference || !received[0].IsSuccess() {
+		t.Fatalf("Unexpected webhooks: %+v", received)
+	}
+
+	settled, _ := fake.Session(session.ID)
+	if settled.Outcome != OutcomeAuthorised {
+		t.Errorf("Expected settled outcome Authorised, got %s", settled.Outcome)
+	}
+
+	if err := fake.SettlePayment(pspReference, OutcomeRefused); err == nil {
+		t.Error("Expected error settling a payment that is no longer pending")
+	}
+	if err := fake.SettlePayment("UNKNOWN", OutcomeAuthorised); err == nil {
+		t.Error("Expected error settling an unknown payment")
+	}
+}
+
+func TestServer_Modifications(t *testing.T) {
+	recorder := &webhookRecorder{}
+	webhooks := httptest.NewServer(recorder)
+	defer webhooks.Close()
+
+	fake, _, client := setupFake(t, Config{WebhookURL: webhooks.URL, HMACKey: testHMACKey})
+
+	session := createSession(t, client, "ORDER-2")
+	status, err := client.GetSessionStatus(context.Background(), session.ID, "result")
+	if err != nil {
+		t.Fatalf("GetSessionStatus() unexpected error = %v", err)
+	}
+	pspReference := status.Payments[0].PSPReference
+
+	tests := []struct {
+		name      string
+		modify    func(context.Context, string, *services.ModificationRequest) (*services.ModificationResponse, error)
+		eventCode string
+	}{
+		{"refund", client.RefundPayment, services.EventCodeRefund},
+		{"capture", client.CapturePayment, services.EventCodeCapture},
+		{"cancel", client.CancelPayment, services.EventCodeCancellation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.modify(context.Background(), pspReference, &services.ModificationRequest{
+				Amount:    &services.Amount{Currency: "USD", Value: 400},
+				Reference: "ORDER-2",
+			})
+			if err != nil {
+				t.Fatalf("modification unexpected error = %v", err)
+			}
+			if resp.Status != "received" || resp.PaymentPSPReference != pspReference || resp.PSPReference == "" {
+				t.Errorf("Unexpected modification response: %+v", resp)
+			}
+
+			fake.WaitForWebhooks()
+			received := recorder.received()
+			item := received[len(received)-1]
+			if item.EventCode != tt.eventCode || item.OriginalReference != pspReference ||
+				item.PSPReference != resp.PSPReference || item.Amount.Value != 400 || !item.IsSuccess() {
+				t.Errorf("Unexpected webhook: %+v", item)
+			}
+			if err := services.VerifyNotificationHMAC(&item, testHMACKey); err != nil {
+				t.Errorf("Webhook signature did not verify: %v", err)
+			}
+		})
+	}
+
+	t.Run("repeated idempotency key", func(t *testing.T) {
+		before := len(recorder.received())
+		req := func() *services.ModificationRequest {
+			return &services.ModificationRequest{
+				Amount:         &services.Amount{Currency: "USD", Value: 400},
+				Reference:      "ORDER-2",
+				IdempotencyKey: "refund-ORDER-2",
+			}
+		}
+
+		first, err := client.RefundPayment(context.Background(), pspReference, req())
+		if err != nil {
+			t.Fatalf("RefundPayment() unexpected error = %v", err)
+		}
+		second, err := client.RefundPayment(context.Background(), pspReference, req())
+		if err != nil {
+			t.Fatalf("RefundPayment() unexpected error = %v", err)
+		}
+		if second.PSPReference != first.PSPReference {
+			t.Errorf("Expected replayed PSP reference %s, got %s", first.PSPReference, second.PSPReference)
+		}
+
+		fake.WaitForWebhooks()
+		if got := len(recorder.received()) - before; got != 1 {
+			t.Errorf("Expected a single refund webhook, got %d", got)
+		}
+	})
+
+	t.Run("unknown payment", func(t *testing.T) {
+		_, err := client.RefundPayment(context.Background(), "UNKNOWN", &services.ModificationRequest{Reference: "ORDER-2"})
+		var apiErr *services.AdyenAPIError
+		if !errors.As(err, &apiErr) || !apiErr.IsValidation() || apiErr.ErrorCode != "167" {
+			t.Errorf("Expected validation error 167, got %v", err)
+		}
+	})
+}
+
+func TestServer_Errors(t *testing.T) {
+	_, server, client := setupFake(t, Config{APIKey: "expected-key"})
+
+	t.Run("wrong API key", func(t *testing.T) {
+		_, err := client.CreateSession(context.Background(), &services.SessionRequest{
+			Amount:    services.Amount{Currency: "USD", Value: 1000},
+			Reference: "ORDER-3",
+			ReturnUrl: "http://localhost:8080/order/confirmation",
+		})
+		var apiErr *services.AdyenAPIError
+		if !errors.As(err, &apiErr) || !apiErr.IsAuthentication() || apiErr.Status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 authentication error, got %v", err)
+		}
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v71/sessions", strings.NewReader(`{"merchantAccount":"TestMerchant"}`))
+		req.Header.Set("X-API-Key", "expected-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/v71/sessions/CSUNKNOWN?sessionResult=x", nil)
+		req.Header.Set("X-API-Key", "expected-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("invalid outcome", func(t *testing.T) {
+		if _, err := NewServer(Config{DefaultOutcome: "Maybe"}); err == nil {
+			t.Error("Expected error for invalid default outcome")
+		}
+		if _, err := NewServer(Config{HMACKey: "not-hex"}); err == nil {
+			t.Error("Expected error for invalid HMAC key")
+		}
+	})
+}
+
+func TestServer_ControlEndpoints(t *testing.T) {
+	fake, server, client := setupFake(t, Config{})
+
+	// Script a refusal over HTTP
+	resp, err := http.Post(server.URL+"/_fake/outcomes", "application/json",
+		strings.NewReader(`{"reference":"ORDER-4","outcome":"Refused"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/_fake/outcomes", "application/json", strings.NewReader(`{"outcome":"Maybe"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown outcome, got %d", resp.StatusCode)
+	}
+
+	session := createSession(t, client, "ORDER-4")
+
+	// The payment page links to each outcome
+	resp, err = http.Get(server.URL + "/_fake/sessions/" + session.ID)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for payment page, got %d", resp.StatusCode)
+	}
+
+	// Completing the session redirects the shopper back to the shop
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err = noRedirects.Get(server.URL + "/_fake/sessions/" + session.ID + "/complete")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected status 302, got %d", resp.StatusCode)
+	}
+
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	if location.Path != "/order/confirmation" || location.Query().Get("sessionId") != session.ID || location.Query().Get("sessionResult") == "" {
+		t.Errorf("Unexpected redirect: %s", location)
+	}
+
+	completed, _ := fake.Session(session.ID)
+	if completed.Outcome != OutcomeRefused {
+		t.Errorf("Expected scripted outcome Refused, got %s", completed.Outcome)
+	}
+}
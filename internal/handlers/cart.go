@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// Cart cookie settings
+const (
+	cartCookieName   = "cart_id"
+	cartCookieMaxAge = 30 * 24 * 60 * 60 // 30 days
+)
+
+// CartData represents the data passed to the cart template
+type CartData struct {
+	Cart *models.Cart
+}
+
+// CartHandler handles the cart page
+type CartHandler struct {
+	template    *template.Template
+	cartService services.CartService
+}
+
+// NewCartHandler creates a new cart handler
+func NewCartHandler(templatePath string, cartService services.CartService) (*CartHandler, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CartHandler{
+		template:    tmpl,
+		cartService: cartService,
+	}, nil
+}
+
+// ServeHTTP handles the GET /cart request
+func (h *CartHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cart, err := loadCart(h.cartService, r)
+	if err != nil {
+		log.Printf("Error loading cart: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.template.Execute(w, CartData{Cart: cart}); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// CartAddHandler handles adding a product to the cart
+type CartAddHandler struct {
+	cartService services.CartService
+}
+
+// NewCartAddHandler creates a new cart add handler
+func NewCartAddHandler(cartService services.CartService) *CartAddHandler {
+	return &CartAddHandler{cartService: cartService}
+}
+
+// ServeHTTP handles the POST /cart/items request.
+// The form carries the sku, an optional quantity (default 1) and an optional redirect path.
+func (h *CartAddHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	quantity := 1
+	if value := r.FormValue("quantity"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid quantity", http.StatusBadRequest)
+			return
+		}
+		quantity = parsed
+	}
+
+	cartID, err := ensureCart(h.cartService, w, r)
+	if err != nil {
+		log.Printf("Error creating cart: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.cartService.AddItem(r.Context(), cartID, r.FormValue("sku"), quantity); err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, safeRedirectPath(r.FormValue("redirect"), "/cart"), http.StatusSeeOther)
+}
+
+// CartUpdateHandler handles changing the quantity of a product in the cart
+type CartUpdateHandler struct {
+	cartService services.CartService
+}
+
+// NewCartUpdateHandler creates a new cart update handler
+func NewCartUpdateHandler(cartService services.CartService) *CartUpdateHandler {
+	return &CartUpdateHandler{cartService: cartService}
+}
+
+// ServeHTTP handles the POST /cart/items/{sku} request. A quantity of zero removes the product.
+func (h *CartUpdateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	quantity, err := strconv.Atoi(r.FormValue("quantity"))
+	if err != nil {
+		http.Error(w, "Invalid quantity", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.cartService.UpdateItemQuantity(r.Context(), cartIDFromRequest(r), r.PathValue("sku"), quantity); err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+// CartRemoveHandler handles removing a product from the cart
+type CartRemoveHandler struct {
+	cartService services.CartService
+}
+
+// NewCartRemoveHandler creates a new cart remove handler
+func NewCartRemoveHandler(cartService services.CartService) *CartRemoveHandler {
+	return &CartRemoveHandler{cartService: cartService}
+}
+
+// ServeHTTP handles the POST /cart/items/{sku}/remove request
+func (h *CartRemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.cartService.RemoveItem(r.Context(), cartIDFromRequest(r), r.PathValue("sku")); err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+// cartIDFromRequest returns the cart ID from the cart cookie, or an empty string
+func cartIDFromRequest(r *http.Request) string {
+	cookie, err := r.Cookie(cartCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// loadCart returns the shopper's cart, or an empty cart if they do not have one yet
+func loadCart(cartService services.CartService, r *http.Request) (*models.Cart, error) {
+	cart, err := cartService.GetCart(r.Context(), cartIDFromRequest(r))
+	if errors.Is(err, models.ErrCartNotFound) {
+		return &models.Cart{}, nil
+	}
+	return cart, err
+}
+
+// ensureCart returns the shopper's cart ID, creating a cart and setting the cookie if needed
+func ensureCart(cartService services.CartService, w http.ResponseWriter, r *http.Request) (string, error) {
+	cart, err := cartService.GetCart(r.Context(), cartIDFromRequest(r))
+	if err == nil {
+		return cart.ID, nil
+	}
+	if !errors.Is(err, models.ErrCartNotFound) {
+		return "", err
+	}
+
+	cart, err = cartService.CreateCart(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cartCookieName,
+		Value:    cart.ID,
+		Path:     "/",
+		MaxAge:   cartCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return cart.ID, nil
+}
+
+// writeCartError maps cart errors to HTTP responses
+func writeCartError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrCartNotFound), errors.Is(err, models.ErrProductNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidQuantity), errors.Is(err, models.ErrCurrencyMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error updating cart: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// safeRedirectPath returns path if it is a local path, otherwise the fallback
+func safeRedirectPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return fallback
+	}
+	return path
+}
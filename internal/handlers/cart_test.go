@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// MockCartService is a mock implementation of CartService for testing
+type MockCartService struct {
+	CreateCartFunc         func() (*models.Cart, error)
+	GetCartFunc            func(string) (*models.Cart, error)
+	AddItemFunc            func(string, string, int) error
+	UpdateItemQuantityFunc func(string, string, int) error
+	RemoveItemFunc         func(string, string) error
+	ClearCartFunc          func(string) error
+}
+
+func (m *MockCartService) CreateCart(ctx context.Context) (*models.Cart, error) {
+	if m.CreateCartFunc != nil {
+		return m.CreateCartFunc()
+	}
+	return &models.Cart{ID: "new-cart"}, nil
+}
+
+func (m *MockCartService) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	if m.GetCartFunc != nil {
+		return m.GetCartFunc(id)
+	}
+	return nil, models.ErrCartNotFound
+}
+
+func (m *MockCartService) AddItem(ctx context.Context, cartID, sku string, quantity int) error {
+	if m.AddItemFunc != nil {
+		return m.AddItemFunc(cartID, sku, quantity)
+	}
+	return nil
+}
+
+func (m *MockCartService) UpdateItemQuantity(ctx context.Context, cartID, sku string, quantity int) error {
+	if m.UpdateItemQuantityFunc != nil {
+		return m.UpdateItemQuantityFunc(cartID, sku, quantity)
+	}
+	return nil
+}
+
+func (m *MockCartService) RemoveItem(ctx context.Context, cartID, sku string) error {
+	if m.RemoveItemFunc != nil {
+		return m.RemoveItemFunc(cartID, sku)
+	}
+	return nil
+}
+
+func (m *MockCartService) ClearCart(ctx context.Context, cartID string) error {
+	if m.ClearCartFunc != nil {
+		return m.ClearCartFunc(cartID)
+	}
+	return nil
+}
+
+// cartServiceWith returns a cart service that knows a single cart with the given items
+func cartServiceWith(cartID string, items ...models.CartItem) *MockCartService {
+	return &MockCartService{
+		GetCartFunc: func(id string) (*models.Cart, error) {
+			if id != cartID {
+				return nil, models.ErrCartNotFound
+			}
+			return &models.Cart{ID: cartID, Items: items}, nil
+		},
+	}
+}
+
+// withCartCookie adds the cart cookie to a request
+func withCartCookie(req *http.Request, cartID string) *http.Request {
+	req.AddCookie(&http.Cookie{Name: cartCookieName, Value: cartID})
+	return req
+}
+
+// postForm builds a form POST request
+func postForm(target string, values url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestCartHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		cartID         string
+		expectedStatus int
+		checkContent   []string
+	}{
+		{
+			name:           "cart with items",
+			method:         http.MethodGet,
+			cartID:         "cart-1",
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Test Product", "$10.00 each", "$30.00", "/cart/items/test-001/remove", "/checkout"},
+		},
+		{
+			name:           "no cart cookie shows empty cart",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Your cart is empty"},
+		},
+		{
+			name:           "method not allowed - POST",
+			method:         http.MethodPost,
+			cartID:         "cart-1",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cartService := cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 3})
+
+			handler, err := NewCartHandler("../../templates/cart.html", cartService)
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, "/cart", nil)
+			if tt.cartID != "" {
+				withCartCookie(req, tt.cartID)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			body := w.Body.String()
+			for _, content := range tt.checkContent {
+				if !strings.Contains(body, content) {
+					t.Errorf("expected response to contain '%s'", content)
+				}
+			}
+		})
+	}
+}
+
+func TestCartAddHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name             string
+		cartID           string
+		form             url.Values
+		addError         error
+		expectedStatus   int
+		expectedLocation string
+		expectedQuantity int
+		expectNewCookie  bool
+	}{
+		{
+			name:             "add to existing cart",
+			cartID:           "cart-1",
+			form:             url.Values{"sku": {"test-001"}, "quantity": {"2"}},
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/cart",
+			expectedQuantity: 2,
+		},
+		{
+			name:             "first item creates a cart",
+			form:             url.Values{"sku": {"test-001"}, "redirect": {"/checkout"}},
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/checkout",
+			expectedQuantity: 1,
+			expectNewCookie:  true,
+		},
+		{
+			name:             "external redirect is ignored",
+			cartID:           "cart-1",
+			form:             url.Values{"sku": {"test-001"}, "redirect": {"//evil.example.com"}},
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/cart",
+			expectedQuantity: 1,
+		},
+		{
+			name:           "invalid quantity",
+			cartID:         "cart-1",
+			form:           url.Values{"sku": {"test-001"}, "quantity": {"many"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:             "unknown product",
+			cartID:           "cart-1",
+			form:             url.Values{"sku": {"missing-001"}},
+			addError:         models.ErrProductNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedQuantity: 1,
+		},
+		{
+			name:             "currency mismatch",
+			cartID:           "cart-1",
+			form:             url.Values{"sku": {"euro-001"}},
+			addError:         models.ErrCurrencyMismatch,
+			expectedStatus:   http.StatusBadRequest,
+			expectedQuantity: 1,
+		},
+		{
+			name:             "storage error",
+			cartID:           "cart-1",
+			form:             url.Values{"sku": {"test-001"}},
+			addError:         errors.New("database error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedQuantity: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var addedCart string
+			var addedQuantity int
+			cartService := cartServiceWith("cart-1")
+			cartService.AddItemFunc = func(cartID, sku string, quantity int) error {
+				addedCart = cartID
+				addedQuantity = quantity
+				return tt.addError
+			}
+
+			req := postForm("/cart/items", tt.form)
+			if tt.cartID != "" {
+				withCartCookie(req, tt.cartID)
+			}
+			w := httptest.NewRecorder()
+
+			NewCartAddHandler(cartService).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedLocation != "" && w.Header().Get("Location") != tt.expectedLocation {
+				t.Errorf("expected redirect to %s, got %s", tt.expectedLocation, w.Header().Get("Location"))
+			}
+			if addedQuantity != tt.expectedQuantity {
+				t.Errorf("expected quantity %d, got %d", tt.expectedQuantity, addedQuantity)
+			}
+
+			setCookie := w.Header().Get("Set-Cookie")
+			if tt.expectNewCookie {
+				if !strings.Contains(setCookie, cartCookieName+"=new-cart") || !strings.Contains(setCookie, "HttpOnly") {
+					t.Errorf("expected new HttpOnly cart cookie, got %q", setCookie)
+				}
+				if addedCart != "new-cart" {
+					t.Errorf("expected item added to new cart, got %q", addedCart)
+				}
+			} else if setCookie != "" {
+				t.Errorf("expected no cookie to be set, got %q", setCookie)
+			}
+		})
+	}
+}
+
+func TestCartUpdateHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		quantity       string
+		updateError    error
+		expectedStatus int
+	}{
+		{"update quantity", "4", nil, http.StatusSeeOther},
+		{"zero quantity", "0", nil, http.StatusSeeOther},
+		{"non-numeric quantity", "lots", nil, http.StatusBadRequest},
+		{"quantity out of range", "500", models.ErrInvalidQuantity, http.StatusBadRequest},
+		{"no cart", "1", models.ErrCartNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updatedSKU string
+			cartService := &MockCartService{
+				UpdateItemQuantityFunc: func(cartID, sku string, quantity int) error {
+					updatedSKU = sku
+					return tt.updateError
+				},
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("/cart/items/{sku}", NewCartUpdateHandler(cartService))
+
+			req := withCartCookie(postForm("/cart/items/test-001", url.Values{"quantity": {tt.quantity}}), "cart-1")
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusSeeOther {
+				if updatedSKU != "test-001" {
+					t.Errorf("expected SKU test-001 to be updated, got %q", updatedSKU)
+				}
+				if w.Header().Get("Location") != "/cart" {
+					t.Errorf("expected redirect to /cart, got %s", w.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
+
+func TestCartRemoveHandler_ServeHTTP(t *testing.T) {
+	var removedSKU, removedCart string
+	cartService := &MockCartService{
+		RemoveItemFunc: func(cartID, sku string) error {
+			removedCart = cartID
+			removedSKU = sku
+			return nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cart/items/{sku}/remove", NewCartRemoveHandler(cartService))
+
+	req := withCartCookie(postForm("/cart/items/test-001/remove", nil), "cart-1")
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	if removedCart != "cart-1" || removedSKU != "test-001" {
+		t.Errorf("expected test-001 removed from cart-1, got %q from %q", removedSKU, removedCart)
+	}
+
+	// GET is not allowed
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/items/test-001/remove", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// CatalogHandler handles the product listing page
+type CatalogHandler struct {
+	template       *template.Template
+	productService services.ProductService
+}
+
+// CatalogData represents the data passed to the catalog template
+type CatalogData struct {
+	Products []*models.Product
+}
+
+// NewCatalogHandler creates a new CatalogHandler
+func NewCatalogHandler(templatePath string, productService services.ProductService) (*CatalogHandler, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CatalogHandler{
+		template:       tmpl,
+		productService: productService,
+	}, nil
+}
+
+// ServeHTTP handles the GET / request
+func (h *CatalogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// "/" matches every path the mux has no other route for
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	products, err := h.productService.ListProducts(r.Context())
+	if err != nil {
+		log.Printf("Error listing products: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.template.Execute(w, CatalogData{Products: products}); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestCatalogHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		products       []*models.Product
+		listError      error
+		expectedStatus int
+		checkContent   []string
+	}{
+		{
+			name:   "lists products",
+			method: http.MethodGet,
+			path:   "/",
+			products: []*models.Product{
+				testProduct(),
+				{SKU: "gadget-001", Name: "Deluxe Gadget", Price: 2550, Currency: "EUR"},
+			},
+			expectedStatus: http.StatusOK,
+			checkContent: []string{
+				"Test Product", "$10.00", "/products/test-001",
+				"Deluxe Gadget", "€25.50", "/products/gadget-001",
+			},
+		},
+		{
+			name:           "empty catalog",
+			method:         http.MethodGet,
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"No products are available"},
+		},
+		{
+			name:           "product service error",
+			method:         http.MethodGet,
+			path:           "/",
+			listError:      errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "unknown path",
+			method:         http.MethodGet,
+			path:           "/does-not-exist",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "method not allowed - POST",
+			method:         http.MethodPost,
+			path:           "/",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productService := &MockProductService{
+				ListProductsFunc: func() ([]*models.Product, error) {
+					return tt.products, tt.listError
+				},
+			}
+
+			handler, err := NewCatalogHandler("../../templates/catalog.html", productService)
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				body := w.Body.String()
+				for _, content := range tt.checkContent {
+					if !strings.Contains(body, content) {
+						t.Errorf("expected response to contain '%s'", content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewCatalogHandler_InvalidTemplate(t *testing.T) {
+	handler, err := NewCatalogHandler("/invalid/path/to/catalog.html", &MockProductService{})
+	if err == nil {
+		t.Error("expected error but got none")
+	}
+	if handler != nil {
+		t.Error("expected nil handler when error occurs")
+	}
+}
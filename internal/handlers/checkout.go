@@ -2,43 +2,123 @@ package handlers
 
 import (
 	"html/template"
+	"log"
 	"net/http"
+	"slices"
 
 	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
 )
 
 // CheckoutHandler handles the checkout page
 type CheckoutHandler struct {
-	template *template.Template
-	product  Product
-	config   *config.AdyenConfig
+	template    *template.Template
+	cartService services.CartService
+	config      *config.AdyenConfig
+	locales     *LocaleResolver
 }
 
 // CheckoutData represents the data passed to the checkout template
 type CheckoutData struct {
-	Product   Product
+	Cart      *models.Cart
 	ClientKey string
+	// Environment is the Drop-in environment, "test" or "live", and SDKURL is where the
+	// Adyen Web SDK for that environment is loaded from
+	Environment string
+	SDKURL      string
+	// Locales are offered by the locale selector, SelectedLocale is the one the shopper
+	// picked or empty if the locale is derived from their browser
+	Locales        []LocaleOption
+	SelectedLocale string
+	// Dropin configures Drop-in to show the payment methods the session allows
+	Dropin DropinSettings
+}
+
+// DropinSettings is the payment method part of the Drop-in configuration
+type DropinSettings struct {
+	AllowPaymentMethods         []string                  `json:"allowPaymentMethods,omitempty"`
+	RemovePaymentMethods        []string                  `json:"removePaymentMethods,omitempty"`
+	PaymentMethodsConfiguration map[string]map[string]any `json:"paymentMethodsConfiguration"`
+}
+
+// cardConfiguration configures the card form, shown for the scheme payment method
+var cardConfiguration = map[string]any{
+	"hasHolderName":          true,
+	"holderNameRequired":     true,
+	"billingAddressRequired": false,
+}
+
+// newDropinSettings returns the Drop-in settings for the payment method rules of a session
+func newDropinSettings(rules config.PaymentMethodRules) DropinSettings {
+	settings := DropinSettings{
+		AllowPaymentMethods:         rules.Allowed,
+		RemovePaymentMethods:        rules.Blocked,
+		PaymentMethodsConfiguration: map[string]map[string]any{},
+	}
+	cardsAllowed := len(rules.Allowed) == 0 || slices.Contains(rules.Allowed, "scheme")
+	if cardsAllowed && !slices.Contains(rules.Blocked, "scheme") {
+		settings.PaymentMethodsConfiguration["card"] = cardConfiguration
+	}
+	return settings
 }
 
 // NewCheckoutHandler creates a new checkout handler
-func NewCheckoutHandler(templatePath string, product Product, cfg *config.AdyenConfig) (*CheckoutHandler, error) {
+func NewCheckoutHandler(templatePath string, cartService services.CartService, cfg *config.AdyenConfig, locales *LocaleResolver) (*CheckoutHandler, error) {
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return nil, err
 	}
 
 	return &CheckoutHandler{
-		template: tmpl,
-		product:  product,
-		config:   cfg,
+		template:    tmpl,
+		cartService: cartService,
+		config:      cfg,
+		locales:     locales,
 	}, nil
 }
 
-// ServeHTTP handles the checkout page request
+// ServeHTTP handles the checkout page request for the shopper's cart
 func (h *CheckoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The locale selector submits the picked locale, which the payment session then uses
+	if r.URL.Query().Has("locale") {
+		if err := h.locales.Select(w, r.URL.Query().Get("locale")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/checkout", http.StatusSeeOther)
+		return
+	}
+
+	cart, err := loadCart(h.cartService, r)
+	if err != nil {
+		log.Printf("Error loading cart for checkout: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// There is nothing to pay for until something is in the cart
+	if cart.IsEmpty() {
+		http.Redirect(w, r, "/cart", http.StatusSeeOther)
+		return
+	}
+
+	// Drop-in offers the same payment methods as the session it shows
+	paymentMethods, err := h.config.PaymentMethods.For(h.locales.Resolve(r).CountryCode, cart.SKUs())
+	if err != nil {
+		// Creating the session fails as well and tells the shopper why
+		log.Printf("No payment method for checkout: %v", err)
+	}
+
 	data := CheckoutData{
-		Product:   h.product,
-		ClientKey: h.config.ClientKey,
+		Cart:        cart,
+		ClientKey:   h.config.ClientKey,
+		Environment: h.config.ClientEnvironment(),
+		SDKURL:      h.config.SDKURL(),
+
+		Locales:        checkoutLocales,
+		SelectedLocale: h.locales.Selected(r),
+		Dropin:         newDropinSettings(paymentMethods),
 	}
 
 	if err := h.template.Execute(w, data); err != nil {
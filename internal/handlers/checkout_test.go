@@ -8,31 +8,50 @@ import (
 	"testing"
 
 	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/models"
 )
 
 func TestCheckoutHandler_ServeHTTP(t *testing.T) {
 	tests := []struct {
-		name           string
-		method         string
-		expectedStatus int
-		checkContent   []string
+		name             string
+		method           string
+		cartID           string
+		expectedStatus   int
+		expectedLocation string
+		checkContent     []string
 	}{
 		{
 			name:           "successful request",
 			method:         http.MethodGet,
+			cartID:         "cart-1",
 			expectedStatus: http.StatusOK,
-			checkContent:   []string{"Test Widget", "test_CLIENT_KEY_123"},
+			checkContent:   []string{"Test Widget", "$50.00", "Deluxe Gadget", "$60.00", "test_CLIENT_KEY_123"},
 		},
 		{
 			name:           "POST request also works",
 			method:         http.MethodPost,
+			cartID:         "cart-1",
 			expectedStatus: http.StatusOK,
 		},
 		{
 			name:           "PUT request works",
 			method:         http.MethodPut,
+			cartID:         "cart-1",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:             "empty cart redirects to cart page",
+			method:           http.MethodGet,
+			cartID:           "empty-cart",
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/cart",
+		},
+		{
+			name:             "no cart redirects to cart page",
+			method:           http.MethodGet,
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/cart",
+		},
 	}
 
 	for _, tt := range tests {
@@ -43,22 +62,35 @@ func TestCheckoutHandler_ServeHTTP(t *testing.T) {
 				APIKey:    "test_api_key",
 			}
 
-			// Create test product
-			product := Product{
-				Name:        "Test Widget",
-				Description: "A premium test widget",
-				Price:       "$25.00",
-				ImageURL:    "/static/images/widget.jpg",
+			// Create test cart
+			widget := &models.Product{SKU: "widget-test", Name: "Test Widget", Price: 2500, Currency: "USD"}
+			gadget := &models.Product{SKU: "gadget-test", Name: "Deluxe Gadget", Price: 1000, Currency: "USD"}
+			cartService := &MockCartService{
+				GetCartFunc: func(id string) (*models.Cart, error) {
+					switch id {
+					case "cart-1":
+						return &models.Cart{ID: id, Items: []models.CartItem{
+							{Product: widget, Quantity: 2},
+							{Product: gadget, Quantity: 1},
+						}}, nil
+					case "empty-cart":
+						return &models.Cart{ID: id}, nil
+					}
+					return nil, models.ErrCartNotFound
+				},
 			}
 
 			// Create handler
-			handler, err := NewCheckoutHandler("../../templates/checkout.html", product, cfg)
+			handler, err := NewCheckoutHandler("../../templates/checkout.html", cartService, cfg, testLocales())
 			if err != nil {
 				t.Fatalf("Failed to create handler: %v", err)
 			}
 
 			// Create request
 			req := httptest.NewRequest(tt.method, "/checkout", nil)
+			if tt.cartID != "" {
+				withCartCookie(req, tt.cartID)
+			}
 			w := httptest.NewRecorder()
 
 			// Execute
@@ -69,6 +101,10 @@ func TestCheckoutHandler_ServeHTTP(t *testing.T) {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
 			}
 
+			if tt.expectedLocation != "" && w.Header().Get("Location") != tt.expectedLocation {
+				t.Errorf("expected redirect to %s, got %s", tt.expectedLocation, w.Header().Get("Location"))
+			}
+
 			// For successful requests, check response content
 			if tt.expectedStatus == http.StatusOK && len(tt.checkContent) > 0 {
 				body := w.Body.String()
@@ -89,19 +125,21 @@ func TestCheckoutHandler_TemplateData(t *testing.T) {
 		APIKey:    "api_key",
 	}
 
-	product := Product{
+	product := &models.Product{
+		SKU:         "super-001",
 		Name:        "Super Product",
 		Description: "The best product ever",
-		Price:       "$99.99",
+		Price:       9999,
+		Currency:    "USD",
 		ImageURL:    "/images/super.jpg",
 	}
 
-	handler, err := NewCheckoutHandler("../../templates/checkout.html", product, cfg)
+	handler, err := NewCheckoutHandler("../../templates/checkout.html", cartServiceWith("cart-1", models.CartItem{Product: product, Quantity: 1}), cfg, testLocales())
 	if err != nil {
 		t.Fatalf("Failed to create handler: %v", err)
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+	req := withCartCookie(httptest.NewRequest(http.MethodGet, "/checkout", nil), "cart-1")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
@@ -123,25 +161,209 @@ func TestCheckoutHandler_TemplateData(t *testing.T) {
 	}
 }
 
+func TestCheckoutHandler_LocaleSelector(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedCookie *http.Cookie
+	}{
+		{
+			name:           "picked locale is remembered",
+			target:         "/checkout?locale=nl_be",
+			expectedStatus: http.StatusSeeOther,
+			expectedCookie: &http.Cookie{Name: localeCookieName, Value: "nl-BE", MaxAge: localeCookieMaxAge},
+		},
+		{
+			name:           "empty locale forgets the pick",
+			target:         "/checkout?locale=",
+			expectedStatus: http.StatusSeeOther,
+			expectedCookie: &http.Cookie{Name: localeCookieName, Value: "", MaxAge: -1},
+		},
+		{
+			name:           "invalid locale is rejected",
+			target:         "/checkout?locale=12",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := NewCheckoutHandler("../../templates/checkout.html", cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), &config.AdyenConfig{}, testLocales())
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := withCartCookie(httptest.NewRequest(http.MethodGet, tt.target, nil), "cart-1")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusSeeOther && w.Header().Get("Location") != "/checkout" {
+				t.Errorf("expected redirect to /checkout, got %s", w.Header().Get("Location"))
+			}
+
+			cookies := w.Result().Cookies()
+			if tt.expectedCookie == nil {
+				if len(cookies) != 0 {
+					t.Errorf("expected no cookie, got %v", cookies)
+				}
+				return
+			}
+			if len(cookies) != 1 || cookies[0].Name != tt.expectedCookie.Name || cookies[0].Value != tt.expectedCookie.Value || cookies[0].MaxAge != tt.expectedCookie.MaxAge {
+				t.Errorf("expected cookie %v, got %v", tt.expectedCookie, cookies)
+			}
+		})
+	}
+}
+
+func TestCheckoutHandler_RendersSelectedLocale(t *testing.T) {
+	handler, err := NewCheckoutHandler("../../templates/checkout.html", cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), &config.AdyenConfig{}, testLocales())
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	req := withCartCookie(httptest.NewRequest(http.MethodGet, "/checkout", nil), "cart-1")
+	req.AddCookie(&http.Cookie{Name: localeCookieName, Value: "fr-BE"})
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `<option value="fr-BE" selected>`) {
+		t.Errorf("expected fr-BE to be selected, got %s", body)
+	}
+}
+
+func TestCheckoutHandler_DropinSettings(t *testing.T) {
+	cfg := &config.AdyenConfig{
+		ClientKey: "test_key",
+		PaymentMethods: config.PaymentMethodsConfig{
+			PaymentMethodRules: config.PaymentMethodRules{Blocked: []string{"paypal"}},
+			Countries: map[string]config.PaymentMethodRules{
+				"NL": {Allowed: []string{"scheme", "ideal"}},
+				"BE": {Allowed: []string{"bcmc", "ideal"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+	}{
+		{
+			name:           "every method but the blocked ones",
+			acceptLanguage: "de-DE",
+			expected:       `{"removePaymentMethods":["paypal"],"paymentMethodsConfiguration":{"card":{"billingAddressRequired":false,"hasHolderName":true,"holderNameRequired":true}}}`,
+		},
+		{
+			name:           "methods of the shopper's country",
+			acceptLanguage: "nl-NL",
+			expected:       `{"allowPaymentMethods":["scheme","ideal"],"removePaymentMethods":["paypal"],"paymentMethodsConfiguration":{"card":{"billingAddressRequired":false,"hasHolderName":true,"holderNameRequired":true}}}`,
+		},
+		{
+			name:           "no card settings without cards",
+			acceptLanguage: "fr-BE",
+			expected:       `{"allowPaymentMethods":["bcmc","ideal"],"removePaymentMethods":["paypal"],"paymentMethodsConfiguration":{}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := NewCheckoutHandler("../../templates/checkout.html", cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), cfg, testLocales())
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := withCartCookie(httptest.NewRequest(http.MethodGet, "/checkout", nil), "cart-1")
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if body := w.Body.String(); !strings.Contains(body, "window.ADYEN_DROPIN_SETTINGS = "+tt.expected+";") {
+				t.Errorf("expected Drop-in settings %s, got %s", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestCheckoutHandler_Environment(t *testing.T) {
+	tests := []struct {
+		name                string
+		environment         string
+		expectedEnvironment string
+		expectedSDKURL      string
+	}{
+		{
+			name:                "test environment",
+			environment:         config.EnvironmentTest,
+			expectedEnvironment: "test",
+			expectedSDKURL:      "https://checkoutshopper-test.adyen.com/checkoutshopper/sdk/5.66.0",
+		},
+		{
+			name:                "live environment",
+			environment:         config.EnvironmentLive,
+			expectedEnvironment: "live",
+			expectedSDKURL:      "https://checkoutshopper-live.adyen.com/checkoutshopper/sdk/5.66.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.AdyenConfig{ClientKey: "client_key", Environment: tt.environment}
+			handler, err := NewCheckoutHandler("../../templates/checkout.html", cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), cfg, testLocales())
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := withCartCookie(httptest.NewRequest(http.MethodGet, "/checkout", nil), "cart-1")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, content := range []string{
+				`window.ADYEN_ENVIRONMENT = "` + tt.expectedEnvironment + `";`,
+				`<link rel="stylesheet" href="` + tt.expectedSDKURL + `/adyen.css" />`,
+				`<script src="` + tt.expectedSDKURL + `/adyen.js"></script>`,
+			} {
+				if !strings.Contains(body, content) {
+					t.Errorf("expected response to contain '%s', got %s", content, body)
+				}
+			}
+		})
+	}
+}
+
 func TestNewCheckoutHandler(t *testing.T) {
 	tests := []struct {
 		name         string
 		templatePath string
-		product      Product
 		config       *config.AdyenConfig
 		wantErr      bool
 	}{
 		{
 			name:         "invalid template path",
 			templatePath: "/invalid/path/to/checkout.html",
-			product:      Product{Name: "Test"},
 			config:       &config.AdyenConfig{ClientKey: "key"},
 			wantErr:      true,
 		},
 		{
 			name:         "empty template path",
 			templatePath: "",
-			product:      Product{Name: "Test"},
 			config:       &config.AdyenConfig{ClientKey: "key"},
 			wantErr:      true,
 		},
@@ -149,7 +371,7 @@ func TestNewCheckoutHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler, err := NewCheckoutHandler(tt.templatePath, tt.product, tt.config)
+			handler, err := NewCheckoutHandler(tt.templatePath, &MockCartService{}, tt.config, testLocales())
 
 			if tt.wantErr && err == nil {
 				t.Error("expected error but got none")
@@ -172,10 +394,6 @@ func TestCheckoutHandler_TemplateExecutionError(t *testing.T) {
 		ClientKey: "test_key",
 	}
 
-	product := Product{
-		Name: "Test Product",
-	}
-
 	// Create a handler with a malformed template
 	tmpl, err := template.New("checkout.html").Parse("{{.InvalidField.NonExistent}}")
 	if err != nil {
@@ -183,12 +401,13 @@ func TestCheckoutHandler_TemplateExecutionError(t *testing.T) {
 	}
 
 	handler := &CheckoutHandler{
-		template: tmpl,
-		product:  product,
-		config:   cfg,
+		template:    tmpl,
+		cartService: cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}),
+		config:      cfg,
+		locales:     testLocales(),
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+	req := withCartCookie(httptest.NewRequest(http.MethodGet, "/checkout", nil), "cart-1")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
@@ -14,15 +14,14 @@ import (
 type ConfirmationHandler struct {
 	template       *template.Template
 	paymentService services.PaymentService
+	cartService    services.CartService
 }
 
 // NewConfirmationHandler creates a new confirmation handler
-func NewConfirmationHandler(templatePath string, paymentService services.PaymentService) (*ConfirmationHandler, error) {
+func NewConfirmationHandler(templatePath string, paymentService services.PaymentService, cartService services.CartService) (*ConfirmationHandler, error) {
 	// Create template with custom functions
 	funcMap := template.FuncMap{
-		"divf": func(a int64, b float64) float64 {
-			return float64(a) / b
-		},
+		"formatAmount": models.FormatAmount,
 	}
 
 	tmpl, err := template.New("confirmation.html").Funcs(funcMap).ParseFiles(templatePath)
@@ -33,6 +32,7 @@ func NewConfirmationHandler(templatePath string, paymentService services.Payment
 	return &ConfirmationHandler{
 		template:       tmpl,
 		paymentService: paymentService,
+		cartService:    cartService,
 	}, nil
 }
 
@@ -64,21 +64,36 @@ func (h *ConfirmationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	log.Printf("Processing payment confirmation - sessionId: %s, sessionResult: %s", sessionID, sessionResult)
 
 	// Verify payment through service
-	result, err := h.paymentService.VerifyPayment(sessionID, sessionResult)
+	result, err := h.paymentService.VerifyPayment(r.Context(), sessionID, sessionResult)
 	if err != nil {
 		log.Printf("Error verifying payment: %v", err)
-		http.Error(w, "Failed to verify payment", http.StatusInternalServerError)
+		statusCode, message := paymentErrorResponse(err, "Failed to verify payment")
+		http.Error(w, message, statusCode)
 		return
 	}
 
-	// Check if payment was successful
-	if result.Status != string(models.OrderStatusAuthorized) {
-		// Redirect to failure page
-		failureURL := fmt.Sprintf("/order/failed?reference=%s&reason=%s", result.Order.Reference, result.ResultCode)
-		http.Redirect(w, r, failureURL, http.StatusSeeOther)
+	// The order could not be saved yet, the processing page waits until the queued update is stored
+	if result.QueuedStatus == models.OrderStatusAuthorized || result.QueuedStatus == models.OrderStatusProcessing {
+		h.clearCart(r)
+		redirectToProcessing(w, r, result.Order.Reference)
 		return
 	}
 
+	switch {
+	case result.Order.IsPaid():
+		// Authorized, or captured or refunded by a webhook before the shopper returned
+	case result.Order.IsProcessing():
+		// The payment was submitted, e.g. a bank transfer, and its outcome arrives by webhook
+		h.clearCart(r)
+		redirectToProcessing(w, r, result.Order.Reference)
+		return
+	default:
+		redirectToFailure(w, r, result.Order.Reference, result.ResultCode)
+		return
+	}
+
+	h.clearCart(r)
+
 	// Render confirmation page
 	data := ConfirmationData{
 		Order:  result.Order,
@@ -90,3 +105,12 @@ func (h *ConfirmationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to render page", http.StatusInternalServerError)
 	}
 }
+
+// clearCart empties the shopper's cart once it has been paid for
+func (h *ConfirmationHandler) clearCart(r *http.Request) {
+	if cartID := cartIDFromRequest(r); cartID != "" {
+		if err := h.cartService.ClearCart(r.Context(), cartID); err != nil {
+			log.Printf("Warning: failed to clear cart %s: %v", cartID, err)
+		}
+	}
+}
@@ -33,16 +33,77 @@ func TestConfirmationHandler_ServeHTTP(t *testing.T) {
 					Reference:    "ORDER-12345",
 					Amount:       2500,
 					Currency:     "USD",
-					ProductName:  "Premium Widget",
+					ProductName:  "Premium Widget and 1 more",
 					Status:       models.OrderStatusAuthorized,
 					PSPReference: "PSP-67890",
+					Items: []models.OrderItem{
+						{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 3},
+						{SKU: "gadget-001", Name: "Deluxe Gadget", UnitPrice: 1000, Quantity: 1},
+					},
 				},
 				ResultCode:   "Authorised",
 				PSPReference: "PSP-67890",
 				Status:       string(models.OrderStatusAuthorized),
 			},
 			expectedStatus: http.StatusOK,
-			checkContent:   []string{"ORDER-12345", "Authorized"},
+			checkContent:   []string{"ORDER-12345", "Authorized", "Premium Widget", "Quantity: 3", "$15.00", "Deluxe Gadget", "$25.00"},
+		},
+		{
+			name:        "captured order shows the confirmation page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-123&sessionResult=result-abc",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference:   "ORDER-12346",
+					Amount:      500,
+					Currency:    "USD",
+					ProductName: "Premium Widget",
+					Status:      models.OrderStatusCaptured,
+					Items:       []models.OrderItem{{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 1}},
+				},
+				ResultCode: "Authorised",
+				Status:     string(models.OrderStatusCaptured),
+			},
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"ORDER-12346", "Premium Widget"},
+		},
+		{
+			name:        "refunded order shows the confirmation page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-123&sessionResult=result-abc",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference:   "ORDER-12347",
+					Amount:      500,
+					Currency:    "USD",
+					ProductName: "Premium Widget",
+					Status:      models.OrderStatusRefunded,
+					Items:       []models.OrderItem{{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 1}},
+				},
+				ResultCode: "Authorised",
+				Status:     string(models.OrderStatusRefunded),
+			},
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"ORDER-12347", "Premium Widget"},
+		},
+		{
+			name:        "partially refunded order shows the confirmation page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-123&sessionResult=result-abc",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference:   "ORDER-12348",
+					Amount:      500,
+					Currency:    "USD",
+					ProductName: "Premium Widget",
+					Status:      models.OrderStatusPartiallyRefunded,
+					Items:       []models.OrderItem{{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 1}},
+				},
+				ResultCode: "Authorised",
+				Status:     string(models.OrderStatusPartiallyRefunded),
+			},
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"ORDER-12348", "Premium Widget"},
 		},
 		{
 			name:        "failed payment redirects to failure page",
@@ -82,6 +143,62 @@ func TestConfirmationHandler_ServeHTTP(t *testing.T) {
 			expectedLocation:  "/order/failed?reference=ORDER-88888&reason=Cancelled",
 			skipTemplateCheck: true,
 		},
+		{
+			name:        "pending payment redirects to processing page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-789&sessionResult=result-def",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference:   "ORDER-77777",
+					Amount:      2500,
+					Currency:    "EUR",
+					ProductName: "Widget",
+					Status:      models.OrderStatusProcessing,
+				},
+				ResultCode: "Received",
+				Status:     string(models.OrderStatusProcessing),
+			},
+			expectedStatus:    http.StatusSeeOther,
+			expectedLocation:  "/order/processing?reference=ORDER-77777",
+			skipTemplateCheck: true,
+		},
+		{
+			name:        "queued order update redirects to processing page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-654&sessionResult=result-ghi",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference:   "ORDER-66666",
+					Amount:      2500,
+					Currency:    "EUR",
+					ProductName: "Widget",
+					Status:      models.OrderStatusPending,
+				},
+				ResultCode:   "Authorised",
+				Status:       string(models.OrderStatusPending),
+				QueuedStatus: models.OrderStatusAuthorized,
+			},
+			expectedStatus:    http.StatusSeeOther,
+			expectedLocation:  "/order/processing?reference=ORDER-66666",
+			skipTemplateCheck: true,
+		},
+		{
+			name:        "queued failure redirects to failure page",
+			method:      http.MethodGet,
+			queryParams: "?sessionId=sess-655&sessionResult=result-jkl",
+			mockVerifyResult: &services.PaymentVerificationResult{
+				Order: &models.Order{
+					Reference: "ORDER-66667",
+					Status:    models.OrderStatusPending,
+				},
+				ResultCode:   "Refused",
+				Status:       string(models.OrderStatusPending),
+				QueuedStatus: models.OrderStatusFailed,
+			},
+			expectedStatus:    http.StatusSeeOther,
+			expectedLocation:  "/order/failed?reference=ORDER-66667&reason=Refused",
+			skipTemplateCheck: true,
+		},
 		{
 			name:              "missing sessionId parameter",
 			method:            http.MethodGet,
@@ -104,6 +221,14 @@ func TestConfirmationHandler_ServeHTTP(t *testing.T) {
 			expectedStatus:    http.StatusInternalServerError,
 			skipTemplateCheck: true,
 		},
+		{
+			name:              "Adyen authentication error",
+			method:            http.MethodGet,
+			queryParams:       "?sessionId=sess-123&sessionResult=result-abc",
+			mockVerifyError:   &services.AdyenAPIError{Status: http.StatusUnauthorized, ErrorType: services.AdyenErrorTypeSecurity},
+			expectedStatus:    http.StatusBadGateway,
+			skipTemplateCheck: true,
+		},
 		{
 			name:              "method not allowed - POST",
 			method:            http.MethodPost,
@@ -133,7 +258,7 @@ func TestConfirmationHandler_ServeHTTP(t *testing.T) {
 			}
 
 			// Create handler
-			handler, err := NewConfirmationHandler("../../templates/confirmation.html", mockService)
+			handler, err := NewConfirmationHandler("../../templates/confirmation.html", mockService, &MockCartService{})
 			if err != nil {
 				if tt.skipTemplateCheck {
 					t.Skip("Template file not available for this test")
@@ -175,6 +300,56 @@ func TestConfirmationHandler_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestConfirmationHandler_ClearsCart(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        models.OrderStatus
+		queuedStatus  models.OrderStatus
+		expectCleared bool
+	}{
+		{"authorized payment clears cart", models.OrderStatusAuthorized, "", true},
+		{"processing payment clears cart", models.OrderStatusProcessing, "", true},
+		{"failed payment keeps cart", models.OrderStatusFailed, "", false},
+		{"queued authorization clears cart", models.OrderStatusPending, models.OrderStatusAuthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockPaymentService{
+				VerifyPaymentFunc: func(sessionID, sessionResult string) (*services.PaymentVerificationResult, error) {
+					return &services.PaymentVerificationResult{
+						Order:        &models.Order{Reference: "ORDER-123", Amount: 100, Currency: "USD", ProductName: "Test", Status: tt.status},
+						Status:       string(tt.status),
+						QueuedStatus: tt.queuedStatus,
+					}, nil
+				},
+			}
+
+			var clearedCart string
+			cartService := &MockCartService{
+				ClearCartFunc: func(cartID string) error {
+					clearedCart = cartID
+					return nil
+				},
+			}
+
+			handler, err := NewConfirmationHandler("../../templates/confirmation.html", mockService, cartService)
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := withCartCookie(httptest.NewRequest(http.MethodGet, "/order/confirmation?sessionId=sess-123", nil), "cart-1")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if cleared := clearedCart == "cart-1"; cleared != tt.expectCleared {
+				t.Errorf("expected cart cleared %v, got cleared cart %q", tt.expectCleared, clearedCart)
+			}
+		})
+	}
+}
+
 func TestConfirmationHandler_ServiceInvocation(t *testing.T) {
 	// Test that the handler calls verify payment with correct parameters
 	var capturedSessionID string
@@ -199,7 +374,7 @@ func TestConfirmationHandler_ServiceInvocation(t *testing.T) {
 		},
 	}
 
-	handler, err := NewConfirmationHandler("../../templates/confirmation.html", mockService)
+	handler, err := NewConfirmationHandler("../../templates/confirmation.html", mockService, &MockCartService{})
 	if err != nil {
 		t.Fatalf("Failed to create handler: %v", err)
 	}
@@ -241,7 +416,7 @@ func TestNewConfirmationHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler, err := NewConfirmationHandler(tt.templatePath, mockService)
+			handler, err := NewConfirmationHandler(tt.templatePath, mockService, &MockCartService{})
 
 			if tt.wantErr && err == nil {
 				t.Error("expected error but got none")
@@ -277,9 +452,7 @@ func TestConfirmationHandler_TemplateExecutionError(t *testing.T) {
 
 	// Create a handler with a malformed template
 	funcMap := template.FuncMap{
-		"divf": func(a int64, b float64) float64 {
-			return float64(a) / b
-		},
+		"formatAmount": models.FormatAmount,
 	}
 	tmpl, err := template.New("confirmation.html").Funcs(funcMap).Parse("{{.InvalidField.NonExistent}}")
 	if err != nil {
@@ -289,6 +462,7 @@ func TestConfirmationHandler_TemplateExecutionError(t *testing.T) {
 	handler := &ConfirmationHandler{
 		template:       tmpl,
 		paymentService: mockService,
+		cartService:    &MockCartService{},
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/order/confirmation?sessionId=sess-123&sessionResult=result-abc", nil)
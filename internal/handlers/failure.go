@@ -6,17 +6,17 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/adyen/ecommerce/internal/repository"
+	"github.com/adyen/ecommerce/internal/services"
 )
 
 // FailureHandler handles payment failure page
 type FailureHandler struct {
 	template  *template.Template
-	orderRepo *repository.OrderRepository
+	orderRepo services.OrderRepository
 }
 
 // NewFailureHandler creates a new failure handler
-func NewFailureHandler(templatePath string, orderRepo *repository.OrderRepository) (*FailureHandler, error) {
+func NewFailureHandler(templatePath string, orderRepo services.OrderRepository) (*FailureHandler, error) {
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
@@ -68,6 +68,8 @@ func getFailureMessage(reason string) string {
 		return "Your payment was declined. Please check your payment details and try again."
 	case "Cancelled":
 		return "The payment was cancelled. You can try again when you're ready."
+	case "Expired":
+		return "Your payment session expired before the payment was completed. Please place your order again."
 	case "Error":
 		return "An error occurred while processing your payment. Please try again."
 	default:
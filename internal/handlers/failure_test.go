@@ -144,6 +144,11 @@ func TestGetFailureMessage(t *testing.T) {
 			reason:         "Error",
 			expectedPhrase: "error occurred",
 		},
+		{
+			name:           "expired session",
+			reason:         "Expired",
+			expectedPhrase: "session expired",
+		},
 		{
 			name:           "unknown reason",
 			reason:         "SomeUnknownReason",
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Order access cookie settings
+const (
+	orderAccessCookieName   = "order_access"
+	orderAccessCookieMaxAge = 30 * 24 * 60 * 60 // 30 days, long enough for delayed payment methods
+	maxOrderAccessRefs      = 10
+	// checkoutCookieName holds the order whose payment session a checkout reload resumes
+	checkoutCookieName = "checkout_order"
+)
+
+// OrderAccess remembers which orders a shopper placed in a signed cookie, so that
+// order details are only shown to the browser that created the order
+type OrderAccess struct {
+	secret []byte
+}
+
+// NewOrderAccess creates an order access checker signing cookies with secret
+func NewOrderAccess(secret []byte) *OrderAccess {
+	return &OrderAccess{secret: secret}
+}
+
+// Grant adds reference to the shopper's order access cookie. Only the most recent
+// orders are kept.
+func (a *OrderAccess) Grant(w http.ResponseWriter, r *http.Request, reference string) {
+	references := slices.DeleteFunc(a.references(r), func(ref string) bool { return ref == reference })
+	references = append(references, reference)
+	if len(references) > maxOrderAccessRefs {
+		references = references[len(references)-maxOrderAccessRefs:]
+	}
+
+	payload, err := json.Marshal(references)
+	if err != nil {
+		return
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     orderAccessCookieName,
+		Value:    encoded + "." + a.sign(encoded),
+		Path:     "/",
+		MaxAge:   orderAccessCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Allowed returns true if the request carries a valid cookie granting access to reference
+func (a *OrderAccess) Allowed(r *http.Request, reference string) bool {
+	return slices.Contains(a.references(r), reference)
+}
+
+// references returns the order references in the request's cookie, or nil if the
+// cookie is missing or its signature does not match
+func (a *OrderAccess) references(r *http.Request) []string {
+	cookie, err := r.Cookie(orderAccessCookieName)
+	if err != nil {
+		return nil
+	}
+
+	encoded, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(encoded))) {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	var references []string
+	if err := json.Unmarshal(payload, &references); err != nil {
+		return nil
+	}
+	return references
+}
+
+// RememberCheckout binds the shopper's checkout to the order, so that reloading the checkout
+// page resumes its payment session instead of creating another order
+func (a *OrderAccess) RememberCheckout(w http.ResponseWriter, reference string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     checkoutCookieName,
+		Value:    reference + "." + a.sign(checkoutCookieName+":"+reference),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// CheckoutReference returns the order bound to the shopper's checkout, or an empty string
+// if there is none or the cookie's signature does not match
+func (a *OrderAccess) CheckoutReference(r *http.Request) string {
+	cookie, err := r.Cookie(checkoutCookieName)
+	if err != nil {
+		return ""
+	}
+
+	reference, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(checkoutCookieName+":"+reference))) {
+		return ""
+	}
+	return reference
+}
+
+// sign returns the HMAC-SHA256 signature of value
+func (a *OrderAccess) sign(value string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testOrderAccess returns an order access checker with a fixed secret
+func testOrderAccess() *OrderAccess {
+	return NewOrderAccess([]byte("test-secret"))
+}
+
+// withOrderAccess adds a cookie granting access to the given orders to the request
+func withOrderAccess(req *http.Request, access *OrderAccess, references ...string) *http.Request {
+	w := httptest.NewRecorder()
+	for _, reference := range references {
+		grantReq := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, cookie := range w.Result().Cookies() {
+			grantReq.AddCookie(cookie)
+		}
+		w = httptest.NewRecorder()
+		access.Grant(w, grantReq, reference)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestOrderAccess_Allowed(t *testing.T) {
+	access := testOrderAccess()
+
+	tests := []struct {
+		name      string
+		request   func() *http.Request
+		reference string
+		expected  bool
+	}{
+		{
+			name: "granted order",
+			request: func() *http.Request {
+				return withOrderAccess(httptest.NewRequest(http.MethodGet, "/", nil), access, "ORDER-1")
+			},
+			reference: "ORDER-1",
+			expected:  true,
+		},
+		{
+			name: "earlier order is kept",
+			request: func() *http.Request {
+				return withOrderAccess(httptest.NewRequest(http.MethodGet, "/", nil), access, "ORDER-1", "ORDER-2")
+			},
+			reference: "ORDER-1",
+			expected:  true,
+		},
+		{
+			name: "other order",
+			request: func() *http.Request {
+				return withOrderAccess(httptest.NewRequest(http.MethodGet, "/", nil), access, "ORDER-1")
+			},
+			reference: "ORDER-2",
+			expected:  false,
+		},
+		{
+			name: "no cookie",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/", nil)
+			},
+			reference: "ORDER-1",
+			expected:  false,
+		},
+		{
+			name: "cookie signed with another secret",
+			request: func() *http.Request {
+				return withOrderAccess(httptest.NewRequest(http.MethodGet, "/", nil), NewOrderAccess([]byte("other-secret")), "ORDER-1")
+			},
+			reference: "ORDER-1",
+			expected:  false,
+		},
+		{
+			name: "tampered cookie",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				// ["ORDER-1"] with a made up signature
+				req.AddCookie(&http.Cookie{Name: orderAccessCookieName, Value: "WyJPUkRFUi0xIl0.c2lnbmF0dXJl"})
+				return req
+			},
+			reference: "ORDER-1",
+			expected:  false,
+		},
+		{
+			name: "malformed cookie",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(&http.Cookie{Name: orderAccessCookieName, Value: "ORDER-1"})
+				return req
+			},
+			reference: "ORDER-1",
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := access.Allowed(tt.request(), tt.reference); allowed != tt.expected {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.reference, allowed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrderAccess_KeepsMostRecentOrders(t *testing.T) {
+	access := testOrderAccess()
+
+	references := make([]string, maxOrderAccessRefs+1)
+	for i := range references {
+		references[i] = fmt.Sprintf("ORDER-%d", i)
+	}
+	req := withOrderAccess(httptest.NewRequest(http.MethodGet, "/", nil), access, references...)
+
+	if access.Allowed(req, references[0]) {
+		t.Errorf("expected the oldest order %s to be dropped", references[0])
+	}
+	for _, reference := range references[1:] {
+		if !access.Allowed(req, reference) {
+			t.Errorf("expected access to %s", reference)
+		}
+	}
+}
+
+func TestOrderAccess_GrantSetsCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	testOrderAccess().Grant(w, httptest.NewRequest(http.MethodPost, "/api/sessions", nil), "ORDER-1")
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != orderAccessCookieName || cookie.Path != "/" || !cookie.HttpOnly || cookie.MaxAge != orderAccessCookieMaxAge {
+		t.Errorf("unexpected cookie %+v", cookie)
+	}
+}
+
+func TestOrderAccess_CheckoutReference(t *testing.T) {
+	access := testOrderAccess()
+	w := httptest.NewRecorder()
+	access.RememberCheckout(w, "ORDER-1")
+	cookie := w.Result().Cookies()[0]
+
+	tests := []struct {
+		name     string
+		cookie   *http.Cookie
+		expected string
+	}{
+		{name: "remembered checkout", cookie: cookie, expected: "ORDER-1"},
+		{name: "no cookie"},
+		{name: "other order with the same signature", cookie: &http.Cookie{Name: checkoutCookieName, Value: "ORDER-2" + cookie.Value[len("ORDER-1"):]}},
+		{name: "unsigned cookie", cookie: &http.Cookie{Name: checkoutCookieName, Value: "ORDER-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			if got := access.CheckoutReference(req); got != tt.expected {
+				t.Errorf("CheckoutReference() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
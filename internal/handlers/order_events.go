@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// orderEventsKeepAlive is how often an idle event stream sends a comment, so proxies
+// do not close the connection
+const orderEventsKeepAlive = 15 * time.Second
+
+// orderEventsPoll is how often an open event stream reloads the order, to pick up transitions
+// made by other processes, e.g. `simplecom orders` commands, which the in-process bus misses
+const orderEventsPoll = 5 * time.Second
+
+// OrderEventsHandler streams an order's status transitions to the shopper who placed it
+// as Server-Sent Events
+type OrderEventsHandler struct {
+	orderRepo   services.OrderRepository
+	events      services.OrderEventBus
+	orderAccess *OrderAccess
+	keepAlive   time.Duration
+	poll        time.Duration
+}
+
+// NewOrderEventsHandler creates a new order events handler
+func NewOrderEventsHandler(orderRepo services.OrderRepository, events services.OrderEventBus, orderAccess *OrderAccess) *OrderEventsHandler {
+	return &OrderEventsHandler{
+		orderRepo:   orderRepo,
+		events:      events,
+		orderAccess: orderAccess,
+		keepAlive:   orderEventsKeepAlive,
+		poll:        orderEventsPoll,
+	}
+}
+
+// ServeHTTP handles the event stream request. The stream starts with the current status
+// and then sends a "status" event for every transition until the client disconnects or
+// the server shuts down. Transitions published on the bus are sent at once, those made by
+// other processes once the stream reloads the order.
+func (h *OrderEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reference := r.PathValue("reference")
+	if reference == "" || !h.orderAccess.Allowed(r, reference) {
+		sendErrorResponse(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendErrorResponse(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before loading the order so no transition is missed in between
+	events, unsubscribe := h.events.Subscribe(reference)
+	defer unsubscribe()
+
+	order, err := h.orderRepo.GetOrderByReference(r.Context(), reference)
+	if errors.Is(err, models.ErrOrderNotFound) {
+		sendErrorResponse(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading order %s: %v", reference, err)
+		sendErrorResponse(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeStatusEvent(w, newOrderStatusResponse(order)); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+	poll := time.NewTicker(h.poll)
+	defer poll.Stop()
+	lastStatus := order.Status
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// The bus closed, e.g. because the server is shutting down
+				return
+			}
+			if err := writeStatusEvent(w, OrderStatusResponse{
+				Reference: event.Reference,
+				Status:    event.Status,
+				Amount:    event.Amount,
+				Currency:  event.Currency,
+				UpdatedAt: event.UpdatedAt,
+			}); err != nil {
+				return
+			}
+			lastStatus = event.Status
+		case <-poll.C:
+			order, err := h.orderRepo.GetOrderByReference(r.Context(), reference)
+			if err != nil {
+				if r.Context().Err() == nil {
+					log.Printf("Error reloading order %s for its event stream: %v", reference, err)
+				}
+				continue
+			}
+			if order.Status == lastStatus {
+				continue
+			}
+			if err := writeStatusEvent(w, newOrderStatusResponse(order)); err != nil {
+				return
+			}
+			lastStatus = order.Status
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeStatusEvent writes an order status as a Server-Sent Event
+func writeStatusEvent(w http.ResponseWriter, status OrderStatusResponse) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode order status: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// newTestOrderEventsHandler creates an events handler for an order ORDER-EVENTS-001 in processing
+func newTestOrderEventsHandler(t *testing.T) (*OrderEventsHandler, services.OrderEventBus, *OrderAccess) {
+	t.Helper()
+
+	orderRepo := repository.NewMemoryOrderRepository()
+	order := &models.Order{
+		ID:        "order-1",
+		Reference: "ORDER-EVENTS-001",
+		Amount:    2500,
+		Currency:  "USD",
+		Status:    models.OrderStatusProcessing,
+	}
+	if err := orderRepo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	events := services.NewOrderEventBus()
+	access := testOrderAccess()
+	t.Cleanup(events.Close)
+
+	return NewOrderEventsHandler(orderRepo, events, access), events, access
+}
+
+// orderEventsMux routes the events endpoint to handler, so the reference path value is set
+func orderEventsMux(handler http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/api/orders/{reference}/events", handler)
+	return mux
+}
+
+// serveOrderEvents serves handler on a test server, which streams responses unlike a recorder
+func serveOrderEvents(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(orderEventsMux(handler))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openOrderEvents opens the event stream for reference with access to the given orders
+func openOrderEvents(t *testing.T, server *httptest.Server, access *OrderAccess, reference string, granted ...string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, server.URL+"/api/orders/"+reference+"/events", nil)
+	req.RequestURI = ""
+	withOrderAccess(req, access, granted...)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readEvent reads the next event or comment block from the stream
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+// statusFromEvent decodes the data of a status event
+func statusFromEvent(t *testing.T, event string) OrderStatusResponse {
+	t.Helper()
+
+	eventType, data, ok := strings.Cut(event, "\n")
+	if !ok || eventType != "event: status" || !strings.HasPrefix(data, "data: ") {
+		t.Fatalf("expected a status event, got %q", event)
+	}
+	var status OrderStatusResponse
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &status); err != nil {
+		t.Fatalf("failed to decode event data: %v", err)
+	}
+	return status
+}
+
+func TestOrderEventsHandler_StreamsStatusTransitions(t *testing.T) {
+	handler, events, access := newTestOrderEventsHandler(t)
+	server := serveOrderEvents(t, handler)
+
+	resp := openOrderEvents(t, server, access, "ORDER-EVENTS-001", "ORDER-EVENTS-001")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("expected Content-Type text/event-stream, got %s", contentType)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	// The stream starts with the current status
+	if status := statusFromEvent(t, readEvent(t, reader)); status.Status != models.OrderStatusProcessing || status.Amount != 2500 {
+		t.Errorf("expected the processing order, got %+v", status)
+	}
+
+	// Events for other orders are not sent
+	events.Publish(services.OrderEvent{Reference: "ORDER-OTHER", Status: models.OrderStatusFailed})
+	events.Publish(services.OrderEvent{
+		Reference:      "ORDER-EVENTS-001",
+		Status:         models.OrderStatusAuthorized,
+		PreviousStatus: models.OrderStatusProcessing,
+		Amount:         2500,
+		Currency:       "USD",
+		UpdatedAt:      time.Now(),
+	})
+
+	status := statusFromEvent(t, readEvent(t, reader))
+	if status.Reference != "ORDER-EVENTS-001" || status.Status != models.OrderStatusAuthorized {
+		t.Errorf("expected ORDER-EVENTS-001 to be authorized, got %+v", status)
+	}
+
+	// Closing the bus ends the stream
+	events.Close()
+	if _, err := reader.ReadString('\n'); err != io.EOF {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestOrderEventsHandler_KeepAlive(t *testing.T) {
+	handler, _, access := newTestOrderEventsHandler(t)
+	handler.keepAlive = 10 * time.Millisecond
+	server := serveOrderEvents(t, handler)
+
+	resp := openOrderEvents(t, server, access, "ORDER-EVENTS-001", "ORDER-EVENTS-001")
+	reader := bufio.NewReader(resp.Body)
+
+	statusFromEvent(t, readEvent(t, reader))
+	if comment := readEvent(t, reader); comment != ": keep-alive" {
+		t.Errorf("expected a keep-alive comment, got %q", comment)
+	}
+}
+
+func TestOrderEventsHandler_PollsChangesOfOtherProcesses(t *testing.T) {
+	handler, _, access := newTestOrderEventsHandler(t)
+	handler.poll = 10 * time.Millisecond
+	server := serveOrderEvents(t, handler)
+
+	resp := openOrderEvents(t, server, access, "ORDER-EVENTS-001", "ORDER-EVENTS-001")
+	reader := bufio.NewReader(resp.Body)
+	statusFromEvent(t, readEvent(t, reader))
+
+	// A `simplecom orders` command stores the change without publishing it on this bus
+	ctx := context.Background()
+	order, _ := handler.orderRepo.GetOrderByReference(ctx, "ORDER-EVENTS-001")
+	if err := handler.orderRepo.UpdateOrderStatus(ctx, "ORDER-EVENTS-001", string(models.OrderStatusCancelRequested), "", order.Version, models.OrderChange{}); err != nil {
+		t.Fatalf("Failed to update order: %v", err)
+	}
+
+	if status := statusFromEvent(t, readEvent(t, reader)); status.Status != models.OrderStatusCancelRequested {
+		t.Errorf("expected the order to wait for its cancellation, got %+v", status)
+	}
+}
+
+func TestOrderEventsHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		reference      string
+		granted        []string
+		expectedStatus int
+	}{
+		{
+			name:           "order placed by another shopper",
+			method:         http.MethodGet,
+			reference:      "ORDER-EVENTS-001",
+			granted:        []string{"ORDER-OTHER"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "no order access cookie",
+			method:         http.MethodGet,
+			reference:      "ORDER-EVENTS-001",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown order",
+			method:         http.MethodGet,
+			reference:      "ORDER-MISSING",
+			granted:        []string{"ORDER-MISSING"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "method not allowed - POST",
+			method:         http.MethodPost,
+			reference:      "ORDER-EVENTS-001",
+			granted:        []string{"ORDER-EVENTS-001"},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, access := newTestOrderEventsHandler(t)
+
+			req := httptest.NewRequest(tt.method, "/api/orders/"+tt.reference+"/events", nil)
+			withOrderAccess(req, access, tt.granted...)
+			w := httptest.NewRecorder()
+
+			orderEventsMux(handler).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestOrderEventsHandler_StreamingUnsupported(t *testing.T) {
+	handler := NewOrderEventsHandler(repository.NewMemoryOrderRepository(), services.NewOrderEventBus(), testOrderAccess())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/ORDER-1/events", nil)
+	req.SetPathValue("reference", "ORDER-1")
+	withOrderAccess(req, testOrderAccess(), "ORDER-1")
+
+	// failingWriter does not implement http.Flusher
+	w := &failingWriter{header: make(http.Header)}
+	handler.ServeHTTP(w, req)
+
+	if w.statusCode != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.statusCode)
+	}
+}
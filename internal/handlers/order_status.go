@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// OrderStatusHandler reports the current status of an order to the shopper who placed it
+type OrderStatusHandler struct {
+	orderRepo   services.OrderRepository
+	orderAccess *OrderAccess
+}
+
+// NewOrderStatusHandler creates a new order status handler
+func NewOrderStatusHandler(orderRepo services.OrderRepository, orderAccess *OrderAccess) *OrderStatusHandler {
+	return &OrderStatusHandler{
+		orderRepo:   orderRepo,
+		orderAccess: orderAccess,
+	}
+}
+
+// OrderStatusResponse represents the order status sent to the client
+type OrderStatusResponse struct {
+	Reference string             `json:"reference"`
+	Status    models.OrderStatus `json:"status"`
+	Amount    int64              `json:"amount"`
+	Currency  string             `json:"currency"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// ServeHTTP handles the order status request. Orders the shopper did not place are
+// reported as not found, so references cannot be probed.
+func (h *OrderStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reference := r.PathValue("reference")
+	if reference == "" || !h.orderAccess.Allowed(r, reference) {
+		sendErrorResponse(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	order, err := h.orderRepo.GetOrderByReference(r.Context(), reference)
+	if errors.Is(err, models.ErrOrderNotFound) {
+		sendErrorResponse(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading order %s: %v", reference, err)
+		sendErrorResponse(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(newOrderStatusResponse(order)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// newOrderStatusResponse builds the client's view of an order's status
+func newOrderStatusResponse(order *models.Order) OrderStatusResponse {
+	return OrderStatusResponse{
+		Reference: order.Reference,
+		Status:    order.Status,
+		Amount:    order.Amount,
+		Currency:  order.Currency,
+		UpdatedAt: order.UpdatedAt,
+	}
+}
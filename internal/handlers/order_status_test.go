@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// failingOrderRepository is an order repository whose lookups always fail
+type failingOrderRepository struct {
+	services.OrderRepository
+}
+
+func (failingOrderRepository) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestOrderStatusHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		reference      string
+		grantedRefs    []string
+		orderRepo      func() services.OrderRepository
+		expectedStatus int
+		expectedOrder  *OrderStatusResponse
+	}{
+		{
+			name:           "processing order",
+			method:         http.MethodGet,
+			reference:      "ORDER-STATUS-001",
+			grantedRefs:    []string{"ORDER-STATUS-001"},
+			expectedStatus: http.StatusOK,
+			expectedOrder: &OrderStatusResponse{
+				Reference: "ORDER-STATUS-001",
+				Status:    models.OrderStatusProcessing,
+				Amount:    2500,
+				Currency:  "USD",
+			},
+		},
+		{
+			name:           "order placed by another shopper",
+			method:         http.MethodGet,
+			reference:      "ORDER-STATUS-001",
+			grantedRefs:    []string{"ORDER-OTHER"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "no order access cookie",
+			method:         http.MethodGet,
+			reference:      "ORDER-STATUS-001",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown order",
+			method:         http.MethodGet,
+			reference:      "ORDER-MISSING",
+			grantedRefs:    []string{"ORDER-MISSING"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "repository error",
+			method:      http.MethodGet,
+			reference:   "ORDER-STATUS-001",
+			grantedRefs: []string{"ORDER-STATUS-001"},
+			orderRepo: func() services.OrderRepository {
+				return failingOrderRepository{}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "method not allowed - POST",
+			method:         http.MethodPost,
+			reference:      "ORDER-STATUS-001",
+			grantedRefs:    []string{"ORDER-STATUS-001"},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var orderRepo services.OrderRepository
+			var updatedAt time.Time
+			if tt.orderRepo != nil {
+				orderRepo = tt.orderRepo()
+			} else {
+				memoryRepo := repository.NewMemoryOrderRepository()
+				order := &models.Order{
+					ID:        "order-1",
+					Reference: "ORDER-STATUS-001",
+					Amount:    2500,
+					Currency:  "USD",
+					Status:    models.OrderStatusProcessing,
+				}
+				if err := memoryRepo.CreateOrder(context.Background(), order); err != nil {
+					t.Fatalf("Failed to create order: %v", err)
+				}
+				orderRepo = memoryRepo
+				updatedAt = order.UpdatedAt
+			}
+
+			access := testOrderAccess()
+			handler := NewOrderStatusHandler(orderRepo, access)
+
+			// Route through a mux so the reference path value is set
+			mux := http.NewServeMux()
+			mux.Handle("/api/orders/{reference}/status", handler)
+
+			req := httptest.NewRequest(tt.method, "/api/orders/"+tt.reference+"/status", nil)
+			if len(tt.grantedRefs) > 0 {
+				withOrderAccess(req, access, tt.grantedRefs...)
+			}
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedOrder == nil {
+				return
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %s", contentType)
+			}
+			if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "no-store" {
+				t.Errorf("expected Cache-Control no-store, got %s", cacheControl)
+			}
+
+			var response OrderStatusResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.UpdatedAt.IsZero() || !response.UpdatedAt.Equal(updatedAt) {
+				t.Errorf("expected updatedAt %v, got %v", updatedAt, response.UpdatedAt)
+			}
+			response.UpdatedAt = time.Time{}
+			if response != *tt.expectedOrder {
+				t.Errorf("expected %+v, got %+v", *tt.expectedOrder, response)
+			}
+		})
+	}
+}
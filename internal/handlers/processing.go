@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// processingRefreshSeconds is how often the processing page checks the order again
+const processingRefreshSeconds = 5
+
+// ProcessingHandler handles the page shown while a submitted payment awaits its outcome
+type ProcessingHandler struct {
+	template    *template.Template
+	orderRepo   services.OrderRepository
+	orderAccess *OrderAccess
+}
+
+// NewProcessingHandler creates a new processing handler
+func NewProcessingHandler(templatePath string, orderRepo services.OrderRepository, orderAccess *OrderAccess) (*ProcessingHandler, error) {
+	funcMap := template.FuncMap{
+		"formatAmount": models.FormatAmount,
+	}
+
+	tmpl, err := template.New("processing.html").Funcs(funcMap).ParseFiles(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return &ProcessingHandler{
+		template:    tmpl,
+		orderRepo:   orderRepo,
+		orderAccess: orderAccess,
+	}, nil
+}
+
+// ProcessingData represents the data for the processing template
+type ProcessingData struct {
+	Order          *models.Order
+	Confirmed      bool
+	RefreshSeconds int
+}
+
+// ServeHTTP handles the processing page request. The page polls the order status API
+// until the AUTHORISATION webhook settles the order, then reloads to show the outcome.
+// Only the shopper who placed the order can see it.
+func (h *ProcessingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reference := r.URL.Query().Get("reference")
+	if reference == "" {
+		http.Error(w, "Missing order reference", http.StatusBadRequest)
+		return
+	}
+
+	if !h.orderAccess.Allowed(r, reference) {
+		http.NotFound(w, r)
+		return
+	}
+
+	order, err := h.orderRepo.GetOrderByReference(r.Context(), reference)
+	if errors.Is(err, models.ErrOrderNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading order %s: %v", reference, err)
+		http.Error(w, "Failed to load order", http.StatusInternalServerError)
+		return
+	}
+
+	data := ProcessingData{Order: order}
+	switch {
+	case order.IsPaid():
+		data.Confirmed = true
+	case order.IsPending(), order.IsProcessing():
+		data.RefreshSeconds = processingRefreshSeconds
+	case order.IsCancelled(), order.IsCancelRequested():
+		redirectToFailure(w, r, order.Reference, "Cancelled")
+		return
+	case order.Status == models.OrderStatusExpired:
+		redirectToFailure(w, r, order.Reference, "Expired")
+		return
+	default:
+		redirectToFailure(w, r, order.Reference, "Refused")
+		return
+	}
+
+	if err := h.template.Execute(w, data); err != nil {
+		log.Printf("Error rendering template: %v", err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+	}
+}
+
+// redirectToProcessing sends the shopper to the processing page, which waits for the order's outcome
+func redirectToProcessing(w http.ResponseWriter, r *http.Request, reference string) {
+	http.Redirect(w, r, "/order/processing?reference="+url.QueryEscape(reference), http.StatusSeeOther)
+}
+
+// redirectToFailure sends the shopper to the failure page for the order
+func redirectToFailure(w http.ResponseWriter, r *http.Request, reference, reason string) {
+	failureURL := fmt.Sprintf("/order/failed?reference=%s&reason=%s", url.QueryEscape(reference), url.QueryEscape(reason))
+	http.Redirect(w, r, failureURL, http.StatusSeeOther)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+)
+
+func TestProcessingHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		queryParams      string
+		orderStatus      models.OrderStatus
+		expectedStatus   int
+		expectedLocation string
+		checkContent     []string
+		expectRefresh    bool
+		withoutAccess    bool
+	}{
+		{
+			name:           "processing order waits for the webhook",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusProcessing,
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Payment Processing", "ORDER-PROC-001", "$25.00"},
+			expectRefresh:  true,
+		},
+		{
+			name:           "authorized order is confirmed",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusAuthorized,
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Payment Confirmed", "ORDER-PROC-001"},
+		},
+		{
+			name:           "captured order is confirmed",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusCaptured,
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Payment Confirmed", "ORDER-PROC-001"},
+		},
+		{
+			name:           "partially refunded order is confirmed",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusPartiallyRefunded,
+			expectedStatus: http.StatusOK,
+			checkContent:   []string{"Payment Confirmed", "ORDER-PROC-001"},
+		},
+		{
+			name:             "failed order redirects to failure page",
+			method:           http.MethodGet,
+			queryParams:      "?reference=ORDER-PROC-001",
+			orderStatus:      models.OrderStatusFailed,
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/order/failed?reference=ORDER-PROC-001&reason=Refused",
+		},
+		{
+			name:             "cancelled order redirects to failure page",
+			method:           http.MethodGet,
+			queryParams:      "?reference=ORDER-PROC-001",
+			orderStatus:      models.OrderStatusCancelled,
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/order/failed?reference=ORDER-PROC-001&reason=Cancelled",
+		},
+		{
+			name:             "order awaiting cancellation redirects to failure page",
+			method:           http.MethodGet,
+			queryParams:      "?reference=ORDER-PROC-001",
+			orderStatus:      models.OrderStatusCancelRequested,
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/order/failed?reference=ORDER-PROC-001&reason=Cancelled",
+		},
+		{
+			name:             "expired order redirects to failure page",
+			method:           http.MethodGet,
+			queryParams:      "?reference=ORDER-PROC-001",
+			orderStatus:      models.OrderStatusExpired,
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "/order/failed?reference=ORDER-PROC-001&reason=Expired",
+		},
+		{
+			name:           "unknown order",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-MISSING",
+			orderStatus:    models.OrderStatusProcessing,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "order placed by another shopper",
+			method:         http.MethodGet,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusProcessing,
+			expectedStatus: http.StatusNotFound,
+			withoutAccess:  true,
+		},
+		{
+			name:           "missing reference",
+			method:         http.MethodGet,
+			orderStatus:    models.OrderStatusProcessing,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "method not allowed - POST",
+			method:         http.MethodPost,
+			queryParams:    "?reference=ORDER-PROC-001",
+			orderStatus:    models.OrderStatusProcessing,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := repository.NewMemoryOrderRepository()
+			order := &models.Order{
+				ID:           "order-1",
+				Reference:    "ORDER-PROC-001",
+				Amount:       2500,
+				Currency:     "USD",
+				Status:       tt.orderStatus,
+				ProductName:  "Widget",
+				PSPReference: "PSP-789",
+			}
+			if err := orderRepo.CreateOrder(context.Background(), order); err != nil {
+				t.Fatalf("Failed to create order: %v", err)
+			}
+
+			access := testOrderAccess()
+			handler, err := NewProcessingHandler("../../templates/processing.html", orderRepo, access)
+			if err != nil {
+				t.Fatalf("Failed to create handler: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, "/order/processing"+tt.queryParams, nil)
+			if !tt.withoutAccess {
+				withOrderAccess(req, access, "ORDER-PROC-001", "ORDER-MISSING")
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedLocation != "" {
+				if location := w.Header().Get("Location"); location != tt.expectedLocation {
+					t.Errorf("expected redirect to '%s', got '%s'", tt.expectedLocation, location)
+				}
+			}
+
+			body := w.Body.String()
+			for _, content := range tt.checkContent {
+				if !strings.Contains(body, content) {
+					t.Errorf("expected response to contain '%s'", content)
+				}
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if hasRefresh := strings.Contains(body, `http-equiv="refresh"`); hasRefresh != tt.expectRefresh {
+					t.Errorf("expected page refresh %v, got %v", tt.expectRefresh, hasRefresh)
+				}
+				if hasPolling := strings.Contains(body, "/static/js/processing.js"); hasPolling != tt.expectRefresh {
+					t.Errorf("expected status polling %v, got %v", tt.expectRefresh, hasPolling)
+				}
+			}
+		})
+	}
+}
+
+func TestNewProcessingHandler_InvalidTemplate(t *testing.T) {
+	handler, err := NewProcessingHandler("/invalid/path/to/processing.html", repository.NewMemoryOrderRepository(), testOrderAccess())
+	if err == nil || handler != nil {
+		t.Errorf("expected error and nil handler, got %v and %v", err, handler)
+	}
+}
@@ -1,46 +1,54 @@
 package handlers
 
 import (
+	"errors"
 	"html/template"
+	"log"
 	"net/http"
-)
 
-// Product represents a product item
-type Product struct {
-	Name        string
-	Description string
-	Price       string
-	ImageURL    string
-}
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/services"
+)
 
 // ProductHandler handles the product page requests
 type ProductHandler struct {
-	template *template.Template
-	product  Product
+	template       *template.Template
+	productService services.ProductService
 }
 
 // NewProductHandler creates a new ProductHandler
-func NewProductHandler(templatePath string, product Product) (*ProductHandler, error) {
+func NewProductHandler(templatePath string, productService services.ProductService) (*ProductHandler, error) {
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ProductHandler{
-		template: tmpl,
-		product:  product,
+		template:       tmpl,
+		productService: productService,
 	}, nil
 }
 
-// ServeHTTP handles the GET / request
+// ServeHTTP handles the GET /products/{sku} request
 func (h *ProductHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Render the template with the injected product
-	if err := h.template.Execute(w, h.product); err != nil {
+	product, err := h.productService.GetProductBySKU(r.Context(), r.PathValue("sku"))
+	if errors.Is(err, models.ErrProductNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading product: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Render the template with the catalog product
+	if err := h.template.Execute(w, product); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -1,44 +1,112 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
 )
 
+// MockProductService is a mock implementation of ProductService for testing
+type MockProductService struct {
+	ListProductsFunc    func() ([]*models.Product, error)
+	GetProductBySKUFunc func(string) (*models.Product, error)
+}
+
+func (m *MockProductService) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	if m.ListProductsFunc != nil {
+		return m.ListProductsFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockProductService) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	if m.GetProductBySKUFunc != nil {
+		return m.GetProductBySKUFunc(sku)
+	}
+	return nil, models.ErrProductNotFound
+}
+
+// testProduct returns a catalog product used across handler tests
+func testProduct() *models.Product {
+	return &models.Product{
+		SKU:         "test-001",
+		Name:        "Test Product",
+		Description: "A wonderful test product",
+		Price:       1000,
+		Currency:    "USD",
+		ImageURL:    "/static/images/product.jpg",
+	}
+}
+
+// productServiceFor returns a product service that only knows the given product
+func productServiceFor(product *models.Product) *MockProductService {
+	return &MockProductService{
+		GetProductBySKUFunc: func(sku string) (*models.Product, error) {
+			if sku != product.SKU {
+				return nil, models.ErrProductNotFound
+			}
+			return product, nil
+		},
+	}
+}
+
 func TestProductHandler_ServeHTTP(t *testing.T) {
 	tests := []struct {
 		name           string
 		method         string
+		sku            string
+		productError   error
 		expectedStatus int
 		templatePath   string
-		templateError  bool
 		checkContent   []string
 	}{
 		{
 			name:           "successful GET request",
 			method:         http.MethodGet,
+			sku:            "test-001",
 			expectedStatus: http.StatusOK,
 			templatePath:   "../../templates/product.html",
-			checkContent:   []string{"Test Product", "$10.00"},
+			checkContent:   []string{"Test Product", "$10.00", `action="/cart/items"`, `name="sku" value="test-001"`},
+		},
+		{
+			name:           "unknown SKU",
+			method:         http.MethodGet,
+			sku:            "missing-001",
+			expectedStatus: http.StatusNotFound,
+			templatePath:   "../../templates/product.html",
+		},
+		{
+			name:           "product service error",
+			method:         http.MethodGet,
+			sku:            "test-001",
+			productError:   errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+			templatePath:   "../../templates/product.html",
 		},
 		{
 			name:           "method not allowed - POST",
 			method:         http.MethodPost,
+			sku:            "test-001",
 			expectedStatus: http.StatusMethodNotAllowed,
 			templatePath:   "../../templates/product.html",
 		},
 		{
 			name:           "method not allowed - PUT",
 			method:         http.MethodPut,
+			sku:            "test-001",
 			expectedStatus: http.StatusMethodNotAllowed,
 			templatePath:   "../../templates/product.html",
 		},
 		{
 			name:           "method not allowed - DELETE",
 			method:         http.MethodDelete,
+			sku:            "test-001",
 			expectedStatus: http.StatusMethodNotAllowed,
 			templatePath:   "../../templates/product.html",
 		},
@@ -46,26 +114,28 @@ func TestProductHandler_ServeHTTP(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create test product
-			product := Product{
-				Name:        "Test Product",
-				Description: "A wonderful test product",
-				Price:       "$10.00",
-				ImageURL:    "/static/images/product.jpg",
+			productService := productServiceFor(testProduct())
+			if tt.productError != nil {
+				productService.GetProductBySKUFunc = func(string) (*models.Product, error) {
+					return nil, tt.productError
+				}
 			}
 
 			// Create handler
-			handler, err := NewProductHandler(tt.templatePath, product)
+			handler, err := NewProductHandler(tt.templatePath, productService)
 			if err != nil {
 				t.Fatalf("Failed to create handler: %v", err)
 			}
 
-			// Create request
-			req := httptest.NewRequest(tt.method, "/", nil)
+			// Route through a mux so the {sku} path value is populated
+			mux := http.NewServeMux()
+			mux.Handle("/products/{sku}", handler)
+
+			req := httptest.NewRequest(tt.method, "/products/"+tt.sku, nil)
 			w := httptest.NewRecorder()
 
 			// Execute
-			handler.ServeHTTP(w, req)
+			mux.ServeHTTP(w, req)
 
 			// Assert status code
 			if w.Code != tt.expectedStatus {
@@ -86,13 +156,6 @@ func TestProductHandler_ServeHTTP(t *testing.T) {
 }
 
 func TestProductHandler_TemplateExecutionError(t *testing.T) {
-	product := Product{
-		Name:        "Test Product",
-		Description: "Description",
-		Price:       "$5.00",
-		ImageURL:    "/image.jpg",
-	}
-
 	// Create a handler with a malformed template
 	tmpl, err := template.New("product.html").Parse("{{.InvalidField.NonExistent}}")
 	if err != nil {
@@ -100,11 +163,12 @@ func TestProductHandler_TemplateExecutionError(t *testing.T) {
 	}
 
 	handler := &ProductHandler{
-		template: tmpl,
-		product:  product,
+		template:       tmpl,
+		productService: productServiceFor(testProduct()),
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := httptest.NewRequest(http.MethodGet, "/products/test-001", nil)
+	req.SetPathValue("sku", "test-001")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
@@ -119,26 +183,23 @@ func TestNewProductHandler(t *testing.T) {
 	tests := []struct {
 		name         string
 		templatePath string
-		product      Product
 		wantErr      bool
 	}{
 		{
 			name:         "invalid template path",
 			templatePath: "/invalid/path/to/template.html",
-			product:      Product{Name: "Test"},
 			wantErr:      true,
 		},
 		{
 			name:         "empty template path",
 			templatePath: "",
-			product:      Product{Name: "Test"},
 			wantErr:      true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler, err := NewProductHandler(tt.templatePath, tt.product)
+			handler, err := NewProductHandler(tt.templatePath, &MockProductService{})
 
 			if tt.wantErr && err == nil {
 				t.Error("expected error but got none")
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// PublicURL builds the absolute URLs the shop hands out, e.g. the URL Adyen returns the
+// shopper to after paying
+type PublicURL struct {
+	baseURL        string
+	trustedProxies []netip.Prefix
+}
+
+// NewPublicURL creates a URL builder for baseURL, e.g. https://shop.example.com. If baseURL
+// is empty, the base URL is derived from each request, believing the X-Forwarded-Proto and
+// X-Forwarded-Host headers only if the request comes from one of trustedProxies.
+func NewPublicURL(baseURL string, trustedProxies []netip.Prefix) *PublicURL {
+	return &PublicURL{
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		trustedProxies: trustedProxies,
+	}
+}
+
+// Resolve returns the absolute URL of path, which must start with a slash
+func (u *PublicURL) Resolve(r *http.Request, path string) string {
+	if u.baseURL != "" {
+		return u.baseURL + path
+	}
+
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if u.fromTrustedProxy(r) {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" && !strings.ContainsAny(forwardedHost, "/\\@ ") {
+			host = forwardedHost
+		}
+	}
+	return scheme + "://" + host + path
+}
+
+// fromTrustedProxy returns true if the request's peer is one of the trusted proxies
+func (u *PublicURL) fromTrustedProxy(r *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	return slices.ContainsFunc(u.trustedProxies, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// firstHeaderValue returns the first entry of a comma-separated header, which the proxy
+// closest to the shopper set
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.ToLower(strings.TrimSpace(value))
+}
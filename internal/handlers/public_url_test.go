@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicURL_Resolve(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		baseURL    string
+		remoteAddr string
+		host       string
+		tls        bool
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:     "configured base URL",
+			baseURL:  "https://shop.example.com/",
+			host:     "internal:8080",
+			headers:  map[string]string{"X-Forwarded-Host": "evil.example.com"},
+			expected: "https://shop.example.com/order/confirmation",
+		},
+		{
+			name:       "request host",
+			remoteAddr: "203.0.113.7:51234",
+			host:       "localhost:9090",
+			expected:   "http://localhost:9090/order/confirmation",
+		},
+		{
+			name:       "TLS request",
+			remoteAddr: "203.0.113.7:51234",
+			host:       "shop.example.com",
+			tls:        true,
+			expected:   "https://shop.example.com/order/confirmation",
+		},
+		{
+			name:       "forwarded headers from a trusted proxy",
+			remoteAddr: "10.1.2.3:51234",
+			host:       "app:8080",
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "shop.example.com"},
+			expected:   "https://shop.example.com/order/confirmation",
+		},
+		{
+			name:       "first entry of a proxy chain",
+			remoteAddr: "10.1.2.3:51234",
+			host:       "app:8080",
+			headers:    map[string]string{"X-Forwarded-Proto": "HTTPS, http", "X-Forwarded-Host": "shop.example.com, app:8080"},
+			expected:   "https://shop.example.com/order/confirmation",
+		},
+		{
+			name:       "forwarded headers from an untrusted client are ignored",
+			remoteAddr: "203.0.113.7:51234",
+			host:       "shop.example.com",
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"},
+			expected:   "http://shop.example.com/order/confirmation",
+		},
+		{
+			name:       "invalid forwarded values are ignored",
+			remoteAddr: "10.1.2.3:51234",
+			host:       "shop.example.com",
+			headers:    map[string]string{"X-Forwarded-Proto": "javascript", "X-Forwarded-Host": "evil.example.com/path"},
+			expected:   "http://shop.example.com/order/confirmation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+			req.Host = tt.host
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if got := NewPublicURL(tt.baseURL, proxies).Resolve(req, "/order/confirmation"); got != tt.expected {
+				t.Errorf("Resolve() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
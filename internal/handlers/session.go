@@ -1,32 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/adyen/ecommerce/internal/models"
 	"github.com/adyen/ecommerce/internal/services"
 )
 
 // SessionHandler handles payment session creation
 type SessionHandler struct {
 	paymentService services.PaymentService
-	product        Product
+	cartService    services.CartService
+	orderAccess    *OrderAccess
+	publicURL      *PublicURL
+	locales        *LocaleResolver
 }
 
-// NewSessionHandler creates a new session handler
-func NewSessionHandler(paymentService services.PaymentService, product Product) *SessionHandler {
+// NewSessionHandler creates a new session handler returning shoppers to the confirmation
+// page under publicURL
+func NewSessionHandler(paymentService services.PaymentService, cartService services.CartService, orderAccess *OrderAccess, publicURL *PublicURL, locales *LocaleResolver) *SessionHandler {
 	return &SessionHandler{
 		paymentService: paymentService,
-		product:        product,
+		cartService:    cartService,
+		orderAccess:    orderAccess,
+		publicURL:      publicURL,
+		locales:        locales,
 	}
 }
 
 // ClientResponse represents the response sent to the client
 type ClientResponse struct {
-	SessionID   string `json:"sessionId"`
-	SessionData string `json:"sessionData"`
-	ClientKey   string `json:"clientKey"`
+	SessionID      string `json:"sessionId"`
+	SessionData    string `json:"sessionData"`
+	ClientKey      string `json:"clientKey"`
+	OrderReference string `json:"orderReference"`
 }
 
 // ErrorResponse represents an error response
@@ -42,26 +53,44 @@ func (h *SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create payment session through service
-	result, err := h.paymentService.CreatePaymentSession(
-		h.product.Name,
-		100, // $1.00 in cents
-		"USD",
-		"http://localhost:8080/order/confirmation",
-	)
+	// The charged amount always comes from the cart and catalog, never from the client
+	cart, err := loadCart(h.cartService, r)
 	if err != nil {
-		log.Printf("Error creating payment session: %v", err)
+		log.Printf("Error loading cart for session: %v", err)
 		sendErrorResponse(w, "Failed to create payment session", http.StatusInternalServerError)
 		return
 	}
+	if cart.IsEmpty() {
+		sendErrorResponse(w, "Cart is empty", http.StatusBadRequest)
+		return
+	}
 
-	log.Printf("Payment session created successfully - SessionID: %s, OrderRef: %s", result.SessionID, result.OrderRef)
+	shopper := h.locales.Resolve(r)
+
+	// Reloading the checkout page offers the same order and session again
+	result := h.resumeSession(r, cart, shopper)
+	if result == nil {
+		result, err = h.paymentService.CreatePaymentSession(r.Context(), cart, shopper, h.publicURL.Resolve(r, "/order/confirmation"))
+		if err != nil {
+			log.Printf("Error creating payment session: %v", err)
+			statusCode, message := paymentErrorResponse(err, "Failed to create payment session")
+			sendErrorResponse(w, message, statusCode)
+			return
+		}
+
+		log.Printf("Payment session created successfully - SessionID: %s, OrderRef: %s", result.SessionID, result.OrderRef)
+	}
+
+	// Let this shopper look up the order while the payment is in progress
+	h.orderAccess.Grant(w, r, result.OrderRef)
+	h.orderAccess.RememberCheckout(w, result.OrderRef)
 
 	// Send response to client
 	clientResp := ClientResponse{
-		SessionID:   result.SessionID,
-		SessionData: result.SessionData,
-		ClientKey:   result.ClientKey,
+		SessionID:      result.SessionID,
+		SessionData:    result.SessionData,
+		ClientKey:      result.ClientKey,
+		OrderReference: result.OrderRef,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -70,6 +99,26 @@ func (h *SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// resumeSession returns the payment session of the order bound to the shopper's checkout,
+// or nil if there is none that can still be used for the cart and shopper locale
+func (h *SessionHandler) resumeSession(r *http.Request, cart *models.Cart, shopper models.ShopperLocale) *services.PaymentSessionResult {
+	reference := h.orderAccess.CheckoutReference(r)
+	if reference == "" {
+		return nil
+	}
+
+	result, err := h.paymentService.ResumePaymentSession(r.Context(), reference, cart, shopper)
+	switch {
+	case errors.Is(err, models.ErrSessionNotReusable), errors.Is(err, models.ErrOrderNotFound):
+		log.Printf("Creating a new payment session: %v", err)
+		return nil
+	case err != nil:
+		log.Printf("Error resuming payment session of order %s: %v", reference, err)
+		return nil
+	}
+	return result
+}
+
 // sendErrorResponse sends a JSON error response
 func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -79,3 +128,28 @@ func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 		Message: message,
 	})
 }
+
+// paymentErrorResponse maps a failed payment call to an HTTP status and a message that is
+// safe to show the shopper. Errors that did not come from Adyen use the fallback message.
+func paymentErrorResponse(err error, fallback string) (int, string) {
+	var apiErr *services.AdyenAPIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "The payment provider took too long to respond. Please try again."
+	case errors.Is(err, models.ErrNoPaymentMethod):
+		return http.StatusUnprocessableEntity, "The items in your cart cannot be paid for together. Please check them out separately."
+	case errors.Is(err, models.ErrCurrencyNotOffered):
+		return http.StatusUnprocessableEntity, "The items in your cart cannot be paid for in your country's currency."
+	case !errors.As(err, &apiErr):
+		return http.StatusInternalServerError, fallback
+	case apiErr.IsRateLimited():
+		return http.StatusServiceUnavailable, "The payment provider is busy. Please try again in a moment."
+	case apiErr.IsAuthentication():
+		// Our credentials were rejected, which the shopper cannot fix
+		return http.StatusBadGateway, "Payments are temporarily unavailable. Please try again later."
+	case apiErr.IsValidation():
+		return http.StatusUnprocessableEntity, "The payment request was rejected. Please review your order and try again."
+	default:
+		return http.StatusBadGateway, "The payment provider is unavailable. Please try again later."
+	}
+}
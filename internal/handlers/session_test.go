@@ -1,24 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/adyen/ecommerce/internal/models"
 	"github.com/adyen/ecommerce/internal/services"
 )
 
 // MockPaymentService is a mock implementation of PaymentService for testing
 type MockPaymentService struct {
-	CreatePaymentSessionFunc func(string, int64, string, string) (*services.PaymentSessionResult, error)
+	CreatePaymentSessionFunc func(*models.Cart, models.ShopperLocale, string) (*services.PaymentSessionResult, error)
+	ResumePaymentSessionFunc func(string, *models.Cart, models.ShopperLocale) (*services.PaymentSessionResult, error)
 	VerifyPaymentFunc        func(string, string) (*services.PaymentVerificationResult, error)
+	RefundOrderFunc          func(string, int64) (*services.ModificationResult, error)
+	CaptureOrderFunc         func(string) (*services.ModificationResult, error)
+	CancelOrderFunc          func(string) (*services.ModificationResult, error)
 }
 
-func (m *MockPaymentService) CreatePaymentSession(productName string, amount int64, currency string, returnURL string) (*services.PaymentSessionResult, error) {
+func (m *MockPaymentService) CreatePaymentSession(ctx context.Context, cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*services.PaymentSessionResult, error) {
 	if m.CreatePaymentSessionFunc != nil {
-		return m.CreatePaymentSessionFunc(productName, amount, currency, returnURL)
+		return m.CreatePaymentSessionFunc(cart, shopper, returnURL)
 	}
 	return &services.PaymentSessionResult{
 		SessionID:   "test-session-123",
@@ -28,17 +36,46 @@ func (m *MockPaymentService) CreatePaymentSession(productName string, amount int
 	}, nil
 }
 
-func (m *MockPaymentService) VerifyPayment(sessionID, sessionResult string) (*services.PaymentVerificationResult, error) {
+func (m *MockPaymentService) ResumePaymentSession(ctx context.Context, reference string, cart *models.Cart, shopper models.ShopperLocale) (*services.PaymentSessionResult, error) {
+	if m.ResumePaymentSessionFunc != nil {
+		return m.ResumePaymentSessionFunc(reference, cart, shopper)
+	}
+	return nil, models.ErrSessionNotReusable
+}
+
+func (m *MockPaymentService) VerifyPayment(ctx context.Context, sessionID, sessionResult string) (*services.PaymentVerificationResult, error) {
 	if m.VerifyPaymentFunc != nil {
 		return m.VerifyPaymentFunc(sessionID, sessionResult)
 	}
 	return nil, nil
 }
 
+func (m *MockPaymentService) RefundOrder(ctx context.Context, reference string, amount int64) (*services.ModificationResult, error) {
+	if m.RefundOrderFunc != nil {
+		return m.RefundOrderFunc(reference, amount)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentService) CaptureOrder(ctx context.Context, reference string) (*services.ModificationResult, error) {
+	if m.CaptureOrderFunc != nil {
+		return m.CaptureOrderFunc(reference)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentService) CancelOrder(ctx context.Context, reference string) (*services.ModificationResult, error) {
+	if m.CancelOrderFunc != nil {
+		return m.CancelOrderFunc(reference)
+	}
+	return nil, nil
+}
+
 func TestSessionHandler_ServeHTTP(t *testing.T) {
 	tests := []struct {
 		name               string
 		method             string
+		cartID             string
 		mockSessionResult  *services.PaymentSessionResult
 		mockSessionError   error
 		expectedStatus     int
@@ -49,6 +86,7 @@ func TestSessionHandler_ServeHTTP(t *testing.T) {
 		{
 			name:   "successful session creation",
 			method: http.MethodPost,
+			cartID: "cart-1",
 			mockSessionResult: &services.PaymentSessionResult{
 				SessionID:   "session-abc-123",
 				SessionData: "encrypted-session-data",
@@ -62,10 +100,40 @@ func TestSessionHandler_ServeHTTP(t *testing.T) {
 		{
 			name:               "payment service error",
 			method:             http.MethodPost,
+			cartID:             "cart-1",
 			mockSessionError:   errors.New("payment service unavailable"),
 			expectedStatus:     http.StatusInternalServerError,
 			checkErrorResponse: true,
 		},
+		{
+			name:               "Adyen rate limit",
+			method:             http.MethodPost,
+			cartID:             "cart-1",
+			mockSessionError:   fmt.Errorf("failed to create Adyen session: %w", &services.AdyenAPIError{Status: http.StatusTooManyRequests}),
+			expectedStatus:     http.StatusServiceUnavailable,
+			checkErrorResponse: true,
+		},
+		{
+			name:               "Adyen validation error",
+			method:             http.MethodPost,
+			cartID:             "cart-1",
+			mockSessionError:   &services.AdyenAPIError{Status: http.StatusUnprocessableEntity, ErrorCode: "14_030", ErrorType: services.AdyenErrorTypeValidation},
+			expectedStatus:     http.StatusUnprocessableEntity,
+			checkErrorResponse: true,
+		},
+		{
+			name:               "empty cart",
+			method:             http.MethodPost,
+			cartID:             "empty-cart",
+			expectedStatus:     http.StatusBadRequest,
+			checkErrorResponse: true,
+		},
+		{
+			name:               "no cart",
+			method:             http.MethodPost,
+			expectedStatus:     http.StatusBadRequest,
+			checkErrorResponse: true,
+		},
 		{
 			name:           "method not allowed - GET",
 			method:         http.MethodGet,
@@ -87,7 +155,7 @@ func TestSessionHandler_ServeHTTP(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock payment service
 			mockService := &MockPaymentService{
-				CreatePaymentSessionFunc: func(productName string, amount int64, currency string, returnURL string) (*services.PaymentSessionResult, error) {
+				CreatePaymentSessionFunc: func(cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*services.PaymentSessionResult, error) {
 					if tt.mockSessionError != nil {
 						return nil, tt.mockSessionError
 					}
@@ -96,15 +164,24 @@ func TestSessionHandler_ServeHTTP(t *testing.T) {
 			}
 
 			// Create handler
-			product := Product{
-				Name:        "Test Product",
-				Description: "A test product",
-				Price:       "$1.00",
+			cartService := &MockCartService{
+				GetCartFunc: func(id string) (*models.Cart, error) {
+					switch id {
+					case "cart-1":
+						return &models.Cart{ID: id, Items: []models.CartItem{{Product: testProduct(), Quantity: 1}}}, nil
+					case "empty-cart":
+						return &models.Cart{ID: id}, nil
+					}
+					return nil, models.ErrCartNotFound
+				},
 			}
-			handler := NewSessionHandler(mockService, product)
+			handler := NewSessionHandler(mockService, cartService, testOrderAccess(), NewPublicURL("", nil), testLocales())
 
 			// Create request
 			req := httptest.NewRequest(tt.method, "/api/sessions", nil)
+			if tt.cartID != "" {
+				withCartCookie(req, tt.cartID)
+			}
 			w := httptest.NewRecorder()
 
 			// Execute
@@ -163,17 +240,15 @@ func TestSessionHandler_ServeHTTP(t *testing.T) {
 }
 
 func TestSessionHandler_ServiceInvocation(t *testing.T) {
-	// Test that the handler calls the payment service with correct parameters
-	var capturedProductName string
-	var capturedAmount int64
-	var capturedCurrency string
+	// Test that the handler calls the payment service with the shopper's cart
+	var capturedCart *models.Cart
+	var capturedShopper models.ShopperLocale
 	var capturedReturnURL string
 
 	mockService := &MockPaymentService{
-		CreatePaymentSessionFunc: func(productName string, amount int64, currency string, returnURL string) (*services.PaymentSessionResult, error) {
-			capturedProductName = productName
-			capturedAmount = amount
-			capturedCurrency = currency
+		CreatePaymentSessionFunc: func(cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*services.PaymentSessionResult, error) {
+			capturedCart = cart
+			capturedShopper = shopper
 			capturedReturnURL = returnURL
 
 			return &services.PaymentSessionResult{
@@ -185,31 +260,190 @@ func TestSessionHandler_ServiceInvocation(t *testing.T) {
 		},
 	}
 
-	product := Product{
-		Name: "Premium Widget",
+	product := &models.Product{
+		SKU:      "widget-001",
+		Name:     "Premium Widget",
+		Price:    100,
+		Currency: "USD",
 	}
-	handler := NewSessionHandler(mockService, product)
+	handler := NewSessionHandler(mockService, cartServiceWith("cart-1", models.CartItem{Product: product, Quantity: 3}), testOrderAccess(), NewPublicURL("https://shop.example.com", nil), testLocales())
 
-	req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+	// A price sent by the client must be ignored
+	req := withCartCookie(httptest.NewRequest(http.MethodPost, "/api/sessions", strings.NewReader(`{"amount":1}`)), "cart-1")
+	req.Header.Set("Accept-Language", "nl-BE,nl;q=0.9,en;q=0.8")
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
 
 	// Verify service was called with correct parameters
-	if capturedProductName != "Premium Widget" {
-		t.Errorf("expected product name 'Premium Widget', got '%s'", capturedProductName)
+	if capturedCart == nil {
+		t.Fatal("expected payment service to be called")
+	}
+
+	if capturedCart.ID != "cart-1" {
+		t.Errorf("expected cart 'cart-1', got '%s'", capturedCart.ID)
+	}
+
+	if capturedCart.Total() != 300 {
+		t.Errorf("expected amount 300, got %d", capturedCart.Total())
+	}
+
+	if capturedCart.Currency() != "USD" {
+		t.Errorf("expected currency 'USD', got '%s'", capturedCart.Currency())
+	}
+
+	if expected := (models.ShopperLocale{CountryCode: "BE", Locale: "nl-BE"}); capturedShopper != expected {
+		t.Errorf("expected shopper %+v, got %+v", expected, capturedShopper)
+	}
+
+	if capturedReturnURL != "https://shop.example.com/order/confirmation" {
+		t.Errorf("expected returnURL 'https://shop.example.com/order/confirmation', got '%s'", capturedReturnURL)
+	}
+}
+
+func TestSessionHandler_GrantsOrderAccess(t *testing.T) {
+	access := testOrderAccess()
+	handler := NewSessionHandler(&MockPaymentService{}, cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), access, NewPublicURL("", nil), testLocales())
+
+	req := withCartCookie(httptest.NewRequest(http.MethodPost, "/api/sessions", nil), "cart-1")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	var response ClientResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.OrderReference != "ORDER-123" {
+		t.Errorf("expected orderReference 'ORDER-123', got '%s'", response.OrderReference)
 	}
 
-	if capturedAmount != 100 {
-		t.Errorf("expected amount 100, got %d", capturedAmount)
+	// The cookie set with the session lets the shopper look up the new order
+	statusReq := httptest.NewRequest(http.MethodGet, "/api/orders/ORDER-123/status", nil)
+	for _, cookie := range w.Result().Cookies() {
+		statusReq.AddCookie(cookie)
+	}
+	if !access.Allowed(statusReq, "ORDER-123") {
+		t.Error("expected the session cookie to grant access to ORDER-123")
 	}
+}
 
-	if capturedCurrency != "USD" {
-		t.Errorf("expected currency 'USD', got '%s'", capturedCurrency)
+func TestSessionHandler_ResumesCheckout(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkoutOrder string
+		forgedCookie  bool
+		resumeError   error
+		expectResume  bool
+		expectCreate  bool
+		expectedOrder string
+	}{
+		{
+			name:          "first checkout creates a session",
+			expectCreate:  true,
+			expectedOrder: "ORDER-NEW",
+		},
+		{
+			name:          "reload resumes the session",
+			checkoutOrder: "ORDER-123",
+			expectResume:  true,
+			expectedOrder: "ORDER-123",
+		},
+		{
+			name:          "expired or changed session is replaced",
+			checkoutOrder: "ORDER-123",
+			resumeError:   fmt.Errorf("%w: order ORDER-123", models.ErrSessionNotReusable),
+			expectResume:  true,
+			expectCreate:  true,
+			expectedOrder: "ORDER-NEW",
+		},
+		{
+			name:          "unknown order is replaced",
+			checkoutOrder: "ORDER-123",
+			resumeError:   models.ErrOrderNotFound,
+			expectResume:  true,
+			expectCreate:  true,
+			expectedOrder: "ORDER-NEW",
+		},
+		{
+			name:          "resume failure falls back to a new session",
+			checkoutOrder: "ORDER-123",
+			resumeError:   errors.New("database unavailable"),
+			expectResume:  true,
+			expectCreate:  true,
+			expectedOrder: "ORDER-NEW",
+		},
+		{
+			name:          "forged checkout cookie is ignored",
+			checkoutOrder: "ORDER-999",
+			forgedCookie:  true,
+			expectCreate:  true,
+			expectedOrder: "ORDER-NEW",
+		},
 	}
 
-	if capturedReturnURL != "http://localhost:8080/order/confirmation" {
-		t.Errorf("expected returnURL 'http://localhost:8080/order/confirmation', got '%s'", capturedReturnURL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resumed, created := false, false
+			mockService := &MockPaymentService{
+				ResumePaymentSessionFunc: func(reference string, cart *models.Cart, shopper models.ShopperLocale) (*services.PaymentSessionResult, error) {
+					resumed = true
+					if reference != tt.checkoutOrder {
+						t.Errorf("expected to resume %s, got %s", tt.checkoutOrder, reference)
+					}
+					if tt.resumeError != nil {
+						return nil, tt.resumeError
+					}
+					return &services.PaymentSessionResult{SessionID: "session-old", SessionData: "old-data", ClientKey: "key", OrderRef: reference}, nil
+				},
+				CreatePaymentSessionFunc: func(cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*services.PaymentSessionResult, error) {
+					created = true
+					return &services.PaymentSessionResult{SessionID: "session-new", SessionData: "new-data", ClientKey: "key", OrderRef: "ORDER-NEW"}, nil
+				},
+			}
+			access := testOrderAccess()
+			handler := NewSessionHandler(mockService, cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), access, NewPublicURL("", nil), testLocales())
+
+			req := withCartCookie(httptest.NewRequest(http.MethodPost, "/api/sessions", nil), "cart-1")
+			if tt.checkoutOrder != "" {
+				checkoutAccess := access
+				if tt.forgedCookie {
+					checkoutAccess = NewOrderAccess([]byte("other-secret"))
+				}
+				w := httptest.NewRecorder()
+				checkoutAccess.RememberCheckout(w, tt.checkoutOrder)
+				for _, cookie := range w.Result().Cookies() {
+					req.AddCookie(cookie)
+				}
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if resumed != tt.expectResume || created != tt.expectCreate {
+				t.Errorf("expected resume %v and create %v, got %v and %v", tt.expectResume, tt.expectCreate, resumed, created)
+			}
+
+			var response ClientResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.OrderReference != tt.expectedOrder {
+				t.Errorf("expected orderReference %s, got %s", tt.expectedOrder, response.OrderReference)
+			}
+
+			// The next reload resumes the order that was returned
+			reloadReq := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+			for _, cookie := range w.Result().Cookies() {
+				reloadReq.AddCookie(cookie)
+			}
+			if got := access.CheckoutReference(reloadReq); got != tt.expectedOrder {
+				t.Errorf("expected the checkout to be bound to %s, got %q", tt.expectedOrder, got)
+			}
+		})
 	}
 }
 
@@ -217,7 +451,7 @@ func TestSessionHandler_JSONEncodingError(t *testing.T) {
 	// Test the error path where JSON encoding fails
 	// We'll use a response recorder and close it to simulate encoding failure
 	mockService := &MockPaymentService{
-		CreatePaymentSessionFunc: func(productName string, amount int64, currency string, returnURL string) (*services.PaymentSessionResult, error) {
+		CreatePaymentSessionFunc: func(cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*services.PaymentSessionResult, error) {
 			return &services.PaymentSessionResult{
 				SessionID:   "session-123",
 				SessionData: "data",
@@ -227,12 +461,9 @@ func TestSessionHandler_JSONEncodingError(t *testing.T) {
 		},
 	}
 
-	product := Product{
-		Name: "Test Product",
-	}
-	handler := NewSessionHandler(mockService, product)
+	handler := NewSessionHandler(mockService, cartServiceWith("cart-1", models.CartItem{Product: testProduct(), Quantity: 1}), testOrderAccess(), NewPublicURL("", nil), testLocales())
 
-	req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+	req := withCartCookie(httptest.NewRequest(http.MethodPost, "/api/sessions", nil), "cart-1")
 
 	// Create a custom response writer that will fail on write
 	w := &failingWriter{
@@ -270,3 +501,73 @@ type customError struct {
 func (e *customError) Error() string {
 	return e.msg
 }
+
+func TestPaymentErrorResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			name:            "unrelated error uses fallback",
+			err:             errors.New("database unavailable"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "fallback",
+		},
+		{
+			name:            "timeout",
+			err:             fmt.Errorf("failed to send request: %w", context.DeadlineExceeded),
+			expectedStatus:  http.StatusGatewayTimeout,
+			expectedMessage: "took too long",
+		},
+		{
+			name:            "no payment method for the cart",
+			err:             models.ErrNoPaymentMethod,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedMessage: "cannot be paid for together",
+		},
+		{
+			name:            "cart not priced in the country's currency",
+			err:             fmt.Errorf("%w: NL pays in EUR, prices are in USD", models.ErrCurrencyNotOffered),
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedMessage: "your country's currency",
+		},
+		{
+			name:            "rate limited",
+			err:             &services.AdyenAPIError{Status: http.StatusTooManyRequests},
+			expectedStatus:  http.StatusServiceUnavailable,
+			expectedMessage: "busy",
+		},
+		{
+			name:            "authentication",
+			err:             &services.AdyenAPIError{Status: http.StatusUnauthorized, ErrorCode: "000", ErrorType: services.AdyenErrorTypeSecurity},
+			expectedStatus:  http.StatusBadGateway,
+			expectedMessage: "temporarily unavailable",
+		},
+		{
+			name:            "validation",
+			err:             &services.AdyenAPIError{Status: http.StatusUnprocessableEntity, ErrorCode: "167", ErrorType: services.AdyenErrorTypeValidation},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedMessage: "rejected",
+		},
+		{
+			name:            "Adyen internal error",
+			err:             &services.AdyenAPIError{Status: http.StatusInternalServerError, ErrorType: services.AdyenErrorTypeInternal},
+			expectedStatus:  http.StatusBadGateway,
+			expectedMessage: "provider is unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := paymentErrorResponse(fmt.Errorf("failed: %w", tt.err), "fallback")
+			if status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, status)
+			}
+			if !strings.Contains(message, tt.expectedMessage) {
+				t.Errorf("expected message containing %q, got %q", tt.expectedMessage, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// Shopper locale cookies
+const (
+	// localeCookieName holds the locale the shopper picked on the checkout page
+	localeCookieName   = "shopper_locale"
+	localeCookieMaxAge = 365 * 24 * 60 * 60
+	// countryCookieName optionally holds the shopper's country, e.g. set by a CDN from the
+	// shopper's IP address
+	countryCookieName = "shopper_country"
+)
+
+// LocaleOption is a locale shoppers can pick on the checkout page
+type LocaleOption struct {
+	Locale string
+	Label  string
+}
+
+// checkoutLocales are offered by the locale selector on the checkout page
+var checkoutLocales = []LocaleOption{
+	{Locale: "en-US", Label: "English (United States)"},
+	{Locale: "en-GB", Label: "English (United Kingdom)"},
+	{Locale: "nl-NL", Label: "Nederlands (Nederland)"},
+	{Locale: "nl-BE", Label: "Nederlands (België)"},
+	{Locale: "fr-BE", Label: "Français (Belgique)"},
+	{Locale: "fr-FR", Label: "Français (France)"},
+	{Locale: "de-DE", Label: "Deutsch (Deutschland)"},
+}
+
+// LocaleResolver works out the shopper's country and locale from the request
+type LocaleResolver struct {
+	defaults models.ShopperLocale
+}
+
+// NewLocaleResolver creates a resolver falling back to defaults when the request does not
+// tell the shopper's country or locale
+func NewLocaleResolver(defaults models.ShopperLocale) *LocaleResolver {
+	return &LocaleResolver{defaults: defaults}
+}
+
+// Resolve returns the shopper's country and locale. The locale is the one picked on the
+// checkout page, else the preferred one in Accept-Language. The country is the region of
+// a picked locale, else the country cookie, else the region of the preferred locale.
+func (l *LocaleResolver) Resolve(r *http.Request) models.ShopperLocale {
+	selected := l.Selected(r)
+	accepted := preferredLocale(r.Header.Get("Accept-Language"))
+
+	shopper := models.ShopperLocale{
+		CountryCode: firstNonEmpty(models.LocaleRegion(selected), countryFromCookie(r), models.LocaleRegion(accepted), l.defaults.CountryCode),
+		Locale:      firstNonEmpty(selected, accepted, l.defaults.Locale),
+	}
+	// A bare language is read in the shopper's country, e.g. nl in BE becomes nl-BE
+	if models.LocaleRegion(shopper.Locale) == "" {
+		shopper.Locale += "-" + shopper.CountryCode
+	}
+	return shopper
+}
+
+// Selected returns the locale the shopper picked, or an empty string if they did not
+func (l *LocaleResolver) Selected(r *http.Request) string {
+	cookie, err := r.Cookie(localeCookieName)
+	if err != nil {
+		return ""
+	}
+	locale, err := models.NormalizeLocale(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return locale
+}
+
+// Select remembers the locale the shopper picked. An empty locale forgets the choice, so
+// the locale is derived from the request again.
+func (l *LocaleResolver) Select(w http.ResponseWriter, locale string) error {
+	if locale == "" {
+		http.SetCookie(w, &http.Cookie{Name: localeCookieName, Value: "", Path: "/", MaxAge: -1})
+		return nil
+	}
+
+	normalized, err := models.NormalizeLocale(locale)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     localeCookieName,
+		Value:    normalized,
+		Path:     "/",
+		MaxAge:   localeCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// countryFromCookie returns the country in the country cookie, or an empty string
+func countryFromCookie(r *http.Request) string {
+	cookie, err := r.Cookie(countryCookieName)
+	if err != nil {
+		return ""
+	}
+	country, err := models.NormalizeCountryCode(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return country
+}
+
+// preferredLocale returns the locale with the highest quality in an Accept-Language header,
+// the first listed one on a tie, or an empty string if none is usable
+func preferredLocale(header string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		locale, err := models.NormalizeLocale(tag)
+		if err != nil {
+			continue // e.g. the * wildcard
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if quality, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{locale: locale, quality: quality})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].locale
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// testLocales returns a locale resolver defaulting to shoppers in the US
+func testLocales() *LocaleResolver {
+	return NewLocaleResolver(models.ShopperLocale{CountryCode: "US", Locale: "en-US"})
+}
+
+func TestLocaleResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		selected       string
+		country        string
+		expected       models.ShopperLocale
+	}{
+		{
+			name:     "defaults",
+			expected: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+		},
+		{
+			name:           "preferred browser locale",
+			acceptLanguage: "de;q=0.5, nl-NL, en;q=0.8",
+			expected:       models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"},
+		},
+		{
+			name:           "first locale wins a tie",
+			acceptLanguage: "fr-FR, de-DE",
+			expected:       models.ShopperLocale{CountryCode: "FR", Locale: "fr-FR"},
+		},
+		{
+			name:           "wildcard, refused and invalid entries are skipped",
+			acceptLanguage: "*, fr-FR;q=0, de-DE;q=abc, nl-BE;q=0.1",
+			expected:       models.ShopperLocale{CountryCode: "BE", Locale: "nl-BE"},
+		},
+		{
+			name:           "bare language is read in the default country",
+			acceptLanguage: "nl",
+			expected:       models.ShopperLocale{CountryCode: "US", Locale: "nl-US"},
+		},
+		{
+			name:           "country cookie takes precedence over the browser region",
+			acceptLanguage: "en-GB,en;q=0.9",
+			country:        "nl",
+			expected:       models.ShopperLocale{CountryCode: "NL", Locale: "en-GB"},
+		},
+		{
+			name:           "bare language is read in the cookie country",
+			acceptLanguage: "fr",
+			country:        "BE",
+			expected:       models.ShopperLocale{CountryCode: "BE", Locale: "fr-BE"},
+		},
+		{
+			name:           "picked locale takes precedence over everything",
+			acceptLanguage: "de-DE",
+			selected:       "fr-BE",
+			country:        "NL",
+			expected:       models.ShopperLocale{CountryCode: "BE", Locale: "fr-BE"},
+		},
+		{
+			name:           "invalid cookies are ignored",
+			acceptLanguage: "de-DE",
+			selected:       "<script>",
+			country:        "Netherlands",
+			expected:       models.ShopperLocale{CountryCode: "DE", Locale: "de-DE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.selected != "" {
+				req.AddCookie(&http.Cookie{Name: localeCookieName, Value: tt.selected})
+			}
+			if tt.country != "" {
+				req.AddCookie(&http.Cookie{Name: countryCookieName, Value: tt.country})
+			}
+
+			if got := testLocales().Resolve(req); got != tt.expected {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
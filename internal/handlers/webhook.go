@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// WebhookHandler handles Adyen standard webhook notifications
+type WebhookHandler struct {
+	webhookService services.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// ServeHTTP handles the webhook notification request
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var notification services.NotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+		log.Printf("Error decoding webhook notification: %v", err)
+		http.Error(w, "Invalid notification payload", http.StatusBadRequest)
+		return
+	}
+
+	// Returning an error status makes Adyen redeliver the batch later
+	if err := h.webhookService.HandleNotifications(r.Context(), &notification); err != nil {
+		log.Printf("Error handling webhook notification: %v", err)
+		http.Error(w, "Failed to process notification", http.StatusInternalServerError)
+		return
+	}
+
+	// Adyen expects this exact body to mark the batch as delivered
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("[accepted]"))
+}
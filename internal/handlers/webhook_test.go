@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/services"
+)
+
+// MockWebhookService is a mock implementation of WebhookService for testing
+type MockWebhookService struct {
+	HandleNotificationsFunc func(*services.NotificationRequest) error
+}
+
+func (m *MockWebhookService) HandleNotifications(ctx context.Context, req *services.NotificationRequest) error {
+	if m.HandleNotificationsFunc != nil {
+		return m.HandleNotificationsFunc(req)
+	}
+	return nil
+}
+
+func TestWebhookHandler_ServeHTTP(t *testing.T) {
+	validBody := `{"live":"false","notificationItems":[{"NotificationRequestItem":{"eventCode":"AUTHORISATION","merchantReference":"ORDER-123","pspReference":"PSP-123","success":"true","amount":{"currency":"USD","value":100}}}]}`
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		serviceError   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "accepted notification",
+			method:         http.MethodPost,
+			body:           validBody,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[accepted]",
+		},
+		{
+			name:           "invalid JSON",
+			method:         http.MethodPost,
+			body:           "not json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			method:         http.MethodPost,
+			body:           validBody,
+			serviceError:   errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "method not allowed - GET",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received *services.NotificationRequest
+			mockService := &MockWebhookService{
+				HandleNotificationsFunc: func(req *services.NotificationRequest) error {
+					received = req
+					return tt.serviceError
+				},
+			}
+
+			handler := NewWebhookHandler(mockService)
+			req := httptest.NewRequest(tt.method, "/api/webhooks/adyen", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if received == nil || len(received.NotificationItems) != 1 {
+					t.Fatal("expected notification batch to be passed to service")
+				}
+				item := received.NotificationItems[0].NotificationRequestItem
+				if item.MerchantReference != "ORDER-123" || item.EventCode != "AUTHORISATION" {
+					t.Errorf("unexpected notification item %+v", item)
+				}
+			}
+		})
+	}
+}
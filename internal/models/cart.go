@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxCartItemQuantity is the largest quantity of a single product a cart can hold
+const MaxCartItemQuantity = 99
+
+// Cart errors
+var (
+	ErrCartNotFound       = errors.New("cart not found")
+	ErrCartEmpty          = errors.New("cart is empty")
+	ErrInvalidQuantity    = errors.New("quantity must be between 1 and 99")
+	ErrCurrencyMismatch   = errors.New("all cart items must use the same currency")
+	ErrNoPaymentMethod    = errors.New("no payment method is allowed for all items in the cart")
+	ErrCurrencyNotOffered = errors.New("cart items are not priced in the currency of the shopper's country")
+)
+
+// Cart represents a shopper's cart, identified by a cookie
+type Cart struct {
+	ID        string
+	Items     []CartItem
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CartItem represents a catalog product and quantity in a cart
+type CartItem struct {
+	Product  *Product
+	Quantity int
+}
+
+// LineTotal returns the unit price multiplied by the quantity
+func (i *CartItem) LineTotal() int64 {
+	return i.Product.Price * int64(i.Quantity)
+}
+
+// GetFormattedLineTotal returns the line total formatted for display
+func (i *CartItem) GetFormattedLineTotal() string {
+	return FormatAmount(i.LineTotal(), i.Product.Currency)
+}
+
+// IsEmpty returns true if the cart has no items
+func (c *Cart) IsEmpty() bool {
+	return len(c.Items) == 0
+}
+
+// ItemCount returns the total quantity of all items in the cart
+func (c *Cart) ItemCount() int {
+	count := 0
+	for _, item := range c.Items {
+		count += item.Quantity
+	}
+	return count
+}
+
+// Currency returns the currency of the cart items, or an empty string for an empty cart
+func (c *Cart) Currency() string {
+	if c.IsEmpty() {
+		return ""
+	}
+	return c.Items[0].Product.Currency
+}
+
+// Total returns the sum of all line totals in the cart
+func (c *Cart) Total() int64 {
+	var total int64
+	for i := range c.Items {
+		total += c.Items[i].LineTotal()
+	}
+	return total
+}
+
+// GetFormattedTotal returns the cart total formatted for display
+func (c *Cart) GetFormattedTotal() string {
+	return FormatAmount(c.Total(), c.Currency())
+}
+
+// FindItem returns the cart item for a SKU, or nil if the product is not in the cart
+func (c *Cart) FindItem(sku string) *CartItem {
+	for i := range c.Items {
+		if c.Items[i].Product.SKU == sku {
+			return &c.Items[i]
+		}
+	}
+	return nil
+}
+
+// CanAdd checks that a product can be added to the cart without mixing currencies
+func (c *Cart) CanAdd(product *Product) error {
+	if !c.IsEmpty() && c.Currency() != product.Currency {
+		return ErrCurrencyMismatch
+	}
+	return nil
+}
+
+// SKUs returns the SKUs of the products in the cart
+func (c *Cart) SKUs() []string {
+	skus := make([]string, 0, len(c.Items))
+	for _, item := range c.Items {
+		skus = append(skus, item.Product.SKU)
+	}
+	return skus
+}
+
+// OrderItems converts the cart lines into order line items, snapshotting the current prices
+func (c *Cart) OrderItems() ([]OrderItem, error) {
+	if c.IsEmpty() {
+		return nil, ErrCartEmpty
+	}
+
+	items := make([]OrderItem, 0, len(c.Items))
+	for _, item := range c.Items {
+		items = append(items, OrderItem{
+			SKU:       item.Product.SKU,
+			Name:      item.Product.Name,
+			UnitPrice: item.Product.Price,
+			Quantity:  item.Quantity,
+		})
+	}
+	return items, nil
+}
+
+// ValidateQuantity checks that a cart item quantity is within the allowed range
+func ValidateQuantity(quantity int) error {
+	if quantity < 1 || quantity > MaxCartItemQuantity {
+		return ErrInvalidQuantity
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCart_Totals(t *testing.T) {
+	cart := &Cart{
+		Items: []CartItem{
+			{Product: &Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}, Quantity: 3},
+			{Product: &Product{SKU: "gadget-001", Name: "Deluxe Gadget", Price: 2500, Currency: "USD"}, Quantity: 1},
+		},
+	}
+
+	if got := cart.ItemCount(); got != 4 {
+		t.Errorf("ItemCount() = %d, want 4", got)
+	}
+	if got := cart.Total(); got != 2800 {
+		t.Errorf("Total() = %d, want 2800", got)
+	}
+	if got := cart.GetFormattedTotal(); got != "$28.00" {
+		t.Errorf("GetFormattedTotal() = %s, want $28.00", got)
+	}
+	if got := cart.Items[0].GetFormattedLineTotal(); got != "$3.00" {
+		t.Errorf("GetFormattedLineTotal() = %s, want $3.00", got)
+	}
+	if item := cart.FindItem("gadget-001"); item == nil || item.Quantity != 1 {
+		t.Errorf("FindItem() = %v, want gadget-001 with quantity 1", item)
+	}
+	if item := cart.FindItem("missing-001"); item != nil {
+		t.Errorf("FindItem() = %v, want nil", item)
+	}
+}
+
+func TestCart_CanAdd(t *testing.T) {
+	usd := &Product{SKU: "widget-001", Price: 100, Currency: "USD"}
+	eur := &Product{SKU: "gadget-001", Price: 100, Currency: "EUR"}
+
+	empty := &Cart{}
+	if err := empty.CanAdd(eur); err != nil {
+		t.Errorf("CanAdd() on empty cart error = %v", err)
+	}
+
+	cart := &Cart{Items: []CartItem{{Product: usd, Quantity: 1}}}
+	if err := cart.CanAdd(usd); err != nil {
+		t.Errorf("CanAdd() same currency error = %v", err)
+	}
+	if err := cart.CanAdd(eur); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("CanAdd() different currency error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestCart_OrderItems(t *testing.T) {
+	if _, err := (&Cart{}).OrderItems(); !errors.Is(err, ErrCartEmpty) {
+		t.Errorf("OrderItems() on empty cart error = %v, want ErrCartEmpty", err)
+	}
+
+	cart := &Cart{
+		Items: []CartItem{
+			{Product: &Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}, Quantity: 2},
+		},
+	}
+	items, err := cart.OrderItems()
+	if err != nil {
+		t.Fatalf("OrderItems() unexpected error = %v", err)
+	}
+	if len(items) != 1 || items[0].SKU != "widget-001" || items[0].UnitPrice != 100 || items[0].Quantity != 2 {
+		t.Errorf("OrderItems() = %+v", items)
+	}
+}
+
+func TestValidateQuantity(t *testing.T) {
+	tests := []struct {
+		quantity int
+		wantErr  bool
+	}{
+		{0, true},
+		{1, false},
+		{MaxCartItemQuantity, false},
+		{MaxCartItemQuantity + 1, true},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateQuantity(tt.quantity); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateQuantity(%d) error = %v, wantErr %v", tt.quantity, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Shopper country and locale used unless configured otherwise
+const (
+	DefaultCountryCode = "US"
+	DefaultLocale      = "en-US"
+)
+
+var (
+	ErrInvalidCountryCode = errors.New("country code must be 2 letters, e.g. NL")
+	ErrInvalidLocale      = errors.New("locale must be a language with an optional region, e.g. nl-NL")
+)
+
+// ShopperLocale is where the shopper is and which language they read. Adyen offers the
+// payment methods of the country, e.g. iDEAL in NL, and shows Drop-in in the locale.
+type ShopperLocale struct {
+	CountryCode string // ISO 3166-1 alpha-2, e.g. NL
+	Locale      string // language and region, e.g. nl-NL
+}
+
+// PaymentSession is an Adyen payment session created for an order
+type PaymentSession struct {
+	ID        string
+	Data      string
+	ExpiresAt time.Time // zero if unknown
+	Shopper   ShopperLocale
+}
+
+// NormalizeCountryCode returns code in upper case, e.g. NL for nl
+func NormalizeCountryCode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != 2 || !isLetters(code) {
+		return "", ErrInvalidCountryCode
+	}
+	return strings.ToUpper(code), nil
+}
+
+// NormalizeLocale returns a language tag such as nl_nl, NL-nl or zh-Hant-TW as language
+// and region, e.g. nl-NL and zh-TW. Script and variant subtags are dropped.
+func NormalizeLocale(tag string) (string, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(tag), func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return "", ErrInvalidLocale
+	}
+
+	language := parts[0]
+	if len(language) < 2 || len(language) > 3 || !isLetters(language) {
+		return "", ErrInvalidLocale
+	}
+	language = strings.ToLower(language)
+
+	for _, part := range parts[1:] {
+		if region, err := NormalizeCountryCode(part); err == nil {
+			return language + "-" + region, nil
+		}
+	}
+	return language, nil
+}
+
+// LocaleRegion returns the region of a normalized locale, e.g. NL for nl-NL, or an empty
+// string if it has none
+func LocaleRegion(locale string) string {
+	_, region, _ := strings.Cut(locale, "-")
+	return region
+}
+
+// isLetters returns true if s only contains ASCII letters
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
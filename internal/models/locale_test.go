@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeCountryCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+		wantErr  error
+	}{
+		{name: "upper case", code: "NL", expected: "NL"},
+		{name: "lower case with spaces", code: " be ", expected: "BE"},
+		{name: "empty", code: "", wantErr: ErrInvalidCountryCode},
+		{name: "three letters", code: "NLD", wantErr: ErrInvalidCountryCode},
+		{name: "digits", code: "42", wantErr: ErrInvalidCountryCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeCountryCode(tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeCountryCode() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeCountryCode() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected string
+		wantErr  error
+	}{
+		{name: "language and region", tag: "nl-NL", expected: "nl-NL"},
+		{name: "underscore and mixed case", tag: "NL_be", expected: "nl-BE"},
+		{name: "script is dropped", tag: "zh-Hant-TW", expected: "zh-TW"},
+		{name: "numeric region is dropped", tag: "es-419", expected: "es"},
+		{name: "bare language", tag: "fr", expected: "fr"},
+		{name: "empty", tag: "", wantErr: ErrInvalidLocale},
+		{name: "wildcard", tag: "*", wantErr: ErrInvalidLocale},
+		{name: "language too long", tag: "dutch-NL", wantErr: ErrInvalidLocale},
+		{name: "markup", tag: "<b>", wantErr: ErrInvalidLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeLocale(tt.tag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeLocale() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeLocale() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocaleRegion(t *testing.T) {
+	if got := LocaleRegion("nl-BE"); got != "BE" {
+		t.Errorf("LocaleRegion(nl-BE) = %s, want BE", got)
+	}
+	if got := LocaleRegion("nl"); got != "" {
+		t.Errorf("LocaleRegion(nl) = %s, want empty", got)
+	}
+}
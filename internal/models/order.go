@@ -14,22 +14,61 @@ type OrderStatus string
 // Order statuses
 const (
 	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusProcessing OrderStatus = "processing"
 	OrderStatusAuthorized OrderStatus = "authorized"
 	OrderStatusFailed     OrderStatus = "failed"
 	OrderStatusCancelled  OrderStatus = "cancelled"
+	// OrderStatusCancelRequested waits for Adyen to confirm the cancellation of the payment
+	OrderStatusCancelRequested OrderStatus = "cancel_requested"
+	// OrderStatusCaptureRequested waits for Adyen to confirm the capture of the payment
+	OrderStatusCaptureRequested OrderStatus = "capture_requested"
+	OrderStatusCaptured         OrderStatus = "captured"
+	OrderStatusExpired          OrderStatus = "expired"
+
+	OrderStatusRefundRequested   OrderStatus = "refund_requested"
+	OrderStatusRefunded          OrderStatus = "refunded"
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded"
 )
 
 // Order represents a customer order with business logic
 type Order struct {
-	ID           string
-	Reference    string
+	ID               string
+	Reference        string
+	Amount           int64
+	Currency         string
+	Status           OrderStatus
+	ProductName      string
+	PSPReference     string
+	RefundedAmount   int64 // total of the refunds confirmed by Adyen
+	Items            []OrderItem
+	Version          int // incremented by every stored status change
+	SessionID        string
+	SessionData      string    // opaque Adyen session data, needed to show Drop-in for the session again
+	SessionExpiresAt time.Time // when the Adyen payment session expires, zero if unknown
+	SessionShopper   ShopperLocale
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// OrderItem represents a line item of an order, with the price paid at the time of ordering
+type OrderItem struct {
+	ID        string
+	SKU       string
+	Name      string
+	UnitPrice int64
+	Quantity  int
+}
+
+// LineTotal returns the unit price multiplied by the quantity
+func (i *OrderItem) LineTotal() int64 {
+	return i.UnitPrice * int64(i.Quantity)
+}
+
+// Refund is the outcome of a refund reported by Adyen
+type Refund struct {
+	PSPReference string // the refund's own PSP reference, not the payment's
 	Amount       int64
-	Currency     string
-	Status       OrderStatus
-	ProductName  string
-	PSPReference string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	Success      bool
 }
 
 // Domain errors
@@ -41,6 +80,11 @@ var (
 	ErrOrderAlreadyAuthorized  = errors.New("order is already authorized")
 	ErrOrderAlreadyFailed      = errors.New("order is already failed")
 	ErrOrderAlreadyCancelled   = errors.New("order is already cancelled")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrOrderVersionConflict    = errors.New("order was modified concurrently")
+	ErrInvalidRefundAmount     = errors.New("refund amount must be positive and cannot exceed the amount left to refund")
+	ErrInvalidOrderItems       = errors.New("order must contain at least one valid item")
+	ErrSessionNotReusable      = errors.New("payment session cannot be reused")
 )
 
 // NewOrder creates a new order with validation
@@ -49,7 +93,10 @@ func NewOrder(productName string, amount int64, currency string) (*Order, error)
 		return nil, err
 	}
 
-	orderRef := fmt.Sprintf("ORDER-%d", time.Now().Unix())
+	orderRef, err := NewOrderReference()
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now()
 
 	return &Order{
@@ -64,6 +111,42 @@ func NewOrder(productName string, amount int64, currency string) (*Order, error)
 	}, nil
 }
 
+// NewOrderFromItems creates a new order for the given line items.
+// The order amount is the sum of the line totals and the product name summarises the items.
+func NewOrderFromItems(items []OrderItem, currency string) (*Order, error) {
+	if len(items) == 0 {
+		return nil, ErrInvalidOrderItems
+	}
+
+	var amount int64
+	for _, item := range items {
+		if item.SKU == "" || item.Name == "" || item.UnitPrice <= 0 || item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: invalid item %q", ErrInvalidOrderItems, item.SKU)
+		}
+		amount += item.LineTotal()
+	}
+
+	order, err := NewOrder(summarizeItems(items), amount, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	order.Items = make([]OrderItem, len(items))
+	for i, item := range items {
+		item.ID = uuid.New().String()
+		order.Items[i] = item
+	}
+	return order, nil
+}
+
+// summarizeItems describes the items in a single line, e.g. "Premium Widget and 2 more"
+func summarizeItems(items []OrderItem) string {
+	if len(items) == 1 {
+		return items[0].Name
+	}
+	return fmt.Sprintf("%s and %d more", items[0].Name, len(items)-1)
+}
+
 // validateOrderInput validates order creation parameters
 func validateOrderInput(productName string, amount int64, currency string) error {
 	if amount <= 0 {
@@ -78,9 +161,26 @@ func validateOrderInput(productName string, amount int64, currency string) error
 	return nil
 }
 
-// Authorize marks the order as authorized with a PSP reference
+// MarkProcessing records that the payment was submitted but its outcome is not known yet,
+// e.g. for bank transfers or wallets. It is settled later by the AUTHORISATION webhook.
+func (o *Order) MarkProcessing(pspReference string) error {
+	if !o.awaitsPayment() {
+		return fmt.Errorf("%w: cannot mark order with status %s as processing", ErrInvalidStatusTransition, o.Status)
+	}
+
+	o.Status = OrderStatusProcessing
+	if pspReference != "" {
+		o.PSPReference = pspReference
+	}
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// Authorize marks the order as authorized with a PSP reference. Expired and failed orders
+// can still be authorized, by a payment that completed after the session expired or a retry
+// after a refused attempt.
 func (o *Order) Authorize(pspReference string) error {
-	if o.Status != OrderStatusPending {
+	if !o.awaitsPayment() && o.Status != OrderStatusProcessing {
 		return fmt.Errorf("%w: cannot authorize order with status %s", ErrInvalidStatusTransition, o.Status)
 	}
 	if pspReference == "" {
@@ -93,24 +193,93 @@ func (o *Order) Authorize(pspReference string) error {
 	return nil
 }
 
+// awaitsPayment returns true if a payment can still be made for the order. Besides pending
+// orders these are orders whose session expired, as a payment may complete after that, and
+// failed orders, as Drop-in lets the shopper try again after a refused payment.
+func (o *Order) awaitsPayment() bool {
+	return o.Status == OrderStatusPending || o.Status == OrderStatusExpired || o.Status == OrderStatusFailed
+}
+
 // Fail marks the order as failed
 func (o *Order) Fail() error {
 	if o.Status == OrderStatusAuthorized {
 		return fmt.Errorf("%w: cannot fail an authorized order", ErrInvalidStatusTransition)
 	}
-	if o.Status == OrderStatusCancelled {
+	if o.Status == OrderStatusCancelled || o.Status == OrderStatusCancelRequested {
 		return fmt.Errorf("%w: cannot fail a cancelled order", ErrInvalidStatusTransition)
 	}
+	if o.Status == OrderStatusCaptureRequested || o.Status == OrderStatusCaptured || o.isRefundState() {
+		return fmt.Errorf("%w: cannot fail order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
 
 	o.Status = OrderStatusFailed
 	o.UpdatedAt = time.Now()
 	return nil
 }
 
-// Cancel marks the order as cancelled
+// Expire marks a pending order as expired once its payment session ran out without a payment
+func (o *Order) Expire() error {
+	if o.Status != OrderStatusPending {
+		return fmt.Errorf("%w: cannot expire order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusExpired
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// IsSessionExpired returns true if the order's payment session expired by now.
+// Orders without a recorded session expiry are assumed to expire sessionLifetime after creation.
+func (o *Order) IsSessionExpired(now time.Time, sessionLifetime time.Duration) bool {
+	expiresAt := o.SessionExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = o.CreatedAt.Add(sessionLifetime)
+	}
+	return !now.Before(expiresAt)
+}
+
+// CanResumeSession returns true if the order's payment session can still be used to pay for
+// items in currency: the order is unpaid, the session stays valid for at least margin after
+// now, it was created for the same shopper country and locale and the items and prices are
+// unchanged
+func (o *Order) CanResumeSession(items []OrderItem, currency string, shopper ShopperLocale, now time.Time, margin time.Duration) bool {
+	if !o.IsPending() || o.SessionID == "" || o.SessionData == "" || o.SessionExpiresAt.IsZero() {
+		return false
+	}
+	if o.SessionExpiresAt.Before(now.Add(margin)) {
+		return false
+	}
+	return o.SessionShopper == shopper && o.Currency == currency && sameItems(o.Items, items)
+}
+
+// sameItems returns true if a and b contain the same products, names, prices and quantities
+// in any order, ignoring item IDs
+func sameItems(a, b []OrderItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	key := func(item OrderItem) OrderItem {
+		item.ID = ""
+		return item
+	}
+	remaining := make(map[OrderItem]int, len(a))
+	for _, item := range a {
+		remaining[key(item)]++
+	}
+	for _, item := range b {
+		if remaining[key(item)] == 0 {
+			return false
+		}
+		remaining[key(item)]--
+	}
+	return true
+}
+
+// Cancel marks the order as cancelled.
+// Authorized orders can be cancelled as long as the payment has not been captured;
+// the authorisation itself must be voided with the payment provider.
 func (o *Order) Cancel() error {
-	if o.Status == OrderStatusAuthorized {
-		return fmt.Errorf("%w: cannot cancel an authorized order", ErrInvalidStatusTransition)
+	if !o.CanBeCancelled() && o.Status != OrderStatusCancelRequested {
+		return fmt.Errorf("%w: cannot cancel order with status %s", ErrInvalidStatusTransition, o.Status)
 	}
 
 	o.Status = OrderStatusCancelled
@@ -118,16 +287,187 @@ func (o *Order) Cancel() error {
 	return nil
 }
 
+// RequestCancel marks the order as waiting for Adyen to confirm the cancellation of its
+// payment. Failed and cancelled orders have no payment left to cancel.
+func (o *Order) RequestCancel() error {
+	if !o.CanBeCancelled() || o.Status == OrderStatusFailed || o.Status == OrderStatusCancelled {
+		return fmt.Errorf("%w: cannot request cancellation of order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusCancelRequested
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// RejectCancel moves an order waiting for a cancellation back to previous, the status it had
+// before the cancellation was requested, when Adyen refuses the cancellation
+func (o *Order) RejectCancel(previous OrderStatus) error {
+	if o.Status != OrderStatusCancelRequested {
+		return fmt.Errorf("%w: no cancellation requested for order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	switch previous {
+	case OrderStatusPending, OrderStatusProcessing, OrderStatusAuthorized, OrderStatusExpired:
+	default:
+		return fmt.Errorf("%w: cannot restore order to status %s", ErrInvalidStatusTransition, previous)
+	}
+	o.Status = previous
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// Capture marks an authorized order, or one waiting for its capture, as captured
+func (o *Order) Capture() error {
+	if o.Status != OrderStatusAuthorized && o.Status != OrderStatusCaptureRequested {
+		return fmt.Errorf("%w: cannot capture order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusCaptured
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// RequestCapture marks an authorized order as waiting for Adyen to confirm the capture of its
+// payment
+func (o *Order) RequestCapture() error {
+	if o.Status != OrderStatusAuthorized {
+		return fmt.Errorf("%w: cannot capture order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusCaptureRequested
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// RejectCapture moves an order waiting for a capture back to authorized when Adyen refuses the
+// capture, so it can be captured again or cancelled
+func (o *Order) RejectCapture() error {
+	if o.Status != OrderStatusCaptureRequested {
+		return fmt.Errorf("%w: no capture requested for order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusAuthorized
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// RequestRefund marks the order as waiting for a refund to be confirmed
+func (o *Order) RequestRefund() error {
+	if !o.CanBeRefunded() {
+		return fmt.Errorf("%w: cannot refund order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusRefundRequested
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// CompleteRefund records a refund of amount confirmed by Adyen. The order is refunded once
+// the refunds add up to the order amount and partially refunded before that.
+func (o *Order) CompleteRefund(amount int64) error {
+	if !o.canCompleteRefund() {
+		return fmt.Errorf("%w: cannot refund order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	if err := o.ValidateRefundAmount(amount); err != nil {
+		return err
+	}
+	o.RefundedAmount += amount
+	if o.RefundedAmount == o.Amount {
+		o.Status = OrderStatusRefunded
+	} else {
+		o.Status = OrderStatusPartiallyRefunded
+	}
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// RejectRefund moves an order waiting for a refund back to previous, the status it had
+// before the refund was requested, when Adyen refuses the refund
+func (o *Order) RejectRefund(previous OrderStatus) error {
+	if o.Status != OrderStatusRefundRequested {
+		return fmt.Errorf("%w: no refund requested for order with status %s", ErrInvalidStatusTransition, o.Status)
+	}
+	switch previous {
+	case OrderStatusAuthorized, OrderStatusCaptured, OrderStatusPartiallyRefunded:
+	default:
+		return fmt.Errorf("%w: cannot restore order to status %s", ErrInvalidStatusTransition, previous)
+	}
+	o.Status = previous
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// MarkRefunded marks the full order amount as refunded
+func (o *Order) MarkRefunded() error {
+	if !o.canCompleteRefund() {
+		return fmt.Errorf("%w: cannot mark order with status %s as refunded", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusRefunded
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// MarkPartiallyRefunded marks part of the order amount as refunded
+func (o *Order) MarkPartiallyRefunded() error {
+	if !o.canCompleteRefund() {
+		return fmt.Errorf("%w: cannot mark order with status %s as partially refunded", ErrInvalidStatusTransition, o.Status)
+	}
+	o.Status = OrderStatusPartiallyRefunded
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// ValidateRefundAmount checks that a refund amount fits within the amount left to refund
+func (o *Order) ValidateRefundAmount(amount int64) error {
+	if amount <= 0 || amount > o.RefundableAmount() {
+		return ErrInvalidRefundAmount
+	}
+	return nil
+}
+
+// RefundableAmount returns the part of the order amount that has not been refunded yet
+func (o *Order) RefundableAmount() int64 {
+	return o.Amount - o.RefundedAmount
+}
+
+// canCompleteRefund returns true if a confirmed refund can be recorded for the order.
+// Authorized orders are included so refunds issued outside this system are still tracked.
+func (o *Order) canCompleteRefund() bool {
+	switch o.Status {
+	case OrderStatusAuthorized, OrderStatusCaptured, OrderStatusRefundRequested, OrderStatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// isRefundState returns true if the order is in one of the refund states
+func (o *Order) isRefundState() bool {
+	switch o.Status {
+	case OrderStatusRefundRequested, OrderStatusRefunded, OrderStatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
 // IsPending returns true if the order is in pending status
 func (o *Order) IsPending() bool {
 	return o.Status == OrderStatusPending
 }
 
+// IsProcessing returns true if the order is waiting for the outcome of a submitted payment
+func (o *Order) IsProcessing() bool {
+	return o.Status == OrderStatusProcessing
+}
+
 // IsAuthorized returns true if the order is authorized
 func (o *Order) IsAuthorized() bool {
 	return o.Status == OrderStatusAuthorized
 }
 
+// IsPaid returns true if the payment went through: the order is authorized, or captured or
+// refunded later on
+func (o *Order) IsPaid() bool {
+	switch o.Status {
+	case OrderStatusAuthorized, OrderStatusCaptureRequested, OrderStatusCaptured:
+		return true
+	}
+	return o.isRefundState()
+}
+
 // IsFailed returns true if the order has failed
 func (o *Order) IsFailed() bool {
 	return o.Status == OrderStatusFailed
@@ -138,13 +478,56 @@ func (o *Order) IsCancelled() bool {
 	return o.Status == OrderStatusCancelled
 }
 
+// IsCancelRequested returns true if the order waits for Adyen to confirm its cancellation
+func (o *Order) IsCancelRequested() bool {
+	return o.Status == OrderStatusCancelRequested
+}
+
+// IsExpired returns true if the order's payment session expired without a payment
+func (o *Order) IsExpired() bool {
+	return o.Status == OrderStatusExpired
+}
+
+// IsCaptureRequested returns true if the order waits for Adyen to confirm its capture
+func (o *Order) IsCaptureRequested() bool {
+	return o.Status == OrderStatusCaptureRequested
+}
+
+// IsCaptured returns true if the order payment has been captured
+func (o *Order) IsCaptured() bool {
+	return o.Status == OrderStatusCaptured
+}
+
+// IsRefunded returns true if the full order amount has been refunded
+func (o *Order) IsRefunded() bool {
+	return o.Status == OrderStatusRefunded
+}
+
+// CanBeRefunded returns true if a new refund can be requested for the order
+func (o *Order) CanBeRefunded() bool {
+	switch o.Status {
+	case OrderStatusAuthorized, OrderStatusCaptured, OrderStatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// CanBeCancelled returns true if the order can be cancelled, which is until money was captured
+// or a capture or cancellation was requested
+func (o *Order) CanBeCancelled() bool {
+	switch o.Status {
+	case OrderStatusCaptureRequested, OrderStatusCaptured, OrderStatusCancelRequested:
+		return false
+	}
+	return !o.isRefundState()
+}
+
 // CanBeModified returns true if the order can still be modified
 func (o *Order) CanBeModified() bool {
 	return o.Status == OrderStatusPending
 }
 
-// GetFormattedAmount returns the amount formatted with currency
+// GetFormattedAmount returns the amount formatted with currency, e.g. "12.34 USD"
 func (o *Order) GetFormattedAmount() string {
-	amountInMajorUnits := float64(o.Amount) / 100.0
-	return fmt.Sprintf("%.2f %s", amountInMajorUnits, o.Currency)
+	return formatMajorUnits(o.Amount, o.Currency) + " " + o.Currency
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// OrderEventSource identifies what caused an order status change
+type OrderEventSource string
+
+const (
+	// OrderEventSourceRedirect is the shopper returning from the payment page
+	OrderEventSourceRedirect OrderEventSource = "redirect"
+	// OrderEventSourceWebhook is an Adyen webhook notification
+	OrderEventSourceWebhook OrderEventSource = "webhook"
+	// OrderEventSourceAdmin is an operator using the simplecom orders command
+	OrderEventSourceAdmin OrderEventSource = "admin"
+	// OrderEventSourceExpiry is an unpaid order expiring with its payment session
+	OrderEventSourceExpiry OrderEventSource = "expiry"
+)
+
+// OrderChange describes why an order status changes, for the order's history
+type OrderChange struct {
+	Source OrderEventSource
+	Actor  string
+	// Payload is the raw data behind the change, e.g. the webhook notification
+	Payload json.RawMessage
+}
+
+// OrderHistoryEvent is an entry in an order's append-only status history
+type OrderHistoryEvent struct {
+	ID             string
+	OrderID        string
+	PreviousStatus OrderStatus
+	Status         OrderStatus
+	PSPReference   string
+	Source         OrderEventSource
+	Actor          string
+	Payload        json.RawMessage
+	CreatedAt      time.Time
+}
+
+// actorContextKey is the context key for the actor making changes
+type actorContextKey struct{}
+
+// WithActor returns a context recording who makes the changes done with it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor recorded by WithActor, or an empty string
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
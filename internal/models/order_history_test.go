@@ -0,0 +1,17 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestActorFromContext(t *testing.T) {
+	if actor := ActorFromContext(context.Background()); actor != "" {
+		t.Errorf("Expected no actor, got %q", actor)
+	}
+
+	ctx := WithActor(context.Background(), "alice")
+	if actor := ActorFromContext(ctx); actor != "alice" {
+		t.Errorf("Expected actor alice, got %q", actor)
+	}
+}
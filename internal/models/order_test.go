@@ -1,7 +1,9 @@
 package models
 
 import (
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestNewOrder(t *testing.T) {
@@ -107,6 +109,18 @@ func TestOrder_Authorize(t *testing.T) {
 			pspReference: "PSP-123",
 			wantErr:      false,
 		},
+		{
+			name:         "authorize processing order",
+			initialState: OrderStatusProcessing,
+			pspReference: "PSP-123",
+			wantErr:      false,
+		},
+		{
+			name:         "authorize order paid after its session expired",
+			initialState: OrderStatusExpired,
+			pspReference: "PSP-123",
+			wantErr:      false,
+		},
 		{
 			name:         "cannot authorize already authorized order",
 			initialState: OrderStatusAuthorized,
@@ -114,10 +128,10 @@ func TestOrder_Authorize(t *testing.T) {
 			wantErr:      true,
 		},
 		{
-			name:         "cannot authorize failed order",
+			name:         "authorize order paid after a refused attempt",
 			initialState: OrderStatusFailed,
 			pspReference: "PSP-123",
-			wantErr:      true,
+			wantErr:      false,
 		},
 		{
 			name:         "cannot authorize cancelled order",
@@ -161,6 +175,77 @@ func TestOrder_Authorize(t *testing.T) {
 	}
 }
 
+func TestOrder_MarkProcessing(t *testing.T) {
+	tests := []struct {
+		name                 string
+		initialState         OrderStatus
+		pspReference         string
+		wantErr              bool
+		expectedPSPReference string
+	}{
+		{
+			name:                 "pending order with PSP reference",
+			initialState:         OrderStatusPending,
+			pspReference:         "PSP-123",
+			expectedPSPReference: "PSP-123",
+		},
+		{
+			name:         "pending order without PSP reference",
+			initialState: OrderStatusPending,
+		},
+		{
+			name:                 "order paid after its session expired",
+			initialState:         OrderStatusExpired,
+			pspReference:         "PSP-123",
+			expectedPSPReference: "PSP-123",
+		},
+		{
+			name:         "cannot mark authorized order",
+			initialState: OrderStatusAuthorized,
+			pspReference: "PSP-123",
+			wantErr:      true,
+		},
+		{
+			name:                 "order paid after a refused attempt",
+			initialState:         OrderStatusFailed,
+			pspReference:         "PSP-123",
+			expectedPSPReference: "PSP-123",
+		},
+		{
+			name:         "cannot mark processing order again",
+			initialState: OrderStatusProcessing,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{
+				ID:       "test-id",
+				Status:   tt.initialState,
+				Amount:   1000,
+				Currency: "EUR",
+			}
+
+			err := order.MarkProcessing(tt.pspReference)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MarkProcessing() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if !order.IsProcessing() {
+					t.Errorf("Expected status %s, got %s", OrderStatusProcessing, order.Status)
+				}
+				if order.PSPReference != tt.expectedPSPReference {
+					t.Errorf("Expected PSPReference %q, got %q", tt.expectedPSPReference, order.PSPReference)
+				}
+			}
+		})
+	}
+}
+
 func TestOrder_Fail(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -172,6 +257,11 @@ func TestOrder_Fail(t *testing.T) {
 			initialState: OrderStatusPending,
 			wantErr:      false,
 		},
+		{
+			name:         "fail processing order",
+			initialState: OrderStatusProcessing,
+			wantErr:      false,
+		},
 		{
 			name:         "cannot fail authorized order",
 			initialState: OrderStatusAuthorized,
@@ -182,6 +272,16 @@ func TestOrder_Fail(t *testing.T) {
 			initialState: OrderStatusCancelled,
 			wantErr:      true,
 		},
+		{
+			name:         "cannot fail order waiting for its capture",
+			initialState: OrderStatusCaptureRequested,
+			wantErr:      true,
+		},
+		{
+			name:         "cannot fail refunded order",
+			initialState: OrderStatusRefunded,
+			wantErr:      true,
+		},
 		{
 			name:         "can fail already failed order (idempotent)",
 			initialState: OrderStatusFailed,
@@ -212,6 +312,166 @@ func TestOrder_Fail(t *testing.T) {
 	}
 }
 
+func TestOrder_Expire(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		wantErr      bool
+	}{
+		{
+			name:         "expire pending order",
+			initialState: OrderStatusPending,
+		},
+		{
+			name:         "cannot expire processing order",
+			initialState: OrderStatusProcessing,
+			wantErr:      true,
+		},
+		{
+			name:         "cannot expire authorized order",
+			initialState: OrderStatusAuthorized,
+			wantErr:      true,
+		},
+		{
+			name:         "cannot expire expired order again",
+			initialState: OrderStatusExpired,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{
+				ID:       "test-id",
+				Status:   tt.initialState,
+				Amount:   1000,
+				Currency: "EUR",
+			}
+
+			err := order.Expire()
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expire() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !order.IsExpired() {
+				t.Errorf("Expected status %s, got %s", OrderStatusExpired, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_IsSessionExpired(t *testing.T) {
+	createdAt := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		sessionExpiresAt time.Time
+		now              time.Time
+		expected         bool
+	}{
+		{
+			name:             "session still open",
+			sessionExpiresAt: createdAt.Add(30 * time.Minute),
+			now:              createdAt.Add(29 * time.Minute),
+			expected:         false,
+		},
+		{
+			name:             "session expired",
+			sessionExpiresAt: createdAt.Add(30 * time.Minute),
+			now:              createdAt.Add(30 * time.Minute),
+			expected:         true,
+		},
+		{
+			name:     "unknown session within its lifetime",
+			now:      createdAt.Add(59 * time.Minute),
+			expected: false,
+		},
+		{
+			name:     "unknown session past its lifetime",
+			now:      createdAt.Add(time.Hour),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{Status: OrderStatusPending, SessionExpiresAt: tt.sessionExpiresAt, CreatedAt: createdAt}
+
+			if got := order.IsSessionExpired(tt.now, time.Hour); got != tt.expected {
+				t.Errorf("IsSessionExpired() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrder_CanResumeSession(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	items := []OrderItem{
+		{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 2},
+		{SKU: "gadget-001", Name: "Gadget", UnitPrice: 1500, Quantity: 1},
+	}
+	shopper := ShopperLocale{CountryCode: "NL", Locale: "nl-NL"}
+	newOrder := func() *Order {
+		return &Order{
+			Status:           OrderStatusPending,
+			Currency:         "USD",
+			Items:            []OrderItem{{ID: "item-2", SKU: "gadget-001", Name: "Gadget", UnitPrice: 1500, Quantity: 1}, {ID: "item-1", SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 2}},
+			SessionID:        "CS-SESSION",
+			SessionData:      "session-data",
+			SessionExpiresAt: now.Add(time.Hour),
+			SessionShopper:   shopper,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*Order)
+		items    []OrderItem
+		currency string
+		shopper  ShopperLocale
+		expected bool
+	}{
+		{name: "same items in another order", expected: true},
+		{name: "session expires within the margin", modify: func(o *Order) { o.SessionExpiresAt = now.Add(5 * time.Minute) }},
+		{name: "session expiry unknown", modify: func(o *Order) { o.SessionExpiresAt = time.Time{} }},
+		{name: "no session recorded", modify: func(o *Order) { o.SessionData = "" }},
+		{name: "order no longer pending", modify: func(o *Order) { o.Status = OrderStatusExpired }},
+		{name: "quantity changed", items: []OrderItem{items[0], {SKU: "gadget-001", Name: "Gadget", UnitPrice: 1500, Quantity: 2}}},
+		{name: "price changed", items: []OrderItem{items[0], {SKU: "gadget-001", Name: "Gadget", UnitPrice: 1200, Quantity: 1}}},
+		{name: "item removed", items: items[:1]},
+		{name: "currency changed", currency: "EUR"},
+		{name: "locale changed", shopper: ShopperLocale{CountryCode: "BE", Locale: "nl-BE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newOrder()
+			if tt.modify != nil {
+				tt.modify(order)
+			}
+			cartItems := items
+			if tt.items != nil {
+				cartItems = tt.items
+			}
+			currency := "USD"
+			if tt.currency != "" {
+				currency = tt.currency
+			}
+
+			cartShopper := shopper
+			if tt.shopper != (ShopperLocale{}) {
+				cartShopper = tt.shopper
+			}
+
+			if got := order.CanResumeSession(cartItems, currency, cartShopper, now, 10*time.Minute); got != tt.expected {
+				t.Errorf("CanResumeSession() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestOrder_Cancel(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -224,8 +484,23 @@ func TestOrder_Cancel(t *testing.T) {
 			wantErr:      false,
 		},
 		{
-			name:         "cannot cancel authorized order",
+			name:         "cancel authorized order",
 			initialState: OrderStatusAuthorized,
+			wantErr:      false,
+		},
+		{
+			name:         "cannot cancel order waiting for its capture",
+			initialState: OrderStatusCaptureRequested,
+			wantErr:      true,
+		},
+		{
+			name:         "cannot cancel captured order",
+			initialState: OrderStatusCaptured,
+			wantErr:      true,
+		},
+		{
+			name:         "cannot cancel refund requested order",
+			initialState: OrderStatusRefundRequested,
 			wantErr:      true,
 		},
 		{
@@ -233,6 +508,11 @@ func TestOrder_Cancel(t *testing.T) {
 			initialState: OrderStatusFailed,
 			wantErr:      false,
 		},
+		{
+			name:         "confirm requested cancellation",
+			initialState: OrderStatusCancelRequested,
+			wantErr:      false,
+		},
 		{
 			name:         "can cancel already cancelled order (idempotent)",
 			initialState: OrderStatusCancelled,
@@ -263,6 +543,260 @@ func TestOrder_Cancel(t *testing.T) {
 	}
 }
 
+func TestOrder_Capture(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		wantErr      bool
+	}{
+		{"capture authorized order", OrderStatusAuthorized, false},
+		{"confirm requested capture", OrderStatusCaptureRequested, false},
+		{"cannot capture pending order", OrderStatusPending, true},
+		{"cannot capture captured order", OrderStatusCaptured, true},
+		{"cannot capture refunded order", OrderStatusRefunded, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{ID: "test-id", Status: tt.initialState, Amount: 1000, Currency: "EUR"}
+
+			err := order.Capture()
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Capture() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && order.Status != OrderStatusCaptured {
+				t.Errorf("Expected status %s, got %s", OrderStatusCaptured, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_RequestCapture(t *testing.T) {
+	tests := []struct {
+		status  OrderStatus
+		wantErr bool
+	}{
+		{status: OrderStatusAuthorized},
+		{status: OrderStatusPending, wantErr: true},
+		{status: OrderStatusCaptureRequested, wantErr: true},
+		{status: OrderStatusCaptured, wantErr: true},
+		{status: OrderStatusCancelRequested, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			order := &Order{Status: tt.status}
+			err := order.RequestCapture()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestCapture() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !order.IsCaptureRequested() {
+				t.Errorf("Expected status %s, got %s", OrderStatusCaptureRequested, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_RejectCapture(t *testing.T) {
+	tests := []struct {
+		status  OrderStatus
+		wantErr bool
+	}{
+		{status: OrderStatusCaptureRequested},
+		{status: OrderStatusAuthorized, wantErr: true},
+		{status: OrderStatusCaptured, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			order := &Order{Status: tt.status}
+			err := order.RejectCapture()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RejectCapture() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !order.IsAuthorized() {
+				t.Errorf("Expected status %s, got %s", OrderStatusAuthorized, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_RequestRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		wantErr      bool
+	}{
+		{"refund authorized order", OrderStatusAuthorized, false},
+		{"refund captured order", OrderStatusCaptured, false},
+		{"refund partially refunded order", OrderStatusPartiallyRefunded, false},
+		{"cannot refund pending order", OrderStatusPending, true},
+		{"cannot refund failed order", OrderStatusFailed, true},
+		{"cannot refund cancelled order", OrderStatusCancelled, true},
+		{"cannot refund refunded order", OrderStatusRefunded, true},
+		{"cannot refund while refund requested", OrderStatusRefundRequested, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{ID: "test-id", Status: tt.initialState, Amount: 1000, Currency: "EUR"}
+
+			err := order.RequestRefund()
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RequestRefund() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && order.Status != OrderStatusRefundRequested {
+				t.Errorf("Expected status %s, got %s", OrderStatusRefundRequested, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_CompleteRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		partial      bool
+		wantErr      bool
+	}{
+		{"refund requested to refunded", OrderStatusRefundRequested, false, false},
+		{"refund requested to partially refunded", OrderStatusRefundRequested, true, false},
+		{"authorized to refunded", OrderStatusAuthorized, false, false},
+		{"partially refunded to refunded", OrderStatusPartiallyRefunded, false, false},
+		{"pending cannot be refunded", OrderStatusPending, false, true},
+		{"cancelled cannot be partially refunded", OrderStatusCancelled, true, true},
+		{"refunded cannot be partially refunded", OrderStatusRefunded, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{ID: "test-id", Status: tt.initialState, Amount: 1000, Currency: "EUR"}
+
+			var err error
+			expected := OrderStatusRefunded
+			if tt.partial {
+				err = order.MarkPartiallyRefunded()
+				expected = OrderStatusPartiallyRefunded
+			} else {
+				err = order.MarkRefunded()
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("complete refund error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && order.Status != expected {
+				t.Errorf("Expected status %s, got %s", expected, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_CompleteRefundAmounts(t *testing.T) {
+	tests := []struct {
+		name           string
+		initialState   OrderStatus
+		refundedAmount int64
+		amount         int64
+		expected       OrderStatus
+		expectedTotal  int64
+		wantErr        error
+	}{
+		{name: "part of the order", initialState: OrderStatusRefundRequested, amount: 400, expected: OrderStatusPartiallyRefunded, expectedTotal: 400},
+		{name: "whole order", initialState: OrderStatusRefundRequested, amount: 1000, expected: OrderStatusRefunded, expectedTotal: 1000},
+		{name: "rest of the order", initialState: OrderStatusRefundRequested, refundedAmount: 400, amount: 600, expected: OrderStatusRefunded, expectedTotal: 1000},
+		{name: "refund issued outside the shop", initialState: OrderStatusCaptured, amount: 300, expected: OrderStatusPartiallyRefunded, expectedTotal: 300},
+		{name: "more than is left", initialState: OrderStatusRefundRequested, refundedAmount: 400, amount: 700, wantErr: ErrInvalidRefundAmount},
+		{name: "zero amount", initialState: OrderStatusRefundRequested, amount: 0, wantErr: ErrInvalidRefundAmount},
+		{name: "refunded order", initialState: OrderStatusRefunded, refundedAmount: 1000, amount: 100, wantErr: ErrInvalidStatusTransition},
+		{name: "pending order", initialState: OrderStatusPending, amount: 100, wantErr: ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{Status: tt.initialState, Amount: 1000, Currency: "EUR", RefundedAmount: tt.refundedAmount}
+
+			err := order.CompleteRefund(tt.amount)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteRefund() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if order.Status != tt.initialState || order.RefundedAmount != tt.refundedAmount {
+					t.Errorf("Expected order to be unchanged, got status %s and refunded amount %d", order.Status, order.RefundedAmount)
+				}
+				return
+			}
+			if order.Status != tt.expected {
+				t.Errorf("Expected status %s, got %s", tt.expected, order.Status)
+			}
+			if order.RefundedAmount != tt.expectedTotal {
+				t.Errorf("Expected refunded amount %d, got %d", tt.expectedTotal, order.RefundedAmount)
+			}
+		})
+	}
+}
+
+func TestOrder_RejectRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		previous     OrderStatus
+		wantErr      bool
+	}{
+		{"back to authorized", OrderStatusRefundRequested, OrderStatusAuthorized, false},
+		{"back to captured", OrderStatusRefundRequested, OrderStatusCaptured, false},
+		{"back to partially refunded", OrderStatusRefundRequested, OrderStatusPartiallyRefunded, false},
+		{"no refund requested", OrderStatusRefunded, OrderStatusAuthorized, true},
+		{"not a refundable status", OrderStatusRefundRequested, OrderStatusPending, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{Status: tt.initialState, Amount: 1000, Currency: "EUR"}
+
+			err := order.RejectRefund(tt.previous)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RejectRefund() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && order.Status != tt.previous {
+				t.Errorf("Expected status %s, got %s", tt.previous, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_ValidateRefundAmount(t *testing.T) {
+	order := &Order{Amount: 1000, Currency: "EUR"}
+
+	if err := order.ValidateRefundAmount(1000); err != nil {
+		t.Errorf("Expected full amount to be valid, got %v", err)
+	}
+	if err := order.ValidateRefundAmount(1); err != nil {
+		t.Errorf("Expected partial amount to be valid, got %v", err)
+	}
+	if err := order.ValidateRefundAmount(0); err != ErrInvalidRefundAmount {
+		t.Errorf("Expected ErrInvalidRefundAmount for zero amount, got %v", err)
+	}
+	if err := order.ValidateRefundAmount(1001); err != ErrInvalidRefundAmount {
+		t.Errorf("Expected ErrInvalidRefundAmount for excessive amount, got %v", err)
+	}
+
+	order.RefundedAmount = 600
+	if err := order.ValidateRefundAmount(400); err != nil {
+		t.Errorf("Expected remaining amount to be valid, got %v", err)
+	}
+	if err := order.ValidateRefundAmount(401); err != ErrInvalidRefundAmount {
+		t.Errorf("Expected ErrInvalidRefundAmount for more than the remaining amount, got %v", err)
+	}
+}
+
 func TestOrder_StatusChecks(t *testing.T) {
 	order := &Order{
 		ID:       "test-id",
@@ -295,6 +829,102 @@ func TestOrder_StatusChecks(t *testing.T) {
 	if !order.IsCancelled() {
 		t.Error("Expected order to be cancelled")
 	}
+
+	order.Status = OrderStatusRefunded
+	if !order.IsRefunded() {
+		t.Error("Expected order to be refunded")
+	}
+	if order.CanBeRefunded() {
+		t.Error("Expected refunded order to not be refundable")
+	}
+}
+
+func TestOrder_IsPaid(t *testing.T) {
+	tests := []struct {
+		status   OrderStatus
+		expected bool
+	}{
+		{status: OrderStatusPending, expected: false},
+		{status: OrderStatusProcessing, expected: false},
+		{status: OrderStatusAuthorized, expected: true},
+		{status: OrderStatusCaptureRequested, expected: true},
+		{status: OrderStatusCaptured, expected: true},
+		{status: OrderStatusRefundRequested, expected: true},
+		{status: OrderStatusPartiallyRefunded, expected: true},
+		{status: OrderStatusRefunded, expected: true},
+		{status: OrderStatusFailed, expected: false},
+		{status: OrderStatusCancelled, expected: false},
+		{status: OrderStatusCancelRequested, expected: false},
+		{status: OrderStatusExpired, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			order := &Order{Status: tt.status}
+			if got := order.IsPaid(); got != tt.expected {
+				t.Errorf("IsPaid() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrder_RequestCancel(t *testing.T) {
+	tests := []struct {
+		status  OrderStatus
+		wantErr bool
+	}{
+		{status: OrderStatusPending},
+		{status: OrderStatusProcessing},
+		{status: OrderStatusAuthorized},
+		{status: OrderStatusExpired},
+		{status: OrderStatusFailed, wantErr: true},
+		{status: OrderStatusCancelled, wantErr: true},
+		{status: OrderStatusCancelRequested, wantErr: true},
+		{status: OrderStatusCaptureRequested, wantErr: true},
+		{status: OrderStatusCaptured, wantErr: true},
+		{status: OrderStatusPartiallyRefunded, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			order := &Order{Status: tt.status}
+			err := order.RequestCancel()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestCancel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !order.IsCancelRequested() {
+				t.Errorf("Expected status %s, got %s", OrderStatusCancelRequested, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_RejectCancel(t *testing.T) {
+	tests := []struct {
+		name         string
+		initialState OrderStatus
+		previous     OrderStatus
+		wantErr      bool
+	}{
+		{"back to authorized", OrderStatusCancelRequested, OrderStatusAuthorized, false},
+		{"back to processing", OrderStatusCancelRequested, OrderStatusProcessing, false},
+		{"no cancellation requested", OrderStatusCancelled, OrderStatusAuthorized, true},
+		{"not a cancellable status", OrderStatusCancelRequested, OrderStatusCaptured, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{Status: tt.initialState, Amount: 1000, Currency: "EUR"}
+
+			err := order.RejectCancel(tt.previous)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RejectCancel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && order.Status != tt.previous {
+				t.Errorf("Expected status %s, got %s", tt.previous, order.Status)
+			}
+		})
+	}
 }
 
 func TestOrder_GetFormattedAmount(t *testing.T) {
@@ -322,6 +952,18 @@ func TestOrder_GetFormattedAmount(t *testing.T) {
 			currency: "GBP",
 			expected: "999.99 GBP",
 		},
+		{
+			name:     "yen have no minor unit",
+			amount:   1234,
+			currency: "JPY",
+			expected: "1234 JPY",
+		},
+		{
+			name:     "Kuwaiti dinar have three decimals",
+			amount:   1234,
+			currency: "KWD",
+			expected: "1.234 KWD",
+		},
 	}
 
 	for _, tt := range tests {
@@ -338,3 +980,70 @@ func TestOrder_GetFormattedAmount(t *testing.T) {
 		})
 	}
 }
+
+func TestNewOrderFromItems(t *testing.T) {
+	widget := OrderItem{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 100, Quantity: 2}
+	gadget := OrderItem{SKU: "gadget-001", Name: "Deluxe Gadget", UnitPrice: 250, Quantity: 1}
+
+	tests := []struct {
+		name                string
+		items               []OrderItem
+		wantErr             error
+		expectedAmount      int64
+		expectedProductName string
+	}{
+		{
+			name:                "single item",
+			items:               []OrderItem{widget},
+			expectedAmount:      200,
+			expectedProductName: "Premium Widget",
+		},
+		{
+			name:                "multiple items",
+			items:               []OrderItem{widget, gadget},
+			expectedAmount:      450,
+			expectedProductName: "Premium Widget and 1 more",
+		},
+		{
+			name:    "no items",
+			items:   nil,
+			wantErr: ErrInvalidOrderItems,
+		},
+		{
+			name:    "zero quantity",
+			items:   []OrderItem{{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 100}},
+			wantErr: ErrInvalidOrderItems,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := NewOrderFromItems(tt.items, "USD")
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("NewOrderFromItems() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewOrderFromItems() unexpected error = %v", err)
+			}
+
+			if order.Amount != tt.expectedAmount {
+				t.Errorf("Expected amount %d, got %d", tt.expectedAmount, order.Amount)
+			}
+			if order.ProductName != tt.expectedProductName {
+				t.Errorf("Expected product name %q, got %q", tt.expectedProductName, order.ProductName)
+			}
+			if len(order.Items) != len(tt.items) {
+				t.Fatalf("Expected %d items, got %d", len(tt.items), len(order.Items))
+			}
+			for _, item := range order.Items {
+				if item.ID == "" {
+					t.Error("Order item ID should not be empty")
+				}
+			}
+		})
+	}
+}
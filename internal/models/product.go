@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Product represents an item in the catalog
+type Product struct {
+	ID          string
+	SKU         string
+	Name        string
+	Description string
+	ImageURL    string
+	Price       int64 // in minor units, e.g. cents
+	Currency    string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// ErrProductNotFound is returned when no product exists for a SKU
+var ErrProductNotFound = errors.New("product not found")
+
+// currencySymbols maps currency codes to the symbol shown before prices
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+}
+
+// GetFormattedPrice returns the price formatted for display, e.g. "$1.00"
+func (p *Product) GetFormattedPrice() string {
+	return FormatAmount(p.Price, p.Currency)
+}
+
+// currencyDecimals lists the ISO 4217 currencies whose minor unit is not a hundredth, by the
+// number of decimals their amounts have, e.g. JPY: 0 and KWD: 3
+var currencyDecimals = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyDecimals returns the number of decimals of an amount in currency, two unless
+// ISO 4217 says otherwise
+func CurrencyDecimals(currency string) int {
+	if decimals, ok := currencyDecimals[currency]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// FormatAmount formats an amount in minor units for display, e.g. "$1.00"
+func FormatAmount(amount int64, currency string) string {
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol + formatMajorUnits(amount, currency)
+	}
+	return formatMajorUnits(amount, currency) + " " + currency
+}
+
+// formatMajorUnits formats an amount in minor units of currency as a decimal number of major
+// units, e.g. 1234 USD as 12.34 and 1234 JPY as 1234
+func formatMajorUnits(amount int64, currency string) string {
+	decimals := CurrencyDecimals(currency)
+	if decimals == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	unit := int64(1)
+	for i := 0; i < decimals; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, decimals, amount%unit)
+}
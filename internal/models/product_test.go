@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestProduct_GetFormattedPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    int64
+		currency string
+		expected string
+	}{
+		{"US dollars", 100, "USD", "$1.00"},
+		{"euros", 2550, "EUR", "€25.50"},
+		{"pounds", 99, "GBP", "£0.99"},
+		{"currency without symbol", 1000, "SEK", "10.00 SEK"},
+		{"currency without decimals", 1500, "JPY", "1500 JPY"},
+		{"currency with three decimals", 12345, "KWD", "12.345 KWD"},
+		{"three decimals below one", 5, "BHD", "0.005 BHD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &Product{Price: tt.price, Currency: tt.currency}
+			if got := product.GetFormattedPrice(); got != tt.expected {
+				t.Errorf("GetFormattedPrice() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
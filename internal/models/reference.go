@@ -0,0 +1,104 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultReferencePrefix is the prefix of order references unless configured otherwise
+const DefaultReferencePrefix = "ORDER"
+
+// ErrDuplicateReference is returned when an order reference is already in use
+var ErrDuplicateReference = errors.New("order reference already exists")
+
+// ErrInvalidReferencePrefix is returned for prefixes that are empty or not upper case alphanumeric
+var ErrInvalidReferencePrefix = errors.New("reference prefix must be 1-16 upper case letters or digits")
+
+var referencePrefixPattern = regexp.MustCompile(`^[A-Z0-9]{1,16}$`)
+
+// ReferenceGenerator produces merchant references for new orders
+type ReferenceGenerator interface {
+	NextReference() (string, error)
+}
+
+// ValidateReferencePrefix checks that a prefix is safe to use in order references
+func ValidateReferencePrefix(prefix string) error {
+	if !referencePrefixPattern.MatchString(prefix) {
+		return ErrInvalidReferencePrefix
+	}
+	return nil
+}
+
+// referenceAlphabet is Crockford's base32, which avoids the ambiguous I, L, O and U
+const referenceAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// RandomReferenceGenerator generates references such as ORDER-20240131-7K3M9QXA
+// from the current date and 40 random bits
+type RandomReferenceGenerator struct {
+	prefix string
+	now    func() time.Time
+}
+
+// NewRandomReferenceGenerator creates a random reference generator with the given prefix
+func NewRandomReferenceGenerator(prefix string) *RandomReferenceGenerator {
+	return &RandomReferenceGenerator{
+		prefix: prefix,
+		now:    time.Now,
+	}
+}
+
+// NextReference returns a new random reference
+func (g *RandomReferenceGenerator) NextReference() (string, error) {
+	random := make([]byte, 5)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate order reference: %w", err)
+	}
+
+	// Encode the 40 random bits as 8 base32 characters
+	bits := uint64(0)
+	for _, b := range random {
+		bits = bits<<8 | uint64(b)
+	}
+	suffix := make([]byte, 8)
+	for i := len(suffix) - 1; i >= 0; i-- {
+		suffix[i] = referenceAlphabet[bits&31]
+		bits >>= 5
+	}
+
+	return FormatReference(g.prefix, g.now(), string(suffix)), nil
+}
+
+// FormatReference builds a reference from a prefix, the UTC date and a unique suffix
+func FormatReference(prefix string, date time.Time, suffix string) string {
+	return fmt.Sprintf("%s-%s-%s", prefix, date.UTC().Format("20060102"), suffix)
+}
+
+var (
+	referenceGeneratorMu sync.RWMutex
+	referenceGenerator   ReferenceGenerator = NewRandomReferenceGenerator(DefaultReferencePrefix)
+)
+
+// SetReferenceGenerator replaces the generator used for new order references.
+// Passing nil restores the default random generator.
+func SetReferenceGenerator(generator ReferenceGenerator) {
+	if generator == nil {
+		generator = NewRandomReferenceGenerator(DefaultReferencePrefix)
+	}
+
+	referenceGeneratorMu.Lock()
+	defer referenceGeneratorMu.Unlock()
+	referenceGenerator = generator
+}
+
+// NewOrderReference returns a reference from the configured generator
+func NewOrderReference() (string, error) {
+	referenceGeneratorMu.RLock()
+	generator := referenceGenerator
+	referenceGeneratorMu.RUnlock()
+
+	return generator.NextReference()
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRandomReferenceGenerator_NextReference(t *testing.T) {
+	generator := NewRandomReferenceGenerator("SHOP")
+	generator.now = func() time.Time {
+		return time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)
+	}
+
+	pattern := regexp.MustCompile(`^SHOP-20240131-[0-9A-HJKMNP-TV-Z]{8}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		reference, err := generator.NextReference()
+		if err != nil {
+			t.Fatalf("NextReference() unexpected error = %v", err)
+		}
+		if !pattern.MatchString(reference) {
+			t.Fatalf("reference %q does not match %s", reference, pattern)
+		}
+		if seen[reference] {
+			t.Fatalf("reference %q generated twice", reference)
+		}
+		seen[reference] = true
+	}
+}
+
+func TestFormatReference(t *testing.T) {
+	// Dates are formatted in UTC regardless of the local time zone
+	date := time.Date(2024, 2, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	if got := FormatReference("ORDER", date, "000042"); got != "ORDER-20240201-000042" {
+		t.Errorf("FormatReference() = %q, want %q", got, "ORDER-20240201-000042")
+	}
+	if got := FormatReference("ORDER", date.Add(-time.Minute), "000042"); got != "ORDER-20240131-000042" {
+		t.Errorf("FormatReference() = %q, want %q", got, "ORDER-20240131-000042")
+	}
+}
+
+func TestValidateReferencePrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"ORDER", false},
+		{"SHOP2", false},
+		{"ABCDEFGHIJKLMNOP", false},
+		{"", true},
+		{"order", true},
+		{"ORD-ER", true},
+		{"ABCDEFGHIJKLMNOPQ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			err := ValidateReferencePrefix(tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateReferencePrefix(%q) error = %v, wantErr %v", tt.prefix, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type stubReferenceGenerator struct {
+	reference string
+	err       error
+}
+
+func (g *stubReferenceGenerator) NextReference() (string, error) {
+	return g.reference, g.err
+}
+
+func TestSetReferenceGenerator(t *testing.T) {
+	defer SetReferenceGenerator(nil)
+
+	SetReferenceGenerator(&stubReferenceGenerator{reference: "SHOP-20240131-000001"})
+	order, err := NewOrder("Premium Widget", 1000, "USD")
+	if err != nil {
+		t.Fatalf("NewOrder() unexpected error = %v", err)
+	}
+	if order.Reference != "SHOP-20240131-000001" {
+		t.Errorf("Expected reference from configured generator, got %s", order.Reference)
+	}
+
+	generatorErr := errors.New("sequence unavailable")
+	SetReferenceGenerator(&stubReferenceGenerator{err: generatorErr})
+	if _, err := NewOrder("Premium Widget", 1000, "USD"); !errors.Is(err, generatorErr) {
+		t.Errorf("Expected generator error, got %v", err)
+	}
+
+	SetReferenceGenerator(nil)
+	reference, err := NewOrderReference()
+	if err != nil {
+		t.Fatalf("NewOrderReference() unexpected error = %v", err)
+	}
+	if !regexp.MustCompile(`^ORDER-\d{8}-[0-9A-Z]{8}$`).MatchString(reference) {
+		t.Errorf("Expected default random reference, got %s", reference)
+	}
+}
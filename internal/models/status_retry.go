@@ -0,0 +1,25 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrStatusRetryNotFound is returned for a status retry that is no longer queued
+var ErrStatusRetryNotFound = errors.New("status retry not found")
+
+// StatusUpdateRetry is an order status change that could not be stored and is retried
+// in the background until it is applied or superseded
+type StatusUpdateRetry struct {
+	ID             string
+	OrderReference string
+	Status         OrderStatus
+	PSPReference   string
+	Change         OrderChange
+	// Attempts counts the retries made so far, not the original update
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/database"
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// CartRepository handles database operations for carts
+type CartRepository struct {
+	db *sql.DB
+}
+
+// NewCartRepository creates a new cart repository
+func NewCartRepository() *CartRepository {
+	return &CartRepository{
+		db: database.DB,
+	}
+}
+
+// NewCartRepositoryWithDB creates a new cart repository with a specific database connection
+func NewCartRepositoryWithDB(db *sql.DB) *CartRepository {
+	return &CartRepository{
+		db: db,
+	}
+}
+
+// CreateCart creates a new, empty cart in the database
+func (r *CartRepository) CreateCart(ctx context.Context, cart *models.Cart) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO carts (id, created_at, updated_at) VALUES ($1, $2, $3)`, cart.ID, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create cart: %w", err)
+	}
+
+	cart.CreatedAt = now
+	cart.UpdatedAt = now
+	return nil
+}
+
+// GetCart retrieves a cart and its items. Items whose product is no longer active are left out.
+func (r *CartRepository) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	cart := &models.Cart{}
+	err := r.db.QueryRowContext(ctx, `SELECT id, created_at, updated_at FROM carts WHERE id = $1`, id).Scan(
+		&cart.ID,
+		&cart.CreatedAt,
+		&cart.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrCartNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+
+	query := `
+		SELECT p.id, p.sku, p.name, p.description, p.image_url, p.price, p.currency, p.created_at, p.updated_at,
+		       ci.quantity
+		FROM cart_items ci
+		JOIN products p ON p.id = ci.product_id
+		WHERE ci.cart_id = $1 AND p.active
+		ORDER BY ci.created_at, p.sku
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product := &models.Product{}
+		item := models.CartItem{Product: product}
+		err := rows.Scan(
+			&product.ID,
+			&product.SKU,
+			&product.Name,
+			&product.Description,
+			&product.ImageURL,
+			&product.Price,
+			&product.Currency,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&item.Quantity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cart item: %w", err)
+		}
+		cart.Items = append(cart.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get cart items: %w", err)
+	}
+
+	return cart, nil
+}
+
+// SetItemQuantity adds a product to a cart or replaces the quantity already in it
+func (r *CartRepository) SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error {
+	query := `
+		INSERT INTO cart_items (cart_id, product_id, quantity, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (cart_id, product_id)
+		DO UPDATE SET quantity = EXCLUDED.quantity, updated_at = EXCLUDED.updated_at
+	`
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	if _, err := r.db.ExecContext(ctx, query, cartID, productID, quantity, now); err != nil {
+		return fmt.Errorf("failed to set cart item quantity: %w", err)
+	}
+	return r.touch(ctx, cartID, now)
+}
+
+// RemoveItem removes a product from a cart
+func (r *CartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2`, cartID, productID); err != nil {
+		return fmt.Errorf("failed to remove cart item: %w", err)
+	}
+	return r.touch(ctx, cartID, time.Now())
+}
+
+// ClearCart removes all items from a cart
+func (r *CartRepository) ClearCart(ctx context.Context, cartID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, cartID); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	return r.touch(ctx, cartID, time.Now())
+}
+
+// touch updates the cart's updated_at timestamp
+func (r *CartRepository) touch(ctx context.Context, cartID string, now time.Time) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE carts SET updated_at = $1 WHERE id = $2`, now, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrCartNotFound
+	}
+	return nil
+}
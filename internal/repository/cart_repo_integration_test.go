@@ -0,0 +1,104 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository/testutil"
+	"github.com/google/uuid"
+)
+
+// seededWidgetID is the id of the product seeded by the migrations
+const seededWidgetID = "6f1c3e0a-3b7d-4c52-9a59-2d1b8f4e7a01"
+
+func TestCartRepository_Items_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewCartRepositoryWithDB(testDB.DB)
+
+	cart := &models.Cart{ID: uuid.New().String()}
+	if err := repo.CreateCart(context.Background(), cart); err != nil {
+		t.Fatalf("CreateCart() error = %v", err)
+	}
+
+	// Adding the same product twice replaces the quantity
+	if err := repo.SetItemQuantity(context.Background(), cart.ID, seededWidgetID, 2); err != nil {
+		t.Fatalf("SetItemQuantity() error = %v", err)
+	}
+	if err := repo.SetItemQuantity(context.Background(), cart.ID, seededWidgetID, 5); err != nil {
+		t.Fatalf("SetItemQuantity() error = %v", err)
+	}
+
+	retrieved, err := repo.GetCart(context.Background(), cart.ID)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+	if len(retrieved.Items) != 1 {
+		t.Fatalf("Expected 1 cart item, got %d", len(retrieved.Items))
+	}
+	if retrieved.Items[0].Quantity != 5 {
+		t.Errorf("Expected quantity 5, got %d", retrieved.Items[0].Quantity)
+	}
+	if retrieved.Items[0].Product.SKU != "widget-001" || retrieved.Items[0].Product.Price != 100 {
+		t.Errorf("Unexpected product %+v", retrieved.Items[0].Product)
+	}
+
+	if err := repo.RemoveItem(context.Background(), cart.ID, seededWidgetID); err != nil {
+		t.Fatalf("RemoveItem() error = %v", err)
+	}
+	retrieved, err = repo.GetCart(context.Background(), cart.ID)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+	if !retrieved.IsEmpty() {
+		t.Errorf("Expected empty cart after removal, got %d items", len(retrieved.Items))
+	}
+}
+
+func TestCartRepository_ClearCart_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewCartRepositoryWithDB(testDB.DB)
+
+	cart := &models.Cart{ID: uuid.New().String()}
+	if err := repo.CreateCart(context.Background(), cart); err != nil {
+		t.Fatalf("CreateCart() error = %v", err)
+	}
+	if err := repo.SetItemQuantity(context.Background(), cart.ID, seededWidgetID, 1); err != nil {
+		t.Fatalf("SetItemQuantity() error = %v", err)
+	}
+
+	if err := repo.ClearCart(context.Background(), cart.ID); err != nil {
+		t.Fatalf("ClearCart() error = %v", err)
+	}
+
+	retrieved, err := repo.GetCart(context.Background(), cart.ID)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+	if !retrieved.IsEmpty() {
+		t.Errorf("Expected empty cart, got %d items", len(retrieved.Items))
+	}
+}
+
+func TestCartRepository_NotFound_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewCartRepositoryWithDB(testDB.DB)
+	missingID := uuid.New().String()
+
+	if _, err := repo.GetCart(context.Background(), missingID); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("GetCart() error = %v, want ErrCartNotFound", err)
+	}
+	if err := repo.ClearCart(context.Background(), missingID); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("ClearCart() error = %v, want ErrCartNotFound", err)
+	}
+}
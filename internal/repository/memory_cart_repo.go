@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// MemoryCartRepository stores carts in memory. It is safe for concurrent use and
+// intended for tests and demos. Cart items are resolved against the product repository.
+type MemoryCartRepository struct {
+	mu       sync.RWMutex
+	carts    map[string]*memoryCart
+	products *MemoryProductRepository
+}
+
+// memoryCart is a stored cart with its items in the order they were added
+type memoryCart struct {
+	cart  models.Cart
+	items []memoryCartItem
+}
+
+// memoryCartItem is the quantity of a product in a stored cart
+type memoryCartItem struct {
+	productID string
+	quantity  int
+}
+
+// NewMemoryCartRepository creates an empty in-memory cart repository
+func NewMemoryCartRepository(products *MemoryProductRepository) *MemoryCartRepository {
+	return &MemoryCartRepository{
+		carts:    make(map[string]*memoryCart),
+		products: products,
+	}
+}
+
+// CreateCart stores a new, empty cart
+func (r *MemoryCartRepository) CreateCart(ctx context.Context, cart *models.Cart) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	cart.CreatedAt = now
+	cart.UpdatedAt = now
+	r.carts[cart.ID] = &memoryCart{cart: models.Cart{ID: cart.ID, CreatedAt: now, UpdatedAt: now}}
+	return nil
+}
+
+// GetCart returns a cart and its items. Items whose product no longer exists are left out.
+func (r *MemoryCartRepository) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.carts[id]
+	if !ok {
+		return nil, models.ErrCartNotFound
+	}
+
+	cart := stored.cart
+	for _, item := range stored.items {
+		product, ok := r.products.getProductByID(item.productID)
+		if !ok {
+			continue
+		}
+		cart.Items = append(cart.Items, models.CartItem{Product: product, Quantity: item.quantity})
+	}
+	return &cart, nil
+}
+
+// SetItemQuantity adds a product to a cart or replaces the quantity already in it
+func (r *MemoryCartRepository) SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.carts[cartID]
+	if !ok {
+		return models.ErrCartNotFound
+	}
+
+	stored.cart.UpdatedAt = time.Now()
+	for i := range stored.items {
+		if stored.items[i].productID == productID {
+			stored.items[i].quantity = quantity
+			return nil
+		}
+	}
+	stored.items = append(stored.items, memoryCartItem{productID: productID, quantity: quantity})
+	return nil
+}
+
+// RemoveItem removes a product from a cart
+func (r *MemoryCartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.carts[cartID]
+	if !ok {
+		return models.ErrCartNotFound
+	}
+
+	stored.cart.UpdatedAt = time.Now()
+	for i := range stored.items {
+		if stored.items[i].productID == productID {
+			stored.items = append(stored.items[:i], stored.items[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// ClearCart removes all items from a cart
+func (r *MemoryCartRepository) ClearCart(ctx context.Context, cartID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.carts[cartID]
+	if !ok {
+		return models.ErrCartNotFound
+	}
+
+	stored.cart.UpdatedAt = time.Now()
+	stored.items = nil
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestMemoryProductRepository(t *testing.T) {
+	gadget := &models.Product{ID: "gadget-id", SKU: "gadget-001", Name: "Deluxe Gadget", Price: 2500, Currency: "USD"}
+	repo := NewMemoryProductRepository(append(DemoProducts(), gadget)...)
+
+	products, err := repo.ListProducts(context.Background())
+	if err != nil {
+		t.Fatalf("ListProducts() unexpected error = %v", err)
+	}
+	if len(products) != 2 || products[0].SKU != "gadget-001" || products[1].SKU != "widget-001" {
+		t.Errorf("Expected products ordered by name, got %+v", products)
+	}
+
+	product, err := repo.GetProductBySKU(context.Background(), "widget-001")
+	if err != nil {
+		t.Fatalf("GetProductBySKU() unexpected error = %v", err)
+	}
+	if product.Name != "Premium Widget" || product.Price != 100 {
+		t.Errorf("Unexpected product: %+v", product)
+	}
+
+	if _, err := repo.GetProductBySKU(context.Background(), "missing-001"); !errors.Is(err, models.ErrProductNotFound) {
+		t.Errorf("Expected ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestMemoryCartRepository(t *testing.T) {
+	products := NewMemoryProductRepository(DemoProducts()...)
+	widget, _ := products.GetProductBySKU(context.Background(), "widget-001")
+	repo := NewMemoryCartRepository(products)
+
+	if _, err := repo.GetCart(context.Background(), "missing"); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("Expected ErrCartNotFound, got %v", err)
+	}
+	if err := repo.SetItemQuantity(context.Background(), "missing", widget.ID, 1); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("Expected ErrCartNotFound, got %v", err)
+	}
+
+	cart := &models.Cart{ID: "cart-1"}
+	if err := repo.CreateCart(context.Background(), cart); err != nil {
+		t.Fatalf("CreateCart() unexpected error = %v", err)
+	}
+
+	if err := repo.SetItemQuantity(context.Background(), "cart-1", widget.ID, 2); err != nil {
+		t.Fatalf("SetItemQuantity() unexpected error = %v", err)
+	}
+	if err := repo.SetItemQuantity(context.Background(), "cart-1", widget.ID, 3); err != nil {
+		t.Fatalf("SetItemQuantity() unexpected error = %v", err)
+	}
+	// Items for products that are not in the catalog are left out
+	if err := repo.SetItemQuantity(context.Background(), "cart-1", "unknown-product", 1); err != nil {
+		t.Fatalf("SetItemQuantity() unexpected error = %v", err)
+	}
+
+	retrieved, err := repo.GetCart(context.Background(), "cart-1")
+	if err != nil {
+		t.Fatalf("GetCart() unexpected error = %v", err)
+	}
+	if len(retrieved.Items) != 1 || retrieved.Items[0].Quantity != 3 || retrieved.Items[0].Product.SKU != "widget-001" {
+		t.Errorf("Expected 3 widgets in cart, got %+v", retrieved.Items)
+	}
+
+	if err := repo.RemoveItem(context.Background(), "cart-1", widget.ID); err != nil {
+		t.Fatalf("RemoveItem() unexpected error = %v", err)
+	}
+	if retrieved, _ := repo.GetCart(context.Background(), "cart-1"); !retrieved.IsEmpty() {
+		t.Errorf("Expected empty cart after removing item, got %+v", retrieved.Items)
+	}
+
+	_ = repo.SetItemQuantity(context.Background(), "cart-1", widget.ID, 1)
+	if err := repo.ClearCart(context.Background(), "cart-1"); err != nil {
+		t.Fatalf("ClearCart() unexpected error = %v", err)
+	}
+	if retrieved, _ := repo.GetCart(context.Background(), "cart-1"); !retrieved.IsEmpty() {
+		t.Errorf("Expected empty cart after clearing, got %+v", retrieved.Items)
+	}
+}
+
+func TestMemoryCartRepository_CancelledContext(t *testing.T) {
+	products := NewMemoryProductRepository(DemoProducts()...)
+	widget, _ := products.GetProductBySKU(context.Background(), "widget-001")
+	repo := NewMemoryCartRepository(products)
+	if err := repo.CreateCart(context.Background(), &models.Cart{ID: "cart-ctx"}); err != nil {
+		t.Fatalf("CreateCart() unexpected error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := products.ListProducts(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListProducts() expected context.Canceled, got %v", err)
+	}
+	if _, err := repo.GetCart(ctx, "cart-ctx"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetCart() expected context.Canceled, got %v", err)
+	}
+	if err := repo.SetItemQuantity(ctx, "cart-ctx", widget.ID, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetItemQuantity() expected context.Canceled, got %v", err)
+	}
+
+	if retrieved, _ := repo.GetCart(context.Background(), "cart-ctx"); !retrieved.IsEmpty() {
+		t.Errorf("Expected cart to stay empty, got %+v", retrieved.Items)
+	}
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryOrderRepository stores orders in memory. It is safe for concurrent use
+// and intended for tests and demos; orders are lost when the process exits.
+type MemoryOrderRepository struct {
+	mu      sync.RWMutex
+	orders  map[string]*models.Order
+	history map[string][]models.OrderHistoryEvent
+}
+
+// NewMemoryOrderRepository creates an empty in-memory order repository
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		orders:  make(map[string]*models.Order),
+		history: make(map[string][]models.OrderHistoryEvent),
+	}
+}
+
+// CreateOrder stores a copy of the order.
+// If the order reference is already taken, a new one is generated, as in OrderRepository.
+func (r *MemoryOrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for attempt := 1; order.Reference == "" || r.orders[order.Reference] != nil; attempt++ {
+		if attempt > maxReferenceAttempts {
+			return fmt.Errorf("failed to create order after %d attempts: %w", maxReferenceAttempts, models.ErrDuplicateReference)
+		}
+		reference, err := models.NewOrderReference()
+		if err != nil {
+			return err
+		}
+		order.Reference = reference
+	}
+
+	now := time.Now()
+	order.Version = 1
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	r.orders[order.Reference] = copyOrder(order)
+	return nil
+}
+
+// GetOrderByReference returns a copy of the order with the given reference
+func (r *MemoryOrderRepository) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[reference]
+	if !ok {
+		return nil, models.ErrOrderNotFound
+	}
+	return copyOrder(order), nil
+}
+
+// UpdateOrderStatus updates the status and PSP reference of an order if it is still at
+// expectedVersion, and appends the change to the order's history
+func (r *MemoryOrderRepository) UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
+	return r.updateOrder(ctx, reference, status, pspReference, expectedVersion, change, func(order *models.Order) {
+		order.PSPReference = pspReference
+	})
+}
+
+// UpdateOrderRefund updates the status and refunded amount of an order if it is still at
+// expectedVersion, and appends the change to the order's history under the refund's PSP reference
+func (r *MemoryOrderRepository) UpdateOrderRefund(ctx context.Context, reference, status, refundPSPReference string, refundedAmount int64, expectedVersion int, change models.OrderChange) error {
+	return r.updateOrder(ctx, reference, status, refundPSPReference, expectedVersion, change, func(order *models.Order) {
+		order.RefundedAmount = refundedAmount
+	})
+}
+
+// updateOrder sets the status of an order, applies update and records the change with eventPSPReference
+func (r *MemoryOrderRepository) updateOrder(ctx context.Context, reference, status, eventPSPReference string, expectedVersion int, change models.OrderChange, update func(*models.Order)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[reference]
+	if !ok {
+		return models.ErrOrderNotFound
+	}
+	if order.Version != expectedVersion {
+		return fmt.Errorf("%w: %s is at version %d, expected %d", models.ErrOrderVersionConflict, reference, order.Version, expectedVersion)
+	}
+
+	now := time.Now()
+	r.history[reference] = append(r.history[reference], models.OrderHistoryEvent{
+		ID:             uuid.NewString(),
+		OrderID:        order.ID,
+		PreviousStatus: order.Status,
+		Status:         models.OrderStatus(status),
+		PSPReference:   eventPSPReference,
+		Source:         change.Source,
+		Actor:          change.Actor,
+		Payload:        append(json.RawMessage(nil), change.Payload...),
+		CreatedAt:      now,
+	})
+
+	order.Status = models.OrderStatus(status)
+	update(order)
+	order.Version++
+	order.UpdatedAt = now
+	return nil
+}
+
+// SetPaymentSession records the order's payment session
+func (r *MemoryOrderRepository) SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[reference]
+	if !ok {
+		return models.ErrOrderNotFound
+	}
+	order.SessionID = session.ID
+	order.SessionData = session.Data
+	order.SessionExpiresAt = session.ExpiresAt
+	order.SessionShopper = session.Shopper
+	return nil
+}
+
+// ListExpiredOrders returns up to limit pending orders whose payment session expired by now,
+// oldest first. Orders without a recorded session expiry expire sessionLifetime after creation.
+func (r *MemoryOrderRepository) ListExpiredOrders(ctx context.Context, now time.Time, sessionLifetime time.Duration, limit int) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := []models.Order{}
+	for _, order := range r.orders {
+		if order.IsPending() && order.IsSessionExpired(now, sessionLifetime) {
+			orders = append(orders, *copyOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].ID < orders[j].ID
+		}
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (r *MemoryOrderRepository) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.orders[reference]; !ok {
+		return nil, models.ErrOrderNotFound
+	}
+	return append([]models.OrderHistoryEvent{}, r.history[reference]...), nil
+}
+
+// copyOrder returns a copy of the order that shares no memory with the original
+func copyOrder(order *models.Order) *models.Order {
+	copied := *order
+	copied.Items = append([]models.OrderItem(nil), order.Items...)
+	return &copied
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+)
+
+func newTestOrder(t *testing.T, reference string) *models.Order {
+	t.Helper()
+	order, err := models.NewOrderFromItems([]models.OrderItem{
+		{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 500, Quantity: 2},
+	}, "USD")
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	order.Reference = reference
+	return order
+}
+
+func TestMemoryOrderRepository_CreateAndGet(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	order := newTestOrder(t, "ORDER-MEM-001")
+
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+	if order.CreatedAt.IsZero() {
+		t.Error("Expected CreatedAt to be set")
+	}
+
+	retrieved, err := repo.GetOrderByReference(context.Background(), "ORDER-MEM-001")
+	if err != nil {
+		t.Fatalf("GetOrderByReference() unexpected error = %v", err)
+	}
+	if retrieved.ID != order.ID || retrieved.Amount != 1000 || len(retrieved.Items) != 1 {
+		t.Errorf("Retrieved order does not match: %+v", retrieved)
+	}
+
+	// Changing the returned order must not change the stored one
+	retrieved.Status = models.OrderStatusFailed
+	retrieved.Items[0].Quantity = 5
+	again, _ := repo.GetOrderByReference(context.Background(), "ORDER-MEM-001")
+	if again.Status != models.OrderStatusPending || again.Items[0].Quantity != 2 {
+		t.Errorf("Stored order was modified through a returned copy: %+v", again)
+	}
+
+	if _, err := repo.GetOrderByReference(context.Background(), "ORDER-MISSING"); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepository_DuplicateReference(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+
+	if err := repo.CreateOrder(context.Background(), newTestOrder(t, "ORDER-DUP-001")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	second := newTestOrder(t, "ORDER-DUP-001")
+	if err := repo.CreateOrder(context.Background(), second); err != nil {
+		t.Fatalf("Expected duplicate reference to be retried, got %v", err)
+	}
+	if second.Reference == "ORDER-DUP-001" {
+		t.Error("Expected a new reference for the colliding order")
+	}
+	if _, err := repo.GetOrderByReference(context.Background(), second.Reference); err != nil {
+		t.Errorf("Expected retried order to be stored, got %v", err)
+	}
+}
+
+// fixedReference is a reference generator that always returns the same reference
+type fixedReference string
+
+func (g fixedReference) NextReference() (string, error) {
+	return string(g), nil
+}
+
+func TestMemoryOrderRepository_ReferenceRetriesExhausted(t *testing.T) {
+	models.SetReferenceGenerator(fixedReference("ORDER-TAKEN-001"))
+	defer models.SetReferenceGenerator(nil)
+
+	repo := NewMemoryOrderRepository()
+	if err := repo.CreateOrder(context.Background(), newTestOrder(t, "")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	err := repo.CreateOrder(context.Background(), newTestOrder(t, ""))
+	if !errors.Is(err, models.ErrDuplicateReference) {
+		t.Errorf("Expected ErrDuplicateReference, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepository_UpdateOrderStatus(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	if err := repo.CreateOrder(context.Background(), newTestOrder(t, "ORDER-UPD-001")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	if err := repo.UpdateOrderStatus(context.Background(), "ORDER-UPD-001", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	retrieved, _ := repo.GetOrderByReference(context.Background(), "ORDER-UPD-001")
+	if retrieved.Status != models.OrderStatusAuthorized || retrieved.PSPReference != "PSP-123" {
+		t.Errorf("Expected authorized order with PSP-123, got %s %s", retrieved.Status, retrieved.PSPReference)
+	}
+
+	err := repo.UpdateOrderStatus(context.Background(), "ORDER-MISSING", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepository_UpdateOrderRefund(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryOrderRepository()
+	if err := repo.CreateOrder(ctx, newTestOrder(t, "ORDER-REF-001")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+	if err := repo.UpdateOrderStatus(ctx, "ORDER-REF-001", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	if err := repo.UpdateOrderRefund(ctx, "ORDER-REF-001", string(models.OrderStatusPartiallyRefunded), "REFUND-PSP-1", 400, 2, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderRefund() unexpected error = %v", err)
+	}
+
+	retrieved, _ := repo.GetOrderByReference(ctx, "ORDER-REF-001")
+	if retrieved.Status != models.OrderStatusPartiallyRefunded || retrieved.RefundedAmount != 400 {
+		t.Errorf("Expected partially refunded order with 400 refunded, got %s %d", retrieved.Status, retrieved.RefundedAmount)
+	}
+	if retrieved.PSPReference != "PSP-123" {
+		t.Errorf("Expected the payment PSP reference to be kept, got %s", retrieved.PSPReference)
+	}
+
+	history, _ := repo.GetOrderHistory(ctx, "ORDER-REF-001")
+	if last := history[len(history)-1]; last.PSPReference != "REFUND-PSP-1" {
+		t.Errorf("Expected the refund PSP reference in the history, got %s", last.PSPReference)
+	}
+
+	err := repo.UpdateOrderRefund(ctx, "ORDER-REF-001", string(models.OrderStatusRefunded), "REFUND-PSP-2", 1000, 2, models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderVersionConflict) {
+		t.Errorf("Expected ErrOrderVersionConflict, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepository_ListExpiredOrders(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, reference := range []string{"ORDER-EXP-001", "ORDER-EXP-002", "ORDER-EXP-003", "ORDER-EXP-004"} {
+		if err := repo.CreateOrder(ctx, newTestOrder(t, reference)); err != nil {
+			t.Fatalf("CreateOrder() unexpected error = %v", err)
+		}
+	}
+	if err := repo.SetPaymentSession(ctx, "ORDER-EXP-001", models.PaymentSession{
+		ID: "CS-SESSION", Data: "session-data", ExpiresAt: now.Add(-time.Minute),
+		Shopper: models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"},
+	}); err != nil {
+		t.Fatalf("SetPaymentSession() unexpected error = %v", err)
+	}
+	if err := repo.SetPaymentSession(ctx, "ORDER-EXP-002", models.PaymentSession{ID: "CS-SESSION", Data: "session-data", ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("SetPaymentSession() unexpected error = %v", err)
+	}
+	if err := repo.SetPaymentSession(ctx, "ORDER-EXP-004", models.PaymentSession{ID: "CS-SESSION", Data: "session-data", ExpiresAt: now.Add(-time.Minute)}); err != nil {
+		t.Fatalf("SetPaymentSession() unexpected error = %v", err)
+	}
+	// Paid orders never expire
+	if err := repo.UpdateOrderStatus(ctx, "ORDER-EXP-004", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	expired, err := repo.ListExpiredOrders(ctx, now, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("ListExpiredOrders() unexpected error = %v", err)
+	}
+	if len(expired) != 1 || expired[0].Reference != "ORDER-EXP-001" {
+		t.Fatalf("Expected only ORDER-EXP-001, got %+v", expired)
+	}
+	if expired[0].SessionID != "CS-SESSION" || expired[0].SessionData != "session-data" || expired[0].SessionExpiresAt.IsZero() {
+		t.Errorf("Expected the payment session to be returned, got %+v", expired[0])
+	}
+	if expected := (models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"}); expired[0].SessionShopper != expected {
+		t.Errorf("Expected the session shopper %+v, got %+v", expected, expired[0].SessionShopper)
+	}
+
+	// ORDER-EXP-003 has no recorded session and falls back to the session lifetime
+	expired, _ = repo.ListExpiredOrders(ctx, now.Add(2*time.Hour), time.Hour, 10)
+	if len(expired) != 3 {
+		t.Errorf("Expected 3 expired orders after the session lifetime, got %d", len(expired))
+	}
+	if expired, _ = repo.ListExpiredOrders(ctx, now.Add(2*time.Hour), time.Hour, 2); len(expired) != 2 {
+		t.Errorf("Expected the limit to apply, got %d orders", len(expired))
+	}
+
+	if err := repo.SetPaymentSession(ctx, "ORDER-MISSING", models.PaymentSession{ID: "CS-SESSION", Data: "session-data", ExpiresAt: now}); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestMemoryOrderRepository_UpdateOrderStatus_VersionConflict(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	if err := repo.CreateOrder(context.Background(), newTestOrder(t, "ORDER-VER-001")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	created, _ := repo.GetOrderByReference(context.Background(), "ORDER-VER-001")
+	if created.Version != 1 {
+		t.Fatalf("Expected a new order at version 1, got %d", created.Version)
+	}
+
+	if err := repo.UpdateOrderStatus(context.Background(), "ORDER-VER-001", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	// A writer that read the order before the first update loses
+	err := repo.UpdateOrderStatus(context.Background(), "ORDER-VER-001", string(models.OrderStatusFailed), "PSP-456", 1, models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderVersionConflict) {
+		t.Fatalf("Expected ErrOrderVersionConflict, got %v", err)
+	}
+
+	retrieved, _ := repo.GetOrderByReference(context.Background(), "ORDER-VER-001")
+	if retrieved.Status != models.OrderStatusAuthorized || retrieved.PSPReference != "PSP-123" || retrieved.Version != 2 {
+		t.Errorf("Expected authorized order with PSP-123 at version 2, got %s %s %d", retrieved.Status, retrieved.PSPReference, retrieved.Version)
+	}
+	history, _ := repo.GetOrderHistory(context.Background(), "ORDER-VER-001")
+	if len(history) != 1 {
+		t.Errorf("Expected only the successful change in the history, got %d events", len(history))
+	}
+}
+
+func TestMemoryOrderRepository_CancelledContext(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	if err := repo.CreateOrder(context.Background(), newTestOrder(t, "ORDER-CTX-001")); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := repo.CreateOrder(ctx, newTestOrder(t, "ORDER-CTX-002")); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateOrder() expected context.Canceled, got %v", err)
+	}
+	if _, err := repo.GetOrderByReference(ctx, "ORDER-CTX-001"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetOrderByReference() expected context.Canceled, got %v", err)
+	}
+	err := repo.UpdateOrderStatus(ctx, "ORDER-CTX-001", string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateOrderStatus() expected context.Canceled, got %v", err)
+	}
+
+	retrieved, _ := repo.GetOrderByReference(context.Background(), "ORDER-CTX-001")
+	if retrieved.Status != models.OrderStatusPending {
+		t.Errorf("Expected order to stay pending, got %s", retrieved.Status)
+	}
+}
+
+func TestMemoryOrderRepository_ConcurrentAccess(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reference := fmt.Sprintf("ORDER-CONC-%03d", i)
+			order := newTestOrder(t, reference)
+			order.ID = uuid.New().String()
+			if err := repo.CreateOrder(context.Background(), order); err != nil {
+				t.Errorf("CreateOrder() unexpected error = %v", err)
+				return
+			}
+			if err := repo.UpdateOrderStatus(context.Background(), reference, string(models.OrderStatusAuthorized), "PSP", 1, models.OrderChange{}); err != nil {
+				t.Errorf("UpdateOrderStatus() unexpected error = %v", err)
+			}
+			if _, err := repo.GetOrderByReference(context.Background(), reference); err != nil {
+				t.Errorf("GetOrderByReference() unexpected error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMemoryOrderRepository_OrderHistory(t *testing.T) {
+	repo := NewMemoryOrderRepository()
+	order := newTestOrder(t, "ORDER-HIST-001")
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder() unexpected error = %v", err)
+	}
+
+	history, err := repo.GetOrderHistory(context.Background(), "ORDER-HIST-001")
+	if err != nil || len(history) != 0 {
+		t.Fatalf("Expected an empty history, got %v, %v", history, err)
+	}
+
+	webhook := models.OrderChange{Source: models.OrderEventSourceWebhook, Actor: "adyen", Payload: []byte(`{"eventCode":"AUTHORISATION"}`)}
+	if err := repo.UpdateOrderStatus(context.Background(), "ORDER-HIST-001", string(models.OrderStatusAuthorized), "PSP-123", 1, webhook); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+	admin := models.OrderChange{Source: models.OrderEventSourceAdmin, Actor: "alice"}
+	if err := repo.UpdateOrderStatus(context.Background(), "ORDER-HIST-001", string(models.OrderStatusRefundRequested), "PSP-123", 2, admin); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	history, err = repo.GetOrderHistory(context.Background(), "ORDER-HIST-001")
+	if err != nil {
+		t.Fatalf("GetOrderHistory() unexpected error = %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(history))
+	}
+
+	first := history[0]
+	if first.OrderID != order.ID || first.PreviousStatus != models.OrderStatusPending || first.Status != models.OrderStatusAuthorized {
+		t.Errorf("Expected pending -> authorized for order %s, got %+v", order.ID, first)
+	}
+	if first.Source != models.OrderEventSourceWebhook || first.Actor != "adyen" || string(first.Payload) != `{"eventCode":"AUTHORISATION"}` {
+		t.Errorf("Expected the webhook change, got %+v", first)
+	}
+	if second := history[1]; second.PreviousStatus != models.OrderStatusAuthorized || second.Source != models.OrderEventSourceAdmin || second.Actor != "alice" {
+		t.Errorf("Expected the admin refund request, got %+v", second)
+	}
+
+	// The returned history is a copy
+	history[0].Actor = "mallory"
+	history, _ = repo.GetOrderHistory(context.Background(), "ORDER-HIST-001")
+	if history[0].Actor != "adyen" {
+		t.Error("Expected stored history to be unaffected by changes to the returned slice")
+	}
+
+	if _, err := repo.GetOrderHistory(context.Background(), "ORDER-MISSING"); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
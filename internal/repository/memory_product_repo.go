@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// MemoryProductRepository stores the product catalog in memory. It is safe for
+// concurrent use and intended for tests and demos.
+type MemoryProductRepository struct {
+	mu       sync.RWMutex
+	products map[string]*models.Product
+}
+
+// NewMemoryProductRepository creates an in-memory product repository holding the given products
+func NewMemoryProductRepository(products ...*models.Product) *MemoryProductRepository {
+	r := &MemoryProductRepository{
+		products: make(map[string]*models.Product),
+	}
+	for _, product := range products {
+		r.AddProduct(product)
+	}
+	return r
+}
+
+// DemoProducts returns the products seeded by the database migrations
+func DemoProducts() []*models.Product {
+	return []*models.Product{
+		{
+			ID:          "6f1c3e0a-3b7d-4c52-9a59-2d1b8f4e7a01",
+			SKU:         "widget-001",
+			Name:        "Premium Widget",
+			Description: "A high-quality widget perfect for all your widget needs. Durable, reliable, and designed to last.",
+			ImageURL:    "/static/images/widget-placeholder.svg",
+			Price:       100,
+			Currency:    "USD",
+		},
+	}
+}
+
+// AddProduct adds a product to the catalog or replaces the product with the same SKU
+func (r *MemoryProductRepository) AddProduct(product *models.Product) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *product
+	r.products[product.SKU] = &copied
+}
+
+// ListProducts returns all products ordered by name
+func (r *MemoryProductRepository) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := make([]*models.Product, 0, len(r.products))
+	for _, product := range r.products {
+		copied := *product
+		products = append(products, &copied)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+	return products, nil
+}
+
+// GetProductBySKU returns the product with the given SKU
+func (r *MemoryProductRepository) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.products[sku]
+	if !ok {
+		return nil, models.ErrProductNotFound
+	}
+	copied := *product
+	return &copied, nil
+}
+
+// getProductByID returns the product with the given ID
+func (r *MemoryProductRepository) getProductByID(id string) (*models.Product, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, product := range r.products {
+		if product.ID == id {
+			copied := *product
+			return &copied, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryStatusRetryRepository stores status retries in memory. It is safe for concurrent
+// use and intended for tests and demos; queued retries are lost when the process exits.
+type MemoryStatusRetryRepository struct {
+	mu      sync.Mutex
+	retries map[string]*models.StatusUpdateRetry
+}
+
+// NewMemoryStatusRetryRepository creates an empty in-memory status retry repository
+func NewMemoryStatusRetryRepository() *MemoryStatusRetryRepository {
+	return &MemoryStatusRetryRepository{
+		retries: make(map[string]*models.StatusUpdateRetry),
+	}
+}
+
+// EnqueueStatusRetry stores a copy of the retry, setting its ID and timestamps
+func (r *MemoryStatusRetryRepository) EnqueueStatusRetry(ctx context.Context, retry *models.StatusUpdateRetry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if retry.ID == "" {
+		retry.ID = uuid.NewString()
+	}
+	now := time.Now()
+	if retry.NextAttemptAt.IsZero() {
+		retry.NextAttemptAt = now
+	}
+	retry.CreatedAt = now
+	retry.UpdatedAt = now
+	r.retries[retry.ID] = copyStatusRetry(retry)
+	return nil
+}
+
+// DueStatusRetries returns up to limit retries due at now, the longest waiting first
+func (r *MemoryStatusRetryRepository) DueStatusRetries(ctx context.Context, now time.Time, limit int) ([]models.StatusUpdateRetry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := []models.StatusUpdateRetry{}
+	for _, retry := range r.retries {
+		if !retry.NextAttemptAt.After(now) {
+			due = append(due, *copyStatusRetry(retry))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// ListStatusRetries returns all queued retries, oldest first
+func (r *MemoryStatusRetryRepository) ListStatusRetries(ctx context.Context) ([]models.StatusUpdateRetry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	retries := []models.StatusUpdateRetry{}
+	for _, retry := range r.retries {
+		retries = append(retries, *copyStatusRetry(retry))
+	}
+	sort.Slice(retries, func(i, j int) bool {
+		if retries[i].CreatedAt.Equal(retries[j].CreatedAt) {
+			return retries[i].ID < retries[j].ID
+		}
+		return retries[i].CreatedAt.Before(retries[j].CreatedAt)
+	})
+	return retries, nil
+}
+
+// RescheduleStatusRetry records a failed attempt and when to try again
+func (r *MemoryStatusRetryRepository) RescheduleStatusRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	retry, ok := r.retries[id]
+	if !ok {
+		return models.ErrStatusRetryNotFound
+	}
+	retry.Attempts++
+	retry.LastError = lastError
+	retry.NextAttemptAt = nextAttemptAt
+	retry.UpdatedAt = time.Now()
+	return nil
+}
+
+// DeleteStatusRetry removes a retry that was applied or is no longer needed
+func (r *MemoryStatusRetryRepository) DeleteStatusRetry(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.retries, id)
+	return nil
+}
+
+// copyStatusRetry returns a copy of the retry that shares no memory with the original
+func copyStatusRetry(retry *models.StatusUpdateRetry) *models.StatusUpdateRetry {
+	copied := *retry
+	copied.Change.Payload = append(json.RawMessage(nil), retry.Change.Payload...)
+	return &copied
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestMemoryStatusRetryRepository_Queue(t *testing.T) {
+	repo := NewMemoryStatusRetryRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	later := &models.StatusUpdateRetry{OrderReference: "ORDER-002", Status: models.OrderStatusAuthorized, NextAttemptAt: now.Add(time.Minute)}
+	due := &models.StatusUpdateRetry{
+		OrderReference: "ORDER-001",
+		Status:         models.OrderStatusAuthorized,
+		PSPReference:   "PSP-123",
+		Change:         models.OrderChange{Source: models.OrderEventSourceRedirect, Actor: "shopper", Payload: []byte(`{"status":"completed"}`)},
+		LastError:      "database unavailable",
+		NextAttemptAt:  now.Add(-time.Second),
+	}
+	for _, retry := range []*models.StatusUpdateRetry{later, due} {
+		if err := repo.EnqueueStatusRetry(ctx, retry); err != nil {
+			t.Fatalf("EnqueueStatusRetry() unexpected error = %v", err)
+		}
+	}
+	if due.ID == "" || due.CreatedAt.IsZero() {
+		t.Errorf("Expected ID and CreatedAt to be set, got %+v", due)
+	}
+
+	retries, err := repo.DueStatusRetries(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("DueStatusRetries() unexpected error = %v", err)
+	}
+	if len(retries) != 1 || retries[0].ID != due.ID || string(retries[0].Change.Payload) != `{"status":"completed"}` {
+		t.Fatalf("Expected only the due retry, got %+v", retries)
+	}
+
+	if err := repo.RescheduleStatusRetry(ctx, due.ID, "timeout", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RescheduleStatusRetry() unexpected error = %v", err)
+	}
+	if retries, _ := repo.DueStatusRetries(ctx, now.Add(2*time.Minute), 10); len(retries) != 1 || retries[0].ID != later.ID {
+		t.Errorf("Expected the rescheduled retry to wait, got %+v", retries)
+	}
+
+	all, err := repo.ListStatusRetries(ctx)
+	if err != nil {
+		t.Fatalf("ListStatusRetries() unexpected error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 queued retries, got %d", len(all))
+	}
+	for _, retry := range all {
+		if retry.ID == due.ID && (retry.Attempts != 1 || retry.LastError != "timeout") {
+			t.Errorf("Expected 1 attempt failing with timeout, got %d %q", retry.Attempts, retry.LastError)
+		}
+	}
+
+	if err := repo.DeleteStatusRetry(ctx, due.ID); err != nil {
+		t.Fatalf("DeleteStatusRetry() unexpected error = %v", err)
+	}
+	if err := repo.RescheduleStatusRetry(ctx, due.ID, "timeout", now); !errors.Is(err, models.ErrStatusRetryNotFound) {
+		t.Errorf("Expected ErrStatusRetryNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStatusRetryRepository_DueLimit(t *testing.T) {
+	repo := NewMemoryStatusRetryRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		retry := &models.StatusUpdateRetry{OrderReference: "ORDER-001", NextAttemptAt: now.Add(-time.Duration(i) * time.Second)}
+		if err := repo.EnqueueStatusRetry(ctx, retry); err != nil {
+			t.Fatalf("EnqueueStatusRetry() unexpected error = %v", err)
+		}
+	}
+
+	retries, err := repo.DueStatusRetries(ctx, now, 2)
+	if err != nil {
+		t.Fatalf("DueStatusRetries() unexpected error = %v", err)
+	}
+	if len(retries) != 2 || !retries[0].NextAttemptAt.Before(retries[1].NextAttemptAt) {
+		t.Errorf("Expected the 2 longest waiting retries, got %+v", retries)
+	}
+}
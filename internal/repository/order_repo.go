@@ -1,12 +1,17 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/adyen/ecommerce/internal/database"
 	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // OrderRepository handles database operations for orders
@@ -28,15 +33,56 @@ func NewOrderRepositoryWithDB(db *sql.DB) *OrderRepository {
 	}
 }
 
-// CreateOrder creates a new order in the database
-func (r *OrderRepository) CreateOrder(order *models.Order) error {
+// maxReferenceAttempts bounds how often CreateOrder picks a new reference after a collision
+const maxReferenceAttempts = 5
+
+// CreateOrder creates a new order and its line items in the database.
+// If the order reference is already taken, a new one is generated and the insert retried.
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	if order.Reference == "" {
+		reference, err := models.NewOrderReference()
+		if err != nil {
+			return err
+		}
+		order.Reference = reference
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.insertOrder(ctx, order)
+		if !errors.Is(err, models.ErrDuplicateReference) {
+			return err
+		}
+		if attempt == maxReferenceAttempts {
+			return fmt.Errorf("failed to create order after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("Order reference %s already exists, retrying with a new reference", order.Reference)
+		reference, err := models.NewOrderReference()
+		if err != nil {
+			return err
+		}
+		order.Reference = reference
+	}
+}
+
+// insertOrder inserts an order and its line items in a single transaction
+func (r *OrderRepository) insertOrder(ctx context.Context, order *models.Order) error {
 	query := `
 		INSERT INTO orders (id, reference, amount, currency, status, product_name, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	now := time.Now()
-	_, err := r.db.Exec(query,
+	_, err = tx.ExecContext(ctx, query,
 		order.ID,
 		order.Reference,
 		order.Amount,
@@ -47,27 +93,111 @@ func (r *OrderRepository) CreateOrder(order *models.Order) error {
 		now,
 	)
 
+	if isUniqueViolation(err, "orders_reference_key") {
+		return models.ErrDuplicateReference
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
 	}
 
+	for _, item := range order.Items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_items (id, order_id, sku, name, unit_price, quantity, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, item.ID, order.ID, item.SKU, item.Name, item.UnitPrice, item.Quantity, now)
+		if err != nil {
+			return fmt.Errorf("failed to create order item %s: %w", item.SKU, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+
+	order.Version = 1
 	order.CreatedAt = now
 	order.UpdatedAt = now
 
 	return nil
 }
 
+// withQueryTimeout bounds a database call by the configured query timeout
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, database.QueryTimeout)
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique violation of the given constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
 // GetOrderByReference retrieves an order by its reference
-func (r *OrderRepository) GetOrderByReference(reference string) (*models.Order, error) {
+func (r *OrderRepository) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
 	query := `
-		SELECT id, reference, amount, currency, status, product_name, 
-		       COALESCE(psp_reference, ''), created_at, updated_at
+		SELECT id, reference, amount, currency, status, product_name,
+		       COALESCE(psp_reference, ''), refunded_amount, version, COALESCE(session_id, ''), COALESCE(session_data, ''),
+		       session_expires_at, COALESCE(session_country_code, ''), COALESCE(session_locale, ''),
+		       created_at, updated_at
 		FROM orders
 		WHERE reference = $1
 	`
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	order, err := scanOrder(r.db.QueryRowContext(ctx, query, reference))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrOrderNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	order.Items, err = r.getOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// getOrderItems retrieves the line items of an order
+func (r *OrderRepository) getOrderItems(ctx context.Context, orderID string) ([]models.OrderItem, error) {
+	query := `
+		SELECT id, sku, name, unit_price, quantity
+		FROM order_items
+		WHERE order_id = $1
+		ORDER BY created_at, sku
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &item.SKU, &item.Name, &item.UnitPrice, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+
+	return items, nil
+}
+
+// scanOrder scans the columns selected by GetOrderByReference, without the line items
+func scanOrder(row interface{ Scan(...interface{}) error }) (*models.Order, error) {
 	order := &models.Order{}
-	err := r.db.QueryRow(query, reference).Scan(
+	var sessionExpiresAt sql.NullTime
+	err := row.Scan(
 		&order.ID,
 		&order.Reference,
 		&order.Amount,
@@ -75,42 +205,218 @@ func (r *OrderRepository) GetOrderByReference(reference string) (*models.Order,
 		&order.Status,
 		&order.ProductName,
 		&order.PSPReference,
+		&order.RefundedAmount,
+		&order.Version,
+		&order.SessionID,
+		&order.SessionData,
+		&sessionExpiresAt,
+		&order.SessionShopper.CountryCode,
+		&order.SessionShopper.Locale,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
+	order.SessionExpiresAt = sessionExpiresAt.Time
+	return order, err
+}
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("order not found")
+// SetPaymentSession records the order's payment session
+func (r *OrderRepository) SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	// A zero expiry is stored as NULL, the order then expires after the default session lifetime
+	var sessionExpiresAt sql.NullTime
+	if !session.ExpiresAt.IsZero() {
+		sessionExpiresAt = sql.NullTime{Time: session.ExpiresAt, Valid: true}
 	}
 
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE orders
+		SET session_id = $1, session_data = $2, session_expires_at = $3,
+		    session_country_code = $4, session_locale = $5
+		WHERE reference = $6
+	`, session.ID, session.Data, sessionExpiresAt, session.Shopper.CountryCode, session.Shopper.Locale, reference)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get order: %w", err)
+		return fmt.Errorf("failed to set payment session: %w", err)
 	}
 
-	return order, nil
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set payment session: %w", err)
+	}
+	if rows == 0 {
+		return models.ErrOrderNotFound
+	}
+
+	return nil
 }
 
-// UpdateOrderStatus updates the status and PSP reference of an order
-func (r *OrderRepository) UpdateOrderStatus(reference, status, pspReference string) error {
+// ListExpiredOrders returns up to limit pending orders whose payment session expired by now,
+// oldest first. Orders without a recorded session expiry expire sessionLifetime after creation.
+func (r *OrderRepository) ListExpiredOrders(ctx context.Context, now time.Time, sessionLifetime time.Duration, limit int) ([]models.Order, error) {
 	query := `
-		UPDATE orders
-		SET status = $1, psp_reference = $2, updated_at = $3
-		WHERE reference = $4
+		SELECT id, reference, amount, currency, status, product_name,
+		       COALESCE(psp_reference, ''), refunded_amount, version, COALESCE(session_id, ''), COALESCE(session_data, ''),
+		       session_expires_at, COALESCE(session_country_code, ''), COALESCE(session_locale, ''),
+		       created_at, updated_at
+		FROM orders
+		WHERE status = $1
+		  AND (session_expires_at <= $2 OR (session_expires_at IS NULL AND created_at <= $3))
+		ORDER BY created_at, id
+		LIMIT $4
 	`
 
-	result, err := r.db.Exec(query, status, pspReference, time.Now(), reference)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, models.OrderStatusPending, now, now.Add(-sessionLifetime), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list expired orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// UpdateOrderStatus updates the status and PSP reference of an order if it is still at
+// expectedVersion, and appends the change to the order's history in the same transaction.
+// ErrOrderVersionConflict is returned if the order changed since it was read.
+func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
+	return r.updateOrder(ctx, reference, status, pspReference, sql.NullString{String: pspReference, Valid: true}, sql.NullInt64{}, expectedVersion, change)
+}
+
+// UpdateOrderRefund updates the status and refunded amount of an order if it is still at
+// expectedVersion, and appends the change to the order's history under the refund's PSP
+// reference. The order keeps the PSP reference of its payment.
+func (r *OrderRepository) UpdateOrderRefund(ctx context.Context, reference, status, refundPSPReference string, refundedAmount int64, expectedVersion int, change models.OrderChange) error {
+	return r.updateOrder(ctx, reference, status, refundPSPReference, sql.NullString{}, sql.NullInt64{Int64: refundedAmount, Valid: true}, expectedVersion, change)
+}
+
+// updateOrder sets the status of an order, and its PSP reference and refunded amount when
+// valid, then records the change with eventPSPReference in a single transaction
+func (r *OrderRepository) updateOrder(ctx context.Context, reference, status, eventPSPReference string, pspReference sql.NullString, refundedAmount sql.NullInt64, expectedVersion int, change models.OrderChange) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the row so the version check and the previous status in the history hold until commit
+	var orderID, previousStatus string
+	var version int
+	err = tx.QueryRowContext(ctx, `SELECT id, status, version FROM orders WHERE reference = $1 FOR UPDATE`, reference).
+		Scan(&orderID, &previousStatus, &version)
+	if err == sql.ErrNoRows {
+		return models.ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if version != expectedVersion {
+		return fmt.Errorf("%w: %s is at version %d, expected %d", models.ErrOrderVersionConflict, reference, version, expectedVersion)
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status = $1, psp_reference = COALESCE($2, psp_reference),
+		    refunded_amount = COALESCE($3, refunded_amount), updated_at = $4, version = version + 1
+		WHERE id = $5
+	`, status, pspReference, refundedAmount, now, orderID)
 	if err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Passed as text, lib/pq would send a []byte as bytea
+	var payload sql.NullString
+	if len(change.Payload) > 0 {
+		payload = sql.NullString{String: string(change.Payload), Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO order_events (id, order_id, previous_status, status, psp_reference, source, actor, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, uuid.NewString(), orderID, previousStatus, status, eventPSPReference, change.Source, change.Actor, payload, now)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to record order event: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("order not found")
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order status: %w", err)
 	}
 
 	return nil
 }
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (r *OrderRepository) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	query := `
+		SELECT e.id, e.order_id, e.previous_status, e.status, COALESCE(e.psp_reference, ''),
+		       e.source, e.actor, e.payload, e.created_at
+		FROM orders o
+		LEFT JOIN order_events e ON e.order_id = o.id
+		WHERE o.reference = $1
+		ORDER BY e.created_at, e.id
+	`
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+	defer rows.Close()
+
+	found := false
+	events := []models.OrderHistoryEvent{}
+	for rows.Next() {
+		found = true
+
+		// An order without events still returns one row, with NULL event columns
+		var id, orderID, previousStatus, status, pspReference, source, actor sql.NullString
+		var payload []byte
+		var createdAt sql.NullTime
+		if err := rows.Scan(&id, &orderID, &previousStatus, &status, &pspReference, &source, &actor, &payload, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		if !id.Valid {
+			continue
+		}
+
+		events = append(events, models.OrderHistoryEvent{
+			ID:             id.String,
+			OrderID:        orderID.String,
+			PreviousStatus: models.OrderStatus(previousStatus.String),
+			Status:         models.OrderStatus(status.String),
+			PSPReference:   pspReference.String,
+			Source:         models.OrderEventSource(source.String),
+			Actor:          actor.String,
+			Payload:        payload,
+			CreatedAt:      createdAt.Time,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+
+	if !found {
+		return nil, models.ErrOrderNotFound
+	}
+
+	return events, nil
+}
@@ -4,6 +4,9 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"regexp"
 	"testing"
 	"time"
 
@@ -52,7 +55,7 @@ func TestOrderRepository_CreateOrder_Integration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := repo.CreateOrder(tt.order)
+			err := repo.CreateOrder(context.Background(), tt.order)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
@@ -69,7 +72,7 @@ func TestOrderRepository_CreateOrder_Integration(t *testing.T) {
 				}
 
 				// Verify order can be retrieved
-				retrieved, err := repo.GetOrderByReference(tt.order.Reference)
+				retrieved, err := repo.GetOrderByReference(context.Background(), tt.order.Reference)
 				if err != nil {
 					t.Fatalf("Failed to retrieve created order: %v", err)
 				}
@@ -107,7 +110,7 @@ func TestOrderRepository_CreateOrder_DuplicateReference_Integration(t *testing.T
 	}
 
 	// Create first order
-	err := repo.CreateOrder(order1)
+	err := repo.CreateOrder(context.Background(), order1)
 	if err != nil {
 		t.Fatalf("Failed to create first order: %v", err)
 	}
@@ -122,9 +125,79 @@ func TestOrderRepository_CreateOrder_DuplicateReference_Integration(t *testing.T
 		ProductName: "Different Product",
 	}
 
-	err = repo.CreateOrder(order2)
-	if err == nil {
-		t.Error("Expected error when creating order with duplicate reference, got nil")
+	// The colliding order is stored under a freshly generated reference
+	if err := repo.CreateOrder(context.Background(), order2); err != nil {
+		t.Fatalf("Expected order with duplicate reference to be retried, got %v", err)
+	}
+	if order2.Reference == "ORDER-DUP-001" {
+		t.Error("Expected a new reference for the colliding order")
+	}
+
+	retrieved, err := repo.GetOrderByReference(context.Background(), "ORDER-DUP-001")
+	if err != nil {
+		t.Fatalf("Failed to get first order: %v", err)
+	}
+	if retrieved.ID != order1.ID {
+		t.Errorf("Expected first order under original reference, got order %s", retrieved.ID)
+	}
+
+	retrieved, err = repo.GetOrderByReference(context.Background(), order2.Reference)
+	if err != nil {
+		t.Fatalf("Failed to get retried order: %v", err)
+	}
+	if retrieved.ID != order2.ID {
+		t.Errorf("Expected retried order under new reference, got order %s", retrieved.ID)
+	}
+}
+
+func TestOrderRepository_CreateOrder_ReferenceRetriesExhausted_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+
+	models.SetReferenceGenerator(fixedReference("ORDER-TAKEN-001"))
+	defer models.SetReferenceGenerator(nil)
+
+	first, err := models.NewOrder("Test Product", 1000, "USD")
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	if err := repo.CreateOrder(context.Background(), first); err != nil {
+		t.Fatalf("Failed to store first order: %v", err)
+	}
+
+	second, err := models.NewOrder("Test Product", 1000, "USD")
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	err = repo.CreateOrder(context.Background(), second)
+	if !errors.Is(err, models.ErrDuplicateReference) {
+		t.Errorf("Expected ErrDuplicateReference, got %v", err)
+	}
+}
+
+func TestSequenceReferenceGenerator_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	generator := NewSequenceReferenceGeneratorWithDB(testDB.DB, "SHOP")
+	pattern := regexp.MustCompile(`^SHOP-\d{8}-(\d{6,})$`)
+
+	previous := ""
+	for i := 0; i < 3; i++ {
+		reference, err := generator.NextReference()
+		if err != nil {
+			t.Fatalf("NextReference() unexpected error = %v", err)
+		}
+		match := pattern.FindStringSubmatch(reference)
+		if match == nil {
+			t.Fatalf("reference %q does not match %s", reference, pattern)
+		}
+		if match[1] <= previous {
+			t.Errorf("Expected increasing sequence numbers, got %s after %s", match[1], previous)
+		}
+		previous = match[1]
 	}
 }
 
@@ -144,7 +217,7 @@ func TestOrderRepository_GetOrderByReference_Integration(t *testing.T) {
 		ProductName: "Test Product",
 	}
 
-	err := repo.CreateOrder(order)
+	err := repo.CreateOrder(context.Background(), order)
 	if err != nil {
 		t.Fatalf("Failed to create order: %v", err)
 	}
@@ -168,7 +241,7 @@ func TestOrderRepository_GetOrderByReference_Integration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			retrieved, err := repo.GetOrderByReference(tt.reference)
+			retrieved, err := repo.GetOrderByReference(context.Background(), tt.reference)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetOrderByReference() error = %v, wantErr %v", err, tt.wantErr)
@@ -208,7 +281,7 @@ func TestOrderRepository_UpdateOrderStatus_Integration(t *testing.T) {
 		ProductName: "Test Product",
 	}
 
-	err := repo.CreateOrder(order)
+	err := repo.CreateOrder(context.Background(), order)
 	if err != nil {
 		t.Fatalf("Failed to create order: %v", err)
 	}
@@ -238,7 +311,7 @@ func TestOrderRepository_UpdateOrderStatus_Integration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := repo.UpdateOrderStatus(tt.reference, string(tt.status), tt.pspReference)
+			err := repo.UpdateOrderStatus(context.Background(), tt.reference, string(tt.status), tt.pspReference, 1, models.OrderChange{})
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdateOrderStatus() error = %v, wantErr %v", err, tt.wantErr)
@@ -247,7 +320,7 @@ func TestOrderRepository_UpdateOrderStatus_Integration(t *testing.T) {
 
 			if !tt.wantErr {
 				// Verify the update
-				retrieved, err := repo.GetOrderByReference(tt.reference)
+				retrieved, err := repo.GetOrderByReference(context.Background(), tt.reference)
 				if err != nil {
 					t.Fatalf("Failed to retrieve updated order: %v", err)
 				}
@@ -284,13 +357,13 @@ func TestOrderRepository_UpdateOrderStatus_Multiple_Integration(t *testing.T) {
 		ProductName: "Test Product",
 	}
 
-	err := repo.CreateOrder(order)
+	err := repo.CreateOrder(context.Background(), order)
 	if err != nil {
 		t.Fatalf("Failed to create order: %v", err)
 	}
 
 	// First update
-	err = repo.UpdateOrderStatus(order.Reference, string(models.OrderStatusAuthorized), "PSP-001")
+	err = repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusAuthorized), "PSP-001", 1, models.OrderChange{})
 	if err != nil {
 		t.Fatalf("First update failed: %v", err)
 	}
@@ -299,13 +372,13 @@ func TestOrderRepository_UpdateOrderStatus_Multiple_Integration(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	// Second update
-	err = repo.UpdateOrderStatus(order.Reference, string(models.OrderStatusFailed), "PSP-002")
+	err = repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusFailed), "PSP-002", 2, models.OrderChange{})
 	if err != nil {
 		t.Fatalf("Second update failed: %v", err)
 	}
 
 	// Verify final state
-	retrieved, err := repo.GetOrderByReference(order.Reference)
+	retrieved, err := repo.GetOrderByReference(context.Background(), order.Reference)
 	if err != nil {
 		t.Fatalf("Failed to retrieve order: %v", err)
 	}
@@ -316,6 +389,114 @@ func TestOrderRepository_UpdateOrderStatus_Multiple_Integration(t *testing.T) {
 	if retrieved.PSPReference != "PSP-002" {
 		t.Errorf("Expected PSPReference 'PSP-002', got %v", retrieved.PSPReference)
 	}
+	if retrieved.Version != 3 {
+		t.Errorf("Expected version 3, got %d", retrieved.Version)
+	}
+}
+
+func TestOrderRepository_UpdateOrderStatus_VersionConflict_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+
+	order := &models.Order{
+		ID:          uuid.New().String(),
+		Reference:   "ORDER-VERSION-001",
+		Amount:      1500,
+		Currency:    "USD",
+		Status:      models.OrderStatusPending,
+		ProductName: "Test Product",
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	// Two writers read the order at version 1, e.g. the redirect and a webhook
+	stale, err := repo.GetOrderByReference(context.Background(), order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to retrieve order: %v", err)
+	}
+	if stale.Version != 1 {
+		t.Fatalf("Expected a new order at version 1, got %d", stale.Version)
+	}
+
+	if err := repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusAuthorized), "PSP-001", stale.Version, models.OrderChange{}); err != nil {
+		t.Fatalf("First update failed: %v", err)
+	}
+
+	err = repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusFailed), "PSP-002", stale.Version, models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderVersionConflict) {
+		t.Fatalf("Expected ErrOrderVersionConflict, got %v", err)
+	}
+
+	retrieved, err := repo.GetOrderByReference(context.Background(), order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to retrieve order: %v", err)
+	}
+	if retrieved.Status != models.OrderStatusAuthorized || retrieved.PSPReference != "PSP-001" || retrieved.Version != 2 {
+		t.Errorf("Expected the first update to stand, got %s %s version %d", retrieved.Status, retrieved.PSPReference, retrieved.Version)
+	}
+
+	history, err := repo.GetOrderHistory(context.Background(), order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to get order history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("Expected only the first update in the history, got %d events", len(history))
+	}
+}
+
+func TestOrderRepository_UpdateOrderRefund_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+	ctx := context.Background()
+
+	order := &models.Order{
+		ID:          uuid.New().String(),
+		Reference:   "ORDER-REFUND-001",
+		Amount:      1500,
+		Currency:    "USD",
+		Status:      models.OrderStatusPending,
+		ProductName: "Test Product",
+	}
+	if err := repo.CreateOrder(ctx, order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	if err := repo.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusAuthorized), "PSP-001", 1, models.OrderChange{}); err != nil {
+		t.Fatalf("Failed to authorize order: %v", err)
+	}
+
+	if err := repo.UpdateOrderRefund(ctx, order.Reference, string(models.OrderStatusPartiallyRefunded), "REFUND-PSP-001", 500, 2, models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderRefund() unexpected error = %v", err)
+	}
+
+	retrieved, err := repo.GetOrderByReference(ctx, order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to retrieve order: %v", err)
+	}
+	if retrieved.Status != models.OrderStatusPartiallyRefunded || retrieved.RefundedAmount != 500 || retrieved.Version != 3 {
+		t.Errorf("Expected partially refunded order with 500 refunded at version 3, got %s %d version %d",
+			retrieved.Status, retrieved.RefundedAmount, retrieved.Version)
+	}
+	if retrieved.PSPReference != "PSP-001" {
+		t.Errorf("Expected the payment PSP reference to be kept, got %s", retrieved.PSPReference)
+	}
+
+	history, err := repo.GetOrderHistory(ctx, order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to get order history: %v", err)
+	}
+	if last := history[len(history)-1]; last.PSPReference != "REFUND-PSP-001" || last.PreviousStatus != models.OrderStatusAuthorized {
+		t.Errorf("Expected the refund from authorized in the history, got %s from %s", last.PSPReference, last.PreviousStatus)
+	}
+
+	err = repo.UpdateOrderRefund(ctx, order.Reference, string(models.OrderStatusRefunded), "REFUND-PSP-002", 1500, 2, models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderVersionConflict) {
+		t.Errorf("Expected ErrOrderVersionConflict, got %v", err)
+	}
 }
 
 func TestOrderRepository_ConcurrentCreates_Integration(t *testing.T) {
@@ -338,7 +519,7 @@ func TestOrderRepository_ConcurrentCreates_Integration(t *testing.T) {
 				Status:      models.OrderStatusPending,
 				ProductName: "Test Product",
 			}
-			errChan <- repo.CreateOrder(order)
+			errChan <- repo.CreateOrder(context.Background(), order)
 		}(i)
 	}
 
@@ -371,19 +552,19 @@ func TestOrderRepository_TransactionIsolation_Integration(t *testing.T) {
 		ProductName: "Test Product",
 	}
 
-	err := repo1.CreateOrder(order)
+	err := repo1.CreateOrder(context.Background(), order)
 	if err != nil {
 		t.Fatalf("Failed to create order in first database: %v", err)
 	}
 
 	// Verify it exists in first database
-	_, err = repo1.GetOrderByReference(order.Reference)
+	_, err = repo1.GetOrderByReference(context.Background(), order.Reference)
 	if err != nil {
 		t.Errorf("Order should exist in first database: %v", err)
 	}
 
 	// Verify it doesn't exist in second database (different schema)
-	_, err = repo2.GetOrderByReference(order.Reference)
+	_, err = repo2.GetOrderByReference(context.Background(), order.Reference)
 	if err == nil {
 		t.Error("Order should not exist in second database (different schema)")
 	}
@@ -405,13 +586,13 @@ func TestOrderRepository_PSPReferenceNullHandling_Integration(t *testing.T) {
 		ProductName: "Test Product",
 	}
 
-	err := repo.CreateOrder(order)
+	err := repo.CreateOrder(context.Background(), order)
 	if err != nil {
 		t.Fatalf("Failed to create order: %v", err)
 	}
 
 	// Retrieve and verify PSP reference is empty
-	retrieved, err := repo.GetOrderByReference(order.Reference)
+	retrieved, err := repo.GetOrderByReference(context.Background(), order.Reference)
 	if err != nil {
 		t.Fatalf("Failed to retrieve order: %v", err)
 	}
@@ -421,13 +602,13 @@ func TestOrderRepository_PSPReferenceNullHandling_Integration(t *testing.T) {
 	}
 
 	// Update with PSP reference
-	err = repo.UpdateOrderStatus(order.Reference, string(models.OrderStatusAuthorized), "PSP-123")
+	err = repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusAuthorized), "PSP-123", 1, models.OrderChange{})
 	if err != nil {
 		t.Fatalf("Failed to update order: %v", err)
 	}
 
 	// Retrieve and verify PSP reference is set
-	retrieved, err = repo.GetOrderByReference(order.Reference)
+	retrieved, err = repo.GetOrderByReference(context.Background(), order.Reference)
 	if err != nil {
 		t.Fatalf("Failed to retrieve updated order: %v", err)
 	}
@@ -436,3 +617,190 @@ func TestOrderRepository_PSPReferenceNullHandling_Integration(t *testing.T) {
 		t.Errorf("Expected PSPReference 'PSP-123', got %v", retrieved.PSPReference)
 	}
 }
+
+func TestOrderRepository_OrderItems_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+
+	order, err := models.NewOrderFromItems([]models.OrderItem{
+		{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 100, Quantity: 3},
+		{SKU: "gadget-001", Name: "Deluxe Gadget", UnitPrice: 2500, Quantity: 1},
+	}, "USD")
+	if err != nil {
+		t.Fatalf("Failed to build order: %v", err)
+	}
+	order.Reference = "ORDER-ITEMS-001"
+
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	retrieved, err := repo.GetOrderByReference(context.Background(), order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to retrieve order: %v", err)
+	}
+
+	if retrieved.Amount != 2800 {
+		t.Errorf("Expected amount 2800, got %d", retrieved.Amount)
+	}
+	if len(retrieved.Items) != 2 {
+		t.Fatalf("Expected 2 order items, got %d", len(retrieved.Items))
+	}
+
+	quantities := map[string]int{}
+	for _, item := range retrieved.Items {
+		quantities[item.SKU] = item.Quantity
+	}
+	if quantities["widget-001"] != 3 || quantities["gadget-001"] != 1 {
+		t.Errorf("Unexpected order item quantities %v", quantities)
+	}
+}
+
+func TestOrderRepository_OrderHistory_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+
+	order := &models.Order{
+		ID:          uuid.New().String(),
+		Reference:   "ORDER-HISTORY-001",
+		Amount:      1500,
+		Currency:    "USD",
+		Status:      models.OrderStatusPending,
+		ProductName: "Test Product",
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	history, err := repo.GetOrderHistory(context.Background(), order.Reference)
+	if err != nil || len(history) != 0 {
+		t.Fatalf("Expected an empty history, got %v, %v", history, err)
+	}
+
+	redirect := models.OrderChange{Source: models.OrderEventSourceRedirect, Actor: "shopper", Payload: []byte(`{"resultCode": "Pending"}`)}
+	if err := repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusProcessing), "PSP-001", 1, redirect); err != nil {
+		t.Fatalf("Failed to update order: %v", err)
+	}
+	webhook := models.OrderChange{Source: models.OrderEventSourceWebhook, Actor: "adyen"}
+	if err := repo.UpdateOrderStatus(context.Background(), order.Reference, string(models.OrderStatusAuthorized), "PSP-001", 2, webhook); err != nil {
+		t.Fatalf("Failed to update order: %v", err)
+	}
+
+	history, err = repo.GetOrderHistory(context.Background(), order.Reference)
+	if err != nil {
+		t.Fatalf("Failed to get order history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(history))
+	}
+
+	first := history[0]
+	if first.OrderID != order.ID || first.PreviousStatus != models.OrderStatusPending || first.Status != models.OrderStatusProcessing {
+		t.Errorf("Expected pending -> processing, got %+v", first)
+	}
+	if first.Source != models.OrderEventSourceRedirect || first.Actor != "shopper" || first.PSPReference != "PSP-001" {
+		t.Errorf("Expected the redirect change, got %+v", first)
+	}
+	// JSONB normalizes the payload
+	if string(first.Payload) != `{"resultCode": "Pending"}` {
+		t.Errorf("Expected the redirect payload, got %s", first.Payload)
+	}
+
+	second := history[1]
+	if second.PreviousStatus != models.OrderStatusProcessing || second.Status != models.OrderStatusAuthorized || second.Source != models.OrderEventSourceWebhook {
+		t.Errorf("Expected processing -> authorized from a webhook, got %+v", second)
+	}
+	if second.Payload != nil {
+		t.Errorf("Expected no payload, got %s", second.Payload)
+	}
+
+	if _, err := repo.GetOrderHistory(context.Background(), "ORDER-NONEXISTENT"); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestOrderRepository_UpdateOrderStatus_NotFoundRecordsNothing_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+
+	err := repo.UpdateOrderStatus(context.Background(), "ORDER-NONEXISTENT", string(models.OrderStatusAuthorized), "PSP-001", 1, models.OrderChange{Source: models.OrderEventSourceWebhook})
+	if !errors.Is(err, models.ErrOrderNotFound) {
+		t.Fatalf("Expected ErrOrderNotFound, got %v", err)
+	}
+
+	var count int
+	if err := testDB.DB.QueryRow(`SELECT COUNT(*) FROM order_events`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count order events: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no order events, got %d", count)
+	}
+}
+
+func TestOrderRepository_ListExpiredOrders_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewOrderRepositoryWithDB(testDB.DB)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	for _, reference := range []string{"ORDER-EXP-001", "ORDER-EXP-002", "ORDER-EXP-003"} {
+		order := &models.Order{
+			ID:          uuid.New().String(),
+			Reference:   reference,
+			Amount:      1000,
+			Currency:    "USD",
+			Status:      models.OrderStatusPending,
+			ProductName: "Test Product",
+		}
+		if err := repo.CreateOrder(ctx, order); err != nil {
+			t.Fatalf("CreateOrder() unexpected error = %v", err)
+		}
+	}
+	if err := repo.SetPaymentSession(ctx, "ORDER-EXP-001", models.PaymentSession{
+		ID: "CS-SESSION", Data: "session-data", ExpiresAt: now.Add(-time.Minute),
+		Shopper: models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"},
+	}); err != nil {
+		t.Fatalf("SetPaymentSession() unexpected error = %v", err)
+	}
+	if err := repo.SetPaymentSession(ctx, "ORDER-EXP-002", models.PaymentSession{ID: "CS-SESSION", Data: "session-data", ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("SetPaymentSession() unexpected error = %v", err)
+	}
+
+	expired, err := repo.ListExpiredOrders(ctx, now, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("ListExpiredOrders() unexpected error = %v", err)
+	}
+	if len(expired) != 1 || expired[0].Reference != "ORDER-EXP-001" {
+		t.Fatalf("Expected only ORDER-EXP-001, got %+v", expired)
+	}
+	if expired[0].SessionID != "CS-SESSION" || expired[0].SessionData != "session-data" {
+		t.Errorf("Expected the payment session to be returned, got %q %q", expired[0].SessionID, expired[0].SessionData)
+	}
+	if expected := (models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"}); expired[0].SessionShopper != expected {
+		t.Errorf("Expected the session shopper %+v, got %+v", expected, expired[0].SessionShopper)
+	}
+	if !expired[0].SessionExpiresAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Expected session expiry %v, got %v", now.Add(-time.Minute), expired[0].SessionExpiresAt)
+	}
+
+	// ORDER-EXP-003 has no recorded session and falls back to the session lifetime
+	expired, err = repo.ListExpiredOrders(ctx, now.Add(2*time.Hour), time.Hour, 10)
+	if err != nil {
+		t.Fatalf("ListExpiredOrders() unexpected error = %v", err)
+	}
+	if len(expired) != 3 {
+		t.Errorf("Expected 3 expired orders after the session lifetime, got %d", len(expired))
+	}
+
+	if err := repo.SetPaymentSession(ctx, "ORDER-NONEXISTENT", models.PaymentSession{ID: "CS-SESSION", Data: "session-data", ExpiresAt: now}); !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
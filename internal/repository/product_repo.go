@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/adyen/ecommerce/internal/database"
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// ProductRepository handles database operations for products
+type ProductRepository struct {
+	db *sql.DB
+}
+
+// NewProductRepository creates a new product repository
+func NewProductRepository() *ProductRepository {
+	return &ProductRepository{
+		db: database.DB,
+	}
+}
+
+// NewProductRepositoryWithDB creates a new product repository with a specific database connection
+func NewProductRepositoryWithDB(db *sql.DB) *ProductRepository {
+	return &ProductRepository{
+		db: db,
+	}
+}
+
+// ListProducts retrieves all active products ordered by name
+func (r *ProductRepository) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	query := `
+		SELECT id, sku, name, description, image_url, price, currency, created_at, updated_at
+		FROM products
+		WHERE active
+		ORDER BY name
+	`
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+
+	return products, nil
+}
+
+// GetProductBySKU retrieves an active product by its SKU
+func (r *ProductRepository) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	query := `
+		SELECT id, sku, name, description, image_url, price, currency, created_at, updated_at
+		FROM products
+		WHERE sku = $1 AND active
+	`
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, sku))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	return product, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct reads a product from a result row
+func scanProduct(row rowScanner) (*models.Product, error) {
+	product := &models.Product{}
+	err := row.Scan(
+		&product.ID,
+		&product.SKU,
+		&product.Name,
+		&product.Description,
+		&product.ImageURL,
+		&product.Price,
+		&product.Currency,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
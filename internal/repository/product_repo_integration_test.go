@@ -0,0 +1,95 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository/testutil"
+)
+
+func TestProductRepository_ListProducts_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	_, err := testDB.DB.Exec(`
+		INSERT INTO products (id, sku, name, description, image_url, price, currency, active)
+		VALUES
+			('0b8f8a52-6d7e-4a4e-9d0c-7f3c1e2a9b01', 'gadget-001', 'Deluxe Gadget', 'A gadget', '', 2500, 'EUR', TRUE),
+			('0b8f8a52-6d7e-4a4e-9d0c-7f3c1e2a9b02', 'retired-001', 'Retired Thing', 'Gone', '', 500, 'USD', FALSE)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test products: %v", err)
+	}
+
+	repo := NewProductRepositoryWithDB(testDB.DB)
+	products, err := repo.ListProducts(context.Background())
+	if err != nil {
+		t.Fatalf("ListProducts() error = %v", err)
+	}
+
+	var skus []string
+	for _, product := range products {
+		skus = append(skus, product.SKU)
+	}
+	if len(skus) != 2 || skus[0] != "gadget-001" || skus[1] != "widget-001" {
+		t.Errorf("Expected active products [gadget-001 widget-001] ordered by name, got %v", skus)
+	}
+}
+
+func TestProductRepository_GetProductBySKU_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewProductRepositoryWithDB(testDB.DB)
+
+	tests := []struct {
+		name          string
+		sku           string
+		wantErr       error
+		expectedName  string
+		expectedPrice int64
+	}{
+		{
+			name:          "seeded product",
+			sku:           "widget-001",
+			expectedName:  "Premium Widget",
+			expectedPrice: 100,
+		},
+		{
+			name:    "unknown SKU",
+			sku:     "missing-001",
+			wantErr: models.ErrProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, err := repo.GetProductBySKU(context.Background(), tt.sku)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetProductBySKU() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetProductBySKU() unexpected error = %v", err)
+			}
+
+			if product.Name != tt.expectedName {
+				t.Errorf("Name = %s, want %s", product.Name, tt.expectedName)
+			}
+			if product.Price != tt.expectedPrice {
+				t.Errorf("Price = %d, want %d", product.Price, tt.expectedPrice)
+			}
+			if product.Currency != "USD" {
+				t.Errorf("Currency = %s, want USD", product.Currency)
+			}
+		})
+	}
+}
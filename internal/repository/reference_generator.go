@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/database"
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// SequenceReferenceGenerator generates references such as ORDER-20240131-000042
+// from the current date and the order_reference_seq PostgreSQL sequence
+type SequenceReferenceGenerator struct {
+	db     *sql.DB
+	prefix string
+}
+
+// NewSequenceReferenceGenerator creates a sequence-backed reference generator
+func NewSequenceReferenceGenerator(prefix string) *SequenceReferenceGenerator {
+	return &SequenceReferenceGenerator{
+		db:     database.DB,
+		prefix: prefix,
+	}
+}
+
+// NewSequenceReferenceGeneratorWithDB creates a sequence-backed reference generator with a specific database connection
+func NewSequenceReferenceGeneratorWithDB(db *sql.DB, prefix string) *SequenceReferenceGenerator {
+	return &SequenceReferenceGenerator{
+		db:     db,
+		prefix: prefix,
+	}
+}
+
+// NextReference returns a reference using the next value of the sequence
+func (g *SequenceReferenceGenerator) NextReference() (string, error) {
+	ctx, cancel := withQueryTimeout(context.Background())
+	defer cancel()
+
+	var next int64
+	if err := g.db.QueryRowContext(ctx, `SELECT nextval('order_reference_seq')`).Scan(&next); err != nil {
+		return "", fmt.Errorf("failed to get next order reference: %w", err)
+	}
+
+	return models.FormatReference(g.prefix, time.Now(), fmt.Sprintf("%06d", next)), nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/database"
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+)
+
+// StatusRetryRepository stores order status updates waiting to be retried
+type StatusRetryRepository struct {
+	db *sql.DB
+}
+
+// NewStatusRetryRepository creates a new status retry repository
+func NewStatusRetryRepository() *StatusRetryRepository {
+	return &StatusRetryRepository{
+		db: database.DB,
+	}
+}
+
+// NewStatusRetryRepositoryWithDB creates a new status retry repository with a specific database connection
+func NewStatusRetryRepositoryWithDB(db *sql.DB) *StatusRetryRepository {
+	return &StatusRetryRepository{
+		db: db,
+	}
+}
+
+// EnqueueStatusRetry stores a status update to retry, setting its ID and timestamps
+func (r *StatusRetryRepository) EnqueueStatusRetry(ctx context.Context, retry *models.StatusUpdateRetry) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if retry.ID == "" {
+		retry.ID = uuid.NewString()
+	}
+	now := time.Now()
+	if retry.NextAttemptAt.IsZero() {
+		retry.NextAttemptAt = now
+	}
+
+	// Passed as text, lib/pq would send a []byte as bytea
+	var payload sql.NullString
+	if len(retry.Change.Payload) > 0 {
+		payload = sql.NullString{String: string(retry.Change.Payload), Valid: true}
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO order_status_retries (id, order_id, status, psp_reference, source, actor, payload,
+		                                  attempts, last_error, next_attempt_at, created_at, updated_at)
+		SELECT $1, id, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11
+		FROM orders
+		WHERE reference = $2
+	`, retry.ID, retry.OrderReference, retry.Status, retry.PSPReference, retry.Change.Source, retry.Change.Actor, payload,
+		retry.Attempts, retry.LastError, retry.NextAttemptAt, now)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue status retry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue status retry: %w", err)
+	}
+	if rows == 0 {
+		return models.ErrOrderNotFound
+	}
+
+	retry.CreatedAt = now
+	retry.UpdatedAt = now
+	return nil
+}
+
+// DueStatusRetries returns up to limit retries due at now, the longest waiting first
+func (r *StatusRetryRepository) DueStatusRetries(ctx context.Context, now time.Time, limit int) ([]models.StatusUpdateRetry, error) {
+	return r.listStatusRetries(ctx, `WHERE s.next_attempt_at <= $1 ORDER BY s.next_attempt_at, s.id LIMIT $2`, now, limit)
+}
+
+// ListStatusRetries returns all queued retries, oldest first
+func (r *StatusRetryRepository) ListStatusRetries(ctx context.Context) ([]models.StatusUpdateRetry, error) {
+	return r.listStatusRetries(ctx, `ORDER BY s.created_at, s.id`)
+}
+
+// listStatusRetries returns the retries selected by the WHERE and ORDER BY clauses in filter
+func (r *StatusRetryRepository) listStatusRetries(ctx context.Context, filter string, args ...interface{}) ([]models.StatusUpdateRetry, error) {
+	query := `
+		SELECT s.id, o.reference, s.status, COALESCE(s.psp_reference, ''), s.source, s.actor, s.payload,
+		       s.attempts, s.last_error, s.next_attempt_at, s.created_at, s.updated_at
+		FROM order_status_retries s
+		JOIN orders o ON o.id = s.order_id
+	` + filter
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status retries: %w", err)
+	}
+	defer rows.Close()
+
+	retries := []models.StatusUpdateRetry{}
+	for rows.Next() {
+		var retry models.StatusUpdateRetry
+		var payload []byte
+		if err := rows.Scan(
+			&retry.ID,
+			&retry.OrderReference,
+			&retry.Status,
+			&retry.PSPReference,
+			&retry.Change.Source,
+			&retry.Change.Actor,
+			&payload,
+			&retry.Attempts,
+			&retry.LastError,
+			&retry.NextAttemptAt,
+			&retry.CreatedAt,
+			&retry.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status retry: %w", err)
+		}
+		retry.Change.Payload = payload
+		retries = append(retries, retry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get status retries: %w", err)
+	}
+
+	return retries, nil
+}
+
+// RescheduleStatusRetry records a failed attempt and when to try again
+func (r *StatusRetryRepository) RescheduleStatusRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE order_status_retries
+		SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2, updated_at = $3
+		WHERE id = $4
+	`, lastError, nextAttemptAt, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule status retry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to reschedule status retry: %w", err)
+	}
+	if rows == 0 {
+		return models.ErrStatusRetryNotFound
+	}
+
+	return nil
+}
+
+// DeleteStatusRetry removes a retry that was applied or is no longer needed
+func (r *StatusRetryRepository) DeleteStatusRetry(ctx context.Context, id string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM order_status_retries WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete status retry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository/testutil"
+	"github.com/google/uuid"
+)
+
+func TestStatusRetryRepository_Queue_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	orderRepo := NewOrderRepositoryWithDB(testDB.DB)
+	repo := NewStatusRetryRepositoryWithDB(testDB.DB)
+	ctx := context.Background()
+
+	order := &models.Order{
+		ID:          uuid.New().String(),
+		Reference:   "ORDER-RETRY-001",
+		Amount:      1500,
+		Currency:    "USD",
+		Status:      models.OrderStatusPending,
+		ProductName: "Test Product",
+	}
+	if err := orderRepo.CreateOrder(ctx, order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	now := time.Now()
+	retry := &models.StatusUpdateRetry{
+		OrderReference: order.Reference,
+		Status:         models.OrderStatusAuthorized,
+		PSPReference:   "PSP-123",
+		Change:         models.OrderChange{Source: models.OrderEventSourceRedirect, Actor: "shopper", Payload: []byte(`{"status": "completed"}`)},
+		LastError:      "database unavailable",
+		NextAttemptAt:  now.Add(-time.Second),
+	}
+	if err := repo.EnqueueStatusRetry(ctx, retry); err != nil {
+		t.Fatalf("Failed to enqueue status retry: %v", err)
+	}
+
+	retries, err := repo.DueStatusRetries(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("Failed to get due status retries: %v", err)
+	}
+	if len(retries) != 1 {
+		t.Fatalf("Expected 1 due retry, got %d", len(retries))
+	}
+	due := retries[0]
+	if due.ID != retry.ID || due.OrderReference != order.Reference || due.Status != models.OrderStatusAuthorized || due.PSPReference != "PSP-123" {
+		t.Errorf("Expected the queued authorization, got %+v", due)
+	}
+	if due.Change.Source != models.OrderEventSourceRedirect || due.Change.Actor != "shopper" || string(due.Change.Payload) != `{"status": "completed"}` {
+		t.Errorf("Expected the redirect change, got %+v", due.Change)
+	}
+
+	if err := repo.RescheduleStatusRetry(ctx, retry.ID, "timeout", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to reschedule status retry: %v", err)
+	}
+	if retries, _ := repo.DueStatusRetries(ctx, now, 10); len(retries) != 0 {
+		t.Errorf("Expected no due retries after rescheduling, got %d", len(retries))
+	}
+
+	all, err := repo.ListStatusRetries(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list status retries: %v", err)
+	}
+	if len(all) != 1 || all[0].Attempts != 1 || all[0].LastError != "timeout" {
+		t.Fatalf("Expected 1 retry with 1 failed attempt, got %+v", all)
+	}
+
+	if err := repo.DeleteStatusRetry(ctx, retry.ID); err != nil {
+		t.Fatalf("Failed to delete status retry: %v", err)
+	}
+	if err := repo.RescheduleStatusRetry(ctx, retry.ID, "timeout", now); !errors.Is(err, models.ErrStatusRetryNotFound) {
+		t.Errorf("Expected ErrStatusRetryNotFound, got %v", err)
+	}
+}
+
+func TestStatusRetryRepository_UnknownOrder_Integration(t *testing.T) {
+	testDB := testutil.SetupTestDatabase(t)
+	defer testDB.Teardown(t)
+
+	repo := NewStatusRetryRepositoryWithDB(testDB.DB)
+
+	err := repo.EnqueueStatusRetry(context.Background(), &models.StatusUpdateRetry{
+		OrderReference: "ORDER-NONEXISTENT",
+		Status:         models.OrderStatusAuthorized,
+		Change:         models.OrderChange{Source: models.OrderEventSourceRedirect},
+	})
+	if !errors.Is(err, models.ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
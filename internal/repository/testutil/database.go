@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/database"
 	_ "github.com/lib/pq"
 )
 
@@ -104,32 +105,9 @@ func SetupTestDatabase(t *testing.T) *TestDatabase {
 	return testDatabase
 }
 
-// RunMigrations creates the necessary database tables in the test schema
+// RunMigrations applies all schema migrations in the test schema
 func (td *TestDatabase) RunMigrations() error {
-	// Create orders table
-	createOrdersTable := `
-	CREATE TABLE IF NOT EXISTS orders (
-		id UUID PRIMARY KEY,
-		reference VARCHAR(255) UNIQUE NOT NULL,
-		amount INTEGER NOT NULL,
-		currency VARCHAR(3) NOT NULL,
-		status VARCHAR(50) NOT NULL,
-		product_name VARCHAR(255) NOT NULL,
-		psp_reference VARCHAR(255),
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_orders_reference ON orders(reference);
-	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
-	`
-
-	_, err := td.DB.Exec(createOrdersTable)
-	if err != nil {
-		return fmt.Errorf("failed to create orders table: %w", err)
-	}
-
-	return nil
+	return database.NewMigrator(td.DB).Up()
 }
 
 // Teardown cleans up the test database schema
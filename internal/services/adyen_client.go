@@ -2,19 +2,30 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
+	"github.com/google/uuid"
 )
 
+// maxRetryBackoff caps the delay between two attempts of the same request
+const maxRetryBackoff = 5 * time.Second
+
 // AdyenClient handles communication with Adyen API
 type AdyenClient interface {
-	CreateSession(req *SessionRequest) (*SessionResponse, error)
-	GetSessionStatus(sessionID, sessionResult string) (*SessionStatusResponse, error)
+	CreateSession(ctx context.Context, req *SessionRequest) (*SessionResponse, error)
+	GetSessionStatus(ctx context.Context, sessionID, sessionResult string) (*SessionStatusResponse, error)
+	RefundPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error)
+	CapturePayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error)
+	CancelPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error)
 }
 
 // HTTPAdyenClient implements AdyenClient using HTTP
@@ -23,11 +34,12 @@ type HTTPAdyenClient struct {
 	httpClient *http.Client
 }
 
-// NewAdyenClient creates a new Adyen API client
+// NewAdyenClient creates a new Adyen API client.
+// Every call is bounded by the configured request timeout.
 func NewAdyenClient(cfg *config.AdyenConfig) AdyenClient {
 	return &HTTPAdyenClient{
 		config:     cfg,
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Timeout: cfg.RequestTimeout()},
 	}
 }
 
@@ -41,8 +53,12 @@ type SessionRequest struct {
 	ShopperLocale         string                 `json:"shopperLocale"`
 	Channel               string                 `json:"channel"`
 	AllowedPaymentMethods []string               `json:"allowedPaymentMethods,omitempty"`
+	BlockedPaymentMethods []string               `json:"blockedPaymentMethods,omitempty"`
 	LineItems             []LineItem             `json:"lineItems,omitempty"`
 	Metadata              map[string]interface{} `json:"metadata,omitempty"`
+	AdditionalData        map[string]string      `json:"additionalData,omitempty"`
+	// IdempotencyKey is sent as the Idempotency-Key header; one is generated when empty
+	IdempotencyKey string `json:"-"`
 }
 
 // Amount represents a monetary amount
@@ -80,8 +96,28 @@ type SessionStatusResponse struct {
 	} `json:"payments"`
 }
 
-// CreateSession creates a new payment session with Adyen
-func (c *HTTPAdyenClient) CreateSession(req *SessionRequest) (*SessionResponse, error) {
+// ModificationRequest represents a request to modify an existing payment
+type ModificationRequest struct {
+	MerchantAccount string  `json:"merchantAccount"`
+	Amount          *Amount `json:"amount,omitempty"`
+	Reference       string  `json:"reference,omitempty"`
+	// IdempotencyKey is sent as the Idempotency-Key header; one is generated when empty
+	IdempotencyKey string `json:"-"`
+}
+
+// ModificationResponse represents the response to a payment modification request
+type ModificationResponse struct {
+	MerchantAccount     string  `json:"merchantAccount"`
+	PaymentPSPReference string  `json:"paymentPspReference"`
+	PSPReference        string  `json:"pspReference"`
+	Reference           string  `json:"reference"`
+	Status              string  `json:"status"`
+	Amount              *Amount `json:"amount,omitempty"`
+}
+
+// CreateSession creates a new payment session with Adyen.
+// Transient failures are retried under the same idempotency key.
+func (c *HTTPAdyenClient) CreateSession(ctx context.Context, req *SessionRequest) (*SessionResponse, error) {
 	// Set merchant account from config if not provided
 	if req.MerchantAccount == "" {
 		req.MerchantAccount = c.config.MerchantAccount
@@ -94,38 +130,14 @@ func (c *HTTPAdyenClient) CreateSession(req *SessionRequest) (*SessionResponse,
 	}
 
 	// Determine API endpoint based on environment
-	apiURL := c.getAPIEndpoint("/v71/sessions")
-
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-API-Key", c.config.APIKey)
+	apiURL := c.getAPIEndpoint("/sessions")
 
-	// Send request
-	resp, err := c.httpClient.Do(httpReq)
+	body, err := c.postWithRetry(ctx, apiURL, req.IdempotencyKey, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		log.Printf("Adyen API error (status %d): %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	log.Printf("Adyen session created successfully (status %d)", resp.StatusCode)
+	log.Printf("Adyen session created successfully")
 
 	// Parse response
 	var sessionResp SessionResponse
@@ -139,14 +151,17 @@ func (c *HTTPAdyenClient) CreateSession(req *SessionRequest) (*SessionResponse,
 }
 
 // GetSessionStatus retrieves the status of a payment session
-func (c *HTTPAdyenClient) GetSessionStatus(sessionID, sessionResult string) (*SessionStatusResponse, error) {
+func (c *HTTPAdyenClient) GetSessionStatus(ctx context.Context, sessionID, sessionResult string) (*SessionStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout())
+	defer cancel()
+
 	log.Printf("Fetching session status for sessionId: %s with sessionResult: %s", sessionID, sessionResult)
 
 	// Determine API endpoint based on environment
-	apiURL := c.getAPIEndpoint(fmt.Sprintf("/v71/sessions/%s?sessionResult=%s", sessionID, sessionResult))
+	apiURL := c.getAPIEndpoint(fmt.Sprintf("/sessions/%s?sessionResult=%s", sessionID, sessionResult))
 
 	// Create HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -169,7 +184,7 @@ func (c *HTTPAdyenClient) GetSessionStatus(sessionID, sessionResult string) (*Se
 	log.Printf("Session status API response (status %d): %s", resp.StatusCode, string(body))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, parseAdyenAPIError(resp.StatusCode, body)
 	}
 
 	// Parse session response
@@ -181,10 +196,133 @@ func (c *HTTPAdyenClient) GetSessionStatus(sessionID, sessionResult string) (*Se
 	return &sessionResp, nil
 }
 
-// getAPIEndpoint returns the full API endpoint URL based on environment
-func (c *HTTPAdyenClient) getAPIEndpoint(path string) string {
-	if c.config.Environment == "LIVE" {
-		return "https://checkout-live.adyen.com" + path
+// RefundPayment requests a refund of a captured payment
+func (c *HTTPAdyenClient) RefundPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	return c.sendModification(ctx, pspReference, "refunds", req)
+}
+
+// CapturePayment captures a payment that was authorised with manual capture
+func (c *HTTPAdyenClient) CapturePayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	return c.sendModification(ctx, pspReference, "captures", req)
+}
+
+// CancelPayment cancels an authorised payment that has not been captured yet
+func (c *HTTPAdyenClient) CancelPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	return c.sendModification(ctx, pspReference, "cancels", req)
+}
+
+// sendModification posts a modification request for the given payment
+func (c *HTTPAdyenClient) sendModification(ctx context.Context, pspReference, modification string, req *ModificationRequest) (*ModificationResponse, error) {
+	if pspReference == "" {
+		return nil, fmt.Errorf("PSP reference is required for %s", modification)
+	}
+
+	// Set merchant account from config if not provided
+	if req.MerchantAccount == "" {
+		req.MerchantAccount = c.config.MerchantAccount
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	return "https://checkout-test.adyen.com" + path
+
+	apiURL := c.getAPIEndpoint(fmt.Sprintf("/payments/%s/%s", url.PathEscape(pspReference), modification))
+
+	body, err := c.postWithRetry(ctx, apiURL, req.IdempotencyKey, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var modResp ModificationResponse
+	if err := json.Unmarshal(body, &modResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	log.Printf("Adyen %s request %s for payment %s: %s", modification, modResp.PSPReference, pspReference, modResp.Status)
+
+	return &modResp, nil
+}
+
+// postWithRetry posts a JSON body and returns the response body of a successful call.
+// Every attempt carries the same Idempotency-Key, so Adyen applies the request at most once;
+// network errors, 429 and 5xx responses are retried with exponential backoff and jitter.
+func (c *HTTPAdyenClient) postWithRetry(ctx context.Context, apiURL, idempotencyKey string, reqBody []byte) ([]byte, error) {
+	if idempotencyKey == "" {
+		idempotencyKey = uuid.NewString()
+	}
+
+	for retry := 0; ; retry++ {
+		body, retryable, err := c.post(ctx, apiURL, idempotencyKey, reqBody)
+		if err == nil {
+			return body, nil
+		}
+		if !retryable || retry >= c.config.MaxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := retryDelay(c.config.RetryBackoff, retry)
+		log.Printf("Adyen request to %s failed (attempt %d), retrying in %v: %v", apiURL, retry+1, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("gave up retrying: %w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// post makes a single attempt and reports whether a failure is worth retrying
+func (c *HTTPAdyenClient) post(ctx context.Context, apiURL, idempotencyKey string, reqBody []byte) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout())
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", c.config.APIKey)
+	httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		log.Printf("Adyen API error (status %d): %s", resp.StatusCode, string(body))
+		apiErr := parseAdyenAPIError(resp.StatusCode, body)
+		return nil, apiErr.Retryable(), apiErr
+	}
+
+	return body, false, nil
+}
+
+// retryDelay returns the backoff before the given retry: the base delay doubled per
+// earlier retry and capped at maxRetryBackoff, with the upper half randomised
+func retryDelay(base time.Duration, retry int) time.Duration {
+	if base <= 0 {
+		base = config.DefaultAdyenRetryBackoff
+	}
+	backoff := maxRetryBackoff
+	if retry < 16 && base<<retry < maxRetryBackoff {
+		backoff = base << retry
+	}
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// getAPIEndpoint returns the full URL of a Checkout API path, e.g. /sessions
+func (c *HTTPAdyenClient) getAPIEndpoint(path string) string {
+	return c.config.CheckoutURL() + path
 }
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/config"
+)
+
+// newHangingAdyenServer returns a server that never answers until the client gives up
+func newHangingAdyenServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	// Cleanups run in reverse, so the handlers are released before Close waits on them
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+func TestHTTPAdyenClient_Deadlines(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+		call    func(ctx context.Context, client AdyenClient) error
+	}{
+		{
+			name:    "create session times out",
+			timeout: 50 * time.Millisecond,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			call: func(ctx context.Context, client AdyenClient) error {
+				_, err := client.CreateSession(ctx, &SessionRequest{Reference: "ORDER-1"})
+				return err
+			},
+		},
+		{
+			name:    "session status times out",
+			timeout: 50 * time.Millisecond,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			call: func(ctx context.Context, client AdyenClient) error {
+				_, err := client.GetSessionStatus(ctx, "CS123", "result")
+				return err
+			},
+		},
+		{
+			name:    "modification follows caller deadline",
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			call: func(ctx context.Context, client AdyenClient) error {
+				_, err := client.RefundPayment(ctx, "PSP-1", &ModificationRequest{Reference: "ORDER-1"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newHangingAdyenServer(t)
+			client := NewAdyenClient(&config.AdyenConfig{
+				APIKey:  "test-api-key",
+				BaseURL: server.URL,
+				Timeout: tt.timeout,
+			})
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err := tt.call(ctx, client)
+			if err == nil {
+				t.Fatal("Expected error from hung Adyen call, got nil")
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Call took %v, expected it to be cut off by the deadline", elapsed)
+			}
+		})
+	}
+}
+
+func TestHTTPAdyenClient_CancelledContext(t *testing.T) {
+	server := newHangingAdyenServer(t)
+	client := NewAdyenClient(&config.AdyenConfig{APIKey: "test-api-key", BaseURL: server.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.CapturePayment(ctx, "PSP-1", &ModificationRequest{Reference: "ORDER-1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// recordedAttempt is one request seen by a scripted Adyen server
+type recordedAttempt struct {
+	idempotencyKey string
+}
+
+// newScriptedAdyenServer answers successive requests with the given status codes,
+// then with 200 and the given body; a status of -1 drops the connection instead
+func newScriptedAdyenServer(t *testing.T, statuses []int, body string) (*httptest.Server, *[]recordedAttempt) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var attempts []recordedAttempt
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts = append(attempts, recordedAttempt{idempotencyKey: r.Header.Get("Idempotency-Key")})
+		n := len(attempts)
+		mu.Unlock()
+
+		if n <= len(statuses) {
+			if statuses[n-1] == -1 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte(`{"status":500,"errorCode":"901","message":"Transient"}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func TestHTTPAdyenClient_Retry(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		maxRetries       int
+		expectedAttempts int
+		wantErr          bool
+	}{
+		{
+			name:             "succeeds first time",
+			maxRetries:       2,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries server errors",
+			statuses:         []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+			maxRetries:       2,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retries rate limiting",
+			statuses:         []int{http.StatusTooManyRequests},
+			maxRetries:       2,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retries dropped connections",
+			statuses:         []int{-1},
+			maxRetries:       2,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "does not retry client errors",
+			statuses:         []int{http.StatusUnprocessableEntity},
+			maxRetries:       2,
+			expectedAttempts: 1,
+			wantErr:          true,
+		},
+		{
+			name:             "gives up after max retries",
+			statuses:         []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			maxRetries:       2,
+			expectedAttempts: 3,
+			wantErr:          true,
+		},
+		{
+			name:             "retries disabled",
+			statuses:         []int{http.StatusInternalServerError},
+			maxRetries:       0,
+			expectedAttempts: 1,
+			wantErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := newScriptedAdyenServer(t, tt.statuses, `{"id":"CS123","sessionData":"data"}`)
+			client := NewAdyenClient(&config.AdyenConfig{
+				APIKey:       "test-api-key",
+				BaseURL:      server.URL,
+				MaxRetries:   tt.maxRetries,
+				RetryBackoff: time.Millisecond,
+			})
+
+			resp, err := client.CreateSession(context.Background(), &SessionRequest{Reference: "ORDER-1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && resp.ID != "CS123" {
+				t.Errorf("Expected session CS123, got %s", resp.ID)
+			}
+
+			if len(*attempts) != tt.expectedAttempts {
+				t.Fatalf("Expected %d attempts, got %d", tt.expectedAttempts, len(*attempts))
+			}
+			key := (*attempts)[0].idempotencyKey
+			if key == "" {
+				t.Fatal("Expected an Idempotency-Key header")
+			}
+			for i, attempt := range *attempts {
+				if attempt.idempotencyKey != key {
+					t.Errorf("Attempt %d used key %q, want %q", i+1, attempt.idempotencyKey, key)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPAdyenClient_IdempotencyKeys(t *testing.T) {
+	server, attempts := newScriptedAdyenServer(t, nil, `{"pspReference":"REF-1","status":"received"}`)
+	client := NewAdyenClient(&config.AdyenConfig{APIKey: "test-api-key", BaseURL: server.URL})
+
+	// Separate calls get separate keys
+	for i := 0; i < 2; i++ {
+		if _, err := client.RefundPayment(context.Background(), "PSP-1", &ModificationRequest{Reference: "ORDER-1"}); err != nil {
+			t.Fatalf("RefundPayment() unexpected error = %v", err)
+		}
+	}
+	// A caller supplied key is sent as is
+	if _, err := client.CapturePayment(context.Background(), "PSP-1", &ModificationRequest{Reference: "ORDER-1", IdempotencyKey: "capture-ORDER-1"}); err != nil {
+		t.Fatalf("CapturePayment() unexpected error = %v", err)
+	}
+
+	if len(*attempts) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(*attempts))
+	}
+	if (*attempts)[0].idempotencyKey == (*attempts)[1].idempotencyKey {
+		t.Errorf("Expected distinct keys for separate refunds, both were %q", (*attempts)[0].idempotencyKey)
+	}
+	if got := (*attempts)[2].idempotencyKey; got != "capture-ORDER-1" {
+		t.Errorf("Expected caller supplied key, got %q", got)
+	}
+}
+
+func TestHTTPAdyenClient_RetryStopsOnCancel(t *testing.T) {
+	server, attempts := newScriptedAdyenServer(t, []int{http.StatusServiceUnavailable}, `{}`)
+	client := NewAdyenClient(&config.AdyenConfig{
+		APIKey:       "test-api-key",
+		BaseURL:      server.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := client.CancelPayment(ctx, "PSP-1", &ModificationRequest{Reference: "ORDER-1"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(*attempts) != 1 {
+		t.Errorf("Expected a single attempt before cancellation, got %d", len(*attempts))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	for retry, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			delay := retryDelay(base, retry)
+			if delay < max/2 || delay > max {
+				t.Errorf("retryDelay(%v, %d) = %v, want between %v and %v", base, retry, delay, max/2, max)
+			}
+		}
+	}
+
+	if delay := retryDelay(base, 30); delay > maxRetryBackoff {
+		t.Errorf("retryDelay() = %v, want at most %v", delay, maxRetryBackoff)
+	}
+}
+
+func TestHTTPAdyenClient_APIErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		body           string
+		expected       AdyenAPIError
+		validation     bool
+		authentication bool
+		rateLimited    bool
+	}{
+		{
+			name:       "validation error",
+			status:     http.StatusUnprocessableEntity,
+			body:       `{"status":422,"errorCode":"14_030","message":"Return URL is missing.","errorType":"validation"}`,
+			expected:   AdyenAPIError{Status: 422, ErrorCode: "14_030", Message: "Return URL is missing.", ErrorType: "validation"},
+			validation: true,
+		},
+		{
+			name:           "authentication error",
+			status:         http.StatusUnauthorized,
+			body:           `{"status":401,"errorCode":"000","message":"HTTP Status Response - Unauthorized","errorType":"security"}`,
+			expected:       AdyenAPIError{Status: 401, ErrorCode: "000", Message: "HTTP Status Response - Unauthorized", ErrorType: "security"},
+			authentication: true,
+		},
+		{
+			name:        "rate limited",
+			status:      http.StatusTooManyRequests,
+			body:        `{"status":429,"errorCode":"000","message":"Too many requests","pspReference":"PSP-429"}`,
+			expected:    AdyenAPIError{Status: 429, ErrorCode: "000", Message: "Too many requests", PSPReference: "PSP-429"},
+			rateLimited: true,
+		},
+		{
+			name:     "body that is not an error envelope",
+			status:   http.StatusBadGateway,
+			body:     "<html>Bad Gateway</html>\n",
+			expected: AdyenAPIError{Status: 502, Message: "<html>Bad Gateway</html>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			client := NewAdyenClient(&config.AdyenConfig{APIKey: "test-api-key", BaseURL: server.URL})
+
+			// Both the retrying POST path and the session status GET return typed errors
+			_, postErr := client.CreateSession(context.Background(), &SessionRequest{Reference: "ORDER-1"})
+			_, getErr := client.GetSessionStatus(context.Background(), "CS123", "result")
+
+			for _, err := range []error{postErr, getErr} {
+				var apiErr *AdyenAPIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("Expected *AdyenAPIError, got %T: %v", err, err)
+				}
+				if *apiErr != tt.expected {
+					t.Errorf("Expected %+v, got %+v", tt.expected, *apiErr)
+				}
+				if apiErr.IsValidation() != tt.validation || apiErr.IsAuthentication() != tt.authentication || apiErr.IsRateLimited() != tt.rateLimited {
+					t.Errorf("Unexpected classification for %v", apiErr)
+				}
+			}
+		})
+	}
+}
+
+func TestAdyenAPIError_Error(t *testing.T) {
+	err := &AdyenAPIError{Status: 422, ErrorCode: "167", Message: "Original pspReference required for this operation", ErrorType: "validation", PSPReference: "PSP-1"}
+	expected := "Adyen API returned status 422 (validation error 167): Original pspReference required for this operation [pspReference PSP-1]"
+	if got := err.Error(); got != expected {
+		t.Errorf("Error() = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error types reported by Adyen in the errorType field
+const (
+	AdyenErrorTypeValidation    = "validation"
+	AdyenErrorTypeSecurity      = "security"
+	AdyenErrorTypeConfiguration = "configuration"
+	AdyenErrorTypeInternal      = "internal"
+)
+
+// AdyenAPIError is an unsuccessful response from the Adyen API, e.g.
+// {"status": 422, "errorCode": "14_030", "message": "Return URL is missing.", "errorType": "validation"}
+type AdyenAPIError struct {
+	Status       int    `json:"status"`
+	ErrorCode    string `json:"errorCode"`
+	Message      string `json:"message"`
+	ErrorType    string `json:"errorType"`
+	PSPReference string `json:"pspReference,omitempty"`
+}
+
+// Error implements the error interface
+func (e *AdyenAPIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Adyen API returned status %d", e.Status)
+	if e.ErrorCode != "" {
+		errorType := strings.TrimSpace(e.ErrorType + " error")
+		fmt.Fprintf(&b, " (%s %s)", errorType, e.ErrorCode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.PSPReference != "" {
+		fmt.Fprintf(&b, " [pspReference %s]", e.PSPReference)
+	}
+	return b.String()
+}
+
+// IsValidation returns true if Adyen rejected the request itself
+func (e *AdyenAPIError) IsValidation() bool {
+	return e.ErrorType == AdyenErrorTypeValidation ||
+		e.Status == http.StatusBadRequest || e.Status == http.StatusUnprocessableEntity
+}
+
+// IsAuthentication returns true if the API key was missing, invalid or lacked permissions
+func (e *AdyenAPIError) IsAuthentication() bool {
+	return e.ErrorType == AdyenErrorTypeSecurity ||
+		e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+}
+
+// IsRateLimited returns true if Adyen asked us to slow down
+func (e *AdyenAPIError) IsRateLimited() bool {
+	return e.Status == http.StatusTooManyRequests
+}
+
+// Retryable returns true if the same request may succeed when sent again
+func (e *AdyenAPIError) Retryable() bool {
+	return e.IsRateLimited() || e.Status >= http.StatusInternalServerError
+}
+
+// parseAdyenAPIError builds an AdyenAPIError from an unsuccessful response.
+// A body that is not Adyen's error envelope is kept as the message.
+func parseAdyenAPIError(statusCode int, body []byte) *AdyenAPIError {
+	apiErr := &AdyenAPIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil || (apiErr.ErrorCode == "" && apiErr.Message == "") {
+		apiErr = &AdyenAPIError{Message: strings.TrimSpace(string(body))}
+	}
+	// The HTTP status is authoritative, e.g. for error pages from a proxy in front of Adyen
+	apiErr.Status = statusCode
+	return apiErr
+}
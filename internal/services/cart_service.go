@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/google/uuid"
+)
+
+// CartRepository defines the interface for cart persistence
+type CartRepository interface {
+	CreateCart(ctx context.Context, cart *models.Cart) error
+	GetCart(ctx context.Context, id string) (*models.Cart, error)
+	SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error
+	RemoveItem(ctx context.Context, cartID, productID string) error
+	ClearCart(ctx context.Context, cartID string) error
+}
+
+// CartService handles shopping cart business logic
+type CartService interface {
+	CreateCart(ctx context.Context) (*models.Cart, error)
+	GetCart(ctx context.Context, id string) (*models.Cart, error)
+	AddItem(ctx context.Context, cartID, sku string, quantity int) error
+	UpdateItemQuantity(ctx context.Context, cartID, sku string, quantity int) error
+	RemoveItem(ctx context.Context, cartID, sku string) error
+	ClearCart(ctx context.Context, cartID string) error
+}
+
+// CartServiceImpl implements CartService
+type CartServiceImpl struct {
+	cartRepo    CartRepository
+	productRepo ProductRepository
+}
+
+// NewCartService creates a new cart service
+func NewCartService(cartRepo CartRepository, productRepo ProductRepository) CartService {
+	return &CartServiceImpl{
+		cartRepo:    cartRepo,
+		productRepo: productRepo,
+	}
+}
+
+// CreateCart creates a new, empty cart
+func (s *CartServiceImpl) CreateCart(ctx context.Context) (*models.Cart, error) {
+	cart := &models.Cart{ID: uuid.New().String()}
+	if err := s.cartRepo.CreateCart(ctx, cart); err != nil {
+		return nil, fmt.Errorf("failed to create cart: %w", err)
+	}
+	return cart, nil
+}
+
+// GetCart retrieves a cart with its items
+func (s *CartServiceImpl) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	// Cart IDs come from a cookie, so anything that is not a UUID cannot exist
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, models.ErrCartNotFound
+	}
+
+	cart, err := s.cartRepo.GetCart(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	return cart, nil
+}
+
+// AddItem adds a quantity of a product to the cart, on top of any quantity already in it
+func (s *CartServiceImpl) AddItem(ctx context.Context, cartID, sku string, quantity int) error {
+	if err := models.ValidateQuantity(quantity); err != nil {
+		return err
+	}
+
+	cart, product, err := s.loadCartAndProduct(ctx, cartID, sku)
+	if err != nil {
+		return err
+	}
+	if err := cart.CanAdd(product); err != nil {
+		return err
+	}
+
+	if existing := cart.FindItem(sku); existing != nil {
+		quantity += existing.Quantity
+	}
+	if err := models.ValidateQuantity(quantity); err != nil {
+		return err
+	}
+
+	if err := s.cartRepo.SetItemQuantity(ctx, cart.ID, product.ID, quantity); err != nil {
+		return fmt.Errorf("failed to add item: %w", err)
+	}
+	return nil
+}
+
+// UpdateItemQuantity replaces the quantity of a product in the cart.
+// A quantity of zero removes the product.
+func (s *CartServiceImpl) UpdateItemQuantity(ctx context.Context, cartID, sku string, quantity int) error {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, cartID, sku)
+	}
+	if err := models.ValidateQuantity(quantity); err != nil {
+		return err
+	}
+
+	cart, product, err := s.loadCartAndProduct(ctx, cartID, sku)
+	if err != nil {
+		return err
+	}
+	if cart.FindItem(sku) == nil {
+		if err := cart.CanAdd(product); err != nil {
+			return err
+		}
+	}
+
+	if err := s.cartRepo.SetItemQuantity(ctx, cart.ID, product.ID, quantity); err != nil {
+		return fmt.Errorf("failed to update item quantity: %w", err)
+	}
+	return nil
+}
+
+// RemoveItem removes a product from the cart
+func (s *CartServiceImpl) RemoveItem(ctx context.Context, cartID, sku string) error {
+	cart, product, err := s.loadCartAndProduct(ctx, cartID, sku)
+	if err != nil {
+		return err
+	}
+
+	if err := s.cartRepo.RemoveItem(ctx, cart.ID, product.ID); err != nil {
+		return fmt.Errorf("failed to remove item: %w", err)
+	}
+	return nil
+}
+
+// ClearCart removes all items from the cart
+func (s *CartServiceImpl) ClearCart(ctx context.Context, cartID string) error {
+	if _, err := uuid.Parse(cartID); err != nil {
+		return models.ErrCartNotFound
+	}
+
+	if err := s.cartRepo.ClearCart(ctx, cartID); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+	return nil
+}
+
+// loadCartAndProduct retrieves the cart and the catalog product for a SKU
+func (s *CartServiceImpl) loadCartAndProduct(ctx context.Context, cartID, sku string) (*models.Cart, *models.Product, error) {
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sku == "" {
+		return nil, nil, models.ErrProductNotFound
+	}
+	product, err := s.productRepo.GetProductBySKU(ctx, sku)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	return cart, product, nil
+}
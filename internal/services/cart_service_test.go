@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// MockCartRepository is a mock implementation of CartRepository for testing
+type MockCartRepository struct {
+	CreateCartFunc      func(*models.Cart) error
+	GetCartFunc         func(string) (*models.Cart, error)
+	SetItemQuantityFunc func(string, string, int) error
+	RemoveItemFunc      func(string, string) error
+	ClearCartFunc       func(string) error
+}
+
+func (m *MockCartRepository) CreateCart(ctx context.Context, cart *models.Cart) error {
+	if m.CreateCartFunc != nil {
+		return m.CreateCartFunc(cart)
+	}
+	return nil
+}
+
+func (m *MockCartRepository) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	if m.GetCartFunc != nil {
+		return m.GetCartFunc(id)
+	}
+	return &models.Cart{ID: id}, nil
+}
+
+func (m *MockCartRepository) SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error {
+	if m.SetItemQuantityFunc != nil {
+		return m.SetItemQuantityFunc(cartID, productID, quantity)
+	}
+	return nil
+}
+
+func (m *MockCartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	if m.RemoveItemFunc != nil {
+		return m.RemoveItemFunc(cartID, productID)
+	}
+	return nil
+}
+
+func (m *MockCartRepository) ClearCart(ctx context.Context, cartID string) error {
+	if m.ClearCartFunc != nil {
+		return m.ClearCartFunc(cartID)
+	}
+	return nil
+}
+
+const testCartID = "0d5c2a6e-9a51-4f0e-8d8a-3f1c6b2e7a10"
+
+// testCatalog returns a product repository with one USD and one EUR product
+func testCatalog() *MockProductRepository {
+	products := map[string]*models.Product{
+		"widget-001": {ID: "product-widget", SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"},
+		"euro-001":   {ID: "product-euro", SKU: "euro-001", Name: "Euro Product", Price: 100, Currency: "EUR"},
+	}
+	return &MockProductRepository{
+		GetProductBySKUFunc: func(sku string) (*models.Product, error) {
+			product, ok := products[sku]
+			if !ok {
+				return nil, models.ErrProductNotFound
+			}
+			return product, nil
+		},
+	}
+}
+
+// cartWithWidgets returns a cart repository holding the given quantity of widget-001
+func cartWithWidgets(quantity int) *MockCartRepository {
+	return &MockCartRepository{
+		GetCartFunc: func(id string) (*models.Cart, error) {
+			cart := &models.Cart{ID: id}
+			if quantity > 0 {
+				cart.Items = []models.CartItem{{
+					Product:  &models.Product{ID: "product-widget", SKU: "widget-001", Price: 100, Currency: "USD"},
+					Quantity: quantity,
+				}}
+			}
+			return cart, nil
+		},
+	}
+}
+
+func TestCartService_GetCart(t *testing.T) {
+	service := NewCartService(&MockCartRepository{}, testCatalog())
+
+	if _, err := service.GetCart(context.Background(), "not-a-uuid"); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("GetCart() error = %v, want ErrCartNotFound", err)
+	}
+
+	cart, err := service.GetCart(context.Background(), testCartID)
+	if err != nil {
+		t.Fatalf("GetCart() unexpected error = %v", err)
+	}
+	if cart.ID != testCartID {
+		t.Errorf("Expected cart %s, got %s", testCartID, cart.ID)
+	}
+}
+
+func TestCartService_AddItem(t *testing.T) {
+	tests := []struct {
+		name             string
+		existing         int
+		sku              string
+		quantity         int
+		wantErr          error
+		expectedQuantity int
+	}{
+		{
+			name:             "add to empty cart",
+			sku:              "widget-001",
+			quantity:         2,
+			expectedQuantity: 2,
+		},
+		{
+			name:             "add to existing line",
+			existing:         3,
+			sku:              "widget-001",
+			quantity:         2,
+			expectedQuantity: 5,
+		},
+		{
+			name:     "exceeds maximum quantity",
+			existing: models.MaxCartItemQuantity,
+			sku:      "widget-001",
+			quantity: 1,
+			wantErr:  models.ErrInvalidQuantity,
+		},
+		{
+			name:     "zero quantity",
+			sku:      "widget-001",
+			quantity: 0,
+			wantErr:  models.ErrInvalidQuantity,
+		},
+		{
+			name:     "unknown product",
+			sku:      "missing-001",
+			quantity: 1,
+			wantErr:  models.ErrProductNotFound,
+		},
+		{
+			name:     "different currency",
+			existing: 1,
+			sku:      "euro-001",
+			quantity: 1,
+			wantErr:  models.ErrCurrencyMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var setQuantity int
+			repo := cartWithWidgets(tt.existing)
+			repo.SetItemQuantityFunc = func(cartID, productID string, quantity int) error {
+				setQuantity = quantity
+				return nil
+			}
+
+			service := NewCartService(repo, testCatalog())
+			err := service.AddItem(context.Background(), testCartID, tt.sku, tt.quantity)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AddItem() error = %v, want %v", err, tt.wantErr)
+			}
+			if setQuantity != tt.expectedQuantity {
+				t.Errorf("Expected stored quantity %d, got %d", tt.expectedQuantity, setQuantity)
+			}
+		})
+	}
+}
+
+func TestCartService_UpdateItemQuantity(t *testing.T) {
+	tests := []struct {
+		name             string
+		quantity         int
+		wantErr          error
+		expectedQuantity int
+		expectRemove     bool
+	}{
+		{
+			name:             "set quantity",
+			quantity:         7,
+			expectedQuantity: 7,
+		},
+		{
+			name:         "zero removes item",
+			quantity:     0,
+			expectRemove: true,
+		},
+		{
+			name:     "negative quantity",
+			quantity: -1,
+			wantErr:  models.ErrInvalidQuantity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var setQuantity int
+			var removed bool
+			repo := cartWithWidgets(2)
+			repo.SetItemQuantityFunc = func(cartID, productID string, quantity int) error {
+				setQuantity = quantity
+				return nil
+			}
+			repo.RemoveItemFunc = func(cartID, productID string) error {
+				removed = productID == "product-widget"
+				return nil
+			}
+
+			service := NewCartService(repo, testCatalog())
+			err := service.UpdateItemQuantity(context.Background(), testCartID, "widget-001", tt.quantity)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateItemQuantity() error = %v, want %v", err, tt.wantErr)
+			}
+			if setQuantity != tt.expectedQuantity {
+				t.Errorf("Expected stored quantity %d, got %d", tt.expectedQuantity, setQuantity)
+			}
+			if removed != tt.expectRemove {
+				t.Errorf("Expected removed %v, got %v", tt.expectRemove, removed)
+			}
+		})
+	}
+}
+
+func TestCartService_ClearCart(t *testing.T) {
+	var cleared string
+	repo := &MockCartRepository{
+		ClearCartFunc: func(cartID string) error {
+			cleared = cartID
+			return nil
+		},
+	}
+	service := NewCartService(repo, testCatalog())
+
+	if err := service.ClearCart(context.Background(), testCartID); err != nil {
+		t.Fatalf("ClearCart() unexpected error = %v", err)
+	}
+	if cleared != testCartID {
+		t.Errorf("Expected cart %s to be cleared, got %q", testCartID, cleared)
+	}
+
+	if err := service.ClearCart(context.Background(), "not-a-uuid"); !errors.Is(err, models.ErrCartNotFound) {
+		t.Errorf("ClearCart() error = %v, want ErrCartNotFound", err)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// orderEventBuffer is how many events a subscriber can fall behind before events are dropped
+const orderEventBuffer = 16
+
+// OrderEvent describes an order status transition
+type OrderEvent struct {
+	Reference      string
+	Status         models.OrderStatus
+	PreviousStatus models.OrderStatus
+	PSPReference   string
+	Amount         int64
+	Currency       string
+	UpdatedAt      time.Time
+}
+
+// OrderEventBus delivers order status transitions to subscribers within this process
+type OrderEventBus interface {
+	// Publish sends the event to every subscriber of its order without blocking
+	Publish(event OrderEvent)
+	// Subscribe returns a channel receiving events for the order with the given reference,
+	// or for all orders if reference is empty, and a function that ends the subscription
+	Subscribe(reference string) (<-chan OrderEvent, func())
+	// Close ends all subscriptions, closing their channels
+	Close()
+}
+
+// orderSubscriber is a single subscription to the event bus
+type orderSubscriber struct {
+	reference string
+	events    chan OrderEvent
+}
+
+// OrderEventBusImpl implements OrderEventBus with buffered channels
+type OrderEventBusImpl struct {
+	mu          sync.Mutex
+	subscribers map[*orderSubscriber]struct{}
+	closed      bool
+}
+
+// NewOrderEventBus creates a new in-process order event bus
+func NewOrderEventBus() OrderEventBus {
+	return &OrderEventBusImpl{
+		subscribers: make(map[*orderSubscriber]struct{}),
+	}
+}
+
+// Publish sends the event to every matching subscriber. A subscriber whose buffer
+// is full misses the event rather than holding up the publisher.
+func (b *OrderEventBusImpl) Publish(event OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.reference != "" && sub.reference != event.Reference {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("Dropping %s event for order %s: subscriber is not keeping up", event.Status, event.Reference)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the order with the given reference, or for all orders
+func (b *OrderEventBusImpl) Subscribe(reference string) (<-chan OrderEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &orderSubscriber{
+		reference: reference,
+		events:    make(chan OrderEvent, orderEventBuffer),
+	}
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[sub]; ok {
+				delete(b.subscribers, sub)
+				close(sub.events)
+			}
+		})
+	}
+	return sub.events, unsubscribe
+}
+
+// Close ends all subscriptions, e.g. so open event streams finish when the server shuts down
+func (b *OrderEventBusImpl) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
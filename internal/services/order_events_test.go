@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+func TestOrderEventBus_Subscribe(t *testing.T) {
+	bus := NewOrderEventBus()
+
+	orderEvents, unsubscribeOrder := bus.Subscribe("ORDER-1")
+	defer unsubscribeOrder()
+	allEvents, unsubscribeAll := bus.Subscribe("")
+	defer unsubscribeAll()
+
+	bus.Publish(OrderEvent{Reference: "ORDER-2", Status: models.OrderStatusAuthorized})
+	bus.Publish(OrderEvent{Reference: "ORDER-1", Status: models.OrderStatusAuthorized})
+
+	// The order subscriber only sees its own order
+	if event := <-orderEvents; event.Reference != "ORDER-1" {
+		t.Errorf("expected event for ORDER-1, got %s", event.Reference)
+	}
+	select {
+	case event := <-orderEvents:
+		t.Errorf("expected no further events, got %+v", event)
+	default:
+	}
+
+	// The subscriber to all orders sees both, in order
+	for _, expected := range []string{"ORDER-2", "ORDER-1"} {
+		if event := <-allEvents; event.Reference != expected {
+			t.Errorf("expected event for %s, got %s", expected, event.Reference)
+		}
+	}
+}
+
+func TestOrderEventBus_Unsubscribe(t *testing.T) {
+	bus := NewOrderEventBus()
+
+	events, unsubscribe := bus.Subscribe("ORDER-1")
+	unsubscribe()
+	unsubscribe() // safe to call twice
+
+	bus.Publish(OrderEvent{Reference: "ORDER-1", Status: models.OrderStatusAuthorized})
+
+	if _, ok := <-events; ok {
+		t.Error("expected the channel to be closed after unsubscribing")
+	}
+}
+
+func TestOrderEventBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewOrderEventBus()
+
+	events, unsubscribe := bus.Subscribe("ORDER-1")
+	defer unsubscribe()
+
+	// Nobody reads, so events beyond the buffer are dropped instead of blocking
+	for i := 0; i < orderEventBuffer+5; i++ {
+		bus.Publish(OrderEvent{Reference: "ORDER-1", Status: models.OrderStatusProcessing})
+	}
+
+	if len(events) != orderEventBuffer {
+		t.Errorf("expected %d buffered events, got %d", orderEventBuffer, len(events))
+	}
+}
+
+func TestOrderEventBus_Close(t *testing.T) {
+	bus := NewOrderEventBus()
+
+	events, unsubscribe := bus.Subscribe("ORDER-1")
+	bus.Close()
+	unsubscribe() // safe after close
+
+	if _, ok := <-events; ok {
+		t.Error("expected the channel to be closed when the bus closes")
+	}
+
+	// Subscribing to a closed bus returns a closed channel
+	late, _ := bus.Subscribe("ORDER-1")
+	if _, ok := <-late; ok {
+		t.Error("expected a closed channel from a closed bus")
+	}
+
+	// Publishing after close is a no-op
+	bus.Publish(OrderEvent{Reference: "ORDER-1"})
+}
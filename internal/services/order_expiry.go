@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// defaultSessionLifetime is how long an Adyen payment session lasts by default, for orders
+// whose session expiry was not recorded
+const defaultSessionLifetime = time.Hour
+
+// orderExpiryBatchSize bounds how many orders are loaded at once while expiring orders
+const orderExpiryBatchSize = 100
+
+// sweeperActor is recorded as the actor of expiries made by the server itself
+const sweeperActor = "sweeper"
+
+// OrderExpiryService expires pending orders whose payment session ran out without a payment
+type OrderExpiryService interface {
+	// ExpireDue expires the pending orders whose session expired and returns their references
+	ExpireDue(ctx context.Context) ([]string, error)
+	// Run expires due orders every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// OrderExpiryServiceImpl implements OrderExpiryService
+type OrderExpiryServiceImpl struct {
+	orderRepo    OrderRepository
+	orderService OrderService
+	now          func() time.Time
+}
+
+// NewOrderExpiryService creates a new order expiry service finding orders in orderRepo and
+// expiring them through orderService
+func NewOrderExpiryService(orderRepo OrderRepository, orderService OrderService) OrderExpiryService {
+	return &OrderExpiryServiceImpl{
+		orderRepo:    orderRepo,
+		orderService: orderService,
+		now:          time.Now,
+	}
+}
+
+// orderExpiryPayload is recorded in the order history with each expiry
+type orderExpiryPayload struct {
+	SessionExpiresAt *time.Time `json:"sessionExpiresAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// ExpireDue expires the pending orders whose session expired and returns their references
+func (s *OrderExpiryServiceImpl) ExpireDue(ctx context.Context) ([]string, error) {
+	actor := models.ActorFromContext(ctx)
+	if actor == "" {
+		actor = sweeperActor
+	}
+
+	expired := []string{}
+	for {
+		orders, err := s.orderRepo.ListExpiredOrders(ctx, s.now(), defaultSessionLifetime, orderExpiryBatchSize)
+		if err != nil {
+			return expired, fmt.Errorf("failed to list expired orders: %w", err)
+		}
+
+		for _, order := range orders {
+			payload := orderExpiryPayload{CreatedAt: order.CreatedAt}
+			if !order.SessionExpiresAt.IsZero() {
+				payload.SessionExpiresAt = &order.SessionExpiresAt
+			}
+			change := newOrderChange(models.OrderEventSourceExpiry, actor, payload)
+
+			err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusExpired), order.PSPReference, change)
+			switch {
+			case errors.Is(err, models.ErrInvalidStatusTransition):
+				// The shopper paid while the order was being expired
+				log.Printf("Not expiring order %s: %v", order.Reference, err)
+			case err != nil:
+				return expired, fmt.Errorf("failed to expire order %s: %w", order.Reference, err)
+			default:
+				log.Printf("Expired order %s, its payment session ran out without a payment", order.Reference)
+				expired = append(expired, order.Reference)
+			}
+		}
+
+		// Every listed order is no longer pending, so the next batch has new ones
+		if len(orders) < orderExpiryBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// Run expires due orders every interval until ctx is done
+func (s *OrderExpiryServiceImpl) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "expiring orders", func(ctx context.Context) error {
+		_, err := s.ExpireDue(ctx)
+		return err
+	})
+}
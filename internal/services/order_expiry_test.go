@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+)
+
+func TestOrderExpiryService_ExpireDue(t *testing.T) {
+	orderRepo := repository.NewMemoryOrderRepository()
+	orderService := NewOrderService(orderRepo, NewOrderEventBus())
+	ctx := context.Background()
+	now := time.Now()
+
+	// Orders with a session that expired, one that is still open, one without a recorded
+	// session and one that was paid
+	sessions := map[string]time.Time{
+		"ORDER-EXPIRED": now.Add(-time.Minute),
+		"ORDER-OPEN":    now.Add(time.Minute),
+		"ORDER-NEW":     {},
+		"ORDER-PAID":    now.Add(-time.Minute),
+	}
+	for reference, expiresAt := range sessions {
+		order, err := models.NewOrderFromItems([]models.OrderItem{{SKU: "widget-001", Name: "Widget", UnitPrice: 1000, Quantity: 1}}, "USD")
+		if err != nil {
+			t.Fatalf("Failed to build order: %v", err)
+		}
+		order.Reference = reference
+		if err := orderRepo.CreateOrder(ctx, order); err != nil {
+			t.Fatalf("Failed to create order: %v", err)
+		}
+		if !expiresAt.IsZero() {
+			if err := orderService.SetPaymentSession(ctx, reference, models.PaymentSession{ID: "CS-" + reference, Data: "session-data", ExpiresAt: expiresAt}); err != nil {
+				t.Fatalf("Failed to set payment session: %v", err)
+			}
+		}
+	}
+	if err := orderService.UpdateOrderStatus(ctx, "ORDER-PAID", string(models.OrderStatusAuthorized), "PSP-123", models.OrderChange{}); err != nil {
+		t.Fatalf("Failed to authorize order: %v", err)
+	}
+
+	service := NewOrderExpiryService(orderRepo, orderService).(*OrderExpiryServiceImpl)
+	service.now = func() time.Time { return now }
+
+	expired, err := service.ExpireDue(models.WithActor(ctx, "alice"))
+	if err != nil {
+		t.Fatalf("ExpireDue() unexpected error = %v", err)
+	}
+	if len(expired) != 1 || expired[0] != "ORDER-EXPIRED" {
+		t.Fatalf("Expected only ORDER-EXPIRED to expire, got %v", expired)
+	}
+
+	for reference, expected := range map[string]models.OrderStatus{
+		"ORDER-EXPIRED": models.OrderStatusExpired,
+		"ORDER-OPEN":    models.OrderStatusPending,
+		"ORDER-NEW":     models.OrderStatusPending,
+		"ORDER-PAID":    models.OrderStatusAuthorized,
+	} {
+		if order, _ := orderRepo.GetOrderByReference(ctx, reference); order.Status != expected {
+			t.Errorf("Expected %s to be %s, got %s", reference, expected, order.Status)
+		}
+	}
+
+	history, _ := orderRepo.GetOrderHistory(ctx, "ORDER-EXPIRED")
+	if len(history) != 1 || history[0].Source != models.OrderEventSourceExpiry || history[0].Actor != "alice" {
+		t.Fatalf("Expected the expiry in the history, got %+v", history)
+	}
+	var payload orderExpiryPayload
+	if err := json.Unmarshal(history[0].Payload, &payload); err != nil || payload.SessionExpiresAt == nil {
+		t.Errorf("Expected the session expiry in the payload, got %s", history[0].Payload)
+	}
+
+	// An order without a recorded session expires after the default session lifetime
+	service.now = func() time.Time { return now.Add(defaultSessionLifetime + time.Minute) }
+	expired, err = service.ExpireDue(ctx)
+	if err != nil {
+		t.Fatalf("ExpireDue() unexpected error = %v", err)
+	}
+	if len(expired) != 2 {
+		t.Errorf("Expected ORDER-OPEN and ORDER-NEW to expire, got %v", expired)
+	}
+	history, _ = orderRepo.GetOrderHistory(ctx, "ORDER-NEW")
+	if len(history) != 1 || history[0].Actor != sweeperActor {
+		t.Errorf("Expected the sweeper to expire ORDER-NEW, got %+v", history)
+	}
+}
+
+func TestOrderExpiryService_ExpireDue_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		listError   error
+		updateError error
+		wantErr     bool
+	}{
+		{
+			name:      "listing fails",
+			listError: errors.New("database unavailable"),
+			wantErr:   true,
+		},
+		{
+			name:        "order was paid meanwhile",
+			updateError: fmt.Errorf("%w: cannot expire order with status authorized", models.ErrInvalidStatusTransition),
+		},
+		{
+			name:        "update fails",
+			updateError: errors.New("database unavailable"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockOrderRepository{
+				ListExpiredOrdersFunc: func(now time.Time, sessionLifetime time.Duration, limit int) ([]models.Order, error) {
+					if tt.listError != nil {
+						return nil, tt.listError
+					}
+					return []models.Order{{Reference: "ORDER-123", Status: models.OrderStatusPending}}, nil
+				},
+			}
+			mockOrder := &MockOrderService{
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					return tt.updateError
+				},
+			}
+
+			expired, err := NewOrderExpiryService(mockRepo, mockOrder).ExpireDue(context.Background())
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExpireDue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(expired) != 0 {
+				t.Errorf("Expected no expired orders, got %v", expired)
+			}
+		})
+	}
+}
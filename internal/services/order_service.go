@@ -1,47 +1,68 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/adyen/ecommerce/internal/models"
 )
 
 // OrderRepository defines the interface for order persistence
 type OrderRepository interface {
-	CreateOrder(order *models.Order) error
-	GetOrderByReference(reference string) (*models.Order, error)
-	UpdateOrderStatus(reference, status, pspReference string) error
+	CreateOrder(ctx context.Context, order *models.Order) error
+	GetOrderByReference(ctx context.Context, reference string) (*models.Order, error)
+	// UpdateOrderStatus returns models.ErrOrderVersionConflict if the order is no longer at expectedVersion
+	UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, expectedVersion int, change models.OrderChange) error
+	// UpdateOrderRefund returns models.ErrOrderVersionConflict if the order is no longer at expectedVersion
+	UpdateOrderRefund(ctx context.Context, reference, status, refundPSPReference string, refundedAmount int64, expectedVersion int, change models.OrderChange) error
+	GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error)
+	SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error
+	ListExpiredOrders(ctx context.Context, now time.Time, sessionLifetime time.Duration, limit int) ([]models.Order, error)
 }
 
+// maxStatusUpdateAttempts bounds how often a status change is retried after losing a race
+// with a concurrent change to the same order
+const maxStatusUpdateAttempts = 3
+
 // OrderService handles order business logic
 type OrderService interface {
-	CreateOrder(productName string, amount int64, currency string) (*models.Order, error)
-	GetOrderByReference(reference string) (*models.Order, error)
-	UpdateOrderStatus(reference, status, pspReference string) error
+	CreateOrder(ctx context.Context, items []models.OrderItem, currency string) (*models.Order, error)
+	GetOrderByReference(ctx context.Context, reference string) (*models.Order, error)
+	UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, change models.OrderChange) error
+	RecordRefund(ctx context.Context, reference string, refund models.Refund, change models.OrderChange) error
+	RejectRequest(ctx context.Context, reference string, requested models.OrderStatus, change models.OrderChange) error
+	GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error)
+	SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error
 }
 
 // OrderServiceImpl implements OrderService
 type OrderServiceImpl struct {
 	orderRepo OrderRepository
+	events    OrderEventBus
 }
 
-// NewOrderService creates a new order service
-func NewOrderService(orderRepo OrderRepository) OrderService {
+// NewOrderService creates a new order service publishing status transitions to events
+func NewOrderService(orderRepo OrderRepository, events OrderEventBus) OrderService {
 	return &OrderServiceImpl{
 		orderRepo: orderRepo,
+		events:    events,
 	}
 }
 
-// CreateOrder creates a new order with generated ID and reference
-func (s *OrderServiceImpl) CreateOrder(productName string, amount int64, currency string) (*models.Order, error) {
+// CreateOrder creates a new order for the given line items with generated ID and reference
+func (s *OrderServiceImpl) CreateOrder(ctx context.Context, items []models.OrderItem, currency string) (*models.Order, error) {
 	// Create order using domain factory method
-	order, err := models.NewOrder(productName, amount, currency)
+	order, err := models.NewOrderFromItems(items, currency)
 	if err != nil {
 		return nil, fmt.Errorf("invalid order: %w", err)
 	}
 
 	// Persist to database
-	if err := s.orderRepo.CreateOrder(order); err != nil {
+	if err := s.orderRepo.CreateOrder(ctx, order); err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
@@ -49,44 +70,263 @@ func (s *OrderServiceImpl) CreateOrder(productName string, amount int64, currenc
 }
 
 // GetOrderByReference retrieves an order by its reference
-func (s *OrderServiceImpl) GetOrderByReference(reference string) (*models.Order, error) {
-	order, err := s.orderRepo.GetOrderByReference(reference)
+func (s *OrderServiceImpl) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderByReference(ctx, reference)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 	return order, nil
 }
 
-// UpdateOrderStatus updates the status of an order
-func (s *OrderServiceImpl) UpdateOrderStatus(reference, status, pspReference string) error {
+// UpdateOrderStatus updates the status of an order, recording the change in its history.
+// The transition is validated against the stored order and retried if the order was
+// changed concurrently, e.g. by a webhook arriving while the shopper is redirected.
+func (s *OrderServiceImpl) UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, change models.OrderChange) error {
+	var err error
+	for attempt := 1; attempt <= maxStatusUpdateAttempts; attempt++ {
+		err = s.updateOrderStatus(ctx, reference, status, pspReference, change)
+		if !errors.Is(err, models.ErrOrderVersionConflict) {
+			return err
+		}
+		log.Printf("Order %s changed concurrently (attempt %d of %d), retrying status update to %s", reference, attempt, maxStatusUpdateAttempts, status)
+	}
+	return err
+}
+
+// updateOrderStatus makes a single attempt to transition the order to status
+func (s *OrderServiceImpl) updateOrderStatus(ctx context.Context, reference, status, pspReference string, change models.OrderChange) error {
 	// Get the order
-	order, err := s.orderRepo.GetOrderByReference(reference)
+	order, err := s.orderRepo.GetOrderByReference(ctx, reference)
 	if err != nil {
 		return fmt.Errorf("failed to get order: %w", err)
 	}
+	previousStatus := order.Status
 
 	// Use domain methods to transition state
-	switch models.OrderStatus(status) {
+	if err := transitionOrder(order, models.OrderStatus(status), pspReference); err != nil {
+		return err
+	}
+
+	// Update in database, only if nobody changed the order since it was read
+	if err := s.orderRepo.UpdateOrderStatus(ctx, reference, string(order.Status), order.PSPReference, order.Version, change); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	// Tell anyone watching the order, e.g. the shopper's open confirmation page
+	s.events.Publish(OrderEvent{
+		Reference:      order.Reference,
+		Status:         order.Status,
+		PreviousStatus: previousStatus,
+		PSPReference:   order.PSPReference,
+		Amount:         order.Amount,
+		Currency:       order.Currency,
+		UpdatedAt:      order.UpdatedAt,
+	})
+
+	return nil
+}
+
+// transitionOrder applies the domain transition to status, which fails if the order's
+// current status does not allow it
+func transitionOrder(order *models.Order, status models.OrderStatus, pspReference string) error {
+	switch status {
+	case models.OrderStatusProcessing:
+		return order.MarkProcessing(pspReference)
 	case models.OrderStatusAuthorized:
-		if err := order.Authorize(pspReference); err != nil {
-			return err
-		}
+		return order.Authorize(pspReference)
 	case models.OrderStatusFailed:
-		if err := order.Fail(); err != nil {
+		return order.Fail()
+	case models.OrderStatusCancelled:
+		return order.Cancel()
+	case models.OrderStatusCancelRequested:
+		return order.RequestCancel()
+	case models.OrderStatusCaptureRequested:
+		return order.RequestCapture()
+	case models.OrderStatusCaptured:
+		return order.Capture()
+	case models.OrderStatusExpired:
+		return order.Expire()
+	case models.OrderStatusRefundRequested:
+		return order.RequestRefund()
+	case models.OrderStatusRefunded:
+		return order.MarkRefunded()
+	case models.OrderStatusPartiallyRefunded:
+		return order.MarkPartiallyRefunded()
+	default:
+		return fmt.Errorf("invalid order status: %s", status)
+	}
+}
+
+// RecordRefund applies the outcome of a refund reported by Adyen. A successful refund adds to
+// the order's refunded amount, a refused one moves the order back to its status before the
+// refund was requested. A refund that was already recorded is ignored, as Adyen can deliver
+// its notification more than once.
+func (s *OrderServiceImpl) RecordRefund(ctx context.Context, reference string, refund models.Refund, change models.OrderChange) error {
+	var err error
+	for attempt := 1; attempt <= maxStatusUpdateAttempts; attempt++ {
+		err = s.recordRefund(ctx, reference, refund, change)
+		if !errors.Is(err, models.ErrOrderVersionConflict) {
 			return err
 		}
-	case models.OrderStatusCancelled:
-		if err := order.Cancel(); err != nil {
+		log.Printf("Order %s changed concurrently (attempt %d of %d), retrying refund %s", reference, attempt, maxStatusUpdateAttempts, refund.PSPReference)
+	}
+	return err
+}
+
+// recordRefund makes a single attempt to apply a refund to the order
+func (s *OrderServiceImpl) recordRefund(ctx context.Context, reference string, refund models.Refund, change models.OrderChange) error {
+	order, err := s.orderRepo.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	history, err := s.orderRepo.GetOrderHistory(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to get order history: %w", err)
+	}
+	previousStatus := order.Status
+
+	if refund.Success {
+		if refundRecorded(history, refund.PSPReference) {
+			log.Printf("Refund %s of order %s was already recorded", refund.PSPReference, reference)
+			return nil
+		}
+		err = order.CompleteRefund(refund.Amount)
+	} else {
+		err = order.RejectRefund(statusBefore(history, models.OrderStatusRefundRequested))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.UpdateOrderRefund(ctx, reference, string(order.Status), refund.PSPReference, order.RefundedAmount, order.Version, change); err != nil {
+		return fmt.Errorf("failed to update order refund: %w", err)
+	}
+
+	s.events.Publish(OrderEvent{
+		Reference:      order.Reference,
+		Status:         order.Status,
+		PreviousStatus: previousStatus,
+		PSPReference:   order.PSPReference,
+		Amount:         order.Amount,
+		Currency:       order.Currency,
+		UpdatedAt:      order.UpdatedAt,
+	})
+
+	return nil
+}
+
+// refundRecorded returns true if the history holds a completed refund with the PSP reference
+func refundRecorded(history []models.OrderHistoryEvent, pspReference string) bool {
+	for _, event := range history {
+		if event.PSPReference == pspReference &&
+			(event.Status == models.OrderStatusRefunded || event.Status == models.OrderStatusPartiallyRefunded) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusBefore returns the status the order had when it last moved to requested
+func statusBefore(history []models.OrderHistoryEvent, requested models.OrderStatus) models.OrderStatus {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Status == requested {
+			return history[i].PreviousStatus
+		}
+	}
+	return ""
+}
+
+// RejectRequest moves an order waiting for Adyen to confirm a capture or cancellation, its
+// requested status, back to the status it had before, when Adyen refuses it. An order that is no longer
+// waiting for it, e.g. because the notification was delivered twice, is left alone with
+// models.ErrInvalidStatusTransition.
+func (s *OrderServiceImpl) RejectRequest(ctx context.Context, reference string, requested models.OrderStatus, change models.OrderChange) error {
+	var err error
+	for attempt := 1; attempt <= maxStatusUpdateAttempts; attempt++ {
+		err = s.rejectRequest(ctx, reference, requested, change)
+		if !errors.Is(err, models.ErrOrderVersionConflict) {
 			return err
 		}
+		log.Printf("Order %s changed concurrently (attempt %d of %d), retrying rejection of %s", reference, attempt, maxStatusUpdateAttempts, requested)
+	}
+	return err
+}
+
+// rejectRequest makes a single attempt to move the order back from requested
+func (s *OrderServiceImpl) rejectRequest(ctx context.Context, reference string, requested models.OrderStatus, change models.OrderChange) error {
+	order, err := s.orderRepo.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if order.Status != requested {
+		return fmt.Errorf("%w: order with status %s is not waiting for %s", models.ErrInvalidStatusTransition, order.Status, requested)
+	}
+	history, err := s.orderRepo.GetOrderHistory(ctx, reference)
+	if err != nil {
+		return fmt.Errorf("failed to get order history: %w", err)
+	}
+	previousStatus := order.Status
+
+	switch requested {
+	case models.OrderStatusCancelRequested:
+		err = order.RejectCancel(statusBefore(history, requested))
+	case models.OrderStatusCaptureRequested:
+		err = order.RejectCapture()
 	default:
-		return fmt.Errorf("invalid order status: %s", status)
+		err = fmt.Errorf("%w: cannot reject %s", models.ErrInvalidStatusTransition, requested)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Update in database
-	if err := s.orderRepo.UpdateOrderStatus(reference, string(order.Status), order.PSPReference); err != nil {
+	if err := s.orderRepo.UpdateOrderStatus(ctx, reference, string(order.Status), order.PSPReference, order.Version, change); err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
+	s.events.Publish(OrderEvent{
+		Reference:      order.Reference,
+		Status:         order.Status,
+		PreviousStatus: previousStatus,
+		PSPReference:   order.PSPReference,
+		Amount:         order.Amount,
+		Currency:       order.Currency,
+		UpdatedAt:      order.UpdatedAt,
+	})
+
+	return nil
+}
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (s *OrderServiceImpl) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	events, err := s.orderRepo.GetOrderHistory(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+	return events, nil
+}
+
+// SetPaymentSession records the order's payment session, so that it can be resumed until it
+// expires, after which an unpaid order can be expired
+func (s *OrderServiceImpl) SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error {
+	if err := s.orderRepo.SetPaymentSession(ctx, reference, session); err != nil {
+		return fmt.Errorf("failed to set payment session: %w", err)
+	}
 	return nil
 }
+
+// newOrderChange describes a status change for the order history, keeping payload as JSON
+func newOrderChange(source models.OrderEventSource, actor string, payload interface{}) models.OrderChange {
+	change := models.OrderChange{Source: source, Actor: actor}
+	if payload == nil {
+		return change
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		// The status change matters more than its audit payload
+		log.Printf("Warning: failed to encode %s payload for order history: %v", source, err)
+		return change
+	}
+	change.Payload = data
+	return change
+}
@@ -1,40 +1,77 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
 )
 
 // MockOrderRepository is a mock implementation of OrderRepository for testing
 type MockOrderRepository struct {
 	CreateOrderFunc         func(*models.Order) error
 	GetOrderByReferenceFunc func(string) (*models.Order, error)
-	UpdateOrderStatusFunc   func(string, string, string) error
+	UpdateOrderStatusFunc   func(string, string, string, int, models.OrderChange) error
+	UpdateOrderRefundFunc   func(string, string, string, int64, int, models.OrderChange) error
+	GetOrderHistoryFunc     func(string) ([]models.OrderHistoryEvent, error)
+	SetPaymentSessionFunc   func(string, models.PaymentSession) error
+	ListExpiredOrdersFunc   func(time.Time, time.Duration, int) ([]models.Order, error)
 }
 
-func (m *MockOrderRepository) CreateOrder(order *models.Order) error {
+func (m *MockOrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
 	if m.CreateOrderFunc != nil {
 		return m.CreateOrderFunc(order)
 	}
 	return nil
 }
 
-func (m *MockOrderRepository) GetOrderByReference(reference string) (*models.Order, error) {
+func (m *MockOrderRepository) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
 	if m.GetOrderByReferenceFunc != nil {
 		return m.GetOrderByReferenceFunc(reference)
 	}
 	return &models.Order{Reference: reference}, nil
 }
 
-func (m *MockOrderRepository) UpdateOrderStatus(reference, status, pspReference string) error {
+func (m *MockOrderRepository) UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
 	if m.UpdateOrderStatusFunc != nil {
-		return m.UpdateOrderStatusFunc(reference, status, pspReference)
+		return m.UpdateOrderStatusFunc(reference, status, pspReference, expectedVersion, change)
 	}
 	return nil
 }
 
+func (m *MockOrderRepository) UpdateOrderRefund(ctx context.Context, reference, status, refundPSPReference string, refundedAmount int64, expectedVersion int, change models.OrderChange) error {
+	if m.UpdateOrderRefundFunc != nil {
+		return m.UpdateOrderRefundFunc(reference, status, refundPSPReference, refundedAmount, expectedVersion, change)
+	}
+	return nil
+}
+
+func (m *MockOrderRepository) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	if m.GetOrderHistoryFunc != nil {
+		return m.GetOrderHistoryFunc(reference)
+	}
+	return nil, nil
+}
+
+func (m *MockOrderRepository) SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error {
+	if m.SetPaymentSessionFunc != nil {
+		return m.SetPaymentSessionFunc(reference, session)
+	}
+	return nil
+}
+
+func (m *MockOrderRepository) ListExpiredOrders(ctx context.Context, now time.Time, sessionLifetime time.Duration, limit int) ([]models.Order, error) {
+	if m.ListExpiredOrdersFunc != nil {
+		return m.ListExpiredOrdersFunc(now, sessionLifetime, limit)
+	}
+	return nil, nil
+}
+
 func TestOrderService_CreateOrder(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -88,12 +125,16 @@ func TestOrderService_CreateOrder(t *testing.T) {
 					if order.ProductName != tt.productName {
 						t.Errorf("Expected product name %s, got %s", tt.productName, order.ProductName)
 					}
+					if len(order.Items) != 1 || order.Items[0].SKU != "test-001" {
+						t.Errorf("Expected one order item for SKU test-001, got %+v", order.Items)
+					}
 					return nil
 				},
 			}
 
-			service := NewOrderService(mockRepo)
-			order, err := service.CreateOrder(tt.productName, tt.amount, tt.currency)
+			service := NewOrderService(mockRepo, NewOrderEventBus())
+			items := []models.OrderItem{{SKU: "test-001", Name: tt.productName, UnitPrice: tt.amount, Quantity: 1}}
+			order, err := service.CreateOrder(context.Background(), items, tt.currency)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
@@ -154,8 +195,8 @@ func TestOrderService_GetOrderByReference(t *testing.T) {
 				},
 			}
 
-			service := NewOrderService(mockRepo)
-			order, err := service.GetOrderByReference(tt.reference)
+			service := NewOrderService(mockRepo, NewOrderEventBus())
+			order, err := service.GetOrderByReference(context.Background(), tt.reference)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetOrderByReference() error = %v, wantErr %v", err, tt.wantErr)
@@ -194,6 +235,22 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 			mockError:    nil,
 			wantErr:      false,
 		},
+		{
+			name:         "successful update - processing",
+			reference:    "ORDER-123",
+			status:       string(models.OrderStatusProcessing),
+			pspReference: "PSP-789",
+			mockError:    nil,
+			wantErr:      false,
+		},
+		{
+			name:         "invalid transition - refund pending order",
+			reference:    "ORDER-123",
+			status:       string(models.OrderStatusRefundRequested),
+			pspReference: "PSP-123",
+			mockError:    nil,
+			wantErr:      true,
+		},
 		{
 			name:         "invalid status",
 			reference:    "ORDER-123",
@@ -221,20 +278,338 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 						Status:    models.OrderStatusPending,
 					}, nil
 				},
-				UpdateOrderStatusFunc: func(reference, status, pspReference string) error {
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
 					if tt.mockError != nil {
 						return tt.mockError
 					}
+					// The change is stored with the status for the order history
+					if change.Source != models.OrderEventSourceWebhook || change.Actor != "adyen" {
+						t.Errorf("Expected the webhook change to be passed on, got %+v", change)
+					}
 					return nil
 				},
 			}
 
-			service := NewOrderService(mockRepo)
-			err := service.UpdateOrderStatus(tt.reference, tt.status, tt.pspReference)
+			events := NewOrderEventBus()
+			subscription, unsubscribe := events.Subscribe(tt.reference)
+			defer unsubscribe()
+
+			service := NewOrderService(mockRepo, events)
+			err := service.UpdateOrderStatus(context.Background(), tt.reference, tt.status, tt.pspReference,
+				models.OrderChange{Source: models.OrderEventSourceWebhook, Actor: "adyen"})
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdateOrderStatus() error = %v, wantErr %v", err, tt.wantErr)
 			}
+
+			// Only a stored transition is published
+			select {
+			case event := <-subscription:
+				if tt.wantErr {
+					t.Errorf("expected no event, got %+v", event)
+				} else if string(event.Status) != tt.status || event.PreviousStatus != models.OrderStatusPending {
+					t.Errorf("expected transition from %s to %s, got %+v", models.OrderStatusPending, tt.status, event)
+				}
+			default:
+				if !tt.wantErr {
+					t.Error("expected a status event to be published")
+				}
+			}
 		})
 	}
 }
+
+func TestOrderService_UpdateOrderStatus_RetriesVersionConflict(t *testing.T) {
+	// The first read sees a pending order, then a concurrent redirect moves it to processing
+	stored := []*models.Order{
+		{Reference: "ORDER-123", Status: models.OrderStatusPending, Version: 1},
+		{Reference: "ORDER-123", Status: models.OrderStatusProcessing, PSPReference: "PSP-123", Version: 2},
+	}
+	reads := 0
+	var versions []int
+
+	mockRepo := &MockOrderRepository{
+		GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+			order := stored[reads]
+			reads++
+			return order, nil
+		},
+		UpdateOrderStatusFunc: func(reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
+			versions = append(versions, expectedVersion)
+			if expectedVersion == 1 {
+				return fmt.Errorf("%w: %s is at version 2, expected 1", models.ErrOrderVersionConflict, reference)
+			}
+			return nil
+		},
+	}
+
+	events := NewOrderEventBus()
+	subscription, unsubscribe := events.Subscribe("ORDER-123")
+	defer unsubscribe()
+
+	service := NewOrderService(mockRepo, events)
+	if err := service.UpdateOrderStatus(context.Background(), "ORDER-123", string(models.OrderStatusAuthorized), "PSP-123", models.OrderChange{}); err != nil {
+		t.Fatalf("UpdateOrderStatus() unexpected error = %v", err)
+	}
+
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Errorf("Expected updates at versions [1 2], got %v", versions)
+	}
+
+	// Only the stored transition is published, from the status the retry saw
+	event := <-subscription
+	if event.PreviousStatus != models.OrderStatusProcessing || event.Status != models.OrderStatusAuthorized {
+		t.Errorf("Expected processing -> authorized, got %+v", event)
+	}
+	select {
+	case event := <-subscription:
+		t.Errorf("Expected a single event, got %+v", event)
+	default:
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_GivesUpOnVersionConflicts(t *testing.T) {
+	attempts := 0
+	mockRepo := &MockOrderRepository{
+		GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+			return &models.Order{Reference: reference, Status: models.OrderStatusPending, Version: 1}, nil
+		},
+		UpdateOrderStatusFunc: func(reference, status, pspReference string, expectedVersion int, change models.OrderChange) error {
+			attempts++
+			return models.ErrOrderVersionConflict
+		},
+	}
+
+	service := NewOrderService(mockRepo, NewOrderEventBus())
+	err := service.UpdateOrderStatus(context.Background(), "ORDER-123", string(models.OrderStatusAuthorized), "PSP-123", models.OrderChange{})
+	if !errors.Is(err, models.ErrOrderVersionConflict) {
+		t.Errorf("Expected ErrOrderVersionConflict, got %v", err)
+	}
+	if attempts != maxStatusUpdateAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxStatusUpdateAttempts, attempts)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_ConcurrentTransitions(t *testing.T) {
+	orderRepo := repository.NewMemoryOrderRepository()
+	service := NewOrderService(orderRepo, NewOrderEventBus())
+
+	order, err := models.NewOrderFromItems([]models.OrderItem{{SKU: "widget-001", Name: "Widget", UnitPrice: 1000, Quantity: 1}}, "USD")
+	if err != nil {
+		t.Fatalf("Failed to build order: %v", err)
+	}
+	if err := orderRepo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+
+	// The webhook and the redirect both report the authorisation, only one may store it
+	statuses := []models.OrderStatus{models.OrderStatusAuthorized, models.OrderStatusAuthorized}
+	errs := make([]error, len(statuses))
+	var wg sync.WaitGroup
+	for i, status := range statuses {
+		wg.Add(1)
+		go func(i int, status models.OrderStatus) {
+			defer wg.Done()
+			errs[i] = service.UpdateOrderStatus(context.Background(), order.Reference, string(status), "PSP-123", models.OrderChange{})
+		}(i, status)
+	}
+	wg.Wait()
+
+	var winners []models.OrderStatus
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			winners = append(winners, statuses[i])
+		case !errors.Is(err, models.ErrInvalidStatusTransition):
+			t.Errorf("Expected the losing transition to be rejected, got %v", err)
+		}
+	}
+	if len(winners) != 1 {
+		t.Fatalf("Expected exactly one transition to succeed, got %v", winners)
+	}
+
+	stored, _ := orderRepo.GetOrderByReference(context.Background(), order.Reference)
+	if stored.Status != winners[0] || stored.Version != 2 {
+		t.Errorf("Expected the order to be %s at version 2, got %s at version %d", winners[0], stored.Status, stored.Version)
+	}
+	history, _ := orderRepo.GetOrderHistory(context.Background(), order.Reference)
+	if len(history) != 1 {
+		t.Errorf("Expected one history event, got %d", len(history))
+	}
+}
+
+func TestOrderService_RecordRefund(t *testing.T) {
+	ctx := context.Background()
+	refunded := func(pspReference string, amount int64) models.Refund {
+		return models.Refund{PSPReference: pspReference, Amount: amount, Success: true}
+	}
+	refused := func(pspReference string, amount int64) models.Refund {
+		return models.Refund{PSPReference: pspReference, Amount: amount}
+	}
+
+	tests := []struct {
+		name           string
+		paidStatus     models.OrderStatus
+		refunds        []models.Refund
+		expectedStatus models.OrderStatus
+		expectedAmount int64
+		wantErr        error
+	}{
+		{
+			name:           "partial refund",
+			paidStatus:     models.OrderStatusAuthorized,
+			refunds:        []models.Refund{refunded("REFUND-1", 400)},
+			expectedStatus: models.OrderStatusPartiallyRefunded,
+			expectedAmount: 400,
+		},
+		{
+			name:           "partial refunds adding up to the order amount",
+			paidStatus:     models.OrderStatusCaptured,
+			refunds:        []models.Refund{refunded("REFUND-1", 400), refunded("REFUND-2", 600)},
+			expectedStatus: models.OrderStatusRefunded,
+			expectedAmount: 1000,
+		},
+		{
+			name:           "redelivered refund is counted once",
+			paidStatus:     models.OrderStatusAuthorized,
+			refunds:        []models.Refund{refunded("REFUND-1", 400), refunded("REFUND-1", 400)},
+			expectedStatus: models.OrderStatusPartiallyRefunded,
+			expectedAmount: 400,
+		},
+		{
+			name:           "refused refund restores the captured order",
+			paidStatus:     models.OrderStatusCaptured,
+			refunds:        []models.Refund{refused("REFUND-1", 400)},
+			expectedStatus: models.OrderStatusCaptured,
+		},
+		{
+			name:           "refused second refund restores the partially refunded order",
+			paidStatus:     models.OrderStatusAuthorized,
+			refunds:        []models.Refund{refunded("REFUND-1", 400), refused("REFUND-2", 600)},
+			expectedStatus: models.OrderStatusPartiallyRefunded,
+			expectedAmount: 400,
+		},
+		{
+			name:           "refund beyond the order amount is rejected",
+			paidStatus:     models.OrderStatusAuthorized,
+			refunds:        []models.Refund{refunded("REFUND-1", 400), refunded("REFUND-2", 700)},
+			expectedStatus: models.OrderStatusRefundRequested,
+			expectedAmount: 400,
+			wantErr:        models.ErrInvalidRefundAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := repository.NewMemoryOrderRepository()
+			service := NewOrderService(orderRepo, NewOrderEventBus())
+
+			order, err := models.NewOrderFromItems([]models.OrderItem{{SKU: "widget-001", Name: "Widget", UnitPrice: 1000, Quantity: 1}}, "USD")
+			if err != nil {
+				t.Fatalf("Failed to build order: %v", err)
+			}
+			if err := orderRepo.CreateOrder(ctx, order); err != nil {
+				t.Fatalf("Failed to create order: %v", err)
+			}
+			paid := []models.OrderStatus{models.OrderStatusAuthorized}
+			if tt.paidStatus == models.OrderStatusCaptured {
+				paid = append(paid, models.OrderStatusCaptured)
+			}
+			for _, status := range paid {
+				if err := service.UpdateOrderStatus(ctx, order.Reference, string(status), "PSP-123", models.OrderChange{}); err != nil {
+					t.Fatalf("Failed to move order to %s: %v", status, err)
+				}
+			}
+
+			// Each refund is requested before Adyen reports its outcome
+			for i, refund := range tt.refunds {
+				if i == 0 || refund.PSPReference != tt.refunds[i-1].PSPReference {
+					if err := service.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusRefundRequested), "PSP-123", models.OrderChange{}); err != nil {
+						t.Fatalf("Failed to request refund: %v", err)
+					}
+				}
+				err = service.RecordRefund(ctx, order.Reference, refund, models.OrderChange{Source: models.OrderEventSourceWebhook})
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RecordRefund() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := orderRepo.GetOrderByReference(ctx, order.Reference)
+			if stored.Status != tt.expectedStatus || stored.RefundedAmount != tt.expectedAmount {
+				t.Errorf("Expected %s order with %d refunded, got %s with %d", tt.expectedStatus, tt.expectedAmount, stored.Status, stored.RefundedAmount)
+			}
+			if stored.PSPReference != "PSP-123" {
+				t.Errorf("Expected the payment PSP reference to be kept, got %s", stored.PSPReference)
+			}
+		})
+	}
+}
+
+func TestOrderService_GetOrderHistory(t *testing.T) {
+	history := []models.OrderHistoryEvent{
+		{PreviousStatus: models.OrderStatusPending, Status: models.OrderStatusAuthorized, Source: models.OrderEventSourceWebhook},
+	}
+
+	tests := []struct {
+		name      string
+		mockError error
+		wantErr   bool
+	}{
+		{
+			name: "successful retrieval",
+		},
+		{
+			name:      "order not found",
+			mockError: models.ErrOrderNotFound,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockOrderRepository{
+				GetOrderHistoryFunc: func(reference string) ([]models.OrderHistoryEvent, error) {
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return history, nil
+				},
+			}
+
+			service := NewOrderService(mockRepo, NewOrderEventBus())
+			events, err := service.GetOrderHistory(context.Background(), "ORDER-123")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOrderHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, tt.mockError) {
+					t.Errorf("Expected error wrapping %v, got %v", tt.mockError, err)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Status != models.OrderStatusAuthorized {
+				t.Errorf("Expected the repository history, got %+v", events)
+			}
+		})
+	}
+}
+
+func TestNewOrderChange(t *testing.T) {
+	change := newOrderChange(models.OrderEventSourceWebhook, "adyen", map[string]string{"eventCode": "AUTHORISATION"})
+	if change.Source != models.OrderEventSourceWebhook || change.Actor != "adyen" {
+		t.Errorf("Expected webhook change by adyen, got %+v", change)
+	}
+	if string(change.Payload) != `{"eventCode":"AUTHORISATION"}` {
+		t.Errorf("Expected JSON payload, got %s", change.Payload)
+	}
+
+	if change := newOrderChange(models.OrderEventSourceAdmin, "alice", nil); change.Payload != nil {
+		t.Errorf("Expected no payload, got %s", change.Payload)
+	}
+
+	// A payload that cannot be encoded is left out rather than failing the change
+	if change := newOrderChange(models.OrderEventSourceAdmin, "alice", make(chan int)); change.Payload != nil || change.Actor != "alice" {
+		t.Errorf("Expected the change without payload, got %+v", change)
+	}
+}
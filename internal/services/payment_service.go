@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
 	"github.com/adyen/ecommerce/internal/models"
@@ -10,23 +14,38 @@ import (
 
 // PaymentService handles payment-related business logic
 type PaymentService interface {
-	CreatePaymentSession(productName string, amount int64, currency string, returnURL string) (*PaymentSessionResult, error)
-	VerifyPayment(sessionID, sessionResult string) (*PaymentVerificationResult, error)
+	CreatePaymentSession(ctx context.Context, cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*PaymentSessionResult, error)
+	ResumePaymentSession(ctx context.Context, reference string, cart *models.Cart, shopper models.ShopperLocale) (*PaymentSessionResult, error)
+	VerifyPayment(ctx context.Context, sessionID, sessionResult string) (*PaymentVerificationResult, error)
+	RefundOrder(ctx context.Context, reference string, amount int64) (*ModificationResult, error)
+	CaptureOrder(ctx context.Context, reference string) (*ModificationResult, error)
+	CancelOrder(ctx context.Context, reference string) (*ModificationResult, error)
 }
 
+// unrecordedPayments counts payments Adyen authorised that their order could not record,
+// published on /debug/vars
+var unrecordedPayments = expvar.NewInt("unrecorded_payments")
+
+// sessionResumeMargin is how long a payment session must remain valid to be offered again,
+// so that the shopper has time to complete the payment
+const sessionResumeMargin = 10 * time.Minute
+
 // PaymentServiceImpl implements PaymentService
 type PaymentServiceImpl struct {
-	adyenClient  AdyenClient
-	orderService OrderService
-	config       *config.AdyenConfig
+	adyenClient   AdyenClient
+	orderService  OrderService
+	statusRetries StatusRetryService
+	config        *config.AdyenConfig
 }
 
-// NewPaymentService creates a new payment service
-func NewPaymentService(adyenClient AdyenClient, orderService OrderService, cfg *config.AdyenConfig) PaymentService {
+// NewPaymentService creates a new payment service, queueing order updates that fail
+// after a payment in statusRetries
+func NewPaymentService(adyenClient AdyenClient, orderService OrderService, statusRetries StatusRetryService, cfg *config.AdyenConfig) PaymentService {
 	return &PaymentServiceImpl{
-		adyenClient:  adyenClient,
-		orderService: orderService,
-		config:       cfg,
+		adyenClient:   adyenClient,
+		orderService:  orderService,
+		statusRetries: statusRetries,
+		config:        cfg,
 	}
 }
 
@@ -44,12 +63,41 @@ type PaymentVerificationResult struct {
 	ResultCode   string
 	PSPReference string
 	Status       string
+	// QueuedStatus is the status the order moves to once a queued update is stored,
+	// empty if the order is up to date
+	QueuedStatus models.OrderStatus
+}
+
+// ModificationResult represents the result of requesting a payment modification
+type ModificationResult struct {
+	Order        *models.Order
+	Amount       int64
+	PSPReference string
+	Status       string
 }
 
-// CreatePaymentSession creates a new payment session and order
-func (s *PaymentServiceImpl) CreatePaymentSession(productName string, amount int64, currency string, returnURL string) (*PaymentSessionResult, error) {
+// CreatePaymentSession creates a new payment session and an order for the items in the cart,
+// offering the payment methods configured for the shopper's country and the products, in the
+// currency configured for the country
+func (s *PaymentServiceImpl) CreatePaymentSession(ctx context.Context, cart *models.Cart, shopper models.ShopperLocale, returnURL string) (*PaymentSessionResult, error) {
+	items, err := cart.OrderItems()
+	if err != nil {
+		return nil, err
+	}
+	shopper = withDefaultLocale(shopper)
+
+	// Fail before creating an order that could not be paid for
+	paymentMethods, err := s.config.PaymentMethods.For(shopper.CountryCode, cart.SKUs())
+	if err != nil {
+		return nil, err
+	}
+	currency, err := s.config.Currencies.For(shopper.CountryCode, cart.Currency())
+	if err != nil {
+		return nil, err
+	}
+
 	// Create order in database
-	order, err := s.orderService.CreateOrder(productName, amount, currency)
+	order, err := s.orderService.CreateOrder(ctx, items, currency)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
@@ -60,34 +108,33 @@ func (s *PaymentServiceImpl) CreatePaymentSession(productName string, amount int
 	sessionReq := &SessionRequest{
 		MerchantAccount: s.config.MerchantAccount,
 		Amount: Amount{
-			Currency: currency,
-			Value:    amount,
+			Currency: order.Currency,
+			Value:    order.Amount,
 		},
 		Reference:             order.Reference,
 		ReturnUrl:             returnURL,
-		CountryCode:           "US",
-		ShopperLocale:         "en-US",
+		CountryCode:           shopper.CountryCode,
+		ShopperLocale:         shopper.Locale,
 		Channel:               "Web",
-		AllowedPaymentMethods: []string{"scheme"}, // Only allow credit/debit cards
-		LineItems: []LineItem{
-			{
-				Quantity:           1,
-				AmountExcludingTax: calculateAmountExcludingTax(amount, 1000), // 10% tax
-				TaxPercentage:      1000,                                      // 10%
-				Description:        productName,
-				ID:                 "widget-001",
-				TaxAmount:          calculateTaxAmount(amount, 1000),
-				AmountIncludingTax: amount,
-			},
-		},
+		AllowedPaymentMethods: paymentMethods.Allowed,
+		BlockedPaymentMethods: paymentMethods.Blocked,
+		LineItems:             buildLineItems(order.Items),
+	}
+
+	// Ask Adyen to hold the funds until the order is captured
+	if s.config.IsManualCapture() {
+		sessionReq.AdditionalData = map[string]string{"manualCapture": "true"}
 	}
 
 	// Create session with Adyen
-	sessionResp, err := s.adyenClient.CreateSession(sessionReq)
+	sessionResp, err := s.adyenClient.CreateSession(ctx, sessionReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Adyen session: %w", err)
 	}
 
+	// Checkout reloads resume the session, and the order expires with it unless it is paid
+	s.recordPaymentSession(ctx, order.Reference, sessionResp, shopper)
+
 	return &PaymentSessionResult{
 		SessionID:   sessionResp.ID,
 		SessionData: sessionResp.SessionData,
@@ -96,10 +143,64 @@ func (s *PaymentServiceImpl) CreatePaymentSession(productName string, amount int
 	}, nil
 }
 
+// recordPaymentSession stores the order's session and when it expires. Failures are only
+// logged: the session is then not resumed and the order expires after the default session lifetime.
+func (s *PaymentServiceImpl) recordPaymentSession(ctx context.Context, reference string, resp *SessionResponse, shopper models.ShopperLocale) {
+	session := models.PaymentSession{ID: resp.ID, Data: resp.SessionData, Shopper: shopper}
+	if resp.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, resp.ExpiresAt)
+		if err != nil {
+			log.Printf("Warning: invalid session expiry %q for order %s: %v", resp.ExpiresAt, reference, err)
+		}
+		session.ExpiresAt = expiresAt
+	}
+	if err := s.orderService.SetPaymentSession(ctx, reference, session); err != nil {
+		log.Printf("Warning: failed to record payment session of order %s: %v", reference, err)
+	}
+}
+
+// ResumePaymentSession returns the payment session of a pending order created earlier for
+// the same cart and shopper locale. ErrSessionNotReusable is returned if the session is about
+// to expire, or the cart or locale changed since, and a new session must be created.
+func (s *PaymentServiceImpl) ResumePaymentSession(ctx context.Context, reference string, cart *models.Cart, shopper models.ShopperLocale) (*PaymentSessionResult, error) {
+	items, err := cart.OrderItems()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orderService.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	if !order.CanResumeSession(items, cart.Currency(), withDefaultLocale(shopper), time.Now(), sessionResumeMargin) {
+		return nil, fmt.Errorf("%w: order %s", models.ErrSessionNotReusable, reference)
+	}
+
+	log.Printf("Resuming payment session of order: %s", order.Reference)
+
+	return &PaymentSessionResult{
+		SessionID:   order.SessionID,
+		SessionData: order.SessionData,
+		ClientKey:   s.config.ClientKey,
+		OrderRef:    order.Reference,
+	}, nil
+}
+
+// withDefaultLocale fills in the default country and locale if the shopper's are unknown
+func withDefaultLocale(shopper models.ShopperLocale) models.ShopperLocale {
+	if shopper.CountryCode == "" {
+		shopper.CountryCode = models.DefaultCountryCode
+	}
+	if shopper.Locale == "" {
+		shopper.Locale = models.DefaultLocale
+	}
+	return shopper
+}
+
 // VerifyPayment verifies a payment and updates the order status
-func (s *PaymentServiceImpl) VerifyPayment(sessionID, sessionResult string) (*PaymentVerificationResult, error) {
+func (s *PaymentServiceImpl) VerifyPayment(ctx context.Context, sessionID, sessionResult string) (*PaymentVerificationResult, error) {
 	// Get payment status from Adyen
-	sessionStatus, err := s.adyenClient.GetSessionStatus(sessionID, sessionResult)
+	sessionStatus, err := s.adyenClient.GetSessionStatus(ctx, sessionID, sessionResult)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session status: %w", err)
 	}
@@ -119,26 +220,213 @@ func (s *PaymentServiceImpl) VerifyPayment(sessionID, sessionResult string) (*Pa
 	log.Printf("Mapped result code '%s' to order status '%s'", resultCode, orderStatus)
 
 	// Get order from database
-	order, err := s.orderService.GetOrderByReference(sessionStatus.Reference)
+	order, err := s.orderService.GetOrderByReference(ctx, sessionStatus.Reference)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	// Update order status
-	if err := s.orderService.UpdateOrderStatus(order.Reference, string(orderStatus), pspReference); err != nil {
-		log.Printf("Warning: failed to update order status: %v", err)
-		// Continue anyway - we can still return the result
+	// Shoppers may reload the page, so an order already in the target status is left untouched
+	var queuedStatus models.OrderStatus
+	if order.Status != orderStatus {
+		change := newOrderChange(models.OrderEventSourceRedirect, "shopper", sessionStatus)
+		err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(orderStatus), pspReference, change)
+		switch {
+		case errors.Is(err, models.ErrInvalidStatusTransition):
+			// Something settled the order first, show the status it has now
+			if current, getErr := s.orderService.GetOrderByReference(ctx, order.Reference); getErr == nil {
+				order = current
+			}
+			if orderStatus == models.OrderStatusAuthorized && !order.IsPaid() {
+				reportUnrecordedPayment(order, pspReference, err)
+			} else {
+				// e.g. a webhook authorised the order before the shopper returned with Pending
+				log.Printf("Keeping order %s in status %s: %v", order.Reference, order.Status, err)
+			}
+		case err != nil:
+			// Adyen has the payment, so the update is retried in the background instead of lost
+			if queueErr := s.statusRetries.Enqueue(ctx, order.Reference, orderStatus, pspReference, change, err); queueErr != nil {
+				return nil, fmt.Errorf("failed to update order status: %w", errors.Join(err, queueErr))
+			}
+			queuedStatus = orderStatus
+		default:
+			order.Status = orderStatus
+			if pspReference != "" {
+				order.PSPReference = pspReference
+			}
+		}
 	}
 
-	// Update order object with new values
-	order.Status = orderStatus
-	order.PSPReference = pspReference
-
 	return &PaymentVerificationResult{
 		Order:        order,
 		ResultCode:   resultCode,
 		PSPReference: pspReference,
-		Status:       string(orderStatus),
+		Status:       string(order.Status),
+		QueuedStatus: queuedStatus,
+	}, nil
+}
+
+// reportUnrecordedPayment flags a payment Adyen authorised for an order that cannot record it,
+// e.g. because the order was cancelled meanwhile, so that the payment is reconciled by hand
+func reportUnrecordedPayment(order *models.Order, pspReference string, cause error) {
+	unrecordedPayments.Add(1)
+	log.Printf("Warning: payment %s was authorised for order %s in status %s, which cannot record it and needs reconciliation: %v",
+		pspReference, order.Reference, order.Status, cause)
+}
+
+// RefundOrder requests a refund for a paid order. With manual capture an authorized order has
+// not been captured yet, so it must be cancelled instead.
+// An amount of zero refunds whatever has not been refunded yet.
+func (s *PaymentServiceImpl) RefundOrder(ctx context.Context, reference string, amount int64) (*ModificationResult, error) {
+	order, err := s.orderService.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !order.CanBeRefunded() {
+		return nil, fmt.Errorf("%w: cannot refund order with status %s", models.ErrInvalidStatusTransition, order.Status)
+	}
+	if order.IsAuthorized() && s.config.IsManualCapture() {
+		return nil, fmt.Errorf("%w: the payment of order %s has not been captured, cancel it instead", models.ErrInvalidStatusTransition, order.Reference)
+	}
+
+	if amount == 0 {
+		amount = order.RefundableAmount()
+	}
+	if err := order.ValidateRefundAmount(amount); err != nil {
+		return nil, err
+	}
+
+	// Request refund from Adyen
+	refundResp, err := s.adyenClient.RefundPayment(ctx, order.PSPReference, &ModificationRequest{
+		MerchantAccount: s.config.MerchantAccount,
+		Amount: &Amount{
+			Currency: order.Currency,
+			Value:    amount,
+		},
+		Reference: order.Reference,
+		// Repeating the refund of an unchanged order, e.g. after the status update below
+		// failed, must not refund the shopper twice
+		IdempotencyKey: fmt.Sprintf("refund-%s-%d-%d", order.Reference, order.Version, amount),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request Adyen refund: %w", err)
+	}
+
+	log.Printf("Refund of %d %s requested for order %s (PSP reference %s)", amount, order.Currency, order.Reference, refundResp.PSPReference)
+
+	// The refund is confirmed asynchronously through the REFUND webhook
+	change := newOrderChange(models.OrderEventSourceAdmin, models.ActorFromContext(ctx), refundResp)
+	if err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusRefundRequested), order.PSPReference, change); err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	order.Status = models.OrderStatusRefundRequested
+
+	return &ModificationResult{
+		Order:        order,
+		Amount:       amount,
+		PSPReference: refundResp.PSPReference,
+		Status:       refundResp.Status,
+	}, nil
+}
+
+// CaptureOrder captures the full amount of an order authorized with manual capture. The order
+// waits for the CAPTURE webhook, which captures it or moves it back to authorized if Adyen
+// refuses the capture.
+func (s *PaymentServiceImpl) CaptureOrder(ctx context.Context, reference string) (*ModificationResult, error) {
+	order, err := s.orderService.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !order.IsAuthorized() {
+		return nil, fmt.Errorf("%w: cannot capture order with status %s", models.ErrInvalidStatusTransition, order.Status)
+	}
+
+	// Request capture from Adyen
+	captureResp, err := s.adyenClient.CapturePayment(ctx, order.PSPReference, &ModificationRequest{
+		MerchantAccount: s.config.MerchantAccount,
+		Amount: &Amount{
+			Currency: order.Currency,
+			Value:    order.Amount,
+		},
+		Reference: order.Reference,
+		// Repeating the capture of an unchanged order, e.g. after the status update below
+		// failed, must not capture it twice
+		IdempotencyKey: fmt.Sprintf("capture-%s-%d", order.Reference, order.Version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request Adyen capture: %w", err)
+	}
+
+	log.Printf("Capture of %d %s requested for order %s (PSP reference %s)", order.Amount, order.Currency, order.Reference, captureResp.PSPReference)
+
+	change := newOrderChange(models.OrderEventSourceAdmin, models.ActorFromContext(ctx), captureResp)
+	if err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusCaptureRequested), order.PSPReference, change); err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	order.Status = models.OrderStatusCaptureRequested
+
+	return &ModificationResult{
+		Order:        order,
+		Amount:       order.Amount,
+		PSPReference: captureResp.PSPReference,
+		Status:       captureResp.Status,
+	}, nil
+}
+
+// CancelOrder cancels an order. Without a submitted payment the order is cancelled at once,
+// otherwise the payment is cancelled at Adyen and the order waits for the CANCELLATION webhook
+// to confirm it. Failed and cancelled orders are left as they are.
+func (s *PaymentServiceImpl) CancelOrder(ctx context.Context, reference string) (*ModificationResult, error) {
+	order, err := s.orderService.GetOrderByReference(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	switch {
+	case order.IsFailed() || order.IsCancelled() || order.IsCancelRequested():
+		// Nothing is left to cancel, and cancelling again must not add to the order history
+		return &ModificationResult{Order: order}, nil
+	case !order.CanBeCancelled():
+		return nil, fmt.Errorf("%w: cannot cancel order with status %s", models.ErrInvalidStatusTransition, order.Status)
+	case order.IsAuthorized() && !s.config.IsManualCapture():
+		// Adyen captures the payment on authorisation, so it can only be refunded
+		return nil, fmt.Errorf("%w: the payment of order %s is already captured, refund it instead", models.ErrInvalidStatusTransition, order.Reference)
+	}
+
+	if order.PSPReference == "" {
+		change := newOrderChange(models.OrderEventSourceAdmin, models.ActorFromContext(ctx), nil)
+		if err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusCancelled), "", change); err != nil {
+			return nil, fmt.Errorf("failed to update order status: %w", err)
+		}
+		order.Status = models.OrderStatusCancelled
+		return &ModificationResult{Order: order}, nil
+	}
+
+	// A submitted payment holds funds at Adyen, or may still do so once a processing payment
+	// is authorised. Adyen refuses the cancellation if the payment was captured meanwhile.
+	cancelResp, err := s.adyenClient.CancelPayment(ctx, order.PSPReference, &ModificationRequest{
+		MerchantAccount: s.config.MerchantAccount,
+		Reference:       order.Reference,
+		IdempotencyKey:  fmt.Sprintf("cancel-%s-%d", order.Reference, order.Version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request Adyen cancellation: %w", err)
+	}
+
+	log.Printf("Cancellation requested for order %s (PSP reference %s)", order.Reference, cancelResp.PSPReference)
+
+	change := newOrderChange(models.OrderEventSourceAdmin, models.ActorFromContext(ctx), cancelResp)
+	if err := s.orderService.UpdateOrderStatus(ctx, order.Reference, string(models.OrderStatusCancelRequested), order.PSPReference, change); err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	order.Status = models.OrderStatusCancelRequested
+
+	return &ModificationResult{
+		Order:        order,
+		Amount:       order.Amount,
+		PSPReference: cancelResp.PSPReference,
+		Status:       cancelResp.Status,
 	}, nil
 }
 
@@ -151,11 +439,31 @@ func mapResultCodeToStatus(resultCode string) models.OrderStatus {
 		return models.OrderStatusFailed
 	case "Cancelled":
 		return models.OrderStatusCancelled
+	case "Pending", "Received", "PresentToShopper", "IdentifyShopper", "ChallengeShopper", "RedirectShopper":
+		// The outcome arrives later through the AUTHORISATION webhook
+		return models.OrderStatusProcessing
 	default:
 		return models.OrderStatusPending
 	}
 }
 
+// buildLineItems converts order items into Adyen line items with per-unit amounts
+func buildLineItems(items []models.OrderItem) []LineItem {
+	lineItems := make([]LineItem, 0, len(items))
+	for _, item := range items {
+		lineItems = append(lineItems, LineItem{
+			Quantity:           int64(item.Quantity),
+			AmountExcludingTax: calculateAmountExcludingTax(item.UnitPrice, 1000), // 10% tax
+			TaxPercentage:      1000,                                              // 10%
+			Description:        item.Name,
+			ID:                 item.SKU,
+			TaxAmount:          calculateTaxAmount(item.UnitPrice, 1000),
+			AmountIncludingTax: item.UnitPrice,
+		})
+	}
+	return lineItems
+}
+
 // calculateAmountExcludingTax calculates the amount excluding tax
 func calculateAmountExcludingTax(amountIncludingTax int64, taxPercentage int64) int64 {
 	// taxPercentage is in basis points (1000 = 10%)
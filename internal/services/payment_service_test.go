@@ -1,8 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/adyen/ecommerce/internal/config"
 	"github.com/adyen/ecommerce/internal/models"
@@ -12,9 +17,12 @@ import (
 type MockAdyenClient struct {
 	CreateSessionFunc    func(*SessionRequest) (*SessionResponse, error)
 	GetSessionStatusFunc func(string, string) (*SessionStatusResponse, error)
+	RefundPaymentFunc    func(string, *ModificationRequest) (*ModificationResponse, error)
+	CapturePaymentFunc   func(string, *ModificationRequest) (*ModificationResponse, error)
+	CancelPaymentFunc    func(string, *ModificationRequest) (*ModificationResponse, error)
 }
 
-func (m *MockAdyenClient) CreateSession(req *SessionRequest) (*SessionResponse, error) {
+func (m *MockAdyenClient) CreateSession(ctx context.Context, req *SessionRequest) (*SessionResponse, error) {
 	if m.CreateSessionFunc != nil {
 		return m.CreateSessionFunc(req)
 	}
@@ -25,7 +33,7 @@ func (m *MockAdyenClient) CreateSession(req *SessionRequest) (*SessionResponse,
 	}, nil
 }
 
-func (m *MockAdyenClient) GetSessionStatus(sessionID, sessionResult string) (*SessionStatusResponse, error) {
+func (m *MockAdyenClient) GetSessionStatus(ctx context.Context, sessionID, sessionResult string) (*SessionStatusResponse, error) {
 	if m.GetSessionStatusFunc != nil {
 		return m.GetSessionStatusFunc(sessionID, sessionResult)
 	}
@@ -45,27 +53,63 @@ func (m *MockAdyenClient) GetSessionStatus(sessionID, sessionResult string) (*Se
 	}, nil
 }
 
+func (m *MockAdyenClient) RefundPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	if m.RefundPaymentFunc != nil {
+		return m.RefundPaymentFunc(pspReference, req)
+	}
+	return &ModificationResponse{
+		PaymentPSPReference: pspReference,
+		PSPReference:        "REFUND-PSP-123",
+		Reference:           req.Reference,
+		Status:              "received",
+		Amount:              req.Amount,
+	}, nil
+}
+
+func (m *MockAdyenClient) CapturePayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	if m.CapturePaymentFunc != nil {
+		return m.CapturePaymentFunc(pspReference, req)
+	}
+	return &ModificationResponse{
+		PaymentPSPReference: pspReference,
+		PSPReference:        "CAPTURE-PSP-123",
+		Reference:           req.Reference,
+		Status:              "received",
+		Amount:              req.Amount,
+	}, nil
+}
+
+func (m *MockAdyenClient) CancelPayment(ctx context.Context, pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+	if m.CancelPaymentFunc != nil {
+		return m.CancelPaymentFunc(pspReference, req)
+	}
+	return &ModificationResponse{
+		PaymentPSPReference: pspReference,
+		PSPReference:        "CANCEL-PSP-123",
+		Reference:           req.Reference,
+		Status:              "received",
+	}, nil
+}
+
 // MockOrderService is a mock implementation of OrderService for testing
 type MockOrderService struct {
-	CreateOrderFunc         func(string, int64, string) (*models.Order, error)
+	CreateOrderFunc         func([]models.OrderItem, string) (*models.Order, error)
 	GetOrderByReferenceFunc func(string) (*models.Order, error)
-	UpdateOrderStatusFunc   func(string, string, string) error
+	UpdateOrderStatusFunc   func(string, string, string, models.OrderChange) error
+	RecordRefundFunc        func(string, models.Refund, models.OrderChange) error
+	RejectRequestFunc       func(string, models.OrderStatus, models.OrderChange) error
+	GetOrderHistoryFunc     func(string) ([]models.OrderHistoryEvent, error)
+	SetPaymentSessionFunc   func(string, models.PaymentSession) error
 }
 
-func (m *MockOrderService) CreateOrder(productName string, amount int64, currency string) (*models.Order, error) {
+func (m *MockOrderService) CreateOrder(ctx context.Context, items []models.OrderItem, currency string) (*models.Order, error) {
 	if m.CreateOrderFunc != nil {
-		return m.CreateOrderFunc(productName, amount, currency)
+		return m.CreateOrderFunc(items, currency)
 	}
-	return &models.Order{
-		Reference:   "ORDER-123",
-		Amount:      amount,
-		Currency:    currency,
-		ProductName: productName,
-		Status:      models.OrderStatusPending,
-	}, nil
+	return models.NewOrderFromItems(items, currency)
 }
 
-func (m *MockOrderService) GetOrderByReference(reference string) (*models.Order, error) {
+func (m *MockOrderService) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
 	if m.GetOrderByReferenceFunc != nil {
 		return m.GetOrderByReferenceFunc(reference)
 	}
@@ -75,51 +119,111 @@ func (m *MockOrderService) GetOrderByReference(reference string) (*models.Order,
 	}, nil
 }
 
-func (m *MockOrderService) UpdateOrderStatus(reference, status, pspReference string) error {
+func (m *MockOrderService) UpdateOrderStatus(ctx context.Context, reference, status, pspReference string, change models.OrderChange) error {
 	if m.UpdateOrderStatusFunc != nil {
-		return m.UpdateOrderStatusFunc(reference, status, pspReference)
+		return m.UpdateOrderStatusFunc(reference, status, pspReference, change)
+	}
+	return nil
+}
+
+func (m *MockOrderService) RecordRefund(ctx context.Context, reference string, refund models.Refund, change models.OrderChange) error {
+	if m.RecordRefundFunc != nil {
+		return m.RecordRefundFunc(reference, refund, change)
+	}
+	return nil
+}
+
+func (m *MockOrderService) RejectRequest(ctx context.Context, reference string, requested models.OrderStatus, change models.OrderChange) error {
+	if m.RejectRequestFunc != nil {
+		return m.RejectRequestFunc(reference, requested, change)
+	}
+	return nil
+}
+
+func (m *MockOrderService) GetOrderHistory(ctx context.Context, reference string) ([]models.OrderHistoryEvent, error) {
+	if m.GetOrderHistoryFunc != nil {
+		return m.GetOrderHistoryFunc(reference)
+	}
+	return nil, nil
+}
+
+func (m *MockOrderService) SetPaymentSession(ctx context.Context, reference string, session models.PaymentSession) error {
+	if m.SetPaymentSessionFunc != nil {
+		return m.SetPaymentSessionFunc(reference, session)
 	}
 	return nil
 }
 
+// testShopper is the shopper country and locale payment sessions are created for in tests
+var testShopper = models.ShopperLocale{CountryCode: "NL", Locale: "nl-NL"}
+
 func TestPaymentService_CreatePaymentSession(t *testing.T) {
+	widget := &models.Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}
+	gadget := &models.Product{SKU: "gadget-001", Name: "Deluxe Gadget", Price: 2200, Currency: "USD"}
+	euroProduct := &models.Product{SKU: "euro-001", Name: "Euro Product", Price: 2500, Currency: "EUR"}
+
 	tests := []struct {
-		name         string
-		productName  string
-		amount       int64
-		currency     string
-		returnURL    string
-		orderError   error
-		sessionError error
-		wantErr      bool
+		name             string
+		cart             *models.Cart
+		returnURL        string
+		orderError       error
+		sessionError     error
+		wantErr          bool
+		expectedAmount   int64
+		expectedCurrency string
+		expectedLines    []LineItem
 	}{
 		{
-			name:         "successful session creation",
-			productName:  "Test Product",
-			amount:       100,
-			currency:     "USD",
-			returnURL:    "http://localhost:8080/confirmation",
-			orderError:   nil,
-			sessionError: nil,
-			wantErr:      false,
+			name:             "successful session creation",
+			cart:             &models.Cart{Items: []models.CartItem{{Product: widget, Quantity: 1}}},
+			returnURL:        "http://localhost:8080/confirmation",
+			expectedAmount:   100,
+			expectedCurrency: "USD",
+			expectedLines: []LineItem{
+				{Quantity: 1, AmountExcludingTax: 90, TaxPercentage: 1000, Description: "Premium Widget", ID: "widget-001", TaxAmount: 10, AmountIncludingTax: 100},
+			},
 		},
 		{
-			name:         "order creation fails",
-			productName:  "Test Product",
-			amount:       100,
-			currency:     "USD",
-			returnURL:    "http://localhost:8080/confirmation",
-			orderError:   errors.New("database error"),
-			sessionError: nil,
-			wantErr:      true,
+			name: "multiple cart lines",
+			cart: &models.Cart{Items: []models.CartItem{
+				{Product: widget, Quantity: 3},
+				{Product: gadget, Quantity: 1},
+			}},
+			returnURL:        "http://localhost:8080/confirmation",
+			expectedAmount:   2500,
+			expectedCurrency: "USD",
+			expectedLines: []LineItem{
+				{Quantity: 3, AmountExcludingTax: 90, TaxPercentage: 1000, Description: "Premium Widget", ID: "widget-001", TaxAmount: 10, AmountIncludingTax: 100},
+				{Quantity: 1, AmountExcludingTax: 2000, TaxPercentage: 1000, Description: "Deluxe Gadget", ID: "gadget-001", TaxAmount: 200, AmountIncludingTax: 2200},
+			},
+		},
+		{
+			name:             "product priced in euros",
+			cart:             &models.Cart{Items: []models.CartItem{{Product: euroProduct, Quantity: 1}}},
+			returnURL:        "http://localhost:8080/confirmation",
+			expectedAmount:   2500,
+			expectedCurrency: "EUR",
+			expectedLines: []LineItem{
+				{Quantity: 1, AmountExcludingTax: 2272, TaxPercentage: 1000, Description: "Euro Product", ID: "euro-001", TaxAmount: 228, AmountIncludingTax: 2500},
+			},
+		},
+		{
+			name:      "empty cart",
+			cart:      &models.Cart{},
+			returnURL: "http://localhost:8080/confirmation",
+			wantErr:   true,
+		},
+		{
+			name:       "order creation fails",
+			cart:       &models.Cart{Items: []models.CartItem{{Product: widget, Quantity: 1}}},
+			returnURL:  "http://localhost:8080/confirmation",
+			orderError: errors.New("database error"),
+			wantErr:    true,
 		},
 		{
 			name:         "Adyen session creation fails",
-			productName:  "Test Product",
-			amount:       100,
-			currency:     "USD",
+			cart:         &models.Cart{Items: []models.CartItem{{Product: widget, Quantity: 1}}},
 			returnURL:    "http://localhost:8080/confirmation",
-			orderError:   nil,
 			sessionError: errors.New("API error"),
 			wantErr:      true,
 		},
@@ -133,15 +237,21 @@ func TestPaymentService_CreatePaymentSession(t *testing.T) {
 						return nil, tt.sessionError
 					}
 					// Verify request fields
-					if req.Amount.Value != tt.amount {
-						t.Errorf("Expected amount %d, got %d", tt.amount, req.Amount.Value)
+					if req.Amount.Value != tt.expectedAmount {
+						t.Errorf("Expected amount %d, got %d", tt.expectedAmount, req.Amount.Value)
 					}
-					if req.Amount.Currency != tt.currency {
-						t.Errorf("Expected currency %s, got %s", tt.currency, req.Amount.Currency)
+					if req.Amount.Currency != tt.expectedCurrency {
+						t.Errorf("Expected currency %s, got %s", tt.expectedCurrency, req.Amount.Currency)
 					}
 					if req.ReturnUrl != tt.returnURL {
 						t.Errorf("Expected return URL %s, got %s", tt.returnURL, req.ReturnUrl)
 					}
+					if !reflect.DeepEqual(req.LineItems, tt.expectedLines) {
+						t.Errorf("Expected line items %+v, got %+v", tt.expectedLines, req.LineItems)
+					}
+					if req.CountryCode != testShopper.CountryCode || req.ShopperLocale != testShopper.Locale {
+						t.Errorf("Expected shopper %+v, got %s %s", testShopper, req.CountryCode, req.ShopperLocale)
+					}
 					return &SessionResponse{
 						ID:          "session-123",
 						SessionData: "test-data",
@@ -150,17 +260,11 @@ func TestPaymentService_CreatePaymentSession(t *testing.T) {
 			}
 
 			mockOrder := &MockOrderService{
-				CreateOrderFunc: func(productName string, amount int64, currency string) (*models.Order, error) {
+				CreateOrderFunc: func(items []models.OrderItem, currency string) (*models.Order, error) {
 					if tt.orderError != nil {
 						return nil, tt.orderError
 					}
-					return &models.Order{
-						Reference:   "ORDER-123",
-						Amount:      amount,
-						Currency:    currency,
-						ProductName: productName,
-						Status:      models.OrderStatusPending,
-					}, nil
+					return models.NewOrderFromItems(items, currency)
 				},
 			}
 
@@ -169,8 +273,8 @@ func TestPaymentService_CreatePaymentSession(t *testing.T) {
 				ClientKey:       "test-client-key",
 			}
 
-			service := NewPaymentService(mockAdyen, mockOrder, cfg)
-			result, err := service.CreatePaymentSession(tt.productName, tt.amount, tt.currency, tt.returnURL)
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, cfg)
+			result, err := service.CreatePaymentSession(context.Background(), tt.cart, testShopper, tt.returnURL)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreatePaymentSession() error = %v, wantErr %v", err, tt.wantErr)
@@ -193,6 +297,165 @@ func TestPaymentService_CreatePaymentSession(t *testing.T) {
 	}
 }
 
+func TestPaymentService_CreatePaymentSession_RecordsPaymentSession(t *testing.T) {
+	widget := &models.Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}
+	expiresAt := time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		sessionExpiry  string
+		recordError    error
+		expectedExpiry time.Time
+	}{
+		{name: "session with expiry", sessionExpiry: "2026-02-03T12:00:00Z", expectedExpiry: expiresAt},
+		{name: "session without expiry", sessionExpiry: ""},
+		{name: "invalid session expiry", sessionExpiry: "tomorrow"},
+		{name: "recording fails", sessionExpiry: "2026-02-03T12:00:00Z", recordError: errors.New("database error"), expectedExpiry: expiresAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CreateSessionFunc: func(req *SessionRequest) (*SessionResponse, error) {
+					return &SessionResponse{ID: "session-123", SessionData: "test-data", ExpiresAt: tt.sessionExpiry}, nil
+				},
+			}
+
+			recorded := false
+			mockOrder := &MockOrderService{
+				SetPaymentSessionFunc: func(reference string, session models.PaymentSession) error {
+					recorded = true
+					if session.ID != "session-123" || session.Data != "test-data" {
+						t.Errorf("Expected session-123 with its data, got %s %s", session.ID, session.Data)
+					}
+					if !session.ExpiresAt.Equal(tt.expectedExpiry) {
+						t.Errorf("Expected session expiry %v, got %v", tt.expectedExpiry, session.ExpiresAt)
+					}
+					if session.Shopper != testShopper {
+						t.Errorf("Expected session for %+v, got %+v", testShopper, session.Shopper)
+					}
+					return tt.recordError
+				},
+			}
+
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, &config.AdyenConfig{MerchantAccount: "TestMerchant"})
+			cart := &models.Cart{Items: []models.CartItem{{Product: widget, Quantity: 1}}}
+
+			// The session is usable even if it cannot be recorded
+			if _, err := service.CreatePaymentSession(context.Background(), cart, testShopper, "http://localhost:8080/confirmation"); err != nil {
+				t.Fatalf("CreatePaymentSession() unexpected error = %v", err)
+			}
+			if !recorded {
+				t.Error("Expected the payment session to be recorded")
+			}
+		})
+	}
+}
+
+func TestPaymentService_ResumePaymentSession(t *testing.T) {
+	widget := &models.Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}
+	cart := &models.Cart{Items: []models.CartItem{{Product: widget, Quantity: 2}}}
+	items, _ := cart.OrderItems()
+
+	tests := []struct {
+		name          string
+		order         *models.Order
+		orderError    error
+		wantErr       error
+		expectSession bool
+	}{
+		{
+			name: "session of the same cart is resumed",
+			order: &models.Order{
+				Reference: "ORDER-123", Status: models.OrderStatusPending, Currency: "USD", Items: items,
+				SessionID: "session-123", SessionData: "test-data", SessionExpiresAt: time.Now().Add(time.Hour), SessionShopper: testShopper,
+			},
+			expectSession: true,
+		},
+		{
+			name: "shopper picked another locale",
+			order: &models.Order{
+				Reference: "ORDER-123", Status: models.OrderStatusPending, Currency: "USD", Items: items,
+				SessionID: "session-123", SessionData: "test-data", SessionExpiresAt: time.Now().Add(time.Hour),
+				SessionShopper: models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+			},
+			wantErr: models.ErrSessionNotReusable,
+		},
+		{
+			name: "session about to expire",
+			order: &models.Order{
+				Reference: "ORDER-123", Status: models.OrderStatusPending, Currency: "USD", Items: items,
+				SessionID: "session-123", SessionData: "test-data", SessionExpiresAt: time.Now().Add(time.Minute),
+			},
+			wantErr: models.ErrSessionNotReusable,
+		},
+		{
+			name: "cart changed",
+			order: &models.Order{
+				Reference: "ORDER-123", Status: models.OrderStatusPending, Currency: "USD",
+				Items:     []models.OrderItem{{SKU: "widget-001", Name: "Premium Widget", UnitPrice: 100, Quantity: 1}},
+				SessionID: "session-123", SessionData: "test-data", SessionExpiresAt: time.Now().Add(time.Hour),
+			},
+			wantErr: models.ErrSessionNotReusable,
+		},
+		{
+			name: "order already paid",
+			order: &models.Order{
+				Reference: "ORDER-123", Status: models.OrderStatusAuthorized, Currency: "USD", Items: items,
+				SessionID: "session-123", SessionData: "test-data", SessionExpiresAt: time.Now().Add(time.Hour),
+			},
+			wantErr: models.ErrSessionNotReusable,
+		},
+		{
+			name:       "order not found",
+			orderError: models.ErrOrderNotFound,
+			wantErr:    models.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CreateSessionFunc: func(req *SessionRequest) (*SessionResponse, error) {
+					t.Error("Expected no new Adyen session")
+					return nil, errors.New("unexpected call")
+				},
+			}
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					return tt.order, tt.orderError
+				},
+				CreateOrderFunc: func(items []models.OrderItem, currency string) (*models.Order, error) {
+					t.Error("Expected no new order")
+					return nil, errors.New("unexpected call")
+				},
+			}
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, &config.AdyenConfig{ClientKey: "test-client-key"})
+
+			result, err := service.ResumePaymentSession(context.Background(), "ORDER-123", cart, testShopper)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResumePaymentSession() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.expectSession {
+				if result.SessionID != "session-123" || result.SessionData != "test-data" || result.OrderRef != "ORDER-123" || result.ClientKey != "test-client-key" {
+					t.Errorf("Expected the stored session of ORDER-123, got %+v", result)
+				}
+			}
+		})
+	}
+}
+
+// sessionStatusWithResult returns a completed session for ORDER-123 with a single payment
+func sessionStatusWithResult(resultCode, pspReference string) *SessionStatusResponse {
+	status := &SessionStatusResponse{ID: "session-123", Status: "completed", Reference: "ORDER-123"}
+	status.Payments = append(status.Payments, struct {
+		ResultCode   string `json:"resultCode"`
+		PSPReference string `json:"pspReference"`
+	}{ResultCode: resultCode, PSPReference: pspReference})
+	return status
+}
+
 func TestPaymentService_VerifyPayment(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -202,8 +465,14 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 		sessionError   error
 		orderError     error
 		updateError    error
+		queueError     error
+		currentStatus  models.OrderStatus
 		wantErr        bool
 		expectedStatus string
+		expectedQueued models.OrderStatus
+		expectUpdate   bool
+		// expectUnrecorded is set if the payment must be reported for reconciliation
+		expectUnrecorded bool
 	}{
 		{
 			name:          "successful authorization",
@@ -228,6 +497,7 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 			updateError:    nil,
 			wantErr:        false,
 			expectedStatus: string(models.OrderStatusAuthorized),
+			expectUpdate:   true,
 		},
 		{
 			name:          "payment refused",
@@ -252,6 +522,90 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 			updateError:    nil,
 			wantErr:        false,
 			expectedStatus: string(models.OrderStatusFailed),
+			expectUpdate:   true,
+		},
+		{
+			name:           "pending payment is processing",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Pending", "PSP-789"),
+			expectedStatus: string(models.OrderStatusProcessing),
+			expectUpdate:   true,
+		},
+		{
+			name:           "received payment is processing",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Received", "PSP-789"),
+			expectedStatus: string(models.OrderStatusProcessing),
+			expectUpdate:   true,
+		},
+		{
+			name:           "reloading a processing order",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Pending", "PSP-789"),
+			currentStatus:  models.OrderStatusProcessing,
+			expectedStatus: string(models.OrderStatusProcessing),
+		},
+		{
+			name:           "webhook authorized the order first",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Pending", "PSP-789"),
+			currentStatus:  models.OrderStatusAuthorized,
+			updateError:    fmt.Errorf("%w: cannot mark order with status authorized as processing", models.ErrInvalidStatusTransition),
+			expectedStatus: string(models.OrderStatusAuthorized),
+			expectUpdate:   true,
+		},
+		{
+			name:           "authorised retry after a refused attempt",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Authorised", "PSP-123"),
+			currentStatus:  models.OrderStatusFailed,
+			expectedStatus: string(models.OrderStatusAuthorized),
+			expectUpdate:   true,
+		},
+		{
+			name:             "authorised payment of a cancelled order is reported",
+			sessionID:        "session-123",
+			sessionResult:    "result-123",
+			sessionStatus:    sessionStatusWithResult("Authorised", "PSP-123"),
+			currentStatus:    models.OrderStatusCancelled,
+			updateError:      fmt.Errorf("%w: cannot authorize order with status cancelled", models.ErrInvalidStatusTransition),
+			expectedStatus:   string(models.OrderStatusCancelled),
+			expectUpdate:     true,
+			expectUnrecorded: true,
+		},
+		{
+			name:           "order update fails and is queued",
+			sessionID:      "session-123",
+			sessionResult:  "result-123",
+			sessionStatus:  sessionStatusWithResult("Authorised", "PSP-123"),
+			updateError:    errors.New("database unavailable"),
+			expectedStatus: string(models.OrderStatusPending),
+			expectedQueued: models.OrderStatusAuthorized,
+			expectUpdate:   true,
+		},
+		{
+			name:          "order update fails and cannot be queued",
+			sessionID:     "session-123",
+			sessionResult: "result-123",
+			sessionStatus: sessionStatusWithResult("Authorised", "PSP-123"),
+			updateError:   errors.New("database unavailable"),
+			queueError:    errors.New("database unavailable"),
+			wantErr:       true,
+			expectUpdate:  true,
+		},
+		{
+			// The queue lives in the same database, the webhook has to deliver the payment
+			name:          "database down is not queued",
+			sessionID:     "session-123",
+			sessionResult: "result-123",
+			sessionStatus: sessionStatusWithResult("Authorised", "PSP-123"),
+			orderError:    errors.New("database unavailable"),
+			wantErr:       true,
 		},
 		{
 			name:           "session status error",
@@ -288,6 +642,7 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			var updated bool
 			mockAdyen := &MockAdyenClient{
 				GetSessionStatusFunc: func(sessionID, sessionResult string) (*SessionStatusResponse, error) {
 					if tt.sessionError != nil {
@@ -302,12 +657,21 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 					if tt.orderError != nil {
 						return nil, tt.orderError
 					}
+					status := tt.currentStatus
+					if status == "" {
+						status = models.OrderStatusPending
+					}
 					return &models.Order{
 						Reference: reference,
-						Status:    models.OrderStatusPending,
+						Status:    status,
 					}, nil
 				},
-				UpdateOrderStatusFunc: func(reference, status, pspReference string) error {
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updated = true
+					// The shopper's return is kept in the order history with Adyen's session status
+					if change.Source != models.OrderEventSourceRedirect || !strings.Contains(string(change.Payload), tt.sessionStatus.ID) {
+						t.Errorf("Expected the redirect change with the session status, got %+v", change)
+					}
 					if tt.updateError != nil {
 						return tt.updateError
 					}
@@ -315,12 +679,36 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 				},
 			}
 
+			var queued *models.StatusUpdateRetry
+			mockRetries := &MockStatusRetryService{
+				EnqueueFunc: func(reference string, status models.OrderStatus, pspReference string, change models.OrderChange, cause error) error {
+					queued = &models.StatusUpdateRetry{OrderReference: reference, Status: status, PSPReference: pspReference, Change: change}
+					return tt.queueError
+				},
+			}
+
 			cfg := &config.AdyenConfig{
 				MerchantAccount: "TestMerchant",
 			}
 
-			service := NewPaymentService(mockAdyen, mockOrder, cfg)
-			result, err := service.VerifyPayment(tt.sessionID, tt.sessionResult)
+			service := NewPaymentService(mockAdyen, mockOrder, mockRetries, cfg)
+			unrecordedBefore := unrecordedPayments.Value()
+			result, err := service.VerifyPayment(context.Background(), tt.sessionID, tt.sessionResult)
+
+			if unrecorded := unrecordedPayments.Value() > unrecordedBefore; unrecorded != tt.expectUnrecorded {
+				t.Errorf("Expected payment reported for reconciliation %v, got %v", tt.expectUnrecorded, unrecorded)
+			}
+
+			// Only updates that failed for another reason than the order's status are queued
+			if expectQueue := tt.expectedQueued != "" || tt.queueError != nil; (queued != nil) != expectQueue {
+				t.Errorf("Expected queued update %v, got %+v", expectQueue, queued)
+			} else if queued != nil && (queued.Status != models.OrderStatusAuthorized || queued.PSPReference != "PSP-123" || queued.Change.Source != models.OrderEventSourceRedirect) {
+				t.Errorf("Expected the authorization to be queued with the redirect change, got %+v", queued)
+			}
+
+			if updated != tt.expectUpdate {
+				t.Errorf("Expected order update %v, got %v", tt.expectUpdate, updated)
+			}
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("VerifyPayment() error = %v, wantErr %v", err, tt.wantErr)
@@ -335,6 +723,9 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 				if result.Status != tt.expectedStatus {
 					t.Errorf("Expected status '%s', got '%s'", tt.expectedStatus, result.Status)
 				}
+				if result.QueuedStatus != tt.expectedQueued {
+					t.Errorf("Expected queued status '%s', got '%s'", tt.expectedQueued, result.QueuedStatus)
+				}
 				if result.Order == nil {
 					t.Error("Expected order in result, got nil")
 				}
@@ -343,6 +734,431 @@ func TestPaymentService_VerifyPayment(t *testing.T) {
 	}
 }
 
+func TestPaymentService_RefundOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		orderStatus    models.OrderStatus
+		captureMode    string
+		refundedAmount int64
+		amount         int64
+		refundError    error
+		updateError    error
+		wantErr        bool
+		expectedAmount int64
+	}{
+		{
+			name:           "full refund of authorized order",
+			orderStatus:    models.OrderStatusAuthorized,
+			amount:         0,
+			expectedAmount: 1000,
+		},
+		{
+			name:           "partial refund of authorized order",
+			orderStatus:    models.OrderStatusAuthorized,
+			amount:         400,
+			expectedAmount: 400,
+		},
+		{
+			name:           "full refund of captured order with manual capture",
+			orderStatus:    models.OrderStatusCaptured,
+			captureMode:    config.CaptureModeManual,
+			expectedAmount: 1000,
+		},
+		{
+			name:        "uncaptured order with manual capture must be cancelled instead",
+			orderStatus: models.OrderStatusAuthorized,
+			captureMode: config.CaptureModeManual,
+			wantErr:     true,
+		},
+		{
+			name:        "order waiting for its capture cannot be refunded",
+			orderStatus: models.OrderStatusCaptureRequested,
+			captureMode: config.CaptureModeManual,
+			wantErr:     true,
+		},
+		{
+			name:           "further refund of partially refunded order",
+			orderStatus:    models.OrderStatusPartiallyRefunded,
+			refundedAmount: 400,
+			amount:         200,
+			expectedAmount: 200,
+		},
+		{
+			name:           "full refund of partially refunded order refunds the rest",
+			orderStatus:    models.OrderStatusPartiallyRefunded,
+			refundedAmount: 400,
+			expectedAmount: 600,
+		},
+		{
+			name:           "amount exceeds the rest of a partially refunded order",
+			orderStatus:    models.OrderStatusPartiallyRefunded,
+			refundedAmount: 400,
+			amount:         700,
+			wantErr:        true,
+		},
+		{
+			name:        "pending order cannot be refunded",
+			orderStatus: models.OrderStatusPending,
+			wantErr:     true,
+		},
+		{
+			name:        "amount exceeds order amount",
+			orderStatus: models.OrderStatusAuthorized,
+			amount:      5000,
+			wantErr:     true,
+		},
+		{
+			name:        "Adyen refund fails",
+			orderStatus: models.OrderStatusAuthorized,
+			refundError: errors.New("API error"),
+			wantErr:     true,
+		},
+		{
+			name:           "order update fails",
+			orderStatus:    models.OrderStatusAuthorized,
+			updateError:    errors.New("database error"),
+			wantErr:        true,
+			expectedAmount: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refundCalled := false
+			mockAdyen := &MockAdyenClient{
+				RefundPaymentFunc: func(pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+					refundCalled = true
+					if tt.refundError != nil {
+						return nil, tt.refundError
+					}
+					if pspReference != "PSP-123" {
+						t.Errorf("Expected PSP reference 'PSP-123', got '%s'", pspReference)
+					}
+					if req.Amount == nil || req.Amount.Value != tt.expectedAmount || req.Amount.Currency != "USD" {
+						t.Errorf("Unexpected refund amount %+v", req.Amount)
+					}
+					if expected := fmt.Sprintf("refund-ORDER-123-3-%d", tt.expectedAmount); req.IdempotencyKey != expected {
+						t.Errorf("Expected idempotency key '%s', got '%s'", expected, req.IdempotencyKey)
+					}
+					return &ModificationResponse{PSPReference: "REFUND-PSP-123", Status: "received"}, nil
+				},
+			}
+
+			var updatedStatus string
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					return &models.Order{
+						Reference:      reference,
+						Amount:         1000,
+						RefundedAmount: tt.refundedAmount,
+						Currency:       "USD",
+						Status:         tt.orderStatus,
+						PSPReference:   "PSP-123",
+						Version:        3,
+					}, nil
+				},
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updatedStatus = status
+					// The operator and Adyen's response are kept in the order history
+					if change.Source != models.OrderEventSourceAdmin || change.Actor != "alice" || !strings.Contains(string(change.Payload), "REFUND-PSP-123") {
+						t.Errorf("Expected the admin change by alice, got %+v", change)
+					}
+					return tt.updateError
+				},
+			}
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", CaptureMode: tt.captureMode}
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, cfg)
+			result, err := service.RefundOrder(models.WithActor(context.Background(), "alice"), "ORDER-123", tt.amount)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RefundOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if tt.refundError == nil && tt.updateError == nil && refundCalled {
+					t.Error("Expected Adyen refund not to be requested")
+				}
+				return
+			}
+
+			if result.Amount != tt.expectedAmount {
+				t.Errorf("Expected refund amount %d, got %d", tt.expectedAmount, result.Amount)
+			}
+			if result.PSPReference != "REFUND-PSP-123" {
+				t.Errorf("Expected refund PSP reference 'REFUND-PSP-123', got '%s'", result.PSPReference)
+			}
+			if updatedStatus != string(models.OrderStatusRefundRequested) {
+				t.Errorf("Expected status update to '%s', got '%s'", models.OrderStatusRefundRequested, updatedStatus)
+			}
+			if result.Order.Status != models.OrderStatusRefundRequested {
+				t.Errorf("Expected order status '%s', got '%s'", models.OrderStatusRefundRequested, result.Order.Status)
+			}
+		})
+	}
+}
+
+func TestPaymentService_CreatePaymentSession_CaptureMode(t *testing.T) {
+	tests := []struct {
+		name           string
+		captureMode    string
+		expectedManual bool
+	}{
+		{"immediate capture", config.CaptureModeImmediate, false},
+		{"manual capture", config.CaptureModeManual, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CreateSessionFunc: func(req *SessionRequest) (*SessionResponse, error) {
+					manual := req.AdditionalData["manualCapture"] == "true"
+					if manual != tt.expectedManual {
+						t.Errorf("Expected manualCapture %v, got additional data %v", tt.expectedManual, req.AdditionalData)
+					}
+					return &SessionResponse{ID: "session-123"}, nil
+				},
+			}
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", CaptureMode: tt.captureMode}
+			service := NewPaymentService(mockAdyen, &MockOrderService{}, &MockStatusRetryService{}, cfg)
+			if _, err := service.CreatePaymentSession(context.Background(), &models.Cart{Items: []models.CartItem{{Product: &models.Product{SKU: "test-001", Name: "Test Product", Price: 100, Currency: "USD"}, Quantity: 1}}}, models.ShopperLocale{}, "http://localhost:8080/confirmation"); err != nil {
+				t.Fatalf("CreatePaymentSession() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPaymentService_CaptureOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		orderStatus  models.OrderStatus
+		captureError error
+		wantErr      bool
+	}{
+		{
+			name:        "capture authorized order",
+			orderStatus: models.OrderStatusAuthorized,
+		},
+		{
+			name:        "cannot capture pending order",
+			orderStatus: models.OrderStatusPending,
+			wantErr:     true,
+		},
+		{
+			name:        "cannot capture captured order",
+			orderStatus: models.OrderStatusCaptured,
+			wantErr:     true,
+		},
+		{
+			name:        "cannot capture order waiting for its capture",
+			orderStatus: models.OrderStatusCaptureRequested,
+			wantErr:     true,
+		},
+		{
+			name:         "Adyen capture fails",
+			orderStatus:  models.OrderStatusAuthorized,
+			captureError: errors.New("API error"),
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CapturePaymentFunc: func(pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+					if tt.captureError != nil {
+						return nil, tt.captureError
+					}
+					if req.Amount == nil || req.Amount.Value != 1000 {
+						t.Errorf("Expected capture of full amount, got %+v", req.Amount)
+					}
+					if req.IdempotencyKey != "capture-ORDER-123-3" {
+						t.Errorf("Expected idempotency key 'capture-ORDER-123-3', got '%s'", req.IdempotencyKey)
+					}
+					return &ModificationResponse{PSPReference: "CAPTURE-PSP-123", Status: "received"}, nil
+				},
+			}
+
+			var updatedStatus string
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					return &models.Order{
+						Reference:    reference,
+						Amount:       1000,
+						Currency:     "USD",
+						Status:       tt.orderStatus,
+						PSPReference: "PSP-123",
+						Version:      3,
+					}, nil
+				},
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updatedStatus = status
+					return nil
+				},
+			}
+
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, &config.AdyenConfig{MerchantAccount: "TestMerchant"})
+			result, err := service.CaptureOrder(context.Background(), "ORDER-123")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CaptureOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				// The CAPTURE webhook confirms the capture
+				if updatedStatus != string(models.OrderStatusCaptureRequested) {
+					t.Errorf("Expected status update to '%s', got '%s'", models.OrderStatusCaptureRequested, updatedStatus)
+				}
+				if result.PSPReference != "CAPTURE-PSP-123" {
+					t.Errorf("Expected capture PSP reference 'CAPTURE-PSP-123', got '%s'", result.PSPReference)
+				}
+			}
+		})
+	}
+}
+
+func TestPaymentService_CancelOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		orderStatus    models.OrderStatus
+		captureMode    string
+		noPSPReference bool
+		cancelError    error
+		updateError    error
+		wantErr        bool
+		expectAdyen    bool
+		expectedUpdate models.OrderStatus
+		expectedPSPRef string
+	}{
+		{
+			name:           "cancel authorized order waits for Adyen to release the funds",
+			orderStatus:    models.OrderStatusAuthorized,
+			captureMode:    config.CaptureModeManual,
+			expectAdyen:    true,
+			expectedUpdate: models.OrderStatusCancelRequested,
+			expectedPSPRef: "CANCEL-PSP-123",
+		},
+		{
+			name:        "authorized order captured on authorisation must be refunded",
+			orderStatus: models.OrderStatusAuthorized,
+			captureMode: config.CaptureModeImmediate,
+			wantErr:     true,
+		},
+		{
+			name:           "cancel processing order cancels the payment before it is authorised",
+			orderStatus:    models.OrderStatusProcessing,
+			captureMode:    config.CaptureModeImmediate,
+			expectAdyen:    true,
+			expectedUpdate: models.OrderStatusCancelRequested,
+			expectedPSPRef: "CANCEL-PSP-123",
+		},
+		{
+			name:           "cancel pending order without Adyen call",
+			orderStatus:    models.OrderStatusPending,
+			noPSPReference: true,
+			expectedUpdate: models.OrderStatusCancelled,
+		},
+		{
+			name:        "failed order is left as it is",
+			orderStatus: models.OrderStatusFailed,
+		},
+		{
+			name:        "cancelled order is left as it is",
+			orderStatus: models.OrderStatusCancelled,
+		},
+		{
+			name:        "requested cancellation is not repeated",
+			orderStatus: models.OrderStatusCancelRequested,
+		},
+		{
+			name:        "Adyen cancellation fails",
+			orderStatus: models.OrderStatusAuthorized,
+			captureMode: config.CaptureModeManual,
+			cancelError: errors.New("API error"),
+			expectAdyen: true,
+			wantErr:     true,
+		},
+		{
+			name:        "captured order cannot be cancelled",
+			orderStatus: models.OrderStatusCaptured,
+			wantErr:     true,
+		},
+		{
+			name:        "refunded order cannot be cancelled",
+			orderStatus: models.OrderStatusRefunded,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adyenCalled := false
+			mockAdyen := &MockAdyenClient{
+				CancelPaymentFunc: func(pspReference string, req *ModificationRequest) (*ModificationResponse, error) {
+					adyenCalled = true
+					if tt.cancelError != nil {
+						return nil, tt.cancelError
+					}
+					if pspReference != "PSP-123" {
+						t.Errorf("Expected PSP reference 'PSP-123', got '%s'", pspReference)
+					}
+					// Retrying the cancellation of an unchanged order must not cancel twice
+					if req.IdempotencyKey != "cancel-ORDER-123-3" {
+						t.Errorf("Expected idempotency key 'cancel-ORDER-123-3', got '%s'", req.IdempotencyKey)
+					}
+					return &ModificationResponse{PSPReference: "CANCEL-PSP-123", Status: "received"}, nil
+				},
+			}
+
+			var updatedStatus models.OrderStatus
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					order := &models.Order{
+						Reference:    reference,
+						Amount:       1000,
+						Currency:     "USD",
+						Status:       tt.orderStatus,
+						PSPReference: "PSP-123",
+						Version:      3,
+					}
+					if tt.noPSPReference {
+						order.PSPReference = ""
+					}
+					return order, nil
+				},
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updatedStatus = models.OrderStatus(status)
+					return tt.updateError
+				},
+			}
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", CaptureMode: tt.captureMode}
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, cfg)
+			result, err := service.CancelOrder(context.Background(), "ORDER-123")
+
+			if adyenCalled != tt.expectAdyen {
+				t.Errorf("Expected Adyen cancellation called = %v, got %v", tt.expectAdyen, adyenCalled)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CancelOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if updatedStatus != tt.expectedUpdate {
+					t.Errorf("Expected status update to '%s', got '%s'", tt.expectedUpdate, updatedStatus)
+				}
+				if result.PSPReference != tt.expectedPSPRef {
+					t.Errorf("Expected PSP reference '%s', got '%s'", tt.expectedPSPRef, result.PSPReference)
+				}
+			}
+		})
+	}
+}
+
 func TestMapResultCodeToStatus(t *testing.T) {
 	tests := []struct {
 		resultCode     string
@@ -352,7 +1168,13 @@ func TestMapResultCodeToStatus(t *testing.T) {
 		{"Refused", models.OrderStatusFailed},
 		{"Error", models.OrderStatusFailed},
 		{"Cancelled", models.OrderStatusCancelled},
-		{"Pending", models.OrderStatusPending},
+		{"Pending", models.OrderStatusProcessing},
+		{"Received", models.OrderStatusProcessing},
+		{"PresentToShopper", models.OrderStatusProcessing},
+		{"IdentifyShopper", models.OrderStatusProcessing},
+		{"ChallengeShopper", models.OrderStatusProcessing},
+		{"RedirectShopper", models.OrderStatusProcessing},
+		{"", models.OrderStatusPending},
 		{"Unknown", models.OrderStatusPending},
 	}
 
@@ -411,3 +1233,153 @@ func TestCalculateTaxAmount(t *testing.T) {
 		})
 	}
 }
+
+func TestPaymentService_CreatePaymentSession_PaymentMethods(t *testing.T) {
+	cfg := &config.AdyenConfig{
+		MerchantAccount: "TestMerchant",
+		PaymentMethods: config.PaymentMethodsConfig{
+			PaymentMethodRules: config.PaymentMethodRules{Blocked: []string{"paypal"}},
+			Countries:          map[string]config.PaymentMethodRules{"NL": {Allowed: []string{"scheme", "ideal"}}},
+			Products: map[string]config.PaymentMethodRules{
+				"gift-card-001": {Allowed: []string{"scheme"}},
+				"voucher-001":   {Allowed: []string{"ideal"}},
+			},
+		},
+	}
+	widget := &models.Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"}
+	giftCard := &models.Product{SKU: "gift-card-001", Name: "Gift Card", Price: 2500, Currency: "USD"}
+	voucher := &models.Product{SKU: "voucher-001", Name: "Voucher", Price: 1000, Currency: "USD"}
+
+	tests := []struct {
+		name            string
+		shopper         models.ShopperLocale
+		products        []*models.Product
+		expectedAllowed []string
+		expectedBlocked []string
+		wantErr         error
+	}{
+		{
+			name:            "methods of the shopper's country",
+			shopper:         testShopper,
+			products:        []*models.Product{widget},
+			expectedAllowed: []string{"scheme", "ideal"},
+			expectedBlocked: []string{"paypal"},
+		},
+		{
+			name:            "all methods but the blocked ones elsewhere",
+			shopper:         models.ShopperLocale{CountryCode: "DE", Locale: "de-DE"},
+			products:        []*models.Product{widget},
+			expectedBlocked: []string{"paypal"},
+		},
+		{
+			name:            "methods narrowed by a product",
+			shopper:         testShopper,
+			products:        []*models.Product{widget, giftCard},
+			expectedAllowed: []string{"scheme"},
+			expectedBlocked: []string{"paypal"},
+		},
+		{
+			name:     "no method allowed for all products",
+			shopper:  testShopper,
+			products: []*models.Product{giftCard, voucher},
+			wantErr:  models.ErrNoPaymentMethod,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CreateSessionFunc: func(req *SessionRequest) (*SessionResponse, error) {
+					if !reflect.DeepEqual(req.AllowedPaymentMethods, tt.expectedAllowed) {
+						t.Errorf("Expected allowed payment methods %v, got %v", tt.expectedAllowed, req.AllowedPaymentMethods)
+					}
+					if !reflect.DeepEqual(req.BlockedPaymentMethods, tt.expectedBlocked) {
+						t.Errorf("Expected blocked payment methods %v, got %v", tt.expectedBlocked, req.BlockedPaymentMethods)
+					}
+					return &SessionResponse{ID: "session-123"}, nil
+				},
+			}
+			mockOrder := &MockOrderService{
+				CreateOrderFunc: func(items []models.OrderItem, currency string) (*models.Order, error) {
+					if tt.wantErr != nil {
+						t.Error("Expected no order for a cart that cannot be paid for")
+					}
+					return models.NewOrderFromItems(items, currency)
+				},
+			}
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, cfg)
+
+			cart := &models.Cart{}
+			for _, product := range tt.products {
+				cart.Items = append(cart.Items, models.CartItem{Product: product, Quantity: 1})
+			}
+
+			if _, err := service.CreatePaymentSession(context.Background(), cart, tt.shopper, "http://localhost:8080/confirmation"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreatePaymentSession() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPaymentService_CreatePaymentSession_Currency(t *testing.T) {
+	cfg := &config.AdyenConfig{
+		MerchantAccount: "TestMerchant",
+		Currencies:      config.CountryCurrencies{"NL": "EUR", "US": "USD"},
+	}
+
+	tests := []struct {
+		name             string
+		shopper          models.ShopperLocale
+		priceCurrency    string
+		expectedCurrency string
+		wantErr          error
+	}{
+		{
+			name:             "currency of the shopper's country",
+			shopper:          testShopper,
+			priceCurrency:    "EUR",
+			expectedCurrency: "EUR",
+		},
+		{
+			name:             "price currency in a country without one",
+			shopper:          models.ShopperLocale{CountryCode: "DE", Locale: "de-DE"},
+			priceCurrency:    "EUR",
+			expectedCurrency: "EUR",
+		},
+		{
+			name:          "prices in another currency than the country's",
+			shopper:       models.ShopperLocale{CountryCode: "US", Locale: "en-US"},
+			priceCurrency: "EUR",
+			wantErr:       models.ErrCurrencyNotOffered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAdyen := &MockAdyenClient{
+				CreateSessionFunc: func(req *SessionRequest) (*SessionResponse, error) {
+					if req.Amount.Currency != tt.expectedCurrency {
+						t.Errorf("Expected session currency %s, got %s", tt.expectedCurrency, req.Amount.Currency)
+					}
+					return &SessionResponse{ID: "session-123"}, nil
+				},
+			}
+			mockOrder := &MockOrderService{
+				CreateOrderFunc: func(items []models.OrderItem, currency string) (*models.Order, error) {
+					if tt.wantErr != nil {
+						t.Error("Expected no order for a cart that cannot be paid for")
+					}
+					return models.NewOrderFromItems(items, currency)
+				},
+			}
+			service := NewPaymentService(mockAdyen, mockOrder, &MockStatusRetryService{}, cfg)
+
+			product := &models.Product{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: tt.priceCurrency}
+			cart := &models.Cart{Items: []models.CartItem{{Product: product, Quantity: 1}}}
+
+			if _, err := service.CreatePaymentSession(context.Background(), cart, tt.shopper, "http://localhost:8080/confirmation"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreatePaymentSession() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
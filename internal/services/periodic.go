@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery calls fn every interval until ctx is done, logging its errors with what it does
+func runEvery(ctx context.Context, interval time.Duration, what string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error %s: %v", what, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// ProductRepository defines the interface for product persistence
+type ProductRepository interface {
+	ListProducts(ctx context.Context) ([]*models.Product, error)
+	GetProductBySKU(ctx context.Context, sku string) (*models.Product, error)
+}
+
+// ProductService handles catalog business logic
+type ProductService interface {
+	ListProducts(ctx context.Context) ([]*models.Product, error)
+	GetProductBySKU(ctx context.Context, sku string) (*models.Product, error)
+}
+
+// ProductServiceImpl implements ProductService
+type ProductServiceImpl struct {
+	productRepo ProductRepository
+}
+
+// NewProductService creates a new product service
+func NewProductService(productRepo ProductRepository) ProductService {
+	return &ProductServiceImpl{
+		productRepo: productRepo,
+	}
+}
+
+// ListProducts retrieves all products available for sale
+func (s *ProductServiceImpl) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	products, err := s.productRepo.ListProducts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	return products, nil
+}
+
+// GetProductBySKU retrieves a product by its SKU
+func (s *ProductServiceImpl) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	if sku == "" {
+		return nil, models.ErrProductNotFound
+	}
+
+	product, err := s.productRepo.GetProductBySKU(ctx, sku)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return product, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// MockProductRepository is a mock implementation of ProductRepository for testing
+type MockProductRepository struct {
+	ListProductsFunc    func() ([]*models.Product, error)
+	GetProductBySKUFunc func(string) (*models.Product, error)
+}
+
+func (m *MockProductRepository) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	if m.ListProductsFunc != nil {
+		return m.ListProductsFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockProductRepository) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	if m.GetProductBySKUFunc != nil {
+		return m.GetProductBySKUFunc(sku)
+	}
+	return &models.Product{SKU: sku}, nil
+}
+
+func TestProductService_ListProducts(t *testing.T) {
+	tests := []struct {
+		name      string
+		products  []*models.Product
+		mockError error
+		wantErr   bool
+	}{
+		{
+			name: "lists products",
+			products: []*models.Product{
+				{SKU: "widget-001", Name: "Premium Widget", Price: 100, Currency: "USD"},
+				{SKU: "gadget-001", Name: "Deluxe Gadget", Price: 2500, Currency: "EUR"},
+			},
+		},
+		{
+			name:      "repository error",
+			mockError: errors.New("database error"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockProductRepository{
+				ListProductsFunc: func() ([]*models.Product, error) {
+					return tt.products, tt.mockError
+				},
+			}
+
+			service := NewProductService(mockRepo)
+			products, err := service.ListProducts(context.Background())
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ListProducts() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(products) != len(tt.products) {
+				t.Errorf("Expected %d products, got %d", len(tt.products), len(products))
+			}
+		})
+	}
+}
+
+func TestProductService_GetProductBySKU(t *testing.T) {
+	tests := []struct {
+		name      string
+		sku       string
+		mockError error
+		wantErr   error
+	}{
+		{
+			name: "existing product",
+			sku:  "widget-001",
+		},
+		{
+			name:    "empty SKU",
+			sku:     "",
+			wantErr: models.ErrProductNotFound,
+		},
+		{
+			name:      "unknown SKU",
+			sku:       "missing-001",
+			mockError: models.ErrProductNotFound,
+			wantErr:   models.ErrProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockProductRepository{
+				GetProductBySKUFunc: func(sku string) (*models.Product, error) {
+					if tt.mockError != nil {
+						return nil, tt.mockError
+					}
+					return &models.Product{SKU: sku, Name: "Premium Widget", Price: 100, Currency: "USD"}, nil
+				},
+			}
+
+			service := NewProductService(mockRepo)
+			product, err := service.GetProductBySKU(context.Background(), tt.sku)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetProductBySKU() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr == nil && product.SKU != tt.sku {
+				t.Errorf("Expected SKU %s, got %s", tt.sku, product.SKU)
+			}
+		})
+	}
+}
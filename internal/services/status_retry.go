@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// Backoff between attempts of a queued status update, doubling up to statusRetryMaxDelay
+const (
+	statusRetryBaseDelay = 10 * time.Second
+	statusRetryMaxDelay  = 30 * time.Minute
+	// statusRetryBatchSize bounds how many queued updates a single run retries
+	statusRetryBatchSize = 100
+)
+
+// statusRetryMetrics is published on /debug/vars as order_status_retries
+var (
+	statusRetryMetrics = expvar.NewMap("order_status_retries")
+	// statusRetriesQueued is the number of updates waiting to be stored, as of the last run
+	statusRetriesQueued = new(expvar.Int)
+)
+
+func init() {
+	statusRetryMetrics.Set("queued", statusRetriesQueued)
+}
+
+// StatusRetryRepository defines the interface for persisting status updates to retry
+type StatusRetryRepository interface {
+	EnqueueStatusRetry(ctx context.Context, retry *models.StatusUpdateRetry) error
+	DueStatusRetries(ctx context.Context, now time.Time, limit int) ([]models.StatusUpdateRetry, error)
+	ListStatusRetries(ctx context.Context) ([]models.StatusUpdateRetry, error)
+	RescheduleStatusRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error
+	DeleteStatusRetry(ctx context.Context, id string) error
+}
+
+// StatusRetryService reconciles order status updates that failed to be stored.
+//
+// The queue lives in the orders database, so it only covers updates that fail while the
+// database can still be written, e.g. a timeout or version conflicts the order service gave up
+// on. While the database is down Enqueue fails as well; such updates are counted in
+// enqueue_failures and rely on Adyen redelivering its webhook until the shop accepts it.
+type StatusRetryService interface {
+	// Enqueue records a status update that failed with cause, to be retried in the background
+	Enqueue(ctx context.Context, reference string, status models.OrderStatus, pspReference string, change models.OrderChange, cause error) error
+	// RetryDue retries the queued updates that are due and returns how many were resolved
+	RetryDue(ctx context.Context) (int, error)
+	// Run retries due updates every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+	// ListQueued returns the updates still waiting to be stored, oldest first
+	ListQueued(ctx context.Context) ([]models.StatusUpdateRetry, error)
+}
+
+// StatusRetryServiceImpl implements StatusRetryService
+type StatusRetryServiceImpl struct {
+	retryRepo    StatusRetryRepository
+	orderService OrderService
+	now          func() time.Time
+}
+
+// NewStatusRetryService creates a new status retry service applying updates through orderService
+func NewStatusRetryService(retryRepo StatusRetryRepository, orderService OrderService) StatusRetryService {
+	return &StatusRetryServiceImpl{
+		retryRepo:    retryRepo,
+		orderService: orderService,
+		now:          time.Now,
+	}
+}
+
+// Enqueue records a status update that failed with cause, to be retried in the background
+func (s *StatusRetryServiceImpl) Enqueue(ctx context.Context, reference string, status models.OrderStatus, pspReference string, change models.OrderChange, cause error) error {
+	retry := &models.StatusUpdateRetry{
+		OrderReference: reference,
+		Status:         status,
+		PSPReference:   pspReference,
+		Change:         change,
+		LastError:      cause.Error(),
+		NextAttemptAt:  s.now().Add(statusRetryBaseDelay),
+	}
+	if err := s.retryRepo.EnqueueStatusRetry(ctx, retry); err != nil {
+		statusRetryMetrics.Add("enqueue_failures", 1)
+		log.Printf("Error: status update of order %s to %s (PSP reference %s) could not be queued, only Adyen's webhook can still deliver it: %v (update failed with: %v)",
+			reference, status, pspReference, err, cause)
+		return fmt.Errorf("failed to queue status update of order %s: %w", reference, err)
+	}
+
+	statusRetryMetrics.Add("enqueued", 1)
+	statusRetriesQueued.Add(1)
+	log.Printf("Queued status update of order %s to %s for retry: %v", reference, status, cause)
+	return nil
+}
+
+// RetryDue retries the queued updates that are due and returns how many were resolved
+func (s *StatusRetryServiceImpl) RetryDue(ctx context.Context) (int, error) {
+	retries, err := s.retryRepo.DueStatusRetries(ctx, s.now(), statusRetryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queued status updates: %w", err)
+	}
+
+	resolved := 0
+	for _, retry := range retries {
+		if err := ctx.Err(); err != nil {
+			return resolved, err
+		}
+		ok, err := s.retry(ctx, retry)
+		if err != nil {
+			return resolved, err
+		}
+		if ok {
+			resolved++
+		}
+	}
+
+	// Recount, other server instances work on the same queue
+	if queued, err := s.retryRepo.ListStatusRetries(ctx); err == nil {
+		statusRetriesQueued.Set(int64(len(queued)))
+	}
+
+	return resolved, nil
+}
+
+// retry makes one attempt to store a queued update, returning true once it is off the queue
+func (s *StatusRetryServiceImpl) retry(ctx context.Context, retry models.StatusUpdateRetry) (bool, error) {
+	err := s.applyStatusUpdate(ctx, retry)
+	switch {
+	case err == nil:
+		statusRetryMetrics.Add("resolved", 1)
+		log.Printf("Stored queued status update of order %s to %s after %d retries", retry.OrderReference, retry.Status, retry.Attempts+1)
+	case errors.Is(err, models.ErrInvalidStatusTransition), errors.Is(err, models.ErrOrderNotFound):
+		// Something else settled the order meanwhile, e.g. a webhook, and the update no longer applies
+		statusRetryMetrics.Add("superseded", 1)
+		log.Printf("Dropping queued status update of order %s to %s: %v", retry.OrderReference, retry.Status, err)
+	default:
+		statusRetryMetrics.Add("failed_attempts", 1)
+		attempts := retry.Attempts + 1
+		log.Printf("Retry %d of status update of order %s to %s failed: %v", attempts, retry.OrderReference, retry.Status, err)
+		if err := s.retryRepo.RescheduleStatusRetry(ctx, retry.ID, err.Error(), s.now().Add(statusRetryDelay(attempts))); err != nil {
+			return false, fmt.Errorf("failed to reschedule status update of order %s: %w", retry.OrderReference, err)
+		}
+		return false, nil
+	}
+
+	if err := s.retryRepo.DeleteStatusRetry(ctx, retry.ID); err != nil {
+		return false, fmt.Errorf("failed to remove status update of order %s from the queue: %w", retry.OrderReference, err)
+	}
+	return true, nil
+}
+
+// applyStatusUpdate stores a queued update unless the order already has its status
+func (s *StatusRetryServiceImpl) applyStatusUpdate(ctx context.Context, retry models.StatusUpdateRetry) error {
+	order, err := s.orderService.GetOrderByReference(ctx, retry.OrderReference)
+	if err != nil {
+		return err
+	}
+	if order.Status == retry.Status {
+		return nil
+	}
+	return s.orderService.UpdateOrderStatus(ctx, retry.OrderReference, string(retry.Status), retry.PSPReference, retry.Change)
+}
+
+// Run retries due updates every interval until ctx is done
+func (s *StatusRetryServiceImpl) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "retrying queued status updates", func(ctx context.Context) error {
+		_, err := s.RetryDue(ctx)
+		return err
+	})
+}
+
+// ListQueued returns the updates still waiting to be stored, oldest first
+func (s *StatusRetryServiceImpl) ListQueued(ctx context.Context) ([]models.StatusUpdateRetry, error) {
+	retries, err := s.retryRepo.ListStatusRetries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued status updates: %w", err)
+	}
+	return retries, nil
+}
+
+// statusRetryDelay returns how long to wait before the next attempt after the given number of failed ones
+func statusRetryDelay(attempts int) time.Duration {
+	delay := statusRetryBaseDelay
+	for i := 1; i < attempts && delay < statusRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, statusRetryMaxDelay)
+}
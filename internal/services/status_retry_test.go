@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+)
+
+// MockStatusRetryService is a mock implementation of StatusRetryService for testing
+type MockStatusRetryService struct {
+	EnqueueFunc    func(string, models.OrderStatus, string, models.OrderChange, error) error
+	RetryDueFunc   func() (int, error)
+	ListQueuedFunc func() ([]models.StatusUpdateRetry, error)
+}
+
+func (m *MockStatusRetryService) Enqueue(ctx context.Context, reference string, status models.OrderStatus, pspReference string, change models.OrderChange, cause error) error {
+	if m.EnqueueFunc != nil {
+		return m.EnqueueFunc(reference, status, pspReference, change, cause)
+	}
+	return nil
+}
+
+func (m *MockStatusRetryService) RetryDue(ctx context.Context) (int, error) {
+	if m.RetryDueFunc != nil {
+		return m.RetryDueFunc()
+	}
+	return 0, nil
+}
+
+func (m *MockStatusRetryService) Run(ctx context.Context, interval time.Duration) {
+	<-ctx.Done()
+}
+
+func (m *MockStatusRetryService) ListQueued(ctx context.Context) ([]models.StatusUpdateRetry, error) {
+	if m.ListQueuedFunc != nil {
+		return m.ListQueuedFunc()
+	}
+	return nil, nil
+}
+
+// newTestStatusRetryService creates a status retry service on an in-memory queue with a clock
+// the test controls
+func newTestStatusRetryService(t *testing.T, orderService OrderService) (*StatusRetryServiceImpl, *repository.MemoryStatusRetryRepository, *time.Time) {
+	t.Helper()
+
+	retryRepo := repository.NewMemoryStatusRetryRepository()
+	now := time.Now()
+	service := NewStatusRetryService(retryRepo, orderService).(*StatusRetryServiceImpl)
+	service.now = func() time.Time { return now }
+	return service, retryRepo, &now
+}
+
+// newTestOrderService creates an order service on an in-memory repository with a pending order
+func newTestOrderService(t *testing.T, reference string) (OrderService, *repository.MemoryOrderRepository) {
+	t.Helper()
+
+	orderRepo := repository.NewMemoryOrderRepository()
+	order, err := models.NewOrderFromItems([]models.OrderItem{{SKU: "widget-001", Name: "Widget", UnitPrice: 1000, Quantity: 1}}, "USD")
+	if err != nil {
+		t.Fatalf("Failed to build order: %v", err)
+	}
+	order.Reference = reference
+	if err := orderRepo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	return NewOrderService(orderRepo, NewOrderEventBus()), orderRepo
+}
+
+func TestStatusRetryService_RetryDue(t *testing.T) {
+	orderService, orderRepo := newTestOrderService(t, "ORDER-RETRY-001")
+	service, retryRepo, now := newTestStatusRetryService(t, orderService)
+	ctx := context.Background()
+
+	change := models.OrderChange{Source: models.OrderEventSourceRedirect, Actor: "shopper"}
+	if err := service.Enqueue(ctx, "ORDER-RETRY-001", models.OrderStatusAuthorized, "PSP-123", change, errors.New("database unavailable")); err != nil {
+		t.Fatalf("Enqueue() unexpected error = %v", err)
+	}
+
+	// Nothing is due before the first backoff has passed
+	if resolved, err := service.RetryDue(ctx); err != nil || resolved != 0 {
+		t.Fatalf("Expected no retries yet, got %d, %v", resolved, err)
+	}
+
+	*now = now.Add(statusRetryBaseDelay)
+	resolved, err := service.RetryDue(ctx)
+	if err != nil || resolved != 1 {
+		t.Fatalf("Expected 1 resolved retry, got %d, %v", resolved, err)
+	}
+
+	order, _ := orderRepo.GetOrderByReference(ctx, "ORDER-RETRY-001")
+	if order.Status != models.OrderStatusAuthorized || order.PSPReference != "PSP-123" {
+		t.Errorf("Expected the order to be authorized with PSP-123, got %s %s", order.Status, order.PSPReference)
+	}
+	history, _ := orderRepo.GetOrderHistory(ctx, "ORDER-RETRY-001")
+	if len(history) != 1 || history[0].Source != models.OrderEventSourceRedirect || history[0].Actor != "shopper" {
+		t.Errorf("Expected the original change in the history, got %+v", history)
+	}
+	if queued, _ := retryRepo.ListStatusRetries(ctx); len(queued) != 0 {
+		t.Errorf("Expected an empty queue, got %+v", queued)
+	}
+}
+
+func TestStatusRetryService_RetryDue_Outcomes(t *testing.T) {
+	tests := []struct {
+		name          string
+		currentStatus models.OrderStatus
+		updateError   error
+		expectUpdate  bool
+		expectQueued  bool
+	}{
+		{
+			name:         "update is stored",
+			expectUpdate: true,
+		},
+		{
+			name:          "order already has the status",
+			currentStatus: models.OrderStatusAuthorized,
+		},
+		{
+			name:         "webhook settled the order differently",
+			updateError:  fmt.Errorf("%w: cannot authorize order with status failed", models.ErrInvalidStatusTransition),
+			expectUpdate: true,
+		},
+		{
+			name:         "database still unavailable",
+			updateError:  errors.New("database unavailable"),
+			expectUpdate: true,
+			expectQueued: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated bool
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					status := tt.currentStatus
+					if status == "" {
+						status = models.OrderStatusPending
+					}
+					return &models.Order{Reference: reference, Status: status}, nil
+				},
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updated = true
+					return tt.updateError
+				},
+			}
+			service, retryRepo, now := newTestStatusRetryService(t, mockOrder)
+			ctx := context.Background()
+
+			if err := service.Enqueue(ctx, "ORDER-RETRY-001", models.OrderStatusAuthorized, "PSP-123", models.OrderChange{}, errors.New("timeout")); err != nil {
+				t.Fatalf("Enqueue() unexpected error = %v", err)
+			}
+			*now = now.Add(statusRetryBaseDelay)
+
+			if _, err := service.RetryDue(ctx); err != nil {
+				t.Fatalf("RetryDue() unexpected error = %v", err)
+			}
+			if updated != tt.expectUpdate {
+				t.Errorf("Expected order update %v, got %v", tt.expectUpdate, updated)
+			}
+
+			queued, _ := retryRepo.ListStatusRetries(ctx)
+			if (len(queued) == 1) != tt.expectQueued {
+				t.Fatalf("Expected queued %v, got %+v", tt.expectQueued, queued)
+			}
+			if tt.expectQueued {
+				retry := queued[0]
+				if retry.Attempts != 1 || !strings.Contains(retry.LastError, "database unavailable") {
+					t.Errorf("Expected 1 failed attempt with the database error, got %d %q", retry.Attempts, retry.LastError)
+				}
+				if expected := now.Add(statusRetryDelay(1)); !retry.NextAttemptAt.Equal(expected) {
+					t.Errorf("Expected next attempt at %v, got %v", expected, retry.NextAttemptAt)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusRetryService_Enqueue_Error(t *testing.T) {
+	orderService, _ := newTestOrderService(t, "ORDER-RETRY-001")
+	service, _, _ := newTestStatusRetryService(t, orderService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	before := statusRetryMetric("enqueue_failures")
+	err := service.Enqueue(ctx, "ORDER-RETRY-001", models.OrderStatusAuthorized, "PSP-123", models.OrderChange{}, errors.New("timeout"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if got := statusRetryMetric("enqueue_failures"); got != before+1 {
+		t.Errorf("Expected %d enqueue failures, got %d", before+1, got)
+	}
+}
+
+func TestStatusRetryService_Metrics(t *testing.T) {
+	orderService, _ := newTestOrderService(t, "ORDER-RETRY-001")
+	service, _, now := newTestStatusRetryService(t, orderService)
+	ctx := context.Background()
+
+	before := statusRetryMetric("enqueued")
+
+	if err := service.Enqueue(ctx, "ORDER-RETRY-001", models.OrderStatusAuthorized, "PSP-123", models.OrderChange{}, errors.New("timeout")); err != nil {
+		t.Fatalf("Enqueue() unexpected error = %v", err)
+	}
+	if got := statusRetryMetric("enqueued"); got != before+1 {
+		t.Errorf("Expected %d enqueued updates, got %d", before+1, got)
+	}
+	if got := statusRetriesQueued.Value(); got < 1 {
+		t.Errorf("Expected queued updates to be counted, got %d", got)
+	}
+
+	*now = now.Add(statusRetryBaseDelay)
+	if _, err := service.RetryDue(ctx); err != nil {
+		t.Fatalf("RetryDue() unexpected error = %v", err)
+	}
+	if got := statusRetriesQueued.Value(); got != 0 {
+		t.Errorf("Expected no queued updates after the retry, got %d", got)
+	}
+}
+
+// statusRetryMetric returns a counter of the order_status_retries metrics, zero if never set
+func statusRetryMetric(name string) int64 {
+	counter, _ := statusRetryMetrics.Get(name).(*expvar.Int)
+	if counter == nil {
+		return 0
+	}
+	return counter.Value()
+}
+
+func TestStatusRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: statusRetryBaseDelay},
+		{attempts: 2, expected: 2 * statusRetryBaseDelay},
+		{attempts: 4, expected: 8 * statusRetryBaseDelay},
+		{attempts: 50, expected: statusRetryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := statusRetryDelay(tt.attempts); got != tt.expected {
+			t.Errorf("statusRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/models"
+)
+
+// Adyen webhook event codes
+const (
+	EventCodeAuthorisation = "AUTHORISATION"
+	EventCodeCancellation  = "CANCELLATION"
+	EventCodeRefund        = "REFUND"
+	EventCodeCapture       = "CAPTURE"
+)
+
+// Webhook errors
+var (
+	ErrHMACKeyNotConfigured = errors.New("webhook HMAC key is not configured")
+	ErrInvalidHMACSignature = errors.New("invalid webhook HMAC signature")
+)
+
+// NotificationRequest represents a batch of Adyen standard webhook notifications
+type NotificationRequest struct {
+	Live              string             `json:"live"`
+	NotificationItems []NotificationItem `json:"notificationItems"`
+}
+
+// NotificationItem wraps a single notification in the batch
+type NotificationItem struct {
+	NotificationRequestItem NotificationRequestItem `json:"NotificationRequestItem"`
+}
+
+// NotificationRequestItem represents a single Adyen webhook event
+type NotificationRequestItem struct {
+	AdditionalData      map[string]string `json:"additionalData,omitempty"`
+	Amount              Amount            `json:"amount"`
+	EventCode           string            `json:"eventCode"`
+	EventDate           string            `json:"eventDate"`
+	MerchantAccountCode string            `json:"merchantAccountCode"`
+	MerchantReference   string            `json:"merchantReference"`
+	OriginalReference   string            `json:"originalReference,omitempty"`
+	PSPReference        string            `json:"pspReference"`
+	Reason              string            `json:"reason,omitempty"`
+	Success             string            `json:"success"`
+}
+
+// IsSuccess returns true if Adyen reported the event as successful
+func (i *NotificationRequestItem) IsSuccess() bool {
+	return i.Success == "true"
+}
+
+// WebhookService handles incoming Adyen webhook notifications
+type WebhookService interface {
+	HandleNotifications(ctx context.Context, req *NotificationRequest) error
+}
+
+// WebhookServiceImpl implements WebhookService
+type WebhookServiceImpl struct {
+	orderService OrderService
+	config       *config.AdyenConfig
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(orderService OrderService, cfg *config.AdyenConfig) WebhookService {
+	return &WebhookServiceImpl{
+		orderService: orderService,
+		config:       cfg,
+	}
+}
+
+// HandleNotifications verifies and processes every item in a notification batch.
+// Items with an invalid signature or that no longer apply to the order are skipped;
+// an error is only returned when the batch should be redelivered by Adyen.
+func (s *WebhookServiceImpl) HandleNotifications(ctx context.Context, req *NotificationRequest) error {
+	if s.config.HMACKey == "" {
+		return ErrHMACKeyNotConfigured
+	}
+
+	for _, notification := range req.NotificationItems {
+		item := notification.NotificationRequestItem
+
+		if err := VerifyNotificationHMAC(&item, s.config.HMACKey); err != nil {
+			log.Printf("Skipping webhook %s for %s: %v", item.EventCode, item.MerchantReference, err)
+			continue
+		}
+
+		if item.MerchantAccountCode != s.config.MerchantAccount {
+			log.Printf("Skipping webhook %s for %s: unexpected merchant account %s",
+				item.EventCode, item.MerchantReference, item.MerchantAccountCode)
+			continue
+		}
+
+		if err := s.processNotification(ctx, &item); err != nil {
+			if errors.Is(err, models.ErrOrderNotFound) || errors.Is(err, models.ErrInvalidStatusTransition) ||
+				errors.Is(err, models.ErrInvalidRefundAmount) {
+				log.Printf("Ignoring webhook %s for %s: %v", item.EventCode, item.MerchantReference, err)
+				continue
+			}
+			return fmt.Errorf("failed to process %s webhook for %s: %w", item.EventCode, item.MerchantReference, err)
+		}
+	}
+
+	return nil
+}
+
+// processNotification applies a verified notification to the matching order
+func (s *WebhookServiceImpl) processNotification(ctx context.Context, item *NotificationRequestItem) error {
+	log.Printf("Received webhook %s (success=%s) for %s, PSP reference %s",
+		item.EventCode, item.Success, item.MerchantReference, item.PSPReference)
+
+	if !isOrderEvent(item.EventCode) {
+		log.Printf("No order status change for webhook %s", item.EventCode)
+		return nil
+	}
+
+	// Refunds add up, so they are applied by amount rather than mapped to a status
+	if item.EventCode == EventCodeRefund {
+		refund := models.Refund{PSPReference: item.PSPReference, Amount: item.Amount.Value, Success: item.IsSuccess()}
+		change := newOrderChange(models.OrderEventSourceWebhook, "adyen", item)
+		return s.orderService.RecordRefund(ctx, item.MerchantReference, refund, change)
+	}
+
+	order, err := s.orderService.GetOrderByReference(ctx, item.MerchantReference)
+	if err != nil {
+		return err
+	}
+
+	// A refused modification leaves the payment as it was before it was requested
+	if requested, ok := requestedStatuses[item.EventCode]; ok && !item.IsSuccess() {
+		change := newOrderChange(models.OrderEventSourceWebhook, "adyen", item)
+		return s.orderService.RejectRequest(ctx, order.Reference, requested, change)
+	}
+
+	status, ok := mapEventToStatus(item)
+	if !ok {
+		log.Printf("No order status change for webhook %s (success=%s)", item.EventCode, item.Success)
+		return nil
+	}
+
+	// Notifications can be redelivered, so an order already in the target status is left untouched
+	if order.Status == status {
+		return nil
+	}
+
+	pspReference := item.PSPReference
+	if item.OriginalReference != "" {
+		pspReference = item.OriginalReference
+	}
+
+	change := newOrderChange(models.OrderEventSourceWebhook, "adyen", item)
+	err = s.orderService.UpdateOrderStatus(ctx, order.Reference, string(status), pspReference, change)
+	if status == models.OrderStatusAuthorized && errors.Is(err, models.ErrInvalidStatusTransition) {
+		// Adyen took the payment, which must not go unnoticed if the order cannot record it
+		if current, getErr := s.orderService.GetOrderByReference(ctx, order.Reference); getErr == nil && !current.IsPaid() {
+			reportUnrecordedPayment(current, pspReference, err)
+		}
+	}
+	return err
+}
+
+// isOrderEvent returns true if the event code can change an order status
+func isOrderEvent(eventCode string) bool {
+	switch eventCode {
+	case EventCodeAuthorisation, EventCodeCancellation, EventCodeCapture, EventCodeRefund:
+		return true
+	}
+	return false
+}
+
+// requestedStatuses maps the events confirming a modification to the status the order waits
+// for the confirmation in
+var requestedStatuses = map[string]models.OrderStatus{
+	EventCodeCancellation: models.OrderStatusCancelRequested,
+	EventCodeCapture:      models.OrderStatusCaptureRequested,
+}
+
+// mapEventToStatus maps an Adyen webhook event to the order status it results in
+func mapEventToStatus(item *NotificationRequestItem) (models.OrderStatus, bool) {
+	switch item.EventCode {
+	case EventCodeAuthorisation:
+		if item.IsSuccess() {
+			return models.OrderStatusAuthorized, true
+		}
+		return models.OrderStatusFailed, true
+	case EventCodeCancellation:
+		if item.IsSuccess() {
+			return models.OrderStatusCancelled, true
+		}
+	case EventCodeCapture:
+		if item.IsSuccess() {
+			return models.OrderStatusCaptured, true
+		}
+	}
+	return "", false
+}
+
+// CalculateNotificationHMAC calculates the base64 HMAC-SHA256 signature of a notification item
+func CalculateNotificationHMAC(item *NotificationRequestItem, hexKey string) (string, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode HMAC key: %w", err)
+	}
+
+	payload := strings.Join([]string{
+		item.PSPReference,
+		item.OriginalReference,
+		item.MerchantAccountCode,
+		item.MerchantReference,
+		strconv.FormatInt(item.Amount.Value, 10),
+		item.Amount.Currency,
+		item.EventCode,
+		item.Success,
+	}, ":")
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyNotificationHMAC checks the hmacSignature sent in the notification's additional data
+func VerifyNotificationHMAC(item *NotificationRequestItem, hexKey string) error {
+	signature := item.AdditionalData["hmacSignature"]
+	if signature == "" {
+		return fmt.Errorf("%w: signature missing", ErrInvalidHMACSignature)
+	}
+
+	expected, err := CalculateNotificationHMAC(item, hexKey)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidHMACSignature
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adyen/ecommerce/internal/config"
+	"github.com/adyen/ecommerce/internal/models"
+	"github.com/adyen/ecommerce/internal/repository"
+)
+
+const testHMACKey = "44782DEF547AAA06C910C43932B1EB0C71FC68D9D0C057550C48EC2ACF6BA056"
+
+// signedNotification builds a notification batch with a valid HMAC signature
+func signedNotification(t *testing.T, item NotificationRequestItem) *NotificationRequest {
+	t.Helper()
+	signature, err := CalculateNotificationHMAC(&item, testHMACKey)
+	if err != nil {
+		t.Fatalf("Failed to sign notification: %v", err)
+	}
+	item.AdditionalData = map[string]string{"hmacSignature": signature}
+	return &NotificationRequest{
+		Live:              "false",
+		NotificationItems: []NotificationItem{{NotificationRequestItem: item}},
+	}
+}
+
+// storeOrder stores a new order of 10.00 USD with status in orderRepo
+func storeOrder(t *testing.T, orderRepo OrderRepository, status models.OrderStatus) *models.Order {
+	t.Helper()
+	order, err := models.NewOrderFromItems([]models.OrderItem{{SKU: "widget-001", Name: "Widget", UnitPrice: 1000, Quantity: 1}}, "USD")
+	if err != nil {
+		t.Fatalf("Failed to build order: %v", err)
+	}
+	order.Status = status
+	order.PSPReference = "PSP-123"
+	if err := orderRepo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	return order
+}
+
+func TestCalculateNotificationHMAC(t *testing.T) {
+	// Example taken from the Adyen HMAC signature documentation
+	item := &NotificationRequestItem{
+		PSPReference:        "7914073381342284",
+		MerchantAccountCode: "TestMerchant",
+		MerchantReference:   "TestPayment-1407325143704",
+		Amount:              Amount{Currency: "EUR", Value: 1130},
+		EventCode:           "AUTHORISATION",
+		Success:             "true",
+	}
+
+	signature, err := CalculateNotificationHMAC(item, testHMACKey)
+	if err != nil {
+		t.Fatalf("CalculateNotificationHMAC() unexpected error = %v", err)
+	}
+	if signature != "coqCmt/IZ4E3CzPvMY8zTjQVL5hYJUiBRg8UU+iCWo0=" {
+		t.Errorf("Unexpected signature %s", signature)
+	}
+
+	if _, err := CalculateNotificationHMAC(item, "not-hex"); err == nil {
+		t.Error("Expected error for invalid HMAC key, got nil")
+	}
+}
+
+func TestVerifyNotificationHMAC(t *testing.T) {
+	item := NotificationRequestItem{
+		PSPReference:        "PSP-123",
+		MerchantAccountCode: "TestMerchant",
+		MerchantReference:   "ORDER-123",
+		Amount:              Amount{Currency: "USD", Value: 100},
+		EventCode:           EventCodeAuthorisation,
+		Success:             "true",
+	}
+	signed := signedNotification(t, item).NotificationItems[0].NotificationRequestItem
+
+	if err := VerifyNotificationHMAC(&signed, testHMACKey); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+
+	tampered := signed
+	tampered.Amount.Value = 1
+	if err := VerifyNotificationHMAC(&tampered, testHMACKey); !errors.Is(err, ErrInvalidHMACSignature) {
+		t.Errorf("Expected ErrInvalidHMACSignature for tampered item, got %v", err)
+	}
+
+	if err := VerifyNotificationHMAC(&item, testHMACKey); !errors.Is(err, ErrInvalidHMACSignature) {
+		t.Errorf("Expected ErrInvalidHMACSignature for missing signature, got %v", err)
+	}
+}
+
+func TestWebhookService_HandleNotifications(t *testing.T) {
+	tests := []struct {
+		name           string
+		eventCode      string
+		success        string
+		merchant       string
+		currentStatus  models.OrderStatus
+		orderError     error
+		updateError    error
+		unsigned       bool
+		wantErr        bool
+		expectedUpdate string
+	}{
+		{
+			name:           "successful authorisation authorizes order",
+			eventCode:      EventCodeAuthorisation,
+			success:        "true",
+			currentStatus:  models.OrderStatusPending,
+			expectedUpdate: string(models.OrderStatusAuthorized),
+		},
+		{
+			name:           "failed authorisation fails order",
+			eventCode:      EventCodeAuthorisation,
+			success:        "false",
+			currentStatus:  models.OrderStatusPending,
+			expectedUpdate: string(models.OrderStatusFailed),
+		},
+		{
+			name:           "authorisation settles processing order",
+			eventCode:      EventCodeAuthorisation,
+			success:        "true",
+			currentStatus:  models.OrderStatusProcessing,
+			expectedUpdate: string(models.OrderStatusAuthorized),
+		},
+		{
+			name:           "failed authorisation fails processing order",
+			eventCode:      EventCodeAuthorisation,
+			success:        "false",
+			currentStatus:  models.OrderStatusProcessing,
+			expectedUpdate: string(models.OrderStatusFailed),
+		},
+		{
+			name:           "successful cancellation cancels order",
+			eventCode:      EventCodeCancellation,
+			success:        "true",
+			currentStatus:  models.OrderStatusPending,
+			expectedUpdate: string(models.OrderStatusCancelled),
+		},
+		{
+			name:           "successful cancellation confirms the requested cancellation",
+			eventCode:      EventCodeCancellation,
+			success:        "true",
+			currentStatus:  models.OrderStatusCancelRequested,
+			expectedUpdate: string(models.OrderStatusCancelled),
+		},
+		{
+			name:           "successful capture captures order",
+			eventCode:      EventCodeCapture,
+			success:        "true",
+			currentStatus:  models.OrderStatusAuthorized,
+			expectedUpdate: string(models.OrderStatusCaptured),
+		},
+		{
+			name:           "successful capture confirms the requested capture",
+			eventCode:      EventCodeCapture,
+			success:        "true",
+			currentStatus:  models.OrderStatusCaptureRequested,
+			expectedUpdate: string(models.OrderStatusCaptured),
+		},
+		{
+			name:          "redelivered authorisation is ignored",
+			eventCode:     EventCodeAuthorisation,
+			success:       "true",
+			currentStatus: models.OrderStatusAuthorized,
+		},
+		{
+			name:          "unhandled event code is accepted",
+			eventCode:     "REPORT_AVAILABLE",
+			success:       "true",
+			currentStatus: models.OrderStatusPending,
+		},
+		{
+			name:          "invalid signature is skipped",
+			eventCode:     EventCodeAuthorisation,
+			success:       "true",
+			currentStatus: models.OrderStatusPending,
+			unsigned:      true,
+		},
+		{
+			name:          "unexpected merchant account is skipped",
+			eventCode:     EventCodeAuthorisation,
+			success:       "true",
+			merchant:      "OtherMerchant",
+			currentStatus: models.OrderStatusPending,
+		},
+		{
+			name:          "unknown order is ignored",
+			eventCode:     EventCodeAuthorisation,
+			success:       "true",
+			currentStatus: models.OrderStatusPending,
+			orderError:    models.ErrOrderNotFound,
+		},
+		{
+			name:           "invalid transition is ignored",
+			eventCode:      EventCodeAuthorisation,
+			success:        "false",
+			currentStatus:  models.OrderStatusAuthorized,
+			updateError:    models.ErrInvalidStatusTransition,
+			expectedUpdate: string(models.OrderStatusFailed),
+		},
+		{
+			name:           "storage error requests redelivery",
+			eventCode:      EventCodeAuthorisation,
+			success:        "true",
+			currentStatus:  models.OrderStatusPending,
+			updateError:    errors.New("database error"),
+			wantErr:        true,
+			expectedUpdate: string(models.OrderStatusAuthorized),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updatedStatus string
+			mockOrder := &MockOrderService{
+				GetOrderByReferenceFunc: func(reference string) (*models.Order, error) {
+					if tt.orderError != nil {
+						return nil, tt.orderError
+					}
+					return &models.Order{Reference: reference, Amount: 100, Status: tt.currentStatus}, nil
+				},
+				UpdateOrderStatusFunc: func(reference, status, pspReference string, change models.OrderChange) error {
+					updatedStatus = status
+					if pspReference != "PSP-123" {
+						t.Errorf("Expected PSP reference 'PSP-123', got '%s'", pspReference)
+					}
+					// The notification is kept in the order history
+					if change.Source != models.OrderEventSourceWebhook || !strings.Contains(string(change.Payload), `"eventCode":"`+tt.eventCode+`"`) {
+						t.Errorf("Expected the webhook change with the notification, got %+v", change)
+					}
+					return tt.updateError
+				},
+			}
+
+			merchant := tt.merchant
+			if merchant == "" {
+				merchant = "TestMerchant"
+			}
+			item := NotificationRequestItem{
+				PSPReference:        "PSP-123",
+				MerchantAccountCode: merchant,
+				MerchantReference:   "ORDER-123",
+				Amount:              Amount{Currency: "USD", Value: 100},
+				EventCode:           tt.eventCode,
+				Success:             tt.success,
+			}
+			req := signedNotification(t, item)
+			if tt.unsigned {
+				req.NotificationItems[0].NotificationRequestItem.AdditionalData = nil
+			}
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", HMACKey: testHMACKey}
+			service := NewWebhookService(mockOrder, cfg)
+			err := service.HandleNotifications(context.Background(), req)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HandleNotifications() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if updatedStatus != tt.expectedUpdate {
+				t.Errorf("Expected status update '%s', got '%s'", tt.expectedUpdate, updatedStatus)
+			}
+		})
+	}
+}
+
+func TestWebhookService_HandleNotifications_Refund(t *testing.T) {
+	tests := []struct {
+		name        string
+		success     string
+		recordError error
+		expected    models.Refund
+		wantErr     bool
+	}{
+		{
+			name:     "successful refund is recorded",
+			success:  "true",
+			expected: models.Refund{PSPReference: "REFUND-PSP-1", Amount: 40, Success: true},
+		},
+		{
+			name:     "failed refund is recorded",
+			success:  "false",
+			expected: models.Refund{PSPReference: "REFUND-PSP-1", Amount: 40},
+		},
+		{
+			name:        "refund beyond the order amount is ignored",
+			success:     "true",
+			recordError: models.ErrInvalidRefundAmount,
+			expected:    models.Refund{PSPReference: "REFUND-PSP-1", Amount: 40, Success: true},
+		},
+		{
+			name:        "store failure is redelivered",
+			success:     "true",
+			recordError: errors.New("database error"),
+			expected:    models.Refund{PSPReference: "REFUND-PSP-1", Amount: 40, Success: true},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded models.Refund
+			mockOrder := &MockOrderService{
+				RecordRefundFunc: func(reference string, refund models.Refund, change models.OrderChange) error {
+					recorded = refund
+					if reference != "ORDER-123" {
+						t.Errorf("Expected order ORDER-123, got %s", reference)
+					}
+					if change.Source != models.OrderEventSourceWebhook || !strings.Contains(string(change.Payload), `"eventCode":"REFUND"`) {
+						t.Errorf("Expected the webhook change with the notification, got %+v", change)
+					}
+					return tt.recordError
+				},
+			}
+
+			req := signedNotification(t, NotificationRequestItem{
+				PSPReference:        "REFUND-PSP-1",
+				OriginalReference:   "PSP-123",
+				MerchantAccountCode: "TestMerchant",
+				MerchantReference:   "ORDER-123",
+				Amount:              Amount{Currency: "USD", Value: 40},
+				EventCode:           EventCodeRefund,
+				Success:             tt.success,
+			})
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", HMACKey: testHMACKey}
+			err := NewWebhookService(mockOrder, cfg).HandleNotifications(context.Background(), req)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HandleNotifications() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if recorded != tt.expected {
+				t.Errorf("Expected refund %+v, got %+v", tt.expected, recorded)
+			}
+		})
+	}
+}
+
+func TestWebhookService_HandleNotifications_RetryAfterRefusal(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewMemoryOrderRepository()
+	order := storeOrder(t, orderRepo, models.OrderStatusPending)
+
+	cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", HMACKey: testHMACKey}
+	service := NewWebhookService(NewOrderService(orderRepo, NewOrderEventBus()), cfg)
+
+	// The shopper's card is refused, they try again in the same Drop-in and the payment goes
+	// through, after which Adyen redelivers the refusal
+	steps := []struct {
+		pspReference   string
+		success        string
+		expectedStatus models.OrderStatus
+	}{
+		{"PSP-REFUSED", "false", models.OrderStatusFailed},
+		{"PSP-AUTHORISED", "true", models.OrderStatusAuthorized},
+		{"PSP-REFUSED", "false", models.OrderStatusAuthorized},
+	}
+	for _, step := range steps {
+		req := signedNotification(t, NotificationRequestItem{
+			PSPReference:        step.pspReference,
+			MerchantAccountCode: "TestMerchant",
+			MerchantReference:   order.Reference,
+			Amount:              Amount{Currency: "USD", Value: 1000},
+			EventCode:           EventCodeAuthorisation,
+			Success:             step.success,
+		})
+		if err := service.HandleNotifications(ctx, req); err != nil {
+			t.Fatalf("HandleNotifications() unexpected error = %v", err)
+		}
+
+		stored, _ := orderRepo.GetOrderByReference(ctx, order.Reference)
+		if stored.Status != step.expectedStatus {
+			t.Fatalf("After %s (success=%s) expected order to be %s, got %s", step.pspReference, step.success, step.expectedStatus, stored.Status)
+		}
+	}
+
+	stored, _ := orderRepo.GetOrderByReference(ctx, order.Reference)
+	if stored.PSPReference != "PSP-AUTHORISED" {
+		t.Errorf("Expected the authorised payment's PSP reference, got %s", stored.PSPReference)
+	}
+}
+
+func TestWebhookService_HandleNotifications_AuthorisationOfCancelledOrder(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewMemoryOrderRepository()
+	order := storeOrder(t, orderRepo, models.OrderStatusCancelled)
+
+	cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", HMACKey: testHMACKey}
+	service := NewWebhookService(NewOrderService(orderRepo, NewOrderEventBus()), cfg)
+	req := signedNotification(t, NotificationRequestItem{
+		PSPReference:        "PSP-123",
+		MerchantAccountCode: "TestMerchant",
+		MerchantReference:   order.Reference,
+		Amount:              Amount{Currency: "USD", Value: 1000},
+		EventCode:           EventCodeAuthorisation,
+		Success:             "true",
+	})
+
+	unrecordedBefore := unrecordedPayments.Value()
+	if err := service.HandleNotifications(ctx, req); err != nil {
+		t.Fatalf("HandleNotifications() unexpected error = %v", err)
+	}
+
+	if unrecordedPayments.Value() != unrecordedBefore+1 {
+		t.Error("Expected the payment to be reported for reconciliation")
+	}
+	if stored, _ := orderRepo.GetOrderByReference(ctx, order.Reference); !stored.IsCancelled() {
+		t.Errorf("Expected order to stay cancelled, got %s", stored.Status)
+	}
+}
+
+func TestWebhookService_HandleNotifications_RefusedModification(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventCode string
+		requested models.OrderStatus
+	}{
+		{"refused cancellation", EventCodeCancellation, models.OrderStatusCancelRequested},
+		{"refused capture", EventCodeCapture, models.OrderStatusCaptureRequested},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			orderRepo := repository.NewMemoryOrderRepository()
+			orderService := NewOrderService(orderRepo, NewOrderEventBus())
+			order := storeOrder(t, orderRepo, models.OrderStatusAuthorized)
+			if err := orderService.UpdateOrderStatus(ctx, order.Reference, string(tt.requested), "PSP-123", models.OrderChange{}); err != nil {
+				t.Fatalf("Failed to request the modification: %v", err)
+			}
+
+			cfg := &config.AdyenConfig{MerchantAccount: "TestMerchant", HMACKey: testHMACKey}
+			service := NewWebhookService(orderService, cfg)
+			req := signedNotification(t, NotificationRequestItem{
+				PSPReference:        "MODIFICATION-PSP-1",
+				OriginalReference:   "PSP-123",
+				MerchantAccountCode: "TestMerchant",
+				MerchantReference:   order.Reference,
+				Amount:              Amount{Currency: "USD", Value: 1000},
+				EventCode:           tt.eventCode,
+				Success:             "false",
+			})
+
+			// The refusal is delivered twice, the second delivery no longer applies
+			for delivery := 1; delivery <= 2; delivery++ {
+				if err := service.HandleNotifications(ctx, req); err != nil {
+					t.Fatalf("HandleNotifications() delivery %d unexpected error = %v", delivery, err)
+				}
+				if stored, _ := orderRepo.GetOrderByReference(ctx, order.Reference); !stored.IsAuthorized() {
+					t.Fatalf("Expected order to be authorized again after delivery %d, got %s", delivery, stored.Status)
+				}
+			}
+
+			history, _ := orderRepo.GetOrderHistory(ctx, order.Reference)
+			if len(history) != 2 || history[1].PreviousStatus != tt.requested {
+				t.Errorf("Expected the request and its refusal in the history, got %+v", history)
+			}
+		})
+	}
+}
+
+func TestWebhookService_HandleNotifications_MissingHMACKey(t *testing.T) {
+	service := NewWebhookService(&MockOrderService{}, &config.AdyenConfig{MerchantAccount: "TestMerchant"})
+
+	err := service.HandleNotifications(context.Background(), &NotificationRequest{})
+	if !errors.Is(err, ErrHMACKeyNotConfigured) {
+		t.Errorf("Expected ErrHMACKeyNotConfigured, got %v", err)
+	}
+}
@@ -1,5 +1,5 @@
 // Checkout page JavaScript
-// Note: clientKey must be set before this script runs
+// Note: clientKey, the environment and the Drop-in settings must be set before this script runs
 
 async function initializeCheckout() {
     try {
@@ -12,7 +12,9 @@ async function initializeCheckout() {
         });
 
         if (!response.ok) {
-            throw new Error('Failed to create payment session');
+            // The server explains what went wrong in a message that is safe to show
+            const errorBody = await response.json().catch(() => ({}));
+            throw new Error(errorBody.message || 'Failed to create payment session');
         }
 
         const sessionData = await response.json();
@@ -23,14 +25,15 @@ async function initializeCheckout() {
         // Initialize Adyen Drop-in
         const configuration = {
             clientKey: window.ADYEN_CLIENT_KEY,
-            environment: 'test',
+            environment: window.ADYEN_ENVIRONMENT,
             session: {
                 id: sessionData.sessionId,
                 sessionData: sessionData.sessionData
             },
             onPaymentCompleted: (result, component) => {
                 // Handle the result - with Sessions API we need to manually redirect
-                if (result.resultCode === 'Authorised' || result.resultCode === 'Pending') {
+                // Pending and Received payments are settled by a webhook while the processing page polls the order status
+                if (result.resultCode === 'Authorised' || result.resultCode === 'Pending' || result.resultCode === 'Received') {
                     // Redirect with sessionResult parameter (if available)
                     if (result.sessionResult) {
                         window.location.href = '/order/confirmation?sessionId=' + sessionData.sessionId + '&sessionResult=' + encodeURIComponent(result.sessionResult);
@@ -49,16 +52,11 @@ async function initializeCheckout() {
             onAdditionalDetails: (state, component) => {
                 // Handle additional details if needed
             },
-            paymentMethodsConfiguration: {
-                card: {
-                    hasHolderName: true,
-                    holderNameRequired: true,
-                    billingAddressRequired: false
-                }
-            },
             analytics: {
                 enabled: true
-            }
+            },
+            // The payment methods shown and their settings match the ones the session allows
+            ...window.ADYEN_DROPIN_SETTINGS
         };
 
         const checkout = await AdyenCheckout(configuration);
@@ -0,0 +1,30 @@
+// Confirmation page JavaScript
+// Note: ORDER_REFERENCE and ORDER_POLL_SECONDS must be set and order-status.js loaded before this script runs
+
+// Badge text and style for each order status
+const STATUS_BADGES = {
+    pending: ['Pending', 'status-processing'],
+    processing: ['Processing', 'status-processing'],
+    authorized: ['Authorized', 'status-authorized'],
+    capture_requested: ['Capture Requested', 'status-authorized'],
+    captured: ['Captured', 'status-authorized'],
+    refund_requested: ['Refund Requested', 'status-processing'],
+    partially_refunded: ['Partially Refunded', 'status-inactive'],
+    refunded: ['Refunded', 'status-inactive'],
+    cancel_requested: ['Cancellation Requested', 'status-processing'],
+    cancelled: ['Cancelled', 'status-inactive'],
+    failed: ['Failed', 'status-inactive'],
+    expired: ['Expired', 'status-inactive'],
+};
+
+// Keep the payment status up to date, e.g. when the order is captured or refunded
+document.addEventListener('DOMContentLoaded', () => {
+    const badge = document.getElementById('order-status');
+
+    watchOrderStatus(window.ORDER_REFERENCE, window.ORDER_POLL_SECONDS, (order) => {
+        const [label, style] = STATUS_BADGES[order.status] || [order.status, 'status-inactive'];
+        badge.textContent = label;
+        badge.className = 'status-badge ' + style;
+        return true;
+    });
+});
@@ -0,0 +1,55 @@
+// Order status updates shared by the confirmation and processing pages.
+// Listens to the order's event stream and falls back to polling the status API
+// if the browser cannot keep a stream open.
+
+async function fetchOrderStatus(reference) {
+    const response = await fetch('/api/orders/' + encodeURIComponent(reference) + '/status', {
+        headers: {
+            'Accept': 'application/json',
+        },
+        cache: 'no-store'
+    });
+
+    if (!response.ok) {
+        throw new Error('Failed to load order status: ' + response.status);
+    }
+
+    return response.json();
+}
+
+function pollOrderStatus(reference, pollSeconds, onStatus) {
+    const poll = async () => {
+        try {
+            if (onStatus(await fetchOrderStatus(reference)) === false) {
+                return;
+            }
+        } catch (error) {
+            // Keep polling, the next attempt may succeed
+            console.error('Error checking order status:', error);
+        }
+        setTimeout(poll, pollSeconds * 1000);
+    };
+
+    setTimeout(poll, pollSeconds * 1000);
+}
+
+// watchOrderStatus calls onStatus with every status of the order until it returns false
+function watchOrderStatus(reference, pollSeconds, onStatus) {
+    if (!window.EventSource) {
+        pollOrderStatus(reference, pollSeconds, onStatus);
+        return;
+    }
+
+    const source = new EventSource('/api/orders/' + encodeURIComponent(reference) + '/events');
+    source.addEventListener('status', (event) => {
+        if (onStatus(JSON.parse(event.data)) === false) {
+            source.close();
+        }
+    });
+    source.onerror = () => {
+        // The browser reconnects by itself unless the stream was refused
+        if (source.readyState === EventSource.CLOSED) {
+            pollOrderStatus(reference, pollSeconds, onStatus);
+        }
+    };
+}
@@ -0,0 +1,17 @@
+// Processing page JavaScript
+// Note: ORDER_REFERENCE and ORDER_POLL_SECONDS must be set and order-status.js loaded before this script runs
+
+// Statuses that mean the payment outcome is not known yet
+const UNRESOLVED_STATUSES = ['pending', 'processing'];
+
+// Reload once the payment is settled, the server then renders the confirmation
+// or redirects to the failure page
+document.addEventListener('DOMContentLoaded', () => {
+    watchOrderStatus(window.ORDER_REFERENCE, window.ORDER_POLL_SECONDS, (order) => {
+        if (UNRESOLVED_STATUSES.includes(order.status)) {
+            return true;
+        }
+        window.location.reload();
+        return false;
+    });
+});